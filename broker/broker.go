@@ -1,6 +1,7 @@
 package broker
 
 import (
+	"blockEmulator/core"
 	"blockEmulator/message"
 	"blockEmulator/params"
 	"bufio"
@@ -41,7 +42,7 @@ func (b *Broker) initBrokerAddr(num int) []string {
 	fileScanner := bufio.NewScanner(readFile)
 	fileScanner.Split(bufio.ScanLines)
 	for fileScanner.Scan() {
-		brokerAddress = append(brokerAddress, fileScanner.Text())
+		brokerAddress = append(brokerAddress, core.WorkloadAddress(fileScanner.Text())) //开启交易签名时与数据集中的地址一样被替换
 		num--
 		if num == 0 {
 			break
@@ -3,9 +3,12 @@ package build
 //三个函数分别用于创建和配置主管节点、创建和配置新的PBFT节点以及初始化和配置params.ChainConfig结构
 import (
 	"blockEmulator/consensus_shard/pbft_all"
+	"blockEmulator/networks"
 	"blockEmulator/params"
 	"blockEmulator/supervisor"
+	"log"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -42,23 +45,141 @@ func initConfig(nid, nnm, sid, snm uint64) *params.ChainConfig { //函数initCon
 	return pcc
 }
 
+// 根据配置初始化网络仿真，localAddr 为本机地址，没有配置时不模拟网络
+func initNetworkEmulator(localAddr string) {
+	tp := &networks.Topology{
+		Default: networks.LinkProfile{
+			Latency:   params.NetLatency,
+			Jitter:    params.NetJitter,
+			Dist:      params.NetDelayDist,
+			Bandwidth: params.NetBandwidth,
+			LossRate:  params.NetLossRate,
+		},
+	}
+	if params.NetTopology_path != "" {
+		var err error
+		if tp, err = networks.LoadTopology(params.NetTopology_path); err != nil {
+			log.Panic(err)
+		}
+	} else if tp.Default == (networks.LinkProfile{Dist: params.NetDelayDist}) {
+		return
+	}
+	ne := networks.NewNetworkEmulator(tp.Default)
+	for sid, nodes := range params.IPmap_nodeTable { //每个分片是一个区域，除非拓扑文件把它放到了别的区域中
+		key := strconv.FormatUint(sid, 10)
+		if sid == params.DeciderShard {
+			key = "supervisor"
+		}
+		region, ok := tp.Regions[key]
+		if !ok {
+			region = key
+		}
+		for _, ip := range nodes {
+			ne.SetRegion(ip, region)
+		}
+	}
+	for _, l := range tp.Links {
+		ne.SetLink(l.From, l.To, l.Profile)
+		if l.Both {
+			ne.SetLink(l.To, l.From, l.Profile)
+		}
+	}
+	networks.SetNetworkEmulator(ne, localAddr)
+}
+
 func BuildSupervisor(nnm, snm, mod uint64) { //函数BuildSupervisor负责创建和配置主管节点，参数分别代表节点总数、分片总数和委员会方法
+	lsn := newSupervisor(nnm, snm, mod)
+	initNetworkEmulator(params.SupervisorAddr)
+	runSupervisor(lsn, 10000*time.Millisecond)
+}
+
+func BuildNewPbftNode(nid, nnm, sid, snm, mod uint64, fault string) { //函数BuildNewPbftNode负责创建和配置新的PBFT节点，参数分别代表节点ID、节点总数、分片ID、分片总数、委员会方法和拜占庭行为（为空表示诚实节点）
+	worker := newPbftNode(nid, nnm, sid, snm, mod, fault)
+	initNetworkEmulator(params.IPmap_nodeTable[sid][nid])
+	runPbftNode(worker, nid)
+}
+
+// 单进程模式：主管节点和所有节点作为协程运行在同一个进程中，通过内存网络通信，实验结束后返回。
+// 每个分片中ID小于 faultyNum 的节点被注入拜占庭行为 fault
+func RunLocalCluster(nnm, snm, mod uint64, faultyNum int, fault string) {
+	networks.SetNetwork(networks.NewMemNetwork())
+	defer networks.SetNetwork(networks.TCPNetwork)
+	params.ResetShardLeaders() //同一个进程中可能运行过其他实验
+	if params.NetTopology_path != "" || params.NetLatency != 0 || params.NetBandwidth != 0 || params.NetLossRate != 0 {
+		log.Println("network emulation is not supported in the local mode, ignore it") //所有节点共用一个传输，无法区分发送者
+	}
+
+	lsn := newSupervisor(nnm, snm, mod)
+	workers := make([]*pbft_all.PbftConsensusNode, 0, nnm*snm) //先创建所有节点，再启动，避免 initConfig 与运行中的节点同时访问全局配置
+	for sid := uint64(0); sid < snm; sid++ {
+		for nid := uint64(0); nid < nnm; nid++ {
+			f := ""
+			if int(nid) < faultyNum {
+				f = fault
+			}
+			workers = append(workers, newPbftNode(nid, nnm, sid, snm, mod, f))
+		}
+	}
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(w *pbft_all.PbftConsensusNode) {
+			defer wg.Done()
+			runPbftNode(w, w.NodeID)
+		}(worker)
+	}
+
+	runSupervisor(lsn, time.Second) //所有节点已经在内存网络上监听，不需要等待太久
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select { //等待所有节点收到停止消息后退出
+	case <-done:
+	case <-time.After(10 * time.Second):
+		log.Println("some nodes do not stop in time")
+	}
+}
+
+func newSupervisor(nnm, snm, mod uint64) *supervisor.Supervisor { //创建主管节点
 	var measureMod []string
 	if mod == 0 || mod == 2 {
 		measureMod = params.MeasureBrokerMod
+	} else if params.CommitteeMethod[mod] == "2PC" {
+		measureMod = params.MeasureTwoPCMod
 	} else {
 		measureMod = params.MeasureRelayMod
 	}
+	// 地理位置感知的划分另外测量跨区域的交易比例
+	if params.CLPA_GeoPenalty > 0 {
+		measureMod = append(measureMod, params.MeasureGeoMod...)
+	}
+	measureMod = append(measureMod, params.MeasureFaultMod...) //拜占庭实验的测量方法，诚实运行时安全性违反次数为 0
+	if len(params.MeasureMods) > 0 {                           //实验配置文件中指定了测量方法时，只使用这些测量方法
+		measureMod = params.MeasureMods
+	}
 
 	lsn := new(supervisor.Supervisor)                                                                                    //创建一个指向supervisor.Supervisor结构的指针
 	lsn.NewSupervisor(params.SupervisorAddr, initConfig(123, nnm, 123, snm), params.CommitteeMethod[mod], measureMod...) //初始化主管节点
-	time.Sleep(10000 * time.Millisecond)
+	return lsn
+}
+
+func runSupervisor(lsn *supervisor.Supervisor, startDelay time.Duration) { //等待节点启动后开始发送交易，实验结束后返回
+	time.Sleep(startDelay)
 	go lsn.SupervisorTxHandling()
 	lsn.TcpListen()
 }
 
-func BuildNewPbftNode(nid, nnm, sid, snm, mod uint64) { //函数BuildNewPbftNode负责创建和配置新的PBFT节点，参数分别代表节点ID、节点总数、分片ID、分片总数和委员会方法
+func newPbftNode(nid, nnm, sid, snm, mod uint64, fault string) *pbft_all.PbftConsensusNode { //创建PBFT节点
 	worker := pbft_all.NewPbftNode(sid, nid, initConfig(nid, nnm, sid, snm), params.CommitteeMethod[mod])
+	worker.SetFaultBehavior(fault)
+	return worker
+}
+
+func runPbftNode(worker *pbft_all.PbftConsensusNode, nid uint64) { //运行PBFT节点，收到停止消息后返回
+	go worker.ViewChangeTimer() //所有节点都运行视图切换计时器，主节点失效时由新主节点接替
 	if nid == 0 {
 		go worker.Propose()
 		worker.TcpListen()
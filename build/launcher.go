@@ -0,0 +1,228 @@
+package build
+
+import (
+	"blockEmulator/params"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 跨平台的启动器：代替 .bat/.vbs 文件，在本机启动所有节点进程和主管节点进程，
+// 每个进程的标准输出和标准错误写入 log 目录，主管节点结束后收集测量结果，Ctrl-C 时结束所有进程
+
+const stopWait = 15 * time.Second //主管节点结束后等待其他节点退出的时间
+
+type launchedProc struct { //launchedProc结构表示一个被启动的进程
+	name string
+	cmd  *exec.Cmd
+	out  *os.File
+	done chan struct{} //进程退出后关闭
+	err  error         //进程的退出状态
+}
+
+// 启动所有节点和主管节点，等待实验结束。每个分片中ID小于 faultyNum 的节点被注入拜占庭行为 fault，
+// configPath 不为空时所有进程使用同一个实验配置文件
+func LaunchExperiment(nodenum, shardnum, modID, faultyNum int, fault, configPath string) error {
+	exe, err := os.Executable() //使用当前的可执行文件启动其他进程，go run 时为编译出的临时文件
+	if err != nil {
+		return err
+	}
+	outDir := filepath.Join(params.LogWrite_path, "launcher")
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return err
+	}
+	common := []string{"-N", strconv.Itoa(nodenum), "-S", strconv.Itoa(shardnum), "-m", strconv.Itoa(modID)}
+	if configPath != "" {
+		common = append(common, "-C", configPath)
+	}
+	nodeArgs := func(sid, nid int) []string {
+		args := append([]string{"-n", strconv.Itoa(nid), "-s", strconv.Itoa(sid)}, common...)
+		if nid < faultyNum && fault != "" {
+			args = append(args, "-f", fault)
+		}
+		return args
+	}
+
+	procs := make([]*launchedProc, 0, nodenum*shardnum+1)
+	var procLock sync.Mutex
+	killAll := func() {
+		procLock.Lock()
+		defer procLock.Unlock()
+		for _, lp := range procs {
+			lp.kill()
+		}
+	}
+	interrupt := make(chan os.Signal, 1) //Ctrl-C 时结束所有进程
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	start := func(name string, args []string) (*launchedProc, error) {
+		lp, err := startProc(exe, name, filepath.Join(outDir, name+".out"), args)
+		if err != nil {
+			return nil, err
+		}
+		procLock.Lock()
+		procs = append(procs, lp)
+		procLock.Unlock()
+		log.Printf("launcher: started %s (pid %d)\n", name, lp.cmd.Process.Pid)
+		return lp, nil
+	}
+
+	// 启动顺序与 .bat 文件相同：先启动从节点，然后是主管节点，最后是各分片的主节点
+	for nid := 1; nid < nodenum; nid++ {
+		for sid := 0; sid < shardnum; sid++ {
+			if _, err := start(fmt.Sprintf("S%dN%d", sid, nid), nodeArgs(sid, nid)); err != nil {
+				killAll()
+				return err
+			}
+		}
+	}
+	supervisorProc, err := start("Supervisor", append([]string{"-c"}, common...))
+	if err != nil {
+		killAll()
+		return err
+	}
+	for sid := 0; sid < shardnum; sid++ {
+		if _, err := start(fmt.Sprintf("S%dN0", sid), nodeArgs(sid, 0)); err != nil {
+			killAll()
+			return err
+		}
+	}
+
+	select {
+	case <-supervisorProc.done:
+	case sig := <-interrupt:
+		log.Printf("launcher: received %v, killing all processes\n", sig)
+		killAll()
+		return errors.New("the experiment is interrupted")
+	}
+	if supervisorProc.err != nil {
+		killAll()
+		return fmt.Errorf("the supervisor exited with an error: %v, see %s", supervisorProc.err, supervisorProc.out.Name())
+	}
+
+	// 主管节点已经向所有节点发送了停止消息，等待它们退出，超时未退出的进程被结束
+	deadline := time.After(stopWait)
+	for _, lp := range procs {
+		select {
+		case <-lp.done:
+		case <-deadline:
+			log.Printf("launcher: %s does not exit in time, kill it\n", lp.name)
+			lp.kill()
+		case sig := <-interrupt:
+			log.Printf("launcher: received %v, killing all processes\n", sig)
+			killAll()
+			return errors.New("the experiment is interrupted")
+		}
+	}
+	killAll()
+
+	dst, err := collectResult()
+	if err != nil {
+		return err
+	}
+	log.Printf("launcher: the experiment is finished, the results are collected in %s\n", dst)
+	return nil
+}
+
+// 启动一个进程，标准输出和标准错误写入 outPath
+func startProc(exe, name, outPath string, args []string) (*launchedProc, error) {
+	out, err := os.Create(outPath)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	lp := &launchedProc{
+		name: name,
+		cmd:  cmd,
+		out:  out,
+		done: make(chan struct{}),
+	}
+	go func() {
+		lp.err = cmd.Wait()
+		out.Close()
+		close(lp.done)
+	}()
+	return lp, nil
+}
+
+// 结束进程并等待其退出
+func (lp *launchedProc) kill() {
+	select {
+	case <-lp.done:
+		return
+	default:
+	}
+	lp.cmd.Process.Kill()
+	<-lp.done
+}
+
+// 把本次实验的测量结果和进程输出复制到 result 目录下以时间命名的文件夹中
+func collectResult() (string, error) {
+	dst := filepath.Join(params.DataWrite_path, "launch_"+time.Now().Format("20060102_150405"))
+	dirs := map[string]string{
+		filepath.Join(params.DataWrite_path, "supervisor_measureOutput"): filepath.Join(dst, "supervisor_measureOutput"),
+		filepath.Join(params.LogWrite_path, "launcher"):                  filepath.Join(dst, "output"),
+	}
+	for src, to := range dirs {
+		if err := copyDir(src, to); err != nil {
+			return "", err
+		}
+	}
+	cfgName := "effective_config.json" //主管节点写入的实际使用的配置
+	if _, err := os.Stat(filepath.Join(params.DataWrite_path, cfgName)); err == nil {
+		if err := copyFile(filepath.Join(params.DataWrite_path, cfgName), filepath.Join(dst, cfgName)); err != nil {
+			return "", err
+		}
+	}
+	return dst, nil
+}
+
+func copyDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if err := copyFile(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
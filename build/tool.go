@@ -22,23 +22,33 @@ func getABpath() string { //getABpath()函数检索包含调用它的Go源文件
 	return abPath
 }
 
-func GenerateBatFile(nodenum, shardnum, modID int) { //该函数生成一个批处理文件（batrun_showAll.bat），用于启动所有节点
+func GenerateBatFile(nodenum, shardnum, modID, faultyNum int, fault, configPath string) { //该函数生成一个批处理文件（batrun_showAll.bat），用于启动所有节点，每个分片中ID小于 faultyNum 的节点被注入拜占庭行为 fault，configPath 不为空时所有进程使用该实验配置文件
 	ofile, err := os.OpenFile("batrun_showAll.bat", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
 	if err != nil {
 		log.Panic(err)
 	}
 	defer ofile.Close()
+	faultFlag := func(nid int) string {
+		if nid < faultyNum && fault != "" {
+			return " -f " + fault
+		}
+		return ""
+	}
+	configFlag := ""
+	if configPath != "" {
+		configFlag = " -C " + configPath
+	}
 	for i := 1; i < nodenum; i++ {
 		for j := 0; j < shardnum; j++ {
-			str := fmt.Sprintf("start cmd /k go run main.go -n %d -N %d -s %d -S %d -m %d \n\n", i, nodenum, j, shardnum, modID)
+			str := fmt.Sprintf("start cmd /k go run main.go -n %d -N %d -s %d -S %d -m %d%s%s \n\n", i, nodenum, j, shardnum, modID, faultFlag(i), configFlag)
 			ofile.WriteString(str)
 		}
 	}
-	str := fmt.Sprintf("start cmd /k go run main.go -c -N %d -S %d -m %d \n\n", nodenum, shardnum, modID)
+	str := fmt.Sprintf("start cmd /k go run main.go -c -N %d -S %d -m %d%s \n\n", nodenum, shardnum, modID, configFlag)
 
 	ofile.WriteString(str)
 	for j := 0; j < shardnum; j++ {
-		str := fmt.Sprintf("start cmd /k go run main.go -n 0 -N %d -s %d -S %d -m %d \n\n", nodenum, j, shardnum, modID)
+		str := fmt.Sprintf("start cmd /k go run main.go -n 0 -N %d -s %d -S %d -m %d%s%s \n\n", nodenum, j, shardnum, modID, faultFlag(0), configFlag)
 		ofile.WriteString(str)
 	}
 }
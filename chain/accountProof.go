@@ -0,0 +1,49 @@
+package chain
+
+import (
+	"blockEmulator/core"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var ErrAccountProof = errors.New("the merkle proof does not prove the account against the state root")
+
+// 在状态树根为 stateRoot 的状态树中生成账户 addr 的默克尔证明，返回账户状态和证明。
+// 账户不在状态树中时（没有参与过本分片的交易，余额为初始余额）账户状态为空，证明用于证明账户不存在
+func ProveAccount(triedb *trie.Database, stateRoot []byte, addr string) (*core.AccountState, [][]byte, error) {
+	st, err := trie.New(trie.TrieID(common.BytesToHash(stateRoot)), triedb)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc, err := st.Get([]byte(addr))
+	if err != nil {
+		return nil, nil, err
+	}
+	proof := make(merkleProof, 0)
+	if err := st.Prove([]byte(addr), 0, &proof); err != nil {
+		return nil, nil, err
+	}
+	if enc == nil {
+		return nil, proof, nil
+	}
+	return core.DecodeAS(enc), proof, nil
+}
+
+// 用默克尔证明验证状态树根为 stateRoot 的状态中账户 addr 的状态，证明账户不存在时返回空
+func VerifyAccountProof(stateRoot []byte, addr string, proof [][]byte) (*core.AccountState, error) {
+	val, err := verifyProof(stateRoot, []byte(addr), proof)
+	if err != nil {
+		return nil, ErrAccountProof
+	}
+	if val == nil {
+		return nil, nil
+	}
+	return core.DecodeAS(val), nil
+}
+
+// 在区块链的状态树中生成账户的默克尔证明，stateRoot 为某个区块头中的状态树根
+func (bc *BlockChain) ProveAccount(stateRoot []byte, addr string) (*core.AccountState, [][]byte, error) {
+	return ProveAccount(bc.triedb, stateRoot, addr)
+}
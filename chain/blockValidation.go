@@ -0,0 +1,115 @@
+// 区块验证：从节点在准备（prepare）之前检查主节点提议的区块，
+// 包括高度、父区块哈希、区块哈希、区块大小、重复交易、交易树根、交易签名（包括 2PC 决定的协调者签名）以及执行之后的状态树根。
+
+package chain
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"errors"
+	"fmt"
+)
+
+// 区块验证失败的原因，可以用 errors.Is 判断；交易签名不合法时原因是 core 中的交易签名错误
+var (
+	ErrBlockHeight   = errors.New("the block height is not correct")
+	ErrParentHash    = errors.New("the parent block hash is not equal to the current block hash")
+	ErrBlockHash     = errors.New("the block hash is not the hash of the block header")
+	ErrBlockTooLarge = errors.New("the block contains more transactions than the block size")
+	ErrDuplicateTx   = errors.New("the block contains duplicate transactions")
+	ErrTxRoot        = errors.New("the transaction root is wrong")
+	ErrStateRoot     = errors.New("the state root is wrong")
+)
+
+// 区块验证失败的错误
+type BlockValidationError struct {
+	Height uint64 // 区块高度
+	Reason error  // 失败的原因，上面的错误之一
+	Detail string // 详细信息
+}
+
+func (e *BlockValidationError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("invalid block %d: %v", e.Height, e.Reason)
+	}
+	return fmt.Sprintf("invalid block %d: %v, %s", e.Height, e.Reason, e.Detail)
+}
+
+func (e *BlockValidationError) Unwrap() error {
+	return e.Reason
+}
+
+func (bc *BlockChain) invalid(b *core.Block, reason error, detail string) error {
+	err := &BlockValidationError{Height: b.Header.Number, Reason: reason, Detail: detail}
+	fmt.Println(err)
+	return err
+}
+
+// 检查此区块链配置中的块是否有效，返回 *BlockValidationError
+func (bc *BlockChain) IsValidBlock(b *core.Block) error { //该函数用于检查此区块链配置中的块是否有效。它接受一个块作为参数。
+	if b.Header.Number != bc.CurrentBlock.Header.Number+1 {
+		return bc.invalid(b, ErrBlockHeight, fmt.Sprintf("want %d", bc.CurrentBlock.Header.Number+1))
+	}
+	if string(b.Header.ParentBlockHash) != string(bc.CurrentBlock.Hash) { //如果父块哈希不等于当前块哈希，则返回错误
+		return bc.invalid(b, ErrParentHash, "")
+	}
+	if string(b.Hash) != string(b.Header.Hash()) {
+		return bc.invalid(b, ErrBlockHash, "")
+	}
+	if uint64(len(b.Body)) > bc.ChainConfig.BlockSize {
+		return bc.invalid(b, ErrBlockTooLarge, fmt.Sprintf("%d > %d", len(b.Body), bc.ChainConfig.BlockSize))
+	}
+	seen := make(map[string]bool, len(b.Body))
+	for _, tx := range b.Body {
+		if seen[string(tx.TxHash)] {
+			return bc.invalid(b, ErrDuplicateTx, fmt.Sprintf("tx %x", tx.TxHash))
+		}
+		seen[string(tx.TxHash)] = true
+	}
+	if string(GetTxTreeRoot(b.Body)) != string(b.Header.TxRoot) {
+		return bc.invalid(b, ErrTxRoot, "")
+	}
+	for _, tx := range b.Body {
+		var err error
+		if tx.IsTwoPCDecision() { //2PC 的决定必须由协调者签名
+			err = tx.VerifyTwoPCDecision()
+		} else if params.SignTxs { //开启交易签名时，区块中的每笔交易都必须由其发送者签名
+			err = tx.VerifySignature()
+		}
+		if err != nil {
+			return bc.invalid(b, err, fmt.Sprintf("tx %x", tx.TxHash))
+		}
+	}
+	// 执行区块中的交易，执行之后的状态树根必须与区块头中的一致
+	rt, errs := bc.executeTxs(b.Body, b.Header.Miner)
+	if string(rt.Bytes()) != string(b.Header.StateRoot) {
+		return bc.invalid(b, ErrStateRoot, fmt.Sprintf("the state root after execution is %x, but the header says %x", rt.Bytes(), b.Header.StateRoot))
+	}
+	bc.saveExecResult(b, &execResult{root: rt.Bytes(), errs: errs})
+	return nil
+}
+
+// 区块的执行结果：执行之后的状态树根，以及每笔交易没有被执行的原因
+type execResult struct {
+	root []byte
+	errs []error
+}
+
+func (bc *BlockChain) saveExecResult(b *core.Block, res *execResult) {
+	bc.vlock.Lock()
+	bc.executed[string(b.Hash)] = res
+	bc.vlock.Unlock()
+}
+
+// 执行区块中的交易。区块在生成或验证时已经执行过的，直接使用当时的结果
+func (bc *BlockChain) executeBlock(b *core.Block) *execResult {
+	bc.vlock.Lock()
+	res, ok := bc.executed[string(b.Hash)]
+	bc.executed = make(map[string]*execResult) //只执行下一个高度的区块，添加区块之后其他记录都已经过期
+	bc.vlock.Unlock()
+	if ok {
+		return res
+	}
+	rt, errs := bc.executeTxs(b.Body, b.Header.Miner)
+	return &execResult{root: rt.Bytes(), errs: errs}
+}
@@ -31,19 +31,29 @@ type BlockChain struct { //该结构体用于表示和管理与区块链相关
 	Txpool       *core.TxPool        // 交易池，交易池是待处理交易在包含到块中之前存储的地方。它是一个优先级队列，其中包含待处理交易。交易池的大小是有限的，因此它可以存储有限数量的交易。
 	PartitionMap map[string]uint64   //这是一个包含由某种算法定义的分区图的映射。它用于协助区块链中的帐户分区。该map用于存储分区图。
 	pmlock       sync.RWMutex        // 该字段是一个互斥锁，用于访问时进行读写锁定。它确保对分区图的访问同步，以防止并发修改导致问题。
+
+	executed map[string]*execResult // 已经执行过的区块（主节点生成的区块或者通过验证的区块）的执行结果，添加区块时不需要再次执行
+	vlock    sync.Mutex             // 保护 executed
+
+	LightClient *LightClient // 其他分片已经提交的区块头
 }
 
 //LevelDB：LevelDB通常用于本地数据存储，特别是在需要轻量级嵌入式数据库的情况下。它不限于与 Go 一起使用，并且有多种语言的实现。
 //BoltDB：BoltDB 专为 Go 应用程序中的嵌入式使用而设计。它通常用于在 Go 程序中提供简单、高效和事务性的键值存储。BoltDB与Go生态的结合更加紧密。
 
 func GetTxTreeRoot(txs []*core.Transaction) []byte { //该函数用于获取交易树的根。它接受一个交易数组作为参数，并返回一个字节数组，该字节数组代表交易树的根。
+	return newTxTree(txs).Hash().Bytes()
+}
+
+// 构造交易树，键为交易哈希，值为编码后的交易
+func newTxTree(txs []*core.Transaction) *trie.Trie {
 	// use a memory trie database to do this, instead of disk database
 	triedb := trie.NewDatabase(rawdb.NewMemoryDatabase())
 	transactionTree := trie.NewEmpty(triedb)
 	for _, tx := range txs {
 		transactionTree.Update(tx.TxHash, tx.Encode())
 	}
-	return transactionTree.Hash().Bytes()
+	return transactionTree
 }
 
 // Write Partition Map
@@ -68,12 +78,25 @@ func (bc *BlockChain) SendTx2Pool(txs []*core.Transaction) { //该函数用于
 	bc.Txpool.AddTxs2Pool(txs)
 }
 
-// handle transactions and modify the status trie
-func (bc *BlockChain) GetUpdateStatusTrie(txs []*core.Transaction) common.Hash { //该函数用于处理交易并修改状态树。它接受一个交易数组作为参数，并返回一个common.Hash值。
+// 交易在区块中但没有被执行的原因，记录在交易收据中
+var (
+	ErrTxNonce             = errors.New("the nonce of the transaction is not the next nonce of the sender")
+	ErrInsufficientBalance = errors.New("the balance is less than the transfer amount")
+)
+
+// handle transactions and modify the status trie，收取手续费时区块中的手续费奖励给提出区块的节点 miner
+func (bc *BlockChain) GetUpdateStatusTrie(txs []*core.Transaction, miner uint64) common.Hash { //该函数用于处理交易并修改状态树。它接受一个交易数组作为参数，并返回一个common.Hash值。
+	rt, _ := bc.executeTxs(txs, miner)
+	return rt
+}
+
+// 执行交易并修改状态树，返回状态树根，以及每笔交易没有被执行的原因（nil 表示执行成功）
+func (bc *BlockChain) executeTxs(txs []*core.Transaction, miner uint64) (common.Hash, []error) {
 	fmt.Printf("The len of txs is %d\n", len(txs))
+	errs := make([]error, len(txs))
 	// 空块（txs 长度为 0）条件
 	if len(txs) == 0 {
-		return common.BytesToHash(bc.CurrentBlock.Header.StateRoot)
+		return common.BytesToHash(bc.CurrentBlock.Header.StateRoot), errs
 	}
 	// build trie from the triedb (in disk)
 	st, err := trie.New(trie.TrieID(common.BytesToHash(bc.CurrentBlock.Header.StateRoot)), bc.triedb)
@@ -81,11 +104,18 @@ func (bc *BlockChain) GetUpdateStatusTrie(txs []*core.Transaction) common.Hash {
 		log.Panic(err)
 	}
 	cnt := 0
-	//处理交易，这里忽略签名检查
+	fees := new(big.Int) //本区块收取的手续费
+	//处理交易，签名已经在交易池和区块验证中检查过
 	for i, tx := range txs { //遍历交易数组
 		// fmt.Printf("tx %d: %s, %s\n", i, tx.Sender, tx.Recipient)
+		if tx.TwoPCPhase != core.TwoPCNone { //2PC 交易按阶段单独执行
+			n, err := bc.executeTwoPC(st, tx, fees)
+			cnt += n
+			errs[i] = err
+			continue
+		}
 		// senderIn := false
-		if !tx.Relayed && (bc.Get_PartitionMap(tx.Sender) == bc.ChainConfig.ShardID || tx.HasBroker) { //如果交易未中继且发送者在本分片中，则执行以下操作
+		if bc.isSenderSide(tx) { //如果交易未中继且发送者在本分片中，则执行以下操作
 			// senderIn = true
 			// fmt.Printf("the sender %s is in this shard %d, \n", tx.Sender, bc.ChainConfig.ShardID)
 			// modify local accountstate
@@ -96,23 +126,56 @@ func (bc *BlockChain) GetUpdateStatusTrie(txs []*core.Transaction) common.Hash {
 				ib := new(big.Int)
 				ib.Add(ib, params.Init_Balance) //将初始余额添加到新状态中
 				s_state = &core.AccountState{
-					Nonce:   uint64(i),
+					Nonce:   0, //新账户的下一个 nonce 为 0
 					Balance: ib,
 				}
 			} else {
 				s_state = core.DecodeAS(s_state_enc) //如果状态存在，则使用core.DecodeAS()函数对其进行解码
 			}
-			s_balance := s_state.Balance       //获取发送者的余额
-			if s_balance.Cmp(tx.Value) == -1 { //如果余额小于交易金额，则打印错误消息并继续
+			if params.CheckNonce && !tx.IsBrokerSent() && tx.Nonce != s_state.Nonce { //nonce 必须等于账户的下一个 nonce，重放的交易和乱序的交易都不执行
+				fmt.Printf("the nonce of the tx is %d, but the next nonce of the sender is %d\n", tx.Nonce, s_state.Nonce)
+				errs[i] = ErrTxNonce
+				continue
+			}
+			fee := new(big.Int)
+			if params.TxFee && !tx.IsBrokerSent() { //broker 发出的交易不收取手续费
+				if tx.GasLimit < params.TxGas {
+					fmt.Printf("the gas limit %d is less than the gas of a transfer\n", tx.GasLimit)
+					errs[i] = core.ErrTxGasLimit
+				}
+				fee = tx.Fee()
+			}
+			cost := new(big.Int).Add(tx.Value, fee)
+			s_balance := s_state.Balance                     //获取发送者的余额
+			if errs[i] == nil && s_balance.Cmp(cost) == -1 { //如果余额小于交易金额加手续费，则打印错误消息并继续
 				fmt.Printf("the balance is less than the transfer amount\n")
+				errs[i] = ErrInsufficientBalance
+			}
+			if errs[i] == nil && (s_state.TwoPCLock != nil || bc.isRecipientSide(tx) && isTwoPCLocked(st, tx.Recipient)) {
+				errs[i] = ErrTwoPCLocked //被 2PC 锁定的账户不能被普通交易修改
+			}
+			if errs[i] != nil && (!params.CheckNonce || tx.IsBrokerSent()) { //不检查 nonce 时，执行失败的交易不改变状态
 				continue
 			}
-			s_state.Deduct(tx.Value)                       //否则，减少发送者的余额
+			if errs[i] == nil {
+				s_state.Deduct(cost) //否则，减少发送者的余额
+				fees.Add(fees, fee)
+			}
+			if !tx.IsBrokerSent() { //执行失败的交易同样使用了 nonce，否则发送者之后的交易会一直等待这个 nonce
+				s_state.Nonce++
+			}
 			st.Update([]byte(tx.Sender), s_state.Encode()) //更新状态树
 			cnt++
+			if errs[i] != nil {
+				continue
+			}
 		}
 		// recipientIn := false
-		if bc.Get_PartitionMap(tx.Recipient) == bc.ChainConfig.ShardID || tx.HasBroker { //如果接收者在本分片中，则执行以下操作
+		if bc.isRecipientSide(tx) { //如果接收者在本分片中，则执行以下操作
+			if !bc.isSenderSide(tx) && isTwoPCLocked(st, tx.Recipient) {
+				errs[i] = ErrTwoPCLocked
+				continue
+			}
 			// fmt.Printf("the recipient %s is in this shard %d, \n", tx.Recipient, bc.ChainConfig.ShardID)
 			// recipientIn = true
 			// modify local state
@@ -123,7 +186,7 @@ func (bc *BlockChain) GetUpdateStatusTrie(txs []*core.Transaction) common.Hash {
 				ib := new(big.Int)
 				ib.Add(ib, params.Init_Balance)
 				r_state = &core.AccountState{
-					Nonce:   uint64(i),
+					Nonce:   0,
 					Balance: ib,
 				}
 			} else {
@@ -139,9 +202,19 @@ func (bc *BlockChain) GetUpdateStatusTrie(txs []*core.Transaction) common.Hash {
 		// 	fmt.Printf("this transaciton is cross-shard txs, will be sent to relaypool later\n")
 		// }
 	}
+	if fees.Sign() > 0 { //手续费奖励给提出区块的节点
+		addr := core.MinerAddress(bc.ChainConfig.ShardID, miner)
+		m_state := &core.AccountState{Balance: new(big.Int).Set(params.Init_Balance)} //与其他新账户一样从初始余额开始
+		if m_state_enc, _ := st.Get([]byte(addr)); m_state_enc != nil {
+			m_state = core.DecodeAS(m_state_enc)
+		}
+		m_state.Deposit(fees)
+		st.Update([]byte(addr), m_state.Encode())
+		cnt++
+	}
 	// commit the memory trie to the database in the disk
 	if cnt == 0 {
-		return common.BytesToHash(bc.CurrentBlock.Header.StateRoot)
+		return common.BytesToHash(bc.CurrentBlock.Header.StateRoot), errs
 	}
 	rt, ns := st.Commit(false)
 	err = bc.triedb.Update(trie.NewWithNodeSet(ns))
@@ -153,26 +226,104 @@ func (bc *BlockChain) GetUpdateStatusTrie(txs []*core.Transaction) common.Hash {
 		log.Panic(err)
 	}
 	fmt.Println("modified account number is ", cnt)
-	return rt
+	return rt, errs
+}
+
+// 交易是否在本分片中执行发送者一侧（扣款并检查 nonce）
+func (bc *BlockChain) isSenderSide(tx *core.Transaction) bool {
+	return !tx.Relayed && (bc.Get_PartitionMap(tx.Sender) == bc.ChainConfig.ShardID || tx.HasBroker)
+}
+
+// 交易是否在本分片中执行接收者一侧（入账）
+func (bc *BlockChain) isRecipientSide(tx *core.Transaction) bool {
+	return bc.Get_PartitionMap(tx.Recipient) == bc.ChainConfig.ShardID || tx.HasBroker
+}
+
+// 检查打包的交易的 nonce：nonce 等于发送者下一个 nonce 的交易可以被执行，nonce 过小的交易（重放）被丢弃，
+// nonce 过大的交易放回交易池的队尾，等待前面的交易到达
+func (bc *BlockChain) checkTxNonces(txs []*core.Transaction) []*core.Transaction {
+	if !params.CheckNonce {
+		return txs
+	}
+	st, err := trie.New(trie.TrieID(common.BytesToHash(bc.CurrentBlock.Header.StateRoot)), bc.triedb)
+	if err != nil {
+		log.Panic(err)
+	}
+	next := make(map[string]uint64) //发送者的下一个 nonce，包括本区块中已经接受的交易
+	nextNonce := func(addr string) uint64 {
+		if n, ok := next[addr]; ok {
+			return n
+		}
+		if enc, _ := st.Get([]byte(addr)); enc != nil {
+			next[addr] = core.DecodeAS(enc).Nonce
+		} else {
+			next[addr] = 0
+		}
+		return next[addr]
+	}
+	valid := make([]*core.Transaction, 0, len(txs))
+	future := make([]*core.Transaction, 0)
+	replayed := 0
+	for _, tx := range txs {
+		if !bc.isSenderSide(tx) || tx.IsBrokerSent() || tx.IsTwoPCDecision() {
+			valid = append(valid, tx)
+			continue
+		}
+		switch n := nextNonce(tx.Sender); {
+		case tx.Nonce < n:
+			replayed++
+		case tx.Nonce > n:
+			future = append(future, tx)
+		default:
+			valid = append(valid, tx)
+			next[tx.Sender]++
+		}
+	}
+	for progress := true; progress && len(future) > 0; { //同一批中乱序到达的交易
+		progress = false
+		remain := future[:0]
+		for _, tx := range future {
+			if tx.Nonce == nextNonce(tx.Sender) {
+				valid = append(valid, tx)
+				next[tx.Sender]++
+				progress = true
+			} else if tx.Nonce > next[tx.Sender] {
+				remain = append(remain, tx)
+			} else {
+				replayed++
+			}
+		}
+		future = remain
+	}
+	if len(future) > 0 {
+		bc.Txpool.RequeueTxs(future)
+	}
+	if replayed > 0 || len(future) > 0 {
+		fmt.Printf("%d txs are dropped because of the used nonces, %d txs wait for the previous nonces\n", replayed, len(future))
+	}
+	return valid
 }
 
 // generate (mine) a block, this function return a block
 func (bc *BlockChain) GenerateBlock() *core.Block { //该函数用于生成（挖掘）一个块。它返回一个块。
 	// pack the transactions from the txpool
 	txs := bc.Txpool.PackTxs(bc.ChainConfig.BlockSize) //从交易池中获取交易
+	txs = bc.deferTwoPCLockedTxs(txs)                  //涉及被 2PC 锁定的账户的交易等待解锁
+	txs = bc.checkTxNonces(txs)                        //检查交易的 nonce
 	bh := &core.BlockHeader{
 		ParentBlockHash: bc.CurrentBlock.Hash,
 		Number:          bc.CurrentBlock.Header.Number + 1,
 		Time:            time.Now(),
 	}
 	// handle transactions to build root
-	rt := bc.GetUpdateStatusTrie(txs) //处理交易以构建状态树
+	rt, errs := bc.executeTxs(txs, bc.ChainConfig.NodeID) //处理交易以构建状态树
 
 	bh.StateRoot = rt.Bytes()
 	bh.TxRoot = GetTxTreeRoot(txs)
 	b := core.NewBlock(bh, txs)
-	b.Header.Miner = 0
+	b.Header.Miner = bc.ChainConfig.NodeID //主节点可能因为视图切换而改变，其他节点根据 Miner 判断是否需要执行区块
 	b.Hash = b.Header.Hash()
+	bc.saveExecResult(b, &execResult{root: bh.StateRoot, errs: errs})
 	return b
 }
 
@@ -211,18 +362,23 @@ func (bc *BlockChain) AddGenisisBlock(gb *core.Block) { //该函数用于添加
 }
 
 // add a block
-func (bc *BlockChain) AddBlock(b *core.Block) { //该函数用于添加一个块到区块链。它接受一个块作为参数，并将其添加到区块链中。
+func (bc *BlockChain) AddBlock(b *core.Block) error { //该函数用于添加一个块到区块链。它接受一个块作为参数，并将其添加到区块链中。执行之后的状态树根与区块头中的不一致时，区块不会被添加
 	if b.Header.Number != bc.CurrentBlock.Header.Number+1 {
 		fmt.Println("the block height is not correct")
-		return
+		return bc.invalid(b, ErrBlockHeight, fmt.Sprintf("want %d", bc.CurrentBlock.Header.Number+1))
 	}
-	// 如果该区块被节点挖出，则无需再次处理交易
+	// 如果该区块被节点挖出或者已经通过验证，则无需再次处理交易
+	res := bc.executeBlock(b)
 	if b.Header.Miner != bc.ChainConfig.NodeID {
-		rt := bc.GetUpdateStatusTrie(b.Body)
-		fmt.Println(bc.CurrentBlock.Header.Number+1, "the root = ", rt.Bytes())
+		fmt.Println(bc.CurrentBlock.Header.Number+1, "the root = ", res.root)
+		if string(res.root) != string(b.Header.StateRoot) {
+			return bc.invalid(b, ErrStateRoot, fmt.Sprintf("the state root after execution is %x, but the header says %x", res.root, b.Header.StateRoot))
+		}
 	}
 	bc.CurrentBlock = b
 	bc.Storage.AddBlock(b)
+	bc.Storage.AddReceipts(b, bc.makeReceipts(b, res.errs))
+	return nil
 }
 
 // 新的区块链。
@@ -235,7 +391,10 @@ func NewBlockChain(cc *params.ChainConfig, db ethdb.Database) (*BlockChain, erro
 		Txpool:       core.NewTxPool(),
 		Storage:      storage.NewStorage(cc),
 		PartitionMap: make(map[string]uint64),
+
+		executed: make(map[string]*execResult),
 	}
+	bc.LightClient = NewLightClient(bc.Storage, cc.Nodes_perShard)
 	curHash, err := bc.Storage.GetNewestBlockHash()
 	if err != nil {
 		fmt.Println("Get newest block hash err")
@@ -273,18 +432,6 @@ func NewBlockChain(cc *params.ChainConfig, db ethdb.Database) (*BlockChain, erro
 	return bc, nil
 }
 
-// 检查此区块链配置中的块是否有效
-func (bc *BlockChain) IsValidBlock(b *core.Block) error { //该函数用于检查此区块链配置中的块是否有效。它接受一个块作为参数。
-	if string(b.Header.ParentBlockHash) != string(bc.CurrentBlock.Hash) { //如果父块哈希不等于当前块哈希，则打印错误消息并返回错误
-		fmt.Println("the parentblock hash is not equal to the current block hash")
-		return errors.New("the parentblock hash is not equal to the current block hash")
-	} else if string(GetTxTreeRoot(b.Body)) != string(b.Header.TxRoot) {
-		fmt.Println("the transaction root is wrong")
-		return errors.New("the transaction root is wrong")
-	}
-	return nil
-}
-
 // add accounts
 func (bc *BlockChain) AddAccounts(ac []string, as []*core.AccountState) { //该函数用于添加帐户。它接受一个字符串数组和一个 AccountState 数组作为参数，并将其添加到区块链中。
 	fmt.Printf("The len of accounts is %d, now adding the accounts\n", len(ac))
@@ -335,6 +482,35 @@ func (bc *BlockChain) AddAccounts(ac []string, as []*core.AccountState) { //该
 	bc.Storage.AddBlock(b)
 }
 
+// 按高度获取区块，从最新区块沿父区块哈希向前查找，返回高度在 [start, end] 之间的区块（按高度升序）
+func (bc *BlockChain) GetBlocksByHeight(start, end uint64) []*core.Block {
+	res := make([]*core.Block, 0)
+	if end > bc.CurrentBlock.Header.Number {
+		end = bc.CurrentBlock.Header.Number
+	}
+	if start > end {
+		return res
+	}
+	b := bc.CurrentBlock
+	for b.Header.Number >= start {
+		if b.Header.Number <= end {
+			res = append(res, b)
+		}
+		if b.Header.Number == 0 {
+			break
+		}
+		pb, err := bc.Storage.GetBlock(b.Header.ParentBlockHash)
+		if err != nil {
+			break
+		}
+		b = pb
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
+
 // fetch accounts
 func (bc *BlockChain) FetchAccounts(addrs []string) []*core.AccountState { //该函数用于获取帐户。它接受一个字符串数组作为参数，并返回一个 AccountState 数组。
 	res := make([]*core.AccountState, 0)
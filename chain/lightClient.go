@@ -0,0 +1,88 @@
+// 轻客户端：保存其他分片已经提交的区块头，跨分片验证（中继交易的默克尔证明等）据此判断区块是否已经被提交
+
+package chain
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/message"
+	"blockEmulator/storage"
+	"bytes"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrHeaderCertificate = errors.New("the commit certificate of the header is invalid")
+	ErrHeaderConflict    = errors.New("another header of the same height has been finalized")
+	ErrHeaderParent      = errors.New("the header does not link to the finalized headers of the neighbouring heights")
+)
+
+type LightClient struct {
+	storage *storage.Storage
+	quorum  int        // 提交证书中至少需要的签名数量，即 2f+1
+	lock    sync.Mutex // 保证检查和保存区块头是原子的
+}
+
+// 每个分片有 nodesPerShard 个节点，提交证书需要其中 2f+1 个节点的签名
+func NewLightClient(s *storage.Storage, nodesPerShard uint64) *LightClient {
+	return &LightClient{
+		storage: s,
+		quorum:  int(2*((nodesPerShard-1)/3) + 1),
+	}
+}
+
+// 验证分片 shardID 的区块头（编码后）的提交证书，并检查它与已经保存的相邻高度的区块头相连，通过后保存。
+// 区块头可能乱序到达，父区块头还没有保存时不检查链接关系。返回解码后的区块头
+func (lc *LightClient) AddHeader(shardID uint64, header []byte, cert [][]byte) (*core.BlockHeader, error) {
+	bh := core.DecodeBH(header)
+	hash := bh.Hash()
+	if !message.VerifyCommitCertificate(cert, shardID, hash, lc.quorum) {
+		return nil, ErrHeaderCertificate
+	}
+
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	if old, _, err := lc.storage.GetShardHeader(shardID, bh.Number); err == nil {
+		if !bytes.Equal(core.DecodeBH(old).Hash(), hash) {
+			return nil, ErrHeaderConflict
+		}
+		return bh, nil
+	}
+	if parent, _, err := lc.storage.GetShardHeader(shardID, bh.Number-1); err == nil && !bytes.Equal(core.DecodeBH(parent).Hash(), bh.ParentBlockHash) {
+		return nil, ErrHeaderParent
+	}
+	if child, _, err := lc.storage.GetShardHeader(shardID, bh.Number+1); err == nil && !bytes.Equal(core.DecodeBH(child).ParentBlockHash, hash) {
+		return nil, ErrHeaderParent
+	}
+	lc.storage.AddShardHeader(shardID, bh.Number, bh.Encode(), cert)
+	return bh, nil
+}
+
+// 判断区块头是否是分片 shardID 在该高度已经提交的区块头
+func (lc *LightClient) IsHeaderFinal(shardID uint64, bh *core.BlockHeader) bool {
+	header, _, err := lc.storage.GetShardHeader(shardID, bh.Number)
+	return err == nil && bytes.Equal(core.DecodeBH(header).Hash(), bh.Hash())
+}
+
+// 检查区块头是否已经提交：已经保存过的区块头直接通过，否则用附带的提交证书验证并保存
+func (lc *LightClient) CheckHeader(shardID uint64, header []byte, cert [][]byte) (*core.BlockHeader, error) {
+	bh := core.DecodeBH(header)
+	if lc.IsHeaderFinal(shardID, bh) {
+		return bh, nil
+	}
+	return lc.AddHeader(shardID, header, cert)
+}
+
+// 读取分片 shardID 在某一高度已经提交的区块头
+func (lc *LightClient) GetHeader(shardID, number uint64) (*core.BlockHeader, error) {
+	header, _, err := lc.storage.GetShardHeader(shardID, number)
+	if err != nil {
+		return nil, err
+	}
+	return core.DecodeBH(header), nil
+}
+
+// 已经同步的分片 shardID 的最高区块高度
+func (lc *LightClient) Height(shardID uint64) uint64 {
+	return lc.storage.GetShardHeight(shardID)
+}
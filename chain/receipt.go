@@ -0,0 +1,72 @@
+// 交易收据：添加区块时为区块中的每笔交易生成收据，记录执行结果和跨分片交易的阶段
+
+package chain
+
+import (
+	"blockEmulator/core"
+	"time"
+)
+
+// 交易在本分片中执行的阶段
+func (bc *BlockChain) txStage(tx *core.Transaction) string {
+	switch {
+	case tx.TwoPCPhase == core.TwoPCPrepare:
+		return core.TxStageTwoPCPrepare
+	case tx.TwoPCPhase == core.TwoPCCommit:
+		return core.TxStageTwoPCCommit
+	case tx.TwoPCPhase == core.TwoPCAbort:
+		return core.TxStageTwoPCAbort
+	case tx.Relayed:
+		return core.TxStageRelay2
+	case tx.RawTxHash != nil && tx.Sender == tx.OriginalSender:
+		return core.TxStageBroker1
+	case tx.RawTxHash != nil:
+		return core.TxStageBroker2
+	case !tx.HasBroker && bc.Get_PartitionMap(tx.Recipient) != bc.ChainConfig.ShardID:
+		return core.TxStageRelay1
+	default:
+		return core.TxStageInner
+	}
+}
+
+// 根据执行结果生成区块中每笔交易的收据
+func (bc *BlockChain) makeReceipts(b *core.Block, errs []error) []*core.Receipt {
+	now := time.Now()
+	receipts := make([]*core.Receipt, len(b.Body))
+	for i, tx := range b.Body {
+		r := &core.Receipt{
+			TxHash:       tx.TxHash,
+			Status:       core.ReceiptSuccess,
+			ShardID:      bc.ChainConfig.ShardID,
+			BlockHash:    b.Hash,
+			BlockNumber:  b.Header.Number,
+			Index:        uint64(i),
+			ExecutedTime: now,
+			Stage:        bc.txStage(tx),
+			RawTxHash:    tx.RawTxHash,
+		}
+		if i < len(errs) && errs[i] != nil {
+			r.Status = core.ReceiptFailed
+			r.Reason = errs[i].Error()
+		}
+		receipts[i] = r
+	}
+	return receipts
+}
+
+// 根据哈希查找本分片链上的交易和它的收据
+func (bc *BlockChain) GetTransaction(txHash []byte) (*core.Transaction, *core.Receipt, error) {
+	tl, err := bc.Storage.GetTxLookup(txHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := bc.Storage.GetBlock(tl.BlockHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := bc.Storage.GetReceipt(txHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b.Body[tl.Index], r, nil
+}
@@ -0,0 +1,208 @@
+// 两阶段提交（2PC）交易的执行：准备交易锁定账户，发送者一侧检查 nonce、收取手续费并扣款；
+// 提交交易在接收者一侧入账并解锁；中止交易在发送者一侧退回金额并解锁。
+// 中止交易可能先于准备交易到达（协调者超时），这时在状态树中记录该交易已经中止，之后的准备交易不再执行。
+// 准备交易失败时也在状态树中记录，之后的中止交易删除该记录；中止之后到达的准备交易删除中止的记录，
+// 这样一笔中止的交易在两个阶段都执行之后不会在状态树中留下记录。
+// 账户被锁定期间普通交易也不能修改它：打包区块时这些交易放回交易池，区块中出现时执行失败
+
+package chain
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	ErrTwoPCLocked    = errors.New("the account is locked by another 2PC transaction")
+	ErrTwoPCNotLocked = errors.New("the account is not locked by the 2PC transaction")
+	ErrTwoPCAborted   = errors.New("the 2PC transaction has been aborted")
+)
+
+// 状态树中记录已经中止、但准备交易还没有执行的 2PC 交易的键
+func twoPCAbortedKey(rawTxHash []byte) []byte {
+	return append([]byte("2pc-aborted-"), rawTxHash...)
+}
+
+// 状态树中记录准备失败、但还没有收到中止交易的 2PC 交易的键
+func twoPCRefusedKey(rawTxHash []byte) []byte {
+	return append([]byte("2pc-refused-"), rawTxHash...)
+}
+
+// 从状态树中读取账户状态，不存在的账户以初始余额创建
+func getAccountState(st *trie.Trie, addr string) *core.AccountState {
+	enc, _ := st.Get([]byte(addr))
+	if enc == nil {
+		return &core.AccountState{Nonce: 0, Balance: new(big.Int).Set(params.Init_Balance)}
+	}
+	return core.DecodeAS(enc)
+}
+
+// 账户是否被 2PC 交易锁定
+func isTwoPCLocked(st *trie.Trie, addr string) bool {
+	enc, _ := st.Get([]byte(addr))
+	return enc != nil && core.DecodeAS(enc).TwoPCLock != nil
+}
+
+// 把本分片中的发送者或接收者被 2PC 锁定的普通交易放回交易池，等待提交或中止之后再打包。
+// 同一批交易中准备交易锁定的账户也视为被锁定，之后涉及这些账户的普通交易同样放回
+func (bc *BlockChain) deferTwoPCLockedTxs(txs []*core.Transaction) []*core.Transaction {
+	st, err := trie.New(trie.TrieID(common.BytesToHash(bc.CurrentBlock.Header.StateRoot)), bc.triedb)
+	if err != nil {
+		log.Panic(err)
+	}
+	locked := make(map[string]bool)
+	isLocked := func(addr string) bool {
+		if l, ok := locked[addr]; ok {
+			return l
+		}
+		locked[addr] = isTwoPCLocked(st, addr)
+		return locked[addr]
+	}
+	valid := make([]*core.Transaction, 0, len(txs))
+	deferred := make([]*core.Transaction, 0)
+	for _, tx := range txs {
+		if tx.TwoPCPhase == core.TwoPCPrepare {
+			for _, addr := range []string{tx.Sender, tx.Recipient} {
+				if bc.Get_PartitionMap(addr) == bc.ChainConfig.ShardID {
+					locked[addr] = true
+				}
+			}
+		}
+		if tx.TwoPCPhase == core.TwoPCNone && (bc.isSenderSide(tx) && isLocked(tx.Sender) || bc.isRecipientSide(tx) && isLocked(tx.Recipient)) {
+			deferred = append(deferred, tx)
+			continue
+		}
+		valid = append(valid, tx)
+	}
+	if len(deferred) > 0 {
+		bc.Txpool.RequeueTxs(deferred)
+		fmt.Printf("%d txs wait for the 2PC locks of their accounts\n", len(deferred))
+	}
+	return valid
+}
+
+// 执行 2PC 交易，手续费加到 fees 中，返回修改的状态树条目数量。本分片可能是发送者分片或接收者分片（或者两者都是）
+func (bc *BlockChain) executeTwoPC(st *trie.Trie, tx *core.Transaction, fees *big.Int) (int, error) {
+	senderIn := bc.Get_PartitionMap(tx.Sender) == bc.ChainConfig.ShardID
+	recipientIn := bc.Get_PartitionMap(tx.Recipient) == bc.ChainConfig.ShardID
+	switch tx.TwoPCPhase {
+	case core.TwoPCPrepare:
+		return bc.prepareTwoPC(st, tx, fees, senderIn, recipientIn)
+	case core.TwoPCCommit:
+		return bc.finishTwoPC(st, tx, senderIn, recipientIn, true)
+	default:
+		return bc.finishTwoPC(st, tx, senderIn, recipientIn, false)
+	}
+}
+
+// 准备：所有相关账户都没有被锁定、发送者一侧的检查都通过时才锁定账户并扣款
+func (bc *BlockChain) prepareTwoPC(st *trie.Trie, tx *core.Transaction, fees *big.Int, senderIn, recipientIn bool) (int, error) {
+	var s_state, r_state *core.AccountState
+	if senderIn {
+		s_state = getAccountState(st, tx.Sender)
+	}
+	// 与普通交易一样，nonce 正确但被拒绝的准备交易同样使用了 nonce，否则发送者之后的交易会一直等待这个 nonce
+	useNonce := func(err error) (int, error) {
+		if s_state == nil || !params.CheckNonce || tx.Nonce != s_state.Nonce {
+			return 1, err
+		}
+		s_state.Nonce++
+		st.Update([]byte(tx.Sender), s_state.Encode())
+		return 2, err
+	}
+	if enc, _ := st.Get(twoPCAbortedKey(tx.RawTxHash)); enc != nil { //中止已经完成，不再需要该记录
+		st.Delete(twoPCAbortedKey(tx.RawTxHash))
+		return useNonce(ErrTwoPCAborted)
+	}
+	refuse := func(err error) (int, error) { //之后的中止交易不需要再记录该交易已经中止
+		st.Update(twoPCRefusedKey(tx.RawTxHash), []byte{1})
+		return useNonce(err)
+	}
+	fee := new(big.Int)
+	if senderIn {
+		if params.CheckNonce && tx.Nonce != s_state.Nonce {
+			return refuse(ErrTxNonce)
+		}
+		if s_state.TwoPCLock != nil {
+			return refuse(ErrTwoPCLocked)
+		}
+		if params.TxFee {
+			if tx.GasLimit < params.TxGas {
+				return refuse(core.ErrTxGasLimit)
+			}
+			fee = tx.Fee()
+		}
+		if s_state.Balance.Cmp(new(big.Int).Add(tx.Value, fee)) < 0 {
+			return refuse(ErrInsufficientBalance)
+		}
+	}
+	if recipientIn {
+		r_state = getAccountState(st, tx.Recipient)
+		if r_state.TwoPCLock != nil {
+			return refuse(ErrTwoPCLocked)
+		}
+	}
+	cnt := 0
+	if senderIn {
+		s_state.Deduct(new(big.Int).Add(tx.Value, fee))
+		fees.Add(fees, fee)
+		s_state.Nonce++
+		s_state.TwoPCLock = tx.RawTxHash
+		st.Update([]byte(tx.Sender), s_state.Encode())
+		cnt++
+	}
+	if recipientIn {
+		r_state.TwoPCLock = tx.RawTxHash
+		st.Update([]byte(tx.Recipient), r_state.Encode())
+		cnt++
+	}
+	return cnt, nil
+}
+
+// 提交或中止：由本交易锁定的账户解锁，提交时接收者入账，中止时发送者取回金额。
+// 中止时如果本分片没有准备成功（准备失败或者还没有执行），记录该交易已经中止
+func (bc *BlockChain) finishTwoPC(st *trie.Trie, tx *core.Transaction, senderIn, recipientIn, commit bool) (int, error) {
+	cnt := 0
+	if senderIn {
+		s_state := getAccountState(st, tx.Sender)
+		if bytes.Equal(s_state.TwoPCLock, tx.RawTxHash) {
+			if !commit {
+				s_state.Deposit(tx.Value)
+			}
+			s_state.TwoPCLock = nil
+			st.Update([]byte(tx.Sender), s_state.Encode())
+			cnt++
+		}
+	}
+	if recipientIn {
+		r_state := getAccountState(st, tx.Recipient)
+		if bytes.Equal(r_state.TwoPCLock, tx.RawTxHash) {
+			if commit {
+				r_state.Deposit(tx.Value)
+			}
+			r_state.TwoPCLock = nil
+			st.Update([]byte(tx.Recipient), r_state.Encode())
+			cnt++
+		}
+	}
+	if cnt > 0 {
+		return cnt, nil
+	}
+	if !commit {
+		if enc, _ := st.Get(twoPCRefusedKey(tx.RawTxHash)); enc != nil { //准备失败的交易已经中止
+			st.Delete(twoPCRefusedKey(tx.RawTxHash))
+		} else {
+			st.Update(twoPCAbortedKey(tx.RawTxHash), []byte{1})
+		}
+		return 1, ErrTwoPCNotLocked
+	}
+	return 0, ErrTwoPCNotLocked
+}
@@ -0,0 +1,71 @@
+package chain
+
+import (
+	"blockEmulator/core"
+	"bytes"
+	"errors"
+	"log"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var ErrTxProof = errors.New("the merkle proof does not prove the tx against the tx root")
+
+// 默克尔证明，即树中从根到某个键的路径上的节点
+type merkleProof [][]byte
+
+func (p *merkleProof) Put(key []byte, value []byte) error {
+	*p = append(*p, common.CopyBytes(value))
+	return nil
+}
+
+func (p *merkleProof) Delete(key []byte) error {
+	panic("not supported")
+}
+
+// 交易树，用于为区块中的交易生成默克尔证明
+type TxTree struct {
+	tree *trie.Trie
+}
+
+// 构造区块 body 的交易树，交易的编码在构造时确定，之后修改交易不影响证明
+func NewTxTree(body []*core.Transaction) *TxTree {
+	return &TxTree{tree: newTxTree(body)}
+}
+
+// 生成交易哈希为 txHash 的交易的默克尔证明
+func (t *TxTree) Prove(txHash []byte) [][]byte {
+	proof := make(merkleProof, 0)
+	if err := t.tree.Prove(txHash, 0, &proof); err != nil {
+		log.Panic(err)
+	}
+	return proof
+}
+
+// 用默克尔证明验证交易哈希为 txHash 的交易包含在交易树根为 txRoot 的区块中，返回区块中的交易
+func VerifyTxProof(txRoot []byte, txHash []byte, proof [][]byte) (*core.Transaction, error) {
+	val, err := verifyProof(txRoot, txHash, proof)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, ErrTxProof
+	}
+	tx := core.DecodeTx(val)
+	if !bytes.Equal(tx.TxHash, txHash) {
+		return nil, ErrTxProof
+	}
+	return tx, nil
+}
+
+// 用默克尔证明验证树根为 root 的树中键 key 的值，证明键不存在时返回空
+func verifyProof(root []byte, key []byte, proof [][]byte) ([]byte, error) {
+	db := memorydb.New()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return trie.VerifyProof(common.BytesToHash(root), key, db)
+}
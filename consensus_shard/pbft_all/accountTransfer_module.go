@@ -6,7 +6,6 @@ package pbft_all
 import (
 	"blockEmulator/core"
 	"blockEmulator/message"
-	"blockEmulator/networks"
 	"encoding/json"
 	"log"
 	"time"
@@ -27,10 +26,10 @@ func (cphm *CLPAPbftInsideExtraHandleMod) sendPartitionReady() {
 	if err != nil {
 		log.Panic()
 	}
-	send_msg := message.MergeMessage(message.CPartitionReady, pByte)          //通过将 CPartitionReady 消息类型与封送的 pr 消息合并来构造要发送的消息 (send_msg)
+	send_msg := cphm.pbftNode.signMessage(message.CPartitionReady, pByte)     //通过将 CPartitionReady 消息类型与封送的 pr 消息合并来构造要发送的消息 (send_msg)
 	for sid := 0; sid < int(cphm.pbftNode.pbftChainConfig.ShardNums); sid++ { //迭代所有分片（由 sid 表示），并使用名为 Networks.TcpDial 的函数或库将 send_msg 发送到除当前分片之外的其他分片。此步骤通知其他分片当前分片已准备好进行分区。
 		if sid != int(pr.FromShard) {
-			networks.TcpDial(send_msg, cphm.pbftNode.ip_nodeTable[uint64(sid)][0]) //通过TCP连接发送消息
+			cphm.pbftNode.sendMessage(send_msg, cphm.pbftNode.getLeaderIP(uint64(sid))) //通过TCP连接发送消息
 		}
 	}
 	cphm.pbftNode.pl.Plog.Print("Ready for partition\n") //打印日志，指示当前分片已准备好进行分区
@@ -102,7 +101,7 @@ func (cphm *CLPAPbftInsideExtraHandleMod) sendAccounts_and_Txs() { //sendAccount
 				firstPtr++
 			}
 		}
-		cphm.pbftNode.CurChain.Txpool.TxQueue = cphm.pbftNode.CurChain.Txpool.TxQueue[:firstPtr]
+		cphm.pbftNode.CurChain.Txpool.ResetTxQueue(cphm.pbftNode.CurChain.Txpool.TxQueue[:firstPtr])
 
 		cphm.pbftNode.pl.Plog.Printf("The txSend to shard %d is generated \n", i)
 		ast := message.AccountStateAndTx{ //创建一个新的 AccountStateAndTx 结构，该结构包含有关当前分片的信息，以及当前分片的序列ID。
@@ -115,8 +114,8 @@ func (cphm *CLPAPbftInsideExtraHandleMod) sendAccounts_and_Txs() { //sendAccount
 		if err != nil {
 			log.Panic()
 		}
-		send_msg := message.MergeMessage(message.AccountState_and_TX, aByte)
-		networks.TcpDial(send_msg, cphm.pbftNode.ip_nodeTable[i][0])
+		send_msg := cphm.pbftNode.signMessage(message.AccountState_and_TX, aByte)
+		cphm.pbftNode.sendMessage(send_msg, cphm.pbftNode.getLeaderIP(i))
 		cphm.pbftNode.pl.Plog.Printf("The message to shard %d is sent\n", i)
 	}
 	cphm.pbftNode.pl.Plog.Println("after sending, The size of tx pool is: ", len(cphm.pbftNode.CurChain.Txpool.TxQueue))
@@ -150,8 +149,8 @@ func (cphm *CLPAPbftInsideExtraHandleMod) proposePartition() (bool, *message.Req
 			log.Panic("error tx")
 		}
 	}
-	cphm.pbftNode.CurChain.Txpool.AddTxs2Pool(cphm.cdm.ReceivedNewTx)//将交易添加到交易池中
-	cphm.pbftNode.pl.Plog.Println("The size of txpool: ", len(cphm.pbftNode.CurChain.Txpool.TxQueue))//打印日志，指示当前分片的交易池中的交易数量
+	cphm.pbftNode.CurChain.Txpool.AddTxs2Pool(cphm.cdm.ReceivedNewTx)                                 //将交易添加到交易池中
+	cphm.pbftNode.pl.Plog.Println("The size of txpool: ", len(cphm.pbftNode.CurChain.Txpool.TxQueue)) //打印日志，指示当前分片的交易池中的交易数量
 
 	atmaddr := make([]string, 0)
 	atmAs := make([]*core.AccountState, 0)
@@ -159,14 +158,14 @@ func (cphm *CLPAPbftInsideExtraHandleMod) proposePartition() (bool, *message.Req
 		atmaddr = append(atmaddr, key)
 		atmAs = append(atmAs, val)
 	}
-	atm := message.AccountTransferMsg{//创建一个新的 AccountTransferMsg 结构，该结构包含有关当前分片的信息，以及当前分片的序列ID。
+	atm := message.AccountTransferMsg{ //创建一个新的 AccountTransferMsg 结构，该结构包含有关当前分片的信息，以及当前分片的序列ID。
 		ModifiedMap:  cphm.cdm.ModifiedMap[cphm.cdm.AccountTransferRound],
 		Addrs:        atmaddr,
 		AccountState: atmAs,
 		ATid:         uint64(len(cphm.cdm.ModifiedMap)),
 	}
 	atmbyte := atm.Encode()
-	r := &message.Request{//创建一个新的 Request 结构，该结构包含有关当前分片的信息，以及当前分片的序列ID。
+	r := &message.Request{ //创建一个新的 Request 结构，该结构包含有关当前分片的信息，以及当前分片的序列ID。
 		RequestType: message.PartitionReq,
 		Msg: message.RawMessage{
 			Content: atmbyte,
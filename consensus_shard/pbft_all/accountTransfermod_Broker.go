@@ -6,7 +6,6 @@ package pbft_all
 import (
 	"blockEmulator/core"
 	"blockEmulator/message"
-	"blockEmulator/networks"
 	"blockEmulator/params"
 	"encoding/json"
 	"log"
@@ -27,10 +26,10 @@ func (cphm *CLPAPbftInsideExtraHandleMod_forBroker) sendPartitionReady() {
 	if err != nil {
 		log.Panic()
 	}
-	send_msg := message.MergeMessage(message.CPartitionReady, pByte)
+	send_msg := cphm.pbftNode.signMessage(message.CPartitionReady, pByte)
 	for sid := 0; sid < int(cphm.pbftNode.pbftChainConfig.ShardNums); sid++ { //迭代所有分片（由 sid 表示），并使用名为 Networks.TcpDial 的函数或库将 send_msg 发送到除当前分片之外的其他分片。此步骤通知其他分片当前分片已准备好进行分区。
 		if sid != int(pr.FromShard) {
-			networks.TcpDial(send_msg, cphm.pbftNode.ip_nodeTable[uint64(sid)][0])
+			cphm.pbftNode.sendMessage(send_msg, cphm.pbftNode.getLeaderIP(uint64(sid)))
 		}
 	}
 	cphm.pbftNode.pl.Plog.Print("Ready for partition\n")
@@ -122,7 +121,7 @@ func (cphm *CLPAPbftInsideExtraHandleMod_forBroker) sendAccounts_and_Txs() {
 				firstPtr++
 			}
 		}
-		cphm.pbftNode.CurChain.Txpool.TxQueue = cphm.pbftNode.CurChain.Txpool.TxQueue[:firstPtr]
+		cphm.pbftNode.CurChain.Txpool.ResetTxQueue(cphm.pbftNode.CurChain.Txpool.TxQueue[:firstPtr])
 
 		cphm.pbftNode.pl.Plog.Printf("The txSend to shard %d is generated \n", i)
 		ast := message.AccountStateAndTx{
@@ -135,8 +134,8 @@ func (cphm *CLPAPbftInsideExtraHandleMod_forBroker) sendAccounts_and_Txs() {
 		if err != nil {
 			log.Panic()
 		}
-		send_msg := message.MergeMessage(message.CAccountTransferMsg_broker, aByte)
-		networks.TcpDial(send_msg, cphm.pbftNode.ip_nodeTable[i][0])
+		send_msg := cphm.pbftNode.signMessage(message.CAccountTransferMsg_broker, aByte)
+		cphm.pbftNode.sendMessage(send_msg, cphm.pbftNode.getLeaderIP(i))
 		cphm.pbftNode.pl.Plog.Printf("The message to shard %d is sent\n", i)
 	}
 	cphm.pbftNode.CurChain.Txpool.GetUnlocked()
@@ -149,8 +148,8 @@ func (cphm *CLPAPbftInsideExtraHandleMod_forBroker) sendAccounts_and_Txs() {
 	if err != nil {
 		log.Panic()
 	}
-	send_msg := message.MergeMessage(message.CInner2CrossTx, icByte)
-	networks.TcpDial(send_msg, cphm.pbftNode.ip_nodeTable[params.DeciderShard][0])
+	send_msg := cphm.pbftNode.signMessage(message.CInner2CrossTx, icByte)
+	cphm.pbftNode.sendMessage(send_msg, cphm.pbftNode.ip_nodeTable[params.DeciderShard][0])
 }
 
 // fetch collect infos
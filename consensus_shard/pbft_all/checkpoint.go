@@ -0,0 +1,140 @@
+// 检查点（checkpoint）与垃圾回收：每提交 CheckpointPeriod 个序列，节点广播一次检查点消息，
+// 收到 2f+1 个相同状态树根的检查点后成为稳定检查点，之前的请求和投票记录都会被清除。
+
+package pbft_all
+
+import (
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"encoding/json"
+	"log"
+)
+
+// 获取稳定检查点，即低水位线
+func (p *PbftConsensusNode) getStableCheckpoint() uint64 {
+	p.ckptLock.Lock()
+	defer p.ckptLock.Unlock()
+	return p.stableCheckpoint
+}
+
+// 获取高水位线，主节点只能提议不超过高水位线的序列
+func (p *PbftConsensusNode) getHighWaterMark() uint64 {
+	return p.getStableCheckpoint() + uint64(params.WaterMarkWindow)
+}
+
+// 在提交序列 seq 之后，如果 seq 是检查点，则广播检查点消息
+func (p *PbftConsensusNode) makeCheckpoint(seq uint64) {
+	if seq == 0 || seq%uint64(params.CheckpointPeriod) != 0 || seq <= p.getStableCheckpoint() {
+		return
+	}
+	if seq != p.CurChain.CurrentBlock.Header.Number {
+		p.pl.Plog.Printf("S%dN%d : the block height %d is not consistent with the checkpoint %d\n", p.ShardID, p.NodeID, p.CurChain.CurrentBlock.Header.Number, seq)
+		return
+	}
+	ckpt := &message.Checkpoint{
+		SeqID:      seq,
+		StateRoot:  p.CurChain.CurrentBlock.Header.StateRoot,
+		BlockHash:  p.CurChain.CurrentBlock.Hash,
+		SenderNode: p.RunningNode,
+	}
+	p.recordCheckpoint(ckpt)
+
+	ckptByte, err := json.Marshal(ckpt)
+	if err != nil {
+		log.Panic()
+	}
+	msg_send := p.signMessage(message.CCheckpoint, ckptByte)
+	p.broadcast(p.getNeighborNodes(), msg_send)
+	p.pl.Plog.Printf("S%dN%d : has broadcast the checkpoint message, seq = %d\n", p.ShardID, p.NodeID, seq)
+
+	p.checkStableCheckpoint(seq, string(ckpt.StateRoot))
+}
+
+// 记录一条检查点消息，每个节点对每个检查点只计一票
+func (p *PbftConsensusNode) recordCheckpoint(ckpt *message.Checkpoint) {
+	if _, ok := p.checkpointVotes[ckpt.SeqID]; !ok {
+		p.checkpointVotes[ckpt.SeqID] = make(map[string]map[uint64]bool)
+	}
+	root := string(ckpt.StateRoot)
+	if _, ok := p.checkpointVotes[ckpt.SeqID][root]; !ok {
+		p.checkpointVotes[ckpt.SeqID][root] = make(map[uint64]bool)
+	}
+	p.checkpointVotes[ckpt.SeqID][root][ckpt.SenderNode.NodeID] = true
+}
+
+func (p *PbftConsensusNode) handleCheckpoint(content []byte) {
+	ckpt := new(message.Checkpoint)
+	err := json.Unmarshal(content, ckpt)
+	if err != nil {
+		log.Panic(err)
+	}
+	if ckpt.SenderNode == nil || ckpt.SenderNode.ShardID != p.ShardID || ckpt.SeqID <= p.getStableCheckpoint() {
+		return
+	}
+	p.pl.Plog.Printf("S%dN%d : received the checkpoint (seq %d) from ...%d\n", p.ShardID, p.NodeID, ckpt.SeqID, ckpt.SenderNode.NodeID)
+	p.recordCheckpoint(ckpt)
+	p.checkStableCheckpoint(ckpt.SeqID, string(ckpt.StateRoot))
+}
+
+// 检查点收到 2f+1 个相同状态树根的确认，并且本节点已经提交到该序列时，成为稳定检查点
+func (p *PbftConsensusNode) checkStableCheckpoint(seq uint64, root string) {
+	if len(p.checkpointVotes[seq][root]) < int(2*p.malicious_nums)+1 {
+		return
+	}
+	if p.sequenceID <= seq { // 本节点还没有提交到该序列，等追上之后再确认
+		return
+	}
+	p.ckptLock.Lock()
+	p.stableCheckpoint = seq
+	p.ckptLock.Unlock()
+	p.garbageCollect(seq)
+	p.pl.Plog.Printf("S%dN%d : the checkpoint %d is stable now\n", p.ShardID, p.NodeID, seq)
+}
+
+// 清除稳定检查点之前的请求和投票记录
+func (p *PbftConsensusNode) garbageCollect(seq uint64) {
+	cnt := 0
+	for height, digest := range p.height2Digest {
+		if height > seq {
+			continue
+		}
+		// 非区块请求（如账户迁移请求）无法从区块中恢复，单独保存以便其他节点追赶
+		if r, ok := p.requestPool[digest]; ok && r.RequestType != message.BlockRequest {
+			p.retainedRequests[height] = r
+		}
+		delete(p.requestPool, digest)
+		delete(p.cntPrepareConfirm, digest)
+		delete(p.cntCommitConfirm, digest)
+		delete(p.commitCerts, digest)
+		delete(p.isCommitBordcast, digest)
+		delete(p.isReply, digest)
+		delete(p.height2Digest, height)
+		cnt++
+	}
+	for ckptSeq := range p.checkpointVotes {
+		if ckptSeq <= seq {
+			delete(p.checkpointVotes, ckptSeq)
+		}
+	}
+	p.pl.Plog.Printf("S%dN%d : %d requests before the checkpoint %d are cleared\n", p.ShardID, p.NodeID, cnt, seq)
+}
+
+// 从检查点之前的区块中恢复请求，用于处理旧请求的请求
+func (p *PbftConsensusNode) getStableRequests(start, end uint64) map[uint64]*message.Request {
+	res := make(map[uint64]*message.Request)
+	for _, b := range p.CurChain.GetBlocksByHeight(start, end) {
+		res[b.Header.Number] = &message.Request{
+			RequestType: message.BlockRequest,
+			Msg: message.RawMessage{
+				Content: b.Encode(),
+			},
+			ReqTime: b.Header.Time, //与提议时的请求相同，摘要不变
+		}
+	}
+	for height, r := range p.retainedRequests {
+		if height >= start && height <= end {
+			res[height] = r
+		}
+	}
+	return res
+}
@@ -0,0 +1,277 @@
+// 拜占庭故障注入：在节点发送消息时篡改、丢弃或者延迟消息，用于模拟 f 个恶意节点的实验。
+// 诚实节点的 fbm 为空，发送行为与原来完全一致。
+
+package pbft_all
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/message"
+	"blockEmulator/networks"
+	"blockEmulator/params"
+	"crypto/sha256"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// 拜占庭行为的接口，节点发送的每条消息都会经过它
+type FaultBehavior interface {
+	// 返回发送给 receiver 的消息（可以被篡改），返回 nil 表示丢弃该消息
+	TamperMessage(msg []byte, receiver string) []byte
+	// 返回发送消息之前的延迟
+	SendDelay() time.Duration
+	// 行为的名称
+	Name() string
+}
+
+// 根据名称创建拜占庭行为，名称为空表示诚实节点
+func NewFaultBehavior(name string, p *PbftConsensusNode) FaultBehavior {
+	switch name {
+	case "":
+		return nil
+	case "Equivocation":
+		return &EquivocationFault{pbftNode: p}
+	case "WrongVote":
+		return &WrongVoteFault{pbftNode: p}
+	case "Drop":
+		return &DropFault{rate: params.FaultDropRate}
+	case "Delay":
+		return &DelayFault{delay: time.Duration(params.FaultDelay) * time.Millisecond}
+	case "InvalidBlock":
+		return &InvalidBlockFault{pbftNode: p}
+	case "Silent":
+		return &DropFault{rate: 1}
+	default:
+		log.Panic("unknown fault behavior: ", name, ", it should be one of ", params.FaultBehaviors)
+	}
+	return nil
+}
+
+// 为节点注入拜占庭行为，名称为空表示诚实节点
+func (p *PbftConsensusNode) SetFaultBehavior(name string) {
+	p.fbm = NewFaultBehavior(name, p)
+	if p.fbm != nil {
+		p.pl.Plog.Printf("S%dN%d : is a byzantine node with the behavior %s\n", p.ShardID, p.NodeID, p.fbm.Name())
+	}
+}
+
+// 节点提交请求之后向主管节点报告，用于测量安全性和活性
+func (p *PbftConsensusNode) reportCommit(seq uint64, digest string) {
+	ci := message.CommitInfo{
+		ShardID:    p.ShardID,
+		NodeID:     p.NodeID,
+		SeqID:      seq,
+		View:       p.getView(),
+		Digest:     []byte(digest),
+		Faulty:     p.fbm != nil,
+		CommitTime: time.Now(),
+	}
+	ciByte, err := json.Marshal(ci)
+	if err != nil {
+		log.Panic()
+	}
+	msg_send := p.signMessage(message.CCommitInfo, ciByte)
+	go networks.TcpDial(msg_send, p.ip_nodeTable[params.DeciderShard][0]) // 报告不经过拜占庭行为，保证测量结果完整
+}
+
+// 经过拜占庭行为和场景控制发送消息
+func (p *PbftConsensusNode) sendMessage(msg []byte, receiver string) {
+	if p.isBlocked(receiver) { //崩溃的节点以及被隔离的节点不发送消息
+		return
+	}
+	if p.fbm == nil {
+		networks.TcpDial(msg, receiver)
+		return
+	}
+	msg = p.fbm.TamperMessage(msg, receiver)
+	if msg == nil {
+		return
+	}
+	if delay := p.fbm.SendDelay(); delay > 0 {
+		go func() {
+			time.Sleep(delay)
+			networks.TcpDial(msg, receiver)
+		}()
+		return
+	}
+	networks.TcpDial(msg, receiver)
+}
+
+// 经过拜占庭行为和场景控制广播消息
+func (p *PbftConsensusNode) broadcast(receivers []string, msg []byte) {
+	if p.fbm == nil && !p.isDisturbed() {
+		networks.Broadcast(p.RunningNode.IPaddr, receivers, msg)
+		return
+	}
+	for _, ip := range receivers {
+		if ip == p.RunningNode.IPaddr {
+			continue
+		}
+		go p.sendMessage(msg, ip)
+	}
+}
+
+// 拆开带签名的消息（不验证签名，恶意节点只篡改自己发出的消息）
+func openOwnMessage(msg []byte) (*message.SignedMessage, bool) {
+	msgType, content := message.SplitMessage(msg)
+	if msgType != message.CSigned {
+		return nil, false
+	}
+	sm := new(message.SignedMessage)
+	if err := json.Unmarshal(content, sm); err != nil {
+		return nil, false
+	}
+	return sm, true
+}
+
+// 篡改之后重新签名
+func (p *PbftConsensusNode) resign(sm *message.SignedMessage, content []byte) []byte {
+	return message.MergeSignedMessage(sm.MsgType, content, p.RunningNode, p.priKey)
+}
+
+// 查找接收者在本分片中的节点ID，不在本分片中则返回 false
+func (p *PbftConsensusNode) receiverNodeID(receiver string) (uint64, bool) {
+	for nid, ip := range p.ip_nodeTable[p.ShardID] {
+		if ip == receiver {
+			return nid, true
+		}
+	}
+	return 0, false
+}
+
+// 模棱两可的主节点：给奇数ID的节点和偶数ID的节点发送不同摘要的 PrePrepare
+type EquivocationFault struct {
+	pbftNode *PbftConsensusNode
+}
+
+func (ef *EquivocationFault) Name() string             { return "Equivocation" }
+func (ef *EquivocationFault) SendDelay() time.Duration { return 0 }
+
+func (ef *EquivocationFault) TamperMessage(msg []byte, receiver string) []byte {
+	sm, ok := openOwnMessage(msg)
+	if !ok || sm.MsgType != message.CPrePrepare {
+		return msg
+	}
+	if nid, ok := ef.pbftNode.receiverNodeID(receiver); !ok || nid%2 == 0 {
+		return msg
+	}
+	ppmsg := new(message.PrePrepare)
+	if err := json.Unmarshal(sm.Content, ppmsg); err != nil {
+		return msg
+	}
+	// 修改请求时间，得到另一个合法但不同的请求
+	r := *ppmsg.RequestMsg
+	r.ReqTime = r.ReqTime.Add(time.Nanosecond)
+	ppmsg.RequestMsg = &r
+	ppmsg.Digest = getDigest(&r)
+	ppbyte, err := json.Marshal(ppmsg)
+	if err != nil {
+		log.Panic(err)
+	}
+	return ef.pbftNode.resign(sm, ppbyte)
+}
+
+// 投错票的节点：Prepare 和 Commit 中的摘要被替换为错误的摘要
+type WrongVoteFault struct {
+	pbftNode *PbftConsensusNode
+}
+
+func (wf *WrongVoteFault) Name() string             { return "WrongVote" }
+func (wf *WrongVoteFault) SendDelay() time.Duration { return 0 }
+
+func (wf *WrongVoteFault) TamperMessage(msg []byte, receiver string) []byte {
+	sm, ok := openOwnMessage(msg)
+	if !ok {
+		return msg
+	}
+	var content []byte
+	var err error
+	switch sm.MsgType {
+	case message.CPrepare:
+		pmsg := new(message.Prepare)
+		if json.Unmarshal(sm.Content, pmsg) != nil {
+			return msg
+		}
+		wrong := sha256.Sum256(pmsg.Digest)
+		pmsg.Digest = wrong[:]
+		content, err = json.Marshal(pmsg)
+	case message.CCommit:
+		cmsg := new(message.Commit)
+		if json.Unmarshal(sm.Content, cmsg) != nil {
+			return msg
+		}
+		wrong := sha256.Sum256(cmsg.Digest)
+		cmsg.Digest = wrong[:]
+		content, err = json.Marshal(cmsg)
+	default:
+		return msg
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+	return wf.pbftNode.resign(sm, content)
+}
+
+// 丢包的节点：以一定的概率丢弃发出的消息，概率为 1 时相当于沉默的节点
+type DropFault struct {
+	rate float64
+}
+
+func (df *DropFault) Name() string {
+	if df.rate >= 1 {
+		return "Silent"
+	}
+	return "Drop"
+}
+func (df *DropFault) SendDelay() time.Duration { return 0 }
+
+func (df *DropFault) TamperMessage(msg []byte, receiver string) []byte {
+	if rand.Float64() < df.rate {
+		return nil
+	}
+	return msg
+}
+
+// 延迟的节点：发出的每条消息都延迟一段时间
+type DelayFault struct {
+	delay time.Duration
+}
+
+func (dlf *DelayFault) Name() string                                     { return "Delay" }
+func (dlf *DelayFault) SendDelay() time.Duration                         { return dlf.delay }
+func (dlf *DelayFault) TamperMessage(msg []byte, receiver string) []byte { return msg }
+
+// 提议无效区块的主节点：PrePrepare 中区块的父区块哈希和状态树根被篡改
+type InvalidBlockFault struct {
+	pbftNode *PbftConsensusNode
+}
+
+func (ibf *InvalidBlockFault) Name() string             { return "InvalidBlock" }
+func (ibf *InvalidBlockFault) SendDelay() time.Duration { return 0 }
+
+func (ibf *InvalidBlockFault) TamperMessage(msg []byte, receiver string) []byte {
+	sm, ok := openOwnMessage(msg)
+	if !ok || sm.MsgType != message.CPrePrepare {
+		return msg
+	}
+	ppmsg := new(message.PrePrepare)
+	if err := json.Unmarshal(sm.Content, ppmsg); err != nil || ppmsg.RequestMsg.RequestType != message.BlockRequest {
+		return msg
+	}
+	b := core.DecodeB(ppmsg.RequestMsg.Msg.Content)
+	wrongParent := sha256.Sum256(b.Header.ParentBlockHash)
+	wrongRoot := sha256.Sum256(b.Header.StateRoot)
+	b.Header.ParentBlockHash = wrongParent[:]
+	b.Header.StateRoot = wrongRoot[:]
+	b.Hash = b.Header.Hash()
+	r := *ppmsg.RequestMsg
+	r.Msg.Content = b.Encode()
+	ppmsg.RequestMsg = &r
+	ppmsg.Digest = getDigest(&r)
+	ppbyte, err := json.Marshal(ppmsg)
+	if err != nil {
+		log.Panic(err)
+	}
+	return ibf.pbftNode.resign(sm, ppbyte)
+}
@@ -0,0 +1,51 @@
+package pbft_all
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/message"
+	"encoding/json"
+	"log"
+)
+
+// 主节点提交区块后，把区块头和提交证书发给其他分片的所有节点，调用者持有 p.lock
+func (p *PbftConsensusNode) broadcastHeader(cmsg *message.Commit) {
+	r, ok := p.requestPool[string(cmsg.Digest)]
+	if !ok || r.RequestType != message.BlockRequest {
+		return
+	}
+	block := core.DecodeB(r.Msg.Content)
+	hs := message.HeaderSync{
+		Header:        block.Header.Encode(),
+		Certificate:   p.commitCertificate(cmsg, block),
+		SenderShardID: p.ShardID,
+	}
+	hsByte, err := json.Marshal(hs)
+	if err != nil {
+		log.Panic(err)
+	}
+	msg_send := p.signMessage(message.CHeaderSync, hsByte)
+	for sid := uint64(0); sid < p.pbftChainConfig.ShardNums; sid++ {
+		if sid == p.ShardID {
+			continue
+		}
+		for _, ip := range p.ip_nodeTable[sid] {
+			go p.sendMessage(msg_send, ip)
+		}
+	}
+	p.pl.Plog.Printf("S%dN%d : sended the header %d to other shards\n", p.ShardID, p.NodeID, block.Header.Number)
+}
+
+// 接收其他分片的区块头，验证提交证书后保存到轻客户端中
+func (p *PbftConsensusNode) handleHeaderSync(content []byte) {
+	hs := new(message.HeaderSync)
+	err := json.Unmarshal(content, hs)
+	if err != nil {
+		log.Panic(err)
+	}
+	bh, err := p.CurChain.LightClient.AddHeader(hs.SenderShardID, hs.Header, hs.Certificate)
+	if err != nil {
+		p.pl.Plog.Printf("S%dN%d : cannot sync the header from shard %d: %v, refuse it\n", p.ShardID, p.NodeID, hs.SenderShardID, err)
+		return
+	}
+	p.pl.Plog.Printf("S%dN%d : synced the header %d of shard %d\n", p.ShardID, p.NodeID, bh.Number, hs.SenderShardID)
+}
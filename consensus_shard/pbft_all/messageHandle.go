@@ -2,7 +2,7 @@ package pbft_all
 
 import (
 	"blockEmulator/message"
-	"blockEmulator/networks"
+	"blockEmulator/params"
 	"blockEmulator/shard"
 	"encoding/json"
 	"fmt"
@@ -14,9 +14,18 @@ import (
 
 // 该函数仅由主节点调用，如果请求正确，则主节点将块发送回消息发送者。
 func (p *PbftConsensusNode) Propose() { //Propose()函数用于提出新的块。它不需要参数。它不返回任何值。
-	if p.view != p.NodeID {
+	p.viewLock.Lock()
+	if p.proposing || p.view%p.node_nums != p.NodeID {
+		p.viewLock.Unlock()
 		return
-	} //如果节点不是主节点，则返回。在PBFT中，只有主节点负责提议新区块
+	} //如果节点不是主节点（或者已经有提议协程在运行），则返回。在PBFT中，只有主节点负责提议新区块
+	p.proposing = true
+	p.viewLock.Unlock()
+	defer func() {
+		p.viewLock.Lock()
+		p.proposing = false
+		p.viewLock.Unlock()
+	}()
 	for { //无限for循环。该循环用于不断提出新块
 		select { //使用一条select语句来处理停止信号。如果在通道上收到信号p.pStop，该函数将打印一条停止消息并返回，从而有效地停止提议过程。
 		case <-p.pStop:
@@ -25,8 +34,21 @@ func (p *PbftConsensusNode) Propose() { //Propose()函数用于提出新的块
 		default:
 		}
 		time.Sleep(time.Duration(int64(p.pbftChainConfig.BlockInterval)) * time.Millisecond) //使用time.Sleep函数使主节点休眠一段时间。这段时间是由BlockInterval参数指定的。此睡眠间隔控制提出新块的速率。
+		if p.isCrashed() {                                                                   //崩溃的主节点不再提议，重启后继续
+			continue
+		}
 
-		p.sequenceLock.Lock()                                                                               //使用p.sequenceLock锁定共识序列。这是一个互斥锁，用于确保它具有对序列的独占访问权，在提出新块时不会发生竞争。
+		p.sequenceLock.Lock() //使用p.sequenceLock锁定共识序列。这是一个互斥锁，用于确保它具有对序列的独占访问权，在提出新块时不会发生竞争。
+		if !p.isLeader() {    //等待期间发生了视图切换，本节点不再是主节点，则停止提议
+			p.sequenceLock.Unlock()
+			p.pl.Plog.Printf("S%dN%d is no longer the leader, stop proposing\n", p.ShardID, p.NodeID)
+			return
+		}
+		if p.sequenceID > p.getHighWaterMark() { //序列超过了高水位线，等待稳定检查点推进后再提议
+			p.sequenceLock.Unlock()
+			p.pl.Plog.Printf("S%dN%d : the sequence %d exceeds the high water mark, wait for the checkpoint\n", p.ShardID, p.NodeID, p.sequenceID)
+			continue
+		}
 		p.pl.Plog.Printf("S%dN%d get sequenceLock locked, now trying to propose...\n", p.ShardID, p.NodeID) //打印一条日志消息，指示节点已锁定序列。
 		// propose
 		//实现接口来生成提案
@@ -40,15 +62,17 @@ func (p *PbftConsensusNode) Propose() { //Propose()函数用于提出新的块
 			RequestMsg: r,
 			Digest:     digest,
 			SeqID:      p.sequenceID,
+			View:       p.getView(),
 		}
+		p.setProposingDigest(string(digest))
 		p.height2Digest[p.sequenceID] = string(digest) //将摘要存储在高度到摘要的映射中。这样，可以使用高度来检索摘要。
 		//编组和广播
 		ppbyte, err := json.Marshal(ppmsg) //使用json.Marshal函数将PrePrepare消息编组为字节存储在ppbyte。它返回一个字节切片和一个错误。如果在编组过程中发生错误，则该函数将出现紧急情况并记录错误。
 		if err != nil {
 			log.Panic()
 		}
-		msg_send := message.MergeMessage(message.CPrePrepare, ppbyte)            //使用message.MergeMessage函数将PrePrepare消息与消息类型合并为字节。它返回一个字节切片。这个字节切片将用于广播PrePrepare消息。
-		networks.Broadcast(p.RunningNode.IPaddr, p.getNeighborNodes(), msg_send) //使用networks.Broadcast函数广播PrePrepare消息。它被发送到从 p.getNeighborNodes() 获得的相邻节点的 IP 地址。它需要三个参数： p.RunningNode.IPaddr：这是一个字符串，表示当前节点的IP地址。 p.getNeighborNodes()：这是一个指向shard.Node结构的指针的切片。它包含当前节点的所有邻居节点。 msg_send：这是一个字节切片，包含要广播的消息。
+		msg_send := p.signMessage(message.CPrePrepare, ppbyte) //使用signMessage函数对PrePrepare消息签名，并与消息类型合并为字节。它返回一个字节切片。这个字节切片将用于广播PrePrepare消息。
+		p.broadcast(p.getNeighborNodes(), msg_send)            //使用p.broadcast函数广播PrePrepare消息。它被发送到从 p.getNeighborNodes() 获得的相邻节点的 IP 地址。它需要两个参数： p.getNeighborNodes()：这是一个指向shard.Node结构的指针的切片。它包含当前节点的所有邻居节点。 msg_send：这是一个字节切片，包含要广播的消息。
 	}
 }
 
@@ -61,6 +85,19 @@ func (p *PbftConsensusNode) handlePrePrepare(content []byte) { //handlePrePrepar
 	if err != nil {
 		log.Panic(err)
 	}
+	if p.viewChanging || ppmsg.View != p.getView() { //正在进行视图切换，或者消息不属于当前视图，则拒绝
+		p.pl.Plog.Printf("S%dN%d : the view of PrePrepare is %d but the current view is %d, refuse to prepare. \n", p.ShardID, p.NodeID, ppmsg.View, p.getView())
+		return
+	}
+	p.prePrepareProcess(ppmsg)
+}
+
+// 处理一条已经通过视图检查的PrePrepare消息，新视图中的重新提议也通过这里处理
+func (p *PbftConsensusNode) prePrepareProcess(ppmsg *message.PrePrepare) {
+	if ppmsg.SeqID <= p.getStableCheckpoint() || ppmsg.SeqID > p.getHighWaterMark() { //序列不在高低水位线之间，则拒绝
+		p.pl.Plog.Printf("S%dN%d : the Sequence id %d is out of the water marks, so refuse to prepare. \n", p.ShardID, p.NodeID, ppmsg.SeqID)
+		return
+	}
 	flag := false                                                                      //创建一个布尔变量flag，用于指示是否应该广播Prepare消息。
 	if digest := getDigest(ppmsg.RequestMsg); string(digest) != string(ppmsg.Digest) { //使用getDigest函数计算请求消息的摘要。如果摘要与PrePrepare消息中的摘要不匹配，则打印一条日志消息，指示节点拒绝准备。
 		p.pl.Plog.Printf("S%dN%d : the digest is not consistent, so refuse to prepare. \n", p.ShardID, p.NodeID)
@@ -71,8 +108,10 @@ func (p *PbftConsensusNode) handlePrePrepare(content []byte) { //handlePrePrepar
 	} else {
 		// do your operation in this interface
 		flag = p.ihm.HandleinPrePrepare(ppmsg)
-		p.requestPool[string(getDigest(ppmsg.RequestMsg))] = ppmsg.RequestMsg
-		p.height2Digest[ppmsg.SeqID] = string(getDigest(ppmsg.RequestMsg))
+		if flag { //无效的区块不放入请求池，即使收到足够的 Prepare 也不会提交
+			p.requestPool[string(getDigest(ppmsg.RequestMsg))] = ppmsg.RequestMsg
+			p.height2Digest[ppmsg.SeqID] = string(getDigest(ppmsg.RequestMsg))
+		}
 	}
 	// if the message is true, broadcast the prepare message
 	if flag {
@@ -86,9 +125,10 @@ func (p *PbftConsensusNode) handlePrePrepare(content []byte) { //handlePrePrepar
 			log.Panic()
 		}
 		// broadcast
-		msg_send := message.MergeMessage(message.CPrepare, prepareByte)
-		networks.Broadcast(p.RunningNode.IPaddr, p.getNeighborNodes(), msg_send)
+		msg_send := p.signMessage(message.CPrepare, prepareByte)
+		p.broadcast(p.getNeighborNodes(), msg_send)
 		p.pl.Plog.Printf("S%dN%d : has broadcast the prepare message \n", p.ShardID, p.NodeID)
+		p.tryCommit(ppmsg.Digest, ppmsg.SeqID) //在 PrePrepare 之前到达的 Prepare 可能已经足够
 	}
 }
 
@@ -100,135 +140,184 @@ func (p *PbftConsensusNode) handlePrepare(content []byte) { //handlePrepare函
 	if err != nil {
 		log.Panic(err)
 	}
+	if pmsg.SeqID <= p.getStableCheckpoint() { //该序列已经在稳定检查点之前，记录已被清除
+		return
+	}
 
+	// 先记录投票：PrePrepare 可能晚于其他节点的 Prepare 到达，收到 PrePrepare 之后再检查是否可以提交
+	p.set2DMap(true, string(pmsg.Digest), pmsg.SenderNode)
 	if _, ok := p.requestPool[string(pmsg.Digest)]; !ok {
-		p.pl.Plog.Printf("S%dN%d : doesn't have the digest in the requst pool, refuse to commit\n", p.ShardID, p.NodeID)
+		p.pl.Plog.Printf("S%dN%d : doesn't have the digest in the requst pool, wait for the PrePrepare\n", p.ShardID, p.NodeID)
 	} else if p.sequenceID < pmsg.SeqID {
 		p.pl.Plog.Printf("S%dN%d : inconsistent sequence ID, refuse to commit\n", p.ShardID, p.NodeID)
 	} else {
 		// if needed more operations, implement interfaces
 		p.ihm.HandleinPrepare(pmsg)
+		p.tryCommit(pmsg.Digest, pmsg.SeqID)
+	}
+}
 
-		p.set2DMap(true, string(pmsg.Digest), pmsg.SenderNode)
-		cnt := 0
-		for range p.cntPrepareConfirm[string(pmsg.Digest)] {
-			cnt++
-		}
-		// the main node will not send the prepare message
-		specifiedcnt := int(2 * p.malicious_nums)
-		if p.NodeID != p.view {
-			specifiedcnt -= 1
-		}
+// 如果已经收到足够的 Prepare 并且还没有广播 Commit，则广播 Commit
+func (p *PbftConsensusNode) tryCommit(digest []byte, seqID uint64) {
+	cnt := 0
+	for range p.cntPrepareConfirm[string(digest)] {
+		cnt++
+	}
+	// the main node will not send the prepare message
+	specifiedcnt := int(2 * p.malicious_nums)
+	if !p.isLeader() {
+		specifiedcnt -= 1
+	}
 
-		// if the node has received 2f messages (itself included), and it haven't committed, then it commit
-		p.lock.Lock()
-		defer p.lock.Unlock()
-		if cnt >= specifiedcnt && !p.isCommitBordcast[string(pmsg.Digest)] {
-			p.pl.Plog.Printf("S%dN%d : is going to commit\n", p.ShardID, p.NodeID)
-			// generate commit and broadcast
-			c := message.Commit{
-				Digest:     pmsg.Digest,
-				SeqID:      pmsg.SeqID,
-				SenderNode: p.RunningNode,
-			}
-			commitByte, err := json.Marshal(c)
-			if err != nil {
-				log.Panic()
-			}
-			msg_send := message.MergeMessage(message.CCommit, commitByte)
-			networks.Broadcast(p.RunningNode.IPaddr, p.getNeighborNodes(), msg_send)
-			p.isCommitBordcast[string(pmsg.Digest)] = true
-			p.pl.Plog.Printf("S%dN%d : commit is broadcast\n", p.ShardID, p.NodeID)
+	// if the node has received 2f messages (itself included), and it haven't committed, then it commit
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if cnt >= specifiedcnt && !p.isCommitBordcast[string(digest)] {
+		p.pl.Plog.Printf("S%dN%d : is going to commit\n", p.ShardID, p.NodeID)
+		// generate commit and broadcast
+		c := message.Commit{
+			Digest:     digest,
+			SeqID:      seqID,
+			SenderNode: p.RunningNode,
+			BlockHash:  p.requestBlockHash(string(digest)),
 		}
+		commitByte, err := json.Marshal(c)
+		if err != nil {
+			log.Panic()
+		}
+		msg_send := p.signMessage(message.CCommit, commitByte)
+		p.broadcast(p.getNeighborNodes(), msg_send)
+		p.isCommitBordcast[string(digest)] = true
+		p.pl.Plog.Printf("S%dN%d : commit is broadcast\n", p.ShardID, p.NodeID)
+		p.commitIfQuorum(&c) //其他节点的 Commit 可能先于本节点的 Commit 到达
 	}
 }
 
-func (p *PbftConsensusNode) handleCommit(content []byte) { //handleCommit函数用于处理Commit消息。它需要一个参数： content（类型为[]字节）：这是一个字节切片，包含Commit消息。
+func (p *PbftConsensusNode) handleCommit(content []byte, signed []byte) { //handleCommit函数用于处理Commit消息。它需要两个参数： content（类型为[]字节）：这是一个字节切片，包含Commit消息。 signed（类型为[]字节）：带签名的原始消息，用于构造提交证书。
 	// decode the message
 	cmsg := new(message.Commit)
 	err := json.Unmarshal(content, cmsg)
 	if err != nil {
 		log.Panic(err)
 	}
+	if cmsg.SeqID <= p.getStableCheckpoint() { //该序列已经在稳定检查点之前，记录已被清除
+		return
+	}
 	p.pl.Plog.Printf("S%dN%d received the Commit from ...%d\n", p.ShardID, p.NodeID, cmsg.SenderNode.NodeID)
 	p.set2DMap(false, string(cmsg.Digest), cmsg.SenderNode)
-	cnt := 0
-	for range p.cntCommitConfirm[string(cmsg.Digest)] {
-		cnt++
-	}
 
 	p.lock.Lock()
 	defer p.lock.Unlock()
+	p.recordCommit(cmsg, signed)
+	p.commitIfQuorum(cmsg)
+}
+
+// 收到足够的 Commit 之后提交请求，调用者持有 p.lock
+func (p *PbftConsensusNode) commitIfQuorum(cmsg *message.Commit) {
+	cnt := len(p.cntCommitConfirm[string(cmsg.Digest)])
 	// the main node will not send the prepare message
 	required_cnt := int(2 * p.malicious_nums)
+	if !p.isCommitBordcast[string(cmsg.Digest)] { //本节点没有发出 Commit，需要其他 2f+1 个节点的 Commit
+		required_cnt += 1
+	}
 	if cnt >= required_cnt && !p.isReply[string(cmsg.Digest)] {
 		p.pl.Plog.Printf("S%dN%d : has received 2f + 1 commits ... \n", p.ShardID, p.NodeID)
+		p.lastCommitTime = time.Now()
+		p.isReply[string(cmsg.Digest)] = true
 		// if this node is left behind, so it need to requst blocks
-		if _, ok := p.requestPool[string(cmsg.Digest)]; !ok {
-			p.isReply[string(cmsg.Digest)] = true
-			p.askForLock.Lock()
-			// request the block
-			sn := &shard.Node{
-				NodeID:  p.view,
-				ShardID: p.ShardID,
-				IPaddr:  p.ip_nodeTable[p.ShardID][p.view],
-			}
-			orequest := message.RequestOldMessage{
-				SeqStartHeight: p.sequenceID + 1,
-				SeqEndHeight:   cmsg.SeqID,
-				ServerNode:     sn,
-				SenderNode:     p.RunningNode,
-			}
-			bromyte, err := json.Marshal(orequest)
-			if err != nil {
-				log.Panic()
+		_, ok := p.requestPool[string(cmsg.Digest)]
+		switch {
+		case cmsg.SeqID < p.sequenceID: //该序列已经通过追赶提交
+		case !ok || cmsg.SeqID > p.sequenceID: //没有该请求，或者还没有提交之前的序列
+			p.requestOldSeq(cmsg.SeqID)
+		case !p.ihm.HandleinCommit(cmsg): // implement interface
+			// 区块无法上链，不推进序列，也不中继交易，从主节点追赶该序列
+			p.requestOldSeq(cmsg.SeqID)
+		default:
+			if params.HeaderSync && p.isLeader() {
+				p.broadcastHeader(cmsg)
 			}
-
-			p.pl.Plog.Printf("S%dN%d : is now requesting message (seq %d to %d) ... \n", p.ShardID, p.NodeID, orequest.SeqStartHeight, orequest.SeqEndHeight)
-			msg_send := message.MergeMessage(message.CRequestOldrequest, bromyte)
-			networks.TcpDial(msg_send, orequest.ServerNode.IPaddr)
-		} else {
-			// implement interface
-			p.ihm.HandleinCommit(cmsg)
-			p.isReply[string(cmsg.Digest)] = true
 			p.pl.Plog.Printf("S%dN%d: this round of pbft %d is end \n", p.ShardID, p.NodeID, p.sequenceID)
 			p.sequenceID += 1
+			p.reportCommit(p.sequenceID-1, string(cmsg.Digest))
+			p.makeCheckpoint(p.sequenceID - 1)
 		}
 
 		// if this node is a main node, then unlock the sequencelock
-		if p.NodeID == p.view {
+		if p.isLeader() && p.takeProposingDigest(string(cmsg.Digest)) {
 			p.sequenceLock.Unlock()
 			p.pl.Plog.Printf("S%dN%d get sequenceLock unlocked...\n", p.ShardID, p.NodeID)
 		}
 	}
 }
 
+// 向主节点请求从本节点下一个要提交的序列到 endSeq 的请求。调用者持有 p.lock，不能等待 askForLock：
+// 已经在请求时直接返回，由之后的提交消息再次触发请求；之前的请求超过 ViewChangeTimeOut 没有回复时（主节点崩溃或者沉默），向当前的主节点重新请求
+func (p *PbftConsensusNode) requestOldSeq(endSeq uint64) {
+	if !p.askForLock.TryLock() && time.Since(time.Unix(0, p.askForTime.Load())) < time.Duration(params.ViewChangeTimeOut)*time.Millisecond {
+		return
+	}
+	p.askForTime.Store(time.Now().UnixNano())
+	// request the block
+	sn := &shard.Node{
+		NodeID:  p.getLeaderID(),
+		ShardID: p.ShardID,
+		IPaddr:  p.getLeaderIP(p.ShardID),
+	}
+	orequest := message.RequestOldMessage{
+		SeqStartHeight: p.sequenceID, //本节点下一个要提交的序列
+		SeqEndHeight:   endSeq,
+		ServerNode:     sn,
+		SenderNode:     p.RunningNode,
+	}
+	bromyte, err := json.Marshal(orequest)
+	if err != nil {
+		log.Panic()
+	}
+
+	p.pl.Plog.Printf("S%dN%d : is now requesting message (seq %d to %d) ... \n", p.ShardID, p.NodeID, orequest.SeqStartHeight, orequest.SeqEndHeight)
+	msg_send := p.signMessage(message.CRequestOldrequest, bromyte)
+	p.sendMessage(msg_send, orequest.ServerNode.IPaddr)
+}
+
 // 该函数仅由主节点调用
 // 如果请求正确，主节点会将块发送回消息发送者。
 // 现在这个函数可以发送块和分区
 // RequestOldMessage 可能用于协议或应用中的某些需求，以请求旧消息数据，通常由节点之间进行通信以满足某些需要。
 // 消息的具体内容和用途通常取决于协议或应用的设计。根据上面提供的代码片段，这些消息通常由主节点用于向其他节点请求旧消息。
 func (p *PbftConsensusNode) handleRequestOldSeq(content []byte) { //handleRequestOldSeq函数用于处理RequestOldMessage消息。它需要一个参数： content（类型为[]字节）：这是一个字节切片，包含RequestOldMessage消息。
-	//1.检查当前节点是否为主节点
-	if p.view != p.NodeID { //如果节点不是主节点，则清空消息内容 content 并返回。在PBFT中，只有主节点负责提议新区块
-		content = make([]byte, 0)
-		return
-	}
-
-	//2.解析消息内容
+	//1.解析消息内容
 	rom := new(message.RequestOldMessage)
 	err := json.Unmarshal(content, rom) //使用json.Unmarshal函数将RequestOldMessage消息解组为rom。它需要两个参数： content（类型为[]字节）：这是一个字节切片，包含RequestOldMessage消息。 rom（类型为*message.RequestOldMessage）：这是一个指向message.RequestOldMessage结构的指针，用于存储解组的消息。
 	if err != nil {
 		log.Panic()
 	}
 
+	//2.检查当前节点是否为被请求的节点（通常是主节点，视图切换时新主节点也会向其他节点请求区块）
+	if rom.ServerNode == nil || rom.ServerNode.NodeID != p.NodeID {
+		return
+	}
+
 	//3.记录消息接收情况
 	p.pl.Plog.Printf("S%dN%d : received the old message requst from ...", p.ShardID, p.NodeID) //打印一条日志消息，包括分片ID、节点ID和发送消息的节点信息，指示节点已收到来自rom.SenderNode的旧消息请求。
 	rom.SenderNode.PrintNode()                                                                 //打印rom.SenderNode的信息
 
 	//4.处理旧消息请求
-	oldR := make([]*message.Request, 0)                                      //创建一个新的message.Request结构的切片oldR。它将用于存储旧消息。
-	for height := rom.SeqStartHeight; height <= rom.SeqEndHeight; height++ { //使用for循环遍历rom.SeqStartHeight到rom.SeqEndHeight之间的所有高度。在每次迭代中，将当前高度的消息添加到oldR中。
+	oldR := make([]*message.Request, 0) //创建一个新的message.Request结构的切片oldR。它将用于存储旧消息。
+	// 稳定检查点之前的请求已经被清除，从区块中恢复
+	stableReqs := make(map[uint64]*message.Request)
+	if stable := p.getStableCheckpoint(); rom.SeqStartHeight <= stable {
+		stableReqs = p.getStableRequests(rom.SeqStartHeight, stable)
+	}
+	end := rom.SeqEndHeight
+	if end >= p.sequenceID { //只发送本节点已经提交的请求，未提交的请求可能不是最终提交的请求
+		end = p.sequenceID - 1
+	}
+	for height := rom.SeqStartHeight; height <= end; height++ { //使用for循环遍历rom.SeqStartHeight到rom.SeqEndHeight之间的所有高度。在每次迭代中，将当前高度的消息添加到oldR中。
+		if r, ok := stableReqs[height]; ok {
+			oldR = append(oldR, r)
+			continue
+		}
 		if _, ok := p.height2Digest[height]; !ok { //对于每个高度，检查 p.height2Digest 中是否存在与该高度对应的摘要，如果不存在，则记录错误日志并中断循环
 			p.pl.Plog.Printf("S%dN%d : has no this digest to this height %d\n", p.ShardID, p.NodeID, height)
 			break
@@ -247,7 +336,7 @@ func (p *PbftConsensusNode) handleRequestOldSeq(content []byte) { //handleReques
 	// send the block back
 	sb := message.SendOldMessage{ //创建一个 SendOldMessage 结构，包括开始和结束高度，以及旧请求消息 oldR 和发送消息的节点信息
 		SeqStartHeight: rom.SeqStartHeight,
-		SeqEndHeight:   rom.SeqEndHeight,
+		SeqEndHeight:   rom.SeqStartHeight + uint64(len(oldR)) - 1, //只包含实际发送的请求，请求者之后再追赶其余的序列
 		OldRequest:     oldR,
 		SenderNode:     p.RunningNode,
 	}
@@ -255,9 +344,9 @@ func (p *PbftConsensusNode) handleRequestOldSeq(content []byte) { //handleReques
 	if err != nil {
 		log.Panic()
 	}
-	msg_send := message.MergeMessage(message.CSendOldrequest, sbByte) //使用 message.MergeMessage 函数将消息类型和内容合并为字节切片 msg_send
-	networks.TcpDial(msg_send, rom.SenderNode.IPaddr)                 //使用 networks.TcpDial 函数将消息发送回 rom.SenderNode。它需要两个参数： msg_send：这是一个字节切片，包含要发送的消息。 rom.SenderNode.IPaddr：这是一个字符串，表示消息的发送者节点的IP地址。。
-	p.pl.Plog.Printf("S%dN%d : send blocks\n", p.ShardID, p.NodeID)   //记录消息发送的日志，包括分片ID和节点ID
+	msg_send := p.signMessage(message.CSendOldrequest, sbByte)      //使用 signMessage 函数对消息签名，并将消息类型和内容合并为字节切片 msg_send
+	p.sendMessage(msg_send, rom.SenderNode.IPaddr)                  //使用 p.sendMessage 函数将消息发送回 rom.SenderNode。它需要两个参数： msg_send：这是一个字节切片，包含要发送的消息。 rom.SenderNode.IPaddr：这是一个字符串，表示消息的发送者节点的IP地址。。
+	p.pl.Plog.Printf("S%dN%d : send blocks\n", p.ShardID, p.NodeID) //记录消息发送的日志，包括分片ID和节点ID
 }
 
 // 节点向主节点请求区块并接收区块
@@ -270,12 +359,16 @@ func (p *PbftConsensusNode) handleSendOldSeq(content []byte) { //handleSendOldSe
 	p.pl.Plog.Printf("S%dN%d : has received the SendOldMessage message\n", p.ShardID, p.NodeID) //打印一条日志消息，指示节点已收到SendOldMessage消息。
 
 	// 实现新共识的接口
-	p.ihm.HandleforSequentialRequest(som) //使用HandleforSequentialRequest函数处理SendOldMessage消息。它需要一个参数： som（类型为*message.SendOldMessage）：这是一个指向message.SendOldMessage结构的指针，包含SendOldMessage消息。
-	beginSeq := som.SeqStartHeight        //使用 som.SeqStartHeight 作为开始序列
-	for idx, r := range som.OldRequest {  //使用for循环遍历 som.OldRequest 中的所有请求消息。在每次迭代中，将请求消息添加到请求池中，并将高度到摘要的映射添加到高度到摘要的映射中。这样，可以使用高度来检索摘要。
+	if !p.ihm.HandleforSequentialRequest(som) { //区块验证失败时停止追赶，等待下一次请求
+		p.releaseAskFor()
+		return
+	} //使用HandleforSequentialRequest函数处理SendOldMessage消息。它需要一个参数： som（类型为*message.SendOldMessage）：这是一个指向message.SendOldMessage结构的指针，包含SendOldMessage消息。
+	beginSeq := som.SeqStartHeight       //使用 som.SeqStartHeight 作为开始序列
+	for idx, r := range som.OldRequest { //使用for循环遍历 som.OldRequest 中的所有请求消息。在每次迭代中，将请求消息添加到请求池中，并将高度到摘要的映射添加到高度到摘要的映射中。这样，可以使用高度来检索摘要。
 		p.requestPool[string(getDigest(r))] = r
 		p.height2Digest[uint64(idx)+beginSeq] = string(getDigest(r))
 		p.isReply[string(getDigest(r))] = true
+		p.reportCommit(uint64(idx)+beginSeq, string(getDigest(r)))
 		p.pl.Plog.Printf("this round of pbft %d is end \n", uint64(idx)+beginSeq)
 	}
 	p.sequenceID = som.SeqEndHeight + 1                     //使用 som.SeqEndHeight 作为下一个序列ID
@@ -299,13 +392,28 @@ func (p *PbftConsensusNode) handleSendOldSeq(content []byte) { //handleSendOldSe
 					log.Panic()
 				}
 				// broadcast
-				msg_send := message.MergeMessage(message.CPrepare, prepareByte)
-				networks.Broadcast(p.RunningNode.IPaddr, p.getNeighborNodes(), msg_send)
+				msg_send := p.signMessage(message.CPrepare, prepareByte)
+				p.broadcast(p.getNeighborNodes(), msg_send)
 				p.pl.Plog.Printf("S%dN%d : has broadcast the prepare message \n", p.ShardID, p.NodeID)
 			}
 		}
 	}
 
+	p.lastCommitTime = time.Now()
+	p.makeCheckpoint((p.sequenceID - 1) / uint64(params.CheckpointPeriod) * uint64(params.CheckpointPeriod))
+	p.releaseAskFor()
+
+	// 新主节点已经追上了区块，可以发出新视图消息
+	if p.pendingNewView != 0 {
+		view := p.pendingNewView
+		p.pendingNewView = 0
+		p.prepareNewView(view)
+	}
+}
+
+// 收到请求的区块之后释放 askForLock。重新请求之后可能收到两次回复，这时 askForLock 已经被释放
+func (p *PbftConsensusNode) releaseAskFor() {
+	p.askForLock.TryLock()
 	p.askForLock.Unlock()
 }
 
@@ -11,11 +11,14 @@ import (
 	"blockEmulator/params"
 	"blockEmulator/shard"
 	"bufio"
+	"crypto/ed25519"
 	"io"
 	"log"
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -33,27 +36,49 @@ type PbftConsensusNode struct { //PbftConsensusNode结构包含用于PBFT共识
 
 	// pbft的全局配置
 	pbftChainConfig *params.ChainConfig          //pbft 中的链配置
+	priKey          ed25519.PrivateKey           //本节点的私钥，用于对发送的共识消息和跨分片消息签名
 	ip_nodeTable    map[uint64]map[uint64]string //表示特定节点的ip地址映射，其中键是uint64值，并且每个键都映射到另一个映射。内部映射又使用uint64键来映射到string值
 	node_nums       uint64                       //该PBFT实例中的节点数量，记为N
 	malicious_nums  uint64                       //恶意节点的数量（f），其中3f + 1 = N
 	view            uint64                       //表示当前视图的ID
 
 	//pbft中的控制消息和消息检查实用程序
-	sequenceID        uint64                          //表示PBFT的消息序列ID
-	stop              bool                            //表示共识停止的布尔标志
-	pStop             chan uint64                     //表示共识停止的通道
-	requestPool       map[string]*message.Request     //表示请求池，其中键是字符串，值是指向Request结构的指针
-	cntPrepareConfirm map[string]map[*shard.Node]bool //用于统计准备确认消息的数量，其中键是字符串，值是指向shard.Node结构的指针的映射
-	cntCommitConfirm  map[string]map[*shard.Node]bool //用于统计提交确认消息的数量，其中键是字符串，值是指向shard.Node结构的指针的映射
-	isCommitBordcast  map[string]bool                 //表示是否已经广播提交消息，其中键是字符串
-	isReply           map[string]bool                 //指示消息是否是回复的映射。其中键是字符串，值是布尔值
-	height2Digest     map[uint64]string               //表示消息高度到消息摘要的映射，其中键是uint64值，值是字符串
+	sequenceID        uint64                      //表示PBFT的消息序列ID
+	stop              bool                        //表示共识停止的布尔标志
+	pStop             chan uint64                 //表示共识停止的通道
+	requestPool       map[string]*message.Request //表示请求池，其中键是字符串，值是指向Request结构的指针
+	cntPrepareConfirm map[string]map[uint64]bool  //用于统计准备确认消息的数量，其中键是摘要，值是已经验证签名的发送者节点ID的集合
+	cntCommitConfirm  map[string]map[uint64]bool  //用于统计提交确认消息的数量，其中键是摘要，值是已经验证签名的发送者节点ID的集合
+	isCommitBordcast  map[string]bool             //表示是否已经广播提交消息，其中键是字符串
+	isReply           map[string]bool             //指示消息是否是回复的映射。其中键是字符串，值是布尔值
+	height2Digest     map[uint64]string           //表示消息高度到消息摘要的映射，其中键是uint64值，值是字符串
+
+	// 提交证书，源分片把它附在中继消息中，证明区块已经被本分片提交
+	commitCerts map[string]map[uint64][]byte //收到的带签名的提交消息，键为摘要和发送者节点ID
 
 	//关于 pbft 的锁
-	sequenceLock sync.Mutex //锁定序列ID
-	lock         sync.Mutex //锁定共识
-	askForLock   sync.Mutex //锁定请求
-	stopLock     sync.Mutex //锁定停止
+	sequenceLock sync.Mutex   //锁定序列ID
+	lock         sync.Mutex   //锁定共识
+	askForLock   sync.Mutex   //锁定请求
+	askForTime   atomic.Int64 //持有 askForLock 的请求发出的时间（UnixNano）
+	stopLock     sync.Mutex   //锁定停止
+	ckptLock     sync.Mutex   //锁定稳定检查点
+
+	// 视图切换
+	viewLock        sync.Mutex                              //锁定视图以及主节点的提议状态
+	viewChanging    bool                                    //表示节点是否正在进行视图切换
+	targetView      uint64                                  //本节点已经投票要切换到的最大视图ID
+	lastCommitTime  time.Time                               //最近一次提交区块的时间，用于判断主节点是否失效
+	viewChangeVotes map[uint64]map[uint64]*signedViewChange //收到的视图切换消息，键为视图ID和发送者的节点ID
+	pendingNewView  uint64                                  //新主节点追赶区块后需要发出的新视图ID，0 表示没有
+	proposing       bool                                    //表示 Propose 协程是否正在运行
+	proposingDigest string                                  //主节点持有 sequenceLock 并等待提交的提案摘要
+	newViewMsg      []byte                                  //本节点作为新主节点发出的新视图消息，发给落后的节点
+
+	// 检查点与垃圾回收
+	stableCheckpoint uint64                                //最新的稳定检查点序列ID，即低水位线
+	checkpointVotes  map[uint64]map[string]map[uint64]bool //检查点投票，键为序列ID、状态树根和发送者节点ID
+	retainedRequests map[uint64]*message.Request           //稳定检查点之前的非区块请求（如账户迁移请求），无法从区块中恢复，因此单独保存
 
 	//其他Shards的seqID，用于同步
 	seqIDMap   map[uint64]uint64 //用于与其他分片同步序列ID的映射。
@@ -70,6 +95,15 @@ type PbftConsensusNode struct { //PbftConsensusNode结构包含用于PBFT共识
 
 	// 处理pbft外部的消息
 	ohm PbftOutsideHandleMod //用于处理pbft外部消息的接口
+
+	// 拜占庭故障注入
+	fbm FaultBehavior //本节点的拜占庭行为，诚实节点为空
+
+	// 实验场景控制
+	ctrlLock   sync.Mutex      //锁定场景控制的状态
+	crashed    bool            //节点是否处于崩溃状态
+	isolateAll bool            //是否与所有节点隔离（主管节点除外）
+	isolated   map[string]bool //被隔离的节点地址
 }
 
 // 为节点生成 pbft 共识
@@ -99,17 +133,26 @@ func NewPbftNode(shardID, nodeID uint64, pcc *params.ChainConfig, messageHandleT
 		IPaddr:  p.ip_nodeTable[shardID][nodeID],
 	}
 
+	p.priKey = shard.LoadOrGenerateNodeKey(shardID, nodeID) //读取或生成本节点的密钥对
+
 	p.stop = false //将stop字段设置为false
 	p.sequenceID = p.CurChain.CurrentBlock.Header.Number + 1
 	p.pStop = make(chan uint64)
 	p.requestPool = make(map[string]*message.Request)
-	p.cntPrepareConfirm = make(map[string]map[*shard.Node]bool)
-	p.cntCommitConfirm = make(map[string]map[*shard.Node]bool)
+	p.cntPrepareConfirm = make(map[string]map[uint64]bool)
+	p.cntCommitConfirm = make(map[string]map[uint64]bool)
+	p.commitCerts = make(map[string]map[uint64][]byte)
 	p.isCommitBordcast = make(map[string]bool)
 	p.isReply = make(map[string]bool)
 	p.height2Digest = make(map[uint64]string)
 	p.malicious_nums = (p.node_nums - 1) / 3
 	p.view = 0
+	p.lastCommitTime = time.Now()
+	p.viewChangeVotes = make(map[uint64]map[uint64]*signedViewChange)
+	p.stableCheckpoint = p.sequenceID - 1
+	p.checkpointVotes = make(map[uint64]map[string]map[uint64]bool)
+	p.retainedRequests = make(map[uint64]*message.Request)
+	p.isolated = make(map[string]bool)
 
 	p.seqIDMap = make(map[uint64]uint64)
 
@@ -144,6 +187,13 @@ func NewPbftNode(shardID, nodeID uint64, pcc *params.ChainConfig, messageHandleT
 		p.ohm = &RawBrokerOutsideModule{
 			pbftNode: p,
 		}
+	case "2PC": //跨分片交易使用两阶段提交，注入交易的处理与中继相同
+		p.ihm = &TwoPCPbftExtraHandleMod{
+			RawRelayPbftExtraHandleMod{pbftNode: p},
+		}
+		p.ohm = &RawRelayOutsideModule{
+			pbftNode: p,
+		}
 	default:
 		p.ihm = &RawRelayPbftExtraHandleMod{ //使用&运算符创建一个指向RawRelayPbftExtraHandleMod结构的指针。它需要一个参数： p（类型为*PbftConsensusNode）：这是一个指向PbftConsensusNode结构的指针。
 			pbftNode: p,
@@ -159,6 +209,30 @@ func NewPbftNode(shardID, nodeID uint64, pcc *params.ChainConfig, messageHandleT
 // 处理原始消息，将其发送到相应的接口
 func (p *PbftConsensusNode) handleMessage(msg []byte) { //handleMessage()函数用于处理原始消息。它需要一个参数： msg（类型为[]字节）：这是一个字节切片，表示原始消息。
 	msgType, content := message.SplitMessage(msg) //使用message.SplitMessage()函数将原始消息拆分为消息类型和内容。它需要一个参数： msg（类型为[]字节）：这是一个字节切片，表示原始消息。它返回两个值： msgType（类型为message.MessageType）：这是一个枚举类型，表示消息类型。 content（类型为[]字节）：这是一个字节切片，表示消息内容。
+	//主管节点发来的场景控制消息，崩溃的节点也需要处理
+	if isControlMessage(msgType) {
+		p.handleControlMessage(msgType, content)
+		return
+	}
+	//带签名的消息需要先验证签名，需要签名但没有签名的消息直接拒绝
+	var signer *shard.Node
+	var signed []byte //带签名的原始消息，提交消息被保存下来作为提交证书
+	if msgType == message.CSigned {
+		innerType, innerContent, sn, ok := message.OpenSignedMessage(content)
+		if !ok || !p.checkSigner(innerType, innerContent, sn) {
+			p.pl.Plog.Printf("S%dN%d : the signature of the %s message is invalid, refuse it\n", p.ShardID, p.NodeID, innerType)
+			return
+		}
+		signed = content
+		msgType, content, signer = innerType, innerContent, sn
+	} else if message.NeedSignature(msgType) {
+		p.pl.Plog.Printf("S%dN%d : the %s message is not signed, refuse it\n", p.ShardID, p.NodeID, msgType)
+		return
+	}
+	//崩溃的节点以及被隔离的节点丢弃收到的消息（停止消息除外）
+	if msgType != message.CStop && p.isBlockedFrom(signer) {
+		return
+	}
 	//使用一条switch语句来处理不同类型的消息
 	switch msgType {
 	//pbft 内部消息类型
@@ -167,13 +241,23 @@ func (p *PbftConsensusNode) handleMessage(msg []byte) { //handleMessage()函数
 	case message.CPrepare:
 		p.handlePrepare(content)
 	case message.CCommit:
-		p.handleCommit(content)
+		p.handleCommit(content, signed)
 	case message.CRequestOldrequest:
 		p.handleRequestOldSeq(content)
 	case message.CSendOldrequest:
 		p.handleSendOldSeq(content)
+	case message.CCheckpoint:
+		p.handleCheckpoint(content)
+	case message.CViewChange:
+		p.handleViewChange(content, signed)
+	case message.CNewView:
+		p.handleNewView(content)
+	case message.CLeaderInfo:
+		p.handleLeaderInfo(content)
 	case message.CStop:
 		p.WaitToStop()
+	case message.CHeaderSync:
+		p.handleHeaderSync(content)
 
 	//处理来自外部的消息
 	default:
@@ -187,7 +271,7 @@ func (p *PbftConsensusNode) handleClientRequest(con net.Conn) { //handleClientRe
 	defer con.Close()
 	clientReader := bufio.NewReader(con)
 	for {
-		clientRequest, err := clientReader.ReadBytes('\n')
+		clientRequest, err := networks.ReadFrame(clientReader)
 		if p.getStopSignal() {
 			return
 		}
@@ -207,7 +291,7 @@ func (p *PbftConsensusNode) handleClientRequest(con net.Conn) { //handleClientRe
 }
 
 func (p *PbftConsensusNode) TcpListen() { //TcpListen()函数用于监听TCP连接。它需要一个参数： p（类型为*PbftConsensusNode）：这是一个指向PbftConsensusNode结构的指针。
-	ln, err := net.Listen("tcp", p.RunningNode.IPaddr)
+	ln, err := networks.Listen(p.RunningNode.IPaddr) //单进程模式下使用内存网络
 	p.tcpln = ln
 	if err != nil {
 		log.Panic(err)
@@ -259,7 +343,7 @@ func (p *PbftConsensusNode) WaitToStop() { //WaitToStop()函数用于等待共
 	p.stopLock.Lock()
 	p.stop = true
 	p.stopLock.Unlock()
-	if p.NodeID == p.view {
+	if p.isProposing() {
 		p.pStop <- 1
 	}
 	networks.CloseAllConnInPool()
@@ -4,7 +4,6 @@ import (
 	"blockEmulator/consensus_shard/pbft_all/dataSupport"
 	"blockEmulator/core"
 	"blockEmulator/message"
-	"blockEmulator/networks"
 	"blockEmulator/params"
 	"encoding/json"
 	"fmt"
@@ -38,7 +37,7 @@ func (cphm *CLPAPbftInsideExtraHandleMod_forBroker) HandleinPropose() (bool, *me
 	block := cphm.pbftNode.CurChain.GenerateBlock()
 	r := &message.Request{
 		RequestType: message.BlockRequest,
-		ReqTime:     time.Now(),
+		ReqTime:     block.Header.Time, //与区块时间相同，使请求可以从区块中恢复
 	}
 	r.Msg.Content = block.Encode()
 	return true, r
@@ -55,8 +54,8 @@ func (cphm *CLPAPbftInsideExtraHandleMod_forBroker) HandleinPrePrepare(ppmsg *me
 		cphm.pbftNode.pl.Plog.Printf("S%dN%d : a partition block\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID)
 	} else {
 		// the request is a block
-		if cphm.pbftNode.CurChain.IsValidBlock(core.DecodeB(ppmsg.RequestMsg.Msg.Content)) != nil {
-			cphm.pbftNode.pl.Plog.Printf("S%dN%d : not a valid block\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID)
+		if err := cphm.pbftNode.CurChain.IsValidBlock(core.DecodeB(ppmsg.RequestMsg.Msg.Content)); err != nil {
+			cphm.pbftNode.pl.Plog.Printf("S%dN%d : not a valid block, refuse to prepare: %v\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, err)
 			return false
 		}
 	}
@@ -85,12 +84,15 @@ func (cphm *CLPAPbftInsideExtraHandleMod_forBroker) HandleinCommit(cmsg *message
 	// if a block request ...
 	block := core.DecodeB(r.Msg.Content)
 	cphm.pbftNode.pl.Plog.Printf("S%dN%d : adding the block %d...now height = %d \n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, block.Header.Number, cphm.pbftNode.CurChain.CurrentBlock.Header.Number)
-	cphm.pbftNode.CurChain.AddBlock(block)
+	if err := cphm.pbftNode.CurChain.AddBlock(block); err != nil { //将区块添加到区块链中
+		cphm.pbftNode.pl.Plog.Printf("S%dN%d : cannot add the block %d: %v\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, block.Header.Number, err)
+		return false //区块没有上链，不能中继交易，由调用者发起追赶
+	}
 	cphm.pbftNode.pl.Plog.Printf("S%dN%d : added the block %d... \n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, block.Header.Number)
 	cphm.pbftNode.CurChain.PrintBlockChain()
 
 	// 现在尝试将 txs 中继到其他分片（对于主节点）
-	if cphm.pbftNode.NodeID == cphm.pbftNode.view {
+	if cphm.pbftNode.isLeader() {
 		cphm.pbftNode.pl.Plog.Printf("S%dN%d : main node is trying to send broker confirm txs at height = %d \n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, block.Header.Number)
 		// generate brokertxs and collect txs excuted
 		txExcuted := make([]*core.Transaction, 0)
@@ -146,8 +148,8 @@ func (cphm *CLPAPbftInsideExtraHandleMod_forBroker) HandleinCommit(cmsg *message
 			if err != nil {
 				log.Panic()
 			}
-			msg_send := message.MergeMessage(message.CSeqIDinfo, sByte)
-			networks.TcpDial(msg_send, cphm.pbftNode.ip_nodeTable[sid][0])
+			msg_send := cphm.pbftNode.signMessage(message.CSeqIDinfo, sByte)
+			cphm.pbftNode.sendMessage(msg_send, cphm.pbftNode.getLeaderIP(sid))
 			cphm.pbftNode.pl.Plog.Printf("S%dN%d : sended sequence ids to %d\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, sid)
 		}
 		// send txs excuted in this block to the listener
@@ -164,12 +166,13 @@ func (cphm *CLPAPbftInsideExtraHandleMod_forBroker) HandleinCommit(cmsg *message
 			ProposeTime:     r.ReqTime,
 			CommitTime:      time.Now(),
 		}
+		cphm.pbftNode.fillTxPoolStats(&bim)
 		bByte, err := json.Marshal(bim)
 		if err != nil {
 			log.Panic()
 		}
-		msg_send := message.MergeMessage(message.CBlockInfo, bByte)
-		networks.TcpDial(msg_send, cphm.pbftNode.ip_nodeTable[params.DeciderShard][0])
+		msg_send := cphm.pbftNode.signMessage(message.CBlockInfo, bByte)
+		cphm.pbftNode.sendMessage(msg_send, cphm.pbftNode.ip_nodeTable[params.DeciderShard][0])
 		cphm.pbftNode.pl.Plog.Printf("S%dN%d : sended excuted txs\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID)
 		cphm.pbftNode.CurChain.Txpool.GetLocked()
 		cphm.pbftNode.writeCSVline([]string{strconv.Itoa(len(cphm.pbftNode.CurChain.Txpool.TxQueue)), strconv.Itoa(len(txExcuted)), strconv.Itoa(int(bim.Relay1TxNum))})
@@ -192,7 +195,11 @@ func (cphm *CLPAPbftInsideExtraHandleMod_forBroker) HandleforSequentialRequest(s
 			r := som.OldRequest[height-som.SeqStartHeight]
 			if r.RequestType == message.BlockRequest {
 				b := core.DecodeB(r.Msg.Content)
-				cphm.pbftNode.CurChain.AddBlock(b)
+				if err := cphm.pbftNode.CurChain.AddBlock(b); err != nil { //添加区块失败时，从该高度开始重新追赶
+					cphm.pbftNode.pl.Plog.Printf("S%dN%d : cannot add the old block %d: %v\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, height, err)
+					cphm.pbftNode.sequenceID = height
+					return false
+				}
 			} else {
 				atm := message.DecodeAccountTransferMsg(r.Msg.Content)
 				cphm.accountTransfer_do(atm)
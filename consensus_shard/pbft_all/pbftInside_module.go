@@ -4,7 +4,6 @@ package pbft_all
 import (
 	"blockEmulator/core"
 	"blockEmulator/message"
-	"blockEmulator/networks"
 	"blockEmulator/params"
 	"encoding/json"
 	"fmt"
@@ -25,7 +24,7 @@ func (rphm *RawRelayPbftExtraHandleMod) HandleinPropose() (bool, *message.Reques
 	block := rphm.pbftNode.CurChain.GenerateBlock() //生成区块
 	r := &message.Request{                          //创建一个新的请求
 		RequestType: message.BlockRequest, //请求类型
-		ReqTime:     time.Now(),           //请求时间
+		ReqTime:     block.Header.Time,    //请求时间与区块时间相同，稳定检查点之前的请求可以从区块中恢复出相同的摘要
 	}
 	r.Msg.Content = block.Encode() //将区块编码后的内容设置为请求的内容
 
@@ -34,8 +33,8 @@ func (rphm *RawRelayPbftExtraHandleMod) HandleinPropose() (bool, *message.Reques
 
 // preprepare中的diy操作
 func (rphm *RawRelayPbftExtraHandleMod) HandleinPrePrepare(ppmsg *message.PrePrepare) bool { //HandleinPrePrepare方法用于处理预准备消息
-	if rphm.pbftNode.CurChain.IsValidBlock(core.DecodeB(ppmsg.RequestMsg.Msg.Content)) != nil { //如果区块不合法
-		rphm.pbftNode.pl.Plog.Printf("S%dN%d : not a valid block\n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID)
+	if err := rphm.pbftNode.CurChain.IsValidBlock(core.DecodeB(ppmsg.RequestMsg.Msg.Content)); err != nil { //如果区块不合法
+		rphm.pbftNode.pl.Plog.Printf("S%dN%d : not a valid block, refuse to prepare: %v\n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID, err)
 		return false
 	}
 	rphm.pbftNode.pl.Plog.Printf("S%dN%d : the pre-prepare message is correct, putting it into the RequestPool. \n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID) //打印日志
@@ -56,13 +55,20 @@ func (rphm *RawRelayPbftExtraHandleMod) HandleinCommit(cmsg *message.Commit) boo
 	// 请求类型 ...
 	block := core.DecodeB(r.Msg.Content)                                                                                                                                                                   //解码区块
 	rphm.pbftNode.pl.Plog.Printf("S%dN%d : adding the block %d...now height = %d \n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID, block.Header.Number, rphm.pbftNode.CurChain.CurrentBlock.Header.Number) //打印日志
-	rphm.pbftNode.CurChain.AddBlock(block)                                                                                                                                                                 //将区块添加到区块链中
-	rphm.pbftNode.pl.Plog.Printf("S%dN%d : added the block %d... \n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID, block.Header.Number)                                                                    //打印日志
-	rphm.pbftNode.CurChain.PrintBlockChain()                                                                                                                                                               //打印区块链
+	if err := rphm.pbftNode.CurChain.AddBlock(block); err != nil {                                                                                                                                         //将区块添加到区块链中
+		rphm.pbftNode.pl.Plog.Printf("S%dN%d : cannot add the block %d: %v\n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID, block.Header.Number, err)
+		return false //区块没有上链，不能中继交易，由调用者发起追赶
+	}
+	rphm.pbftNode.pl.Plog.Printf("S%dN%d : added the block %d... \n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID, block.Header.Number) //打印日志
+	rphm.pbftNode.CurChain.PrintBlockChain()                                                                                            //打印区块链
 
 	// 现在尝试将 txs 中继到其他分片（如果当前节点是主节点（大概是分片的领导者或协调者））
-	if rphm.pbftNode.NodeID == rphm.pbftNode.view { //如果是主节点
+	if rphm.pbftNode.isLeader() { //如果是主节点
 		rphm.pbftNode.pl.Plog.Printf("S%dN%d : main node is trying to send relay txs at height = %d \n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID, block.Header.Number) //打印日志，它记录在块的高度发送中继交易的尝试。
+		var rp *relayProof
+		if params.RelayProof { //在区块中的交易被标记为中继交易之前构造证明
+			rp = rphm.pbftNode.newRelayProof(cmsg, block)
+		}
 		// 生成中继池并收集执行的txs
 		//它初始化事务中继的数据结构
 		txExcuted := make([]*core.Transaction, 0)                                      //创建一个新的交易切片
@@ -91,12 +97,15 @@ func (rphm *RawRelayPbftExtraHandleMod) HandleinCommit(cmsg *message.Commit) boo
 				SenderShardID: rphm.pbftNode.ShardID,
 				SenderSeq:     rphm.pbftNode.sequenceID,
 			}
+			if rp != nil {
+				rp.attach(&relay)
+			}
 			rByte, err := json.Marshal(relay)
 			if err != nil {
 				log.Panic()
 			}
-			msg_send := message.MergeMessage(message.CRelay, rByte)
-			go networks.TcpDial(msg_send, rphm.pbftNode.ip_nodeTable[sid][0]) //通过TCP连接发送消息
+			msg_send := rphm.pbftNode.signMessage(message.CRelay, rByte)
+			go rphm.pbftNode.sendMessage(msg_send, rphm.pbftNode.getLeaderIP(sid)) //通过TCP连接发送消息
 			rphm.pbftNode.pl.Plog.Printf("S%dN%d : sended relay txs to %d\n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID, sid)
 		}
 		rphm.pbftNode.CurChain.Txpool.ClearRelayPool() //清除中继池
@@ -113,12 +122,13 @@ func (rphm *RawRelayPbftExtraHandleMod) HandleinCommit(cmsg *message.Commit) boo
 			ProposeTime:     r.ReqTime,
 			CommitTime:      time.Now(),
 		}
+		rphm.pbftNode.fillTxPoolStats(&bim)
 		bByte, err := json.Marshal(bim)
 		if err != nil {
 			log.Panic()
 		}
-		msg_send := message.MergeMessage(message.CBlockInfo, bByte)
-		go networks.TcpDial(msg_send, rphm.pbftNode.ip_nodeTable[params.DeciderShard][0])
+		msg_send := rphm.pbftNode.signMessage(message.CBlockInfo, bByte)
+		go rphm.pbftNode.sendMessage(msg_send, rphm.pbftNode.ip_nodeTable[params.DeciderShard][0])
 		rphm.pbftNode.pl.Plog.Printf("S%dN%d : sended excuted txs\n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID)
 		rphm.pbftNode.CurChain.Txpool.GetLocked()
 		rphm.pbftNode.writeCSVline([]string{strconv.Itoa(len(rphm.pbftNode.CurChain.Txpool.TxQueue)), strconv.Itoa(len(txExcuted)), strconv.Itoa(int(bim.Relay1TxNum))})
@@ -140,8 +150,12 @@ func (rphm *RawRelayPbftExtraHandleMod) HandleforSequentialRequest(som *message.
 		for height := som.SeqStartHeight; height <= som.SeqEndHeight; height++ { //遍历区块高度
 			r := som.OldRequest[height-som.SeqStartHeight] //对于范围内的每个高度，它使用当前高度和 SeqStartHeight 之间的差作为索引，从 OldRequest 切片中检索请求 r。
 			if r.RequestType == message.BlockRequest {     //如果请求类型为BlockRequest，则将区块添加到节点pbft区块链中
-				b := core.DecodeB(r.Msg.Content)   //解码区块
-				rphm.pbftNode.CurChain.AddBlock(b) //使用 AddBlock 方法将解码后的块添加到当前区块链 (rphm.pbftNode.CurChain)。
+				b := core.DecodeB(r.Msg.Content)                           //解码区块
+				if err := rphm.pbftNode.CurChain.AddBlock(b); err != nil { //添加区块失败时，从该高度开始重新追赶
+					rphm.pbftNode.pl.Plog.Printf("S%dN%d : cannot add the old block %d: %v\n", rphm.pbftNode.ShardID, rphm.pbftNode.NodeID, height, err)
+					rphm.pbftNode.sequenceID = height
+					return false
+				}
 			}
 		}
 		rphm.pbftNode.sequenceID = som.SeqEndHeight + 1
@@ -0,0 +1,105 @@
+// 两阶段提交（2PC）的附加模块
+package pbft_all
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+)
+
+// 跨分片交易使用两阶段提交，不中继交易。提出、预准备、准备和追赶旧区块的操作与中继相同
+type TwoPCPbftExtraHandleMod struct {
+	RawRelayPbftExtraHandleMod
+}
+
+// commit 中的操作：将区块添加到区块链中，主节点把区块中 2PC 交易的执行结果作为投票发给协调者（主管节点）
+func (tphm *TwoPCPbftExtraHandleMod) HandleinCommit(cmsg *message.Commit) bool {
+	r := tphm.pbftNode.requestPool[string(cmsg.Digest)]
+	block := core.DecodeB(r.Msg.Content)
+	tphm.pbftNode.pl.Plog.Printf("S%dN%d : adding the block %d...now height = %d \n", tphm.pbftNode.ShardID, tphm.pbftNode.NodeID, block.Header.Number, tphm.pbftNode.CurChain.CurrentBlock.Header.Number)
+	if err := tphm.pbftNode.CurChain.AddBlock(block); err != nil {
+		tphm.pbftNode.pl.Plog.Printf("S%dN%d : cannot add the block %d: %v\n", tphm.pbftNode.ShardID, tphm.pbftNode.NodeID, block.Header.Number, err)
+		return false //区块没有上链，不能投票，由调用者发起追赶
+	}
+	tphm.pbftNode.pl.Plog.Printf("S%dN%d : added the block %d... \n", tphm.pbftNode.ShardID, tphm.pbftNode.NodeID, block.Header.Number)
+	tphm.pbftNode.CurChain.PrintBlockChain()
+
+	if !tphm.pbftNode.isLeader() {
+		return true
+	}
+	// 片内交易和在接收者一侧提交的跨分片交易已经完全执行。提交交易在两个分片中的哈希相同，
+	// 由接收者分片同时作为 Relay1Txs 和 ExcutedTxs 报告，测量模块把它计为一笔跨分片交易
+	txExcuted := make([]*core.Transaction, 0)
+	crossTxs := make([]*core.Transaction, 0)
+	vote := message.TwoPCVote{
+		Prepared:      make([][]byte, 0),
+		Refused:       make([][]byte, 0),
+		SenderShardID: tphm.pbftNode.ShardID,
+	}
+	for _, tx := range block.Body {
+		senderIn := tphm.pbftNode.CurChain.Get_PartitionMap(tx.Sender) == tphm.pbftNode.ShardID
+		recipientIn := tphm.pbftNode.CurChain.Get_PartitionMap(tx.Recipient) == tphm.pbftNode.ShardID
+		switch tx.TwoPCPhase {
+		case core.TwoPCNone:
+			txExcuted = append(txExcuted, tx)
+		case core.TwoPCPrepare:
+			if receipt, err := tphm.pbftNode.CurChain.Storage.GetReceipt(tx.TxHash); err == nil && receipt.Status == core.ReceiptSuccess {
+				vote.Prepared = append(vote.Prepared, tx.RawTxHash)
+			} else {
+				vote.Refused = append(vote.Refused, tx.RawTxHash)
+				if err == nil && receipt.Reason == chain.ErrTwoPCLocked.Error() {
+					vote.LockConflicts++
+				}
+			}
+		case core.TwoPCCommit:
+			if recipientIn {
+				txExcuted = append(txExcuted, tx)
+				crossTxs = append(crossTxs, tx)
+			}
+			if senderIn {
+				vote.Committed++
+			}
+		case core.TwoPCAbort:
+			if senderIn {
+				vote.Aborted++
+			}
+		}
+	}
+	if len(vote.Prepared)+len(vote.Refused)+vote.Committed+vote.Aborted > 0 {
+		vByte, err := json.Marshal(vote)
+		if err != nil {
+			log.Panic(err)
+		}
+		msg_send := tphm.pbftNode.signMessage(message.CTwoPCVote, vByte)
+		go tphm.pbftNode.sendMessage(msg_send, tphm.pbftNode.ip_nodeTable[params.DeciderShard][0])
+		tphm.pbftNode.pl.Plog.Printf("S%dN%d : sended 2PC votes, prepared: %d, refused: %d (lock conflicts: %d)\n", tphm.pbftNode.ShardID, tphm.pbftNode.NodeID, len(vote.Prepared), len(vote.Refused), vote.LockConflicts)
+	}
+
+	bim := message.BlockInfoMsg{
+		BlockBodyLength: len(block.Body),
+		ExcutedTxs:      txExcuted,
+		Epoch:           0,
+		Relay1Txs:       crossTxs,
+		Relay1TxNum:     uint64(len(crossTxs)),
+		SenderShardID:   tphm.pbftNode.ShardID,
+		ProposeTime:     r.ReqTime,
+		CommitTime:      time.Now(),
+	}
+	tphm.pbftNode.fillTxPoolStats(&bim)
+	bByte, err := json.Marshal(bim)
+	if err != nil {
+		log.Panic()
+	}
+	msg_send := tphm.pbftNode.signMessage(message.CBlockInfo, bByte)
+	go tphm.pbftNode.sendMessage(msg_send, tphm.pbftNode.ip_nodeTable[params.DeciderShard][0])
+	tphm.pbftNode.pl.Plog.Printf("S%dN%d : sended excuted txs\n", tphm.pbftNode.ShardID, tphm.pbftNode.NodeID)
+	tphm.pbftNode.CurChain.Txpool.GetLocked()
+	tphm.pbftNode.writeCSVline([]string{strconv.Itoa(len(tphm.pbftNode.CurChain.Txpool.TxQueue)), strconv.Itoa(len(txExcuted)), strconv.Itoa(int(bim.Relay1TxNum))})
+	tphm.pbftNode.CurChain.Txpool.GetUnlocked()
+	return true
+}
@@ -4,7 +4,6 @@ package pbft_all
 import (
 	"blockEmulator/core"
 	"blockEmulator/message"
-	"blockEmulator/networks"
 	"blockEmulator/params"
 	"encoding/json"
 	"fmt"
@@ -25,7 +24,7 @@ func (rbhm *RawBrokerPbftExtraHandleMod) HandleinPropose() (bool, *message.Reque
 	block := rbhm.pbftNode.CurChain.GenerateBlock()
 	r := &message.Request{
 		RequestType: message.BlockRequest,
-		ReqTime:     time.Now(),
+		ReqTime:     block.Header.Time, //与区块时间相同，使请求可以从区块中恢复
 	}
 	r.Msg.Content = block.Encode()
 
@@ -34,8 +33,8 @@ func (rbhm *RawBrokerPbftExtraHandleMod) HandleinPropose() (bool, *message.Reque
 
 // the diy operation in preprepare
 func (rbhm *RawBrokerPbftExtraHandleMod) HandleinPrePrepare(ppmsg *message.PrePrepare) bool {
-	if rbhm.pbftNode.CurChain.IsValidBlock(core.DecodeB(ppmsg.RequestMsg.Msg.Content)) != nil {
-		rbhm.pbftNode.pl.Plog.Printf("S%dN%d : not a valid block\n", rbhm.pbftNode.ShardID, rbhm.pbftNode.NodeID)
+	if err := rbhm.pbftNode.CurChain.IsValidBlock(core.DecodeB(ppmsg.RequestMsg.Msg.Content)); err != nil {
+		rbhm.pbftNode.pl.Plog.Printf("S%dN%d : not a valid block, refuse to prepare: %v\n", rbhm.pbftNode.ShardID, rbhm.pbftNode.NodeID, err)
 		return false
 	}
 	rbhm.pbftNode.pl.Plog.Printf("S%dN%d : the pre-prepare message is correct, putting it into the RequestPool. \n", rbhm.pbftNode.ShardID, rbhm.pbftNode.NodeID)
@@ -56,12 +55,15 @@ func (rbhm *RawBrokerPbftExtraHandleMod) HandleinCommit(cmsg *message.Commit) bo
 	// requestType ...
 	block := core.DecodeB(r.Msg.Content)
 	rbhm.pbftNode.pl.Plog.Printf("S%dN%d : adding the block %d...now height = %d \n", rbhm.pbftNode.ShardID, rbhm.pbftNode.NodeID, block.Header.Number, rbhm.pbftNode.CurChain.CurrentBlock.Header.Number)
-	rbhm.pbftNode.CurChain.AddBlock(block)
+	if err := rbhm.pbftNode.CurChain.AddBlock(block); err != nil { //将区块添加到区块链中
+		rbhm.pbftNode.pl.Plog.Printf("S%dN%d : cannot add the block %d: %v\n", rbhm.pbftNode.ShardID, rbhm.pbftNode.NodeID, block.Header.Number, err)
+		return false //区块没有上链，不能中继交易，由调用者发起追赶
+	}
 	rbhm.pbftNode.pl.Plog.Printf("S%dN%d : added the block %d... \n", rbhm.pbftNode.ShardID, rbhm.pbftNode.NodeID, block.Header.Number)
 	rbhm.pbftNode.CurChain.PrintBlockChain()
 
 	// now try to relay txs to other shards (for main nodes)
-	if rbhm.pbftNode.NodeID == rbhm.pbftNode.view {
+	if rbhm.pbftNode.isLeader() {
 		// do normal operations for block
 		rbhm.pbftNode.pl.Plog.Printf("S%dN%d : main node is trying to send relay txs at height = %d \n", rbhm.pbftNode.ShardID, rbhm.pbftNode.NodeID, block.Header.Number)
 		// generate brokertxs and collect txs excuted
@@ -95,8 +97,8 @@ func (rbhm *RawBrokerPbftExtraHandleMod) HandleinCommit(cmsg *message.Commit) bo
 			if err != nil {
 				log.Panic()
 			}
-			msg_send := message.MergeMessage(message.CSeqIDinfo, sByte)
-			go networks.TcpDial(msg_send, rbhm.pbftNode.ip_nodeTable[sid][0])
+			msg_send := rbhm.pbftNode.signMessage(message.CSeqIDinfo, sByte)
+			go rbhm.pbftNode.sendMessage(msg_send, rbhm.pbftNode.getLeaderIP(sid))
 			rbhm.pbftNode.pl.Plog.Printf("S%dN%d : sended sequence ids to %d\n", rbhm.pbftNode.ShardID, rbhm.pbftNode.NodeID, sid)
 		}
 		// send txs excuted in this block to the listener
@@ -113,12 +115,13 @@ func (rbhm *RawBrokerPbftExtraHandleMod) HandleinCommit(cmsg *message.Commit) bo
 			ProposeTime:     r.ReqTime,
 			CommitTime:      time.Now(),
 		}
+		rbhm.pbftNode.fillTxPoolStats(&bim)
 		bByte, err := json.Marshal(bim)
 		if err != nil {
 			log.Panic()
 		}
-		msg_send := message.MergeMessage(message.CBlockInfo, bByte)
-		go networks.TcpDial(msg_send, rbhm.pbftNode.ip_nodeTable[params.DeciderShard][0])
+		msg_send := rbhm.pbftNode.signMessage(message.CBlockInfo, bByte)
+		go rbhm.pbftNode.sendMessage(msg_send, rbhm.pbftNode.ip_nodeTable[params.DeciderShard][0])
 		rbhm.pbftNode.pl.Plog.Printf("S%dN%d : sended excuted txs\n", rbhm.pbftNode.ShardID, rbhm.pbftNode.NodeID)
 		rbhm.pbftNode.CurChain.Txpool.GetLocked()
 		rbhm.pbftNode.writeCSVline([]string{strconv.Itoa(len(rbhm.pbftNode.CurChain.Txpool.TxQueue)), strconv.Itoa(len(txExcuted)), strconv.Itoa(int(bim.Relay1TxNum))})
@@ -141,7 +144,11 @@ func (rbhm *RawBrokerPbftExtraHandleMod) HandleforSequentialRequest(som *message
 			r := som.OldRequest[height-som.SeqStartHeight]
 			if r.RequestType == message.BlockRequest {
 				b := core.DecodeB(r.Msg.Content)
-				rbhm.pbftNode.CurChain.AddBlock(b)
+				if err := rbhm.pbftNode.CurChain.AddBlock(b); err != nil { //添加区块失败时，从该高度开始重新追赶
+					rbhm.pbftNode.pl.Plog.Printf("S%dN%d : cannot add the old block %d: %v\n", rbhm.pbftNode.ShardID, rbhm.pbftNode.NodeID, height, err)
+					rbhm.pbftNode.sequenceID = height
+					return false
+				}
 			}
 		}
 		rbhm.pbftNode.sequenceID = som.SeqEndHeight + 1
@@ -4,7 +4,6 @@ import (
 	"blockEmulator/consensus_shard/pbft_all/dataSupport"
 	"blockEmulator/core"
 	"blockEmulator/message"
-	"blockEmulator/networks"
 	"blockEmulator/params"
 	"encoding/json"
 	"fmt"
@@ -38,7 +37,7 @@ func (cphm *CLPAPbftInsideExtraHandleMod) HandleinPropose() (bool, *message.Requ
 	block := cphm.pbftNode.CurChain.GenerateBlock()
 	r := &message.Request{
 		RequestType: message.BlockRequest,
-		ReqTime:     time.Now(),
+		ReqTime:     block.Header.Time, //与区块时间相同，使请求可以从区块中恢复
 	}
 	r.Msg.Content = block.Encode()
 	return true, r
@@ -55,8 +54,8 @@ func (cphm *CLPAPbftInsideExtraHandleMod) HandleinPrePrepare(ppmsg *message.PreP
 		cphm.pbftNode.pl.Plog.Printf("S%dN%d : a partition block\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID)
 	} else {
 		// the request is a block
-		if cphm.pbftNode.CurChain.IsValidBlock(core.DecodeB(ppmsg.RequestMsg.Msg.Content)) != nil {
-			cphm.pbftNode.pl.Plog.Printf("S%dN%d : not a valid block\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID)
+		if err := cphm.pbftNode.CurChain.IsValidBlock(core.DecodeB(ppmsg.RequestMsg.Msg.Content)); err != nil {
+			cphm.pbftNode.pl.Plog.Printf("S%dN%d : not a valid block, refuse to prepare: %v\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, err)
 			return false
 		}
 	}
@@ -85,13 +84,20 @@ func (cphm *CLPAPbftInsideExtraHandleMod) HandleinCommit(cmsg *message.Commit) b
 	// if a block request ...
 	block := core.DecodeB(r.Msg.Content)
 	cphm.pbftNode.pl.Plog.Printf("S%dN%d : adding the block %d...now height = %d \n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, block.Header.Number, cphm.pbftNode.CurChain.CurrentBlock.Header.Number)
-	cphm.pbftNode.CurChain.AddBlock(block)
+	if err := cphm.pbftNode.CurChain.AddBlock(block); err != nil { //将区块添加到区块链中
+		cphm.pbftNode.pl.Plog.Printf("S%dN%d : cannot add the block %d: %v\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, block.Header.Number, err)
+		return false //区块没有上链，不能中继交易，由调用者发起追赶
+	}
 	cphm.pbftNode.pl.Plog.Printf("S%dN%d : added the block %d... \n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, block.Header.Number)
 	cphm.pbftNode.CurChain.PrintBlockChain()
 
 	// now try to relay txs to other shards (for main nodes)
-	if cphm.pbftNode.NodeID == cphm.pbftNode.view {
+	if cphm.pbftNode.isLeader() {
 		cphm.pbftNode.pl.Plog.Printf("S%dN%d : main node is trying to send relay txs at height = %d \n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, block.Header.Number)
+		var rp *relayProof
+		if params.RelayProof { //在区块中的交易被标记为中继交易之前构造证明
+			rp = cphm.pbftNode.newRelayProof(cmsg, block)
+		}
 		// generate relay pool and collect txs excuted
 		txExcuted := make([]*core.Transaction, 0)
 		cphm.pbftNode.CurChain.Txpool.RelayPool = make(map[uint64][]*core.Transaction)
@@ -123,12 +129,15 @@ func (cphm *CLPAPbftInsideExtraHandleMod) HandleinCommit(cmsg *message.Commit) b
 				SenderShardID: cphm.pbftNode.ShardID,
 				SenderSeq:     cphm.pbftNode.sequenceID,
 			}
+			if rp != nil {
+				rp.attach(&relay)
+			}
 			rByte, err := json.Marshal(relay)
 			if err != nil {
 				log.Panic()
 			}
-			msg_send := message.MergeMessage(message.CRelay, rByte)
-			go networks.TcpDial(msg_send, cphm.pbftNode.ip_nodeTable[sid][0])
+			msg_send := cphm.pbftNode.signMessage(message.CRelay, rByte)
+			go cphm.pbftNode.sendMessage(msg_send, cphm.pbftNode.getLeaderIP(sid))
 			cphm.pbftNode.pl.Plog.Printf("S%dN%d : sended relay txs to %d\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, sid)
 		}
 		cphm.pbftNode.CurChain.Txpool.ClearRelayPool()
@@ -144,12 +153,13 @@ func (cphm *CLPAPbftInsideExtraHandleMod) HandleinCommit(cmsg *message.Commit) b
 			ProposeTime:     r.ReqTime,
 			CommitTime:      time.Now(),
 		}
+		cphm.pbftNode.fillTxPoolStats(&bim)
 		bByte, err := json.Marshal(bim)
 		if err != nil {
 			log.Panic()
 		}
-		msg_send := message.MergeMessage(message.CBlockInfo, bByte)
-		go networks.TcpDial(msg_send, cphm.pbftNode.ip_nodeTable[params.DeciderShard][0])
+		msg_send := cphm.pbftNode.signMessage(message.CBlockInfo, bByte)
+		go cphm.pbftNode.sendMessage(msg_send, cphm.pbftNode.ip_nodeTable[params.DeciderShard][0])
 		cphm.pbftNode.pl.Plog.Printf("S%dN%d : sended excuted txs\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID)
 		cphm.pbftNode.CurChain.Txpool.GetLocked()
 		cphm.pbftNode.writeCSVline([]string{strconv.Itoa(len(cphm.pbftNode.CurChain.Txpool.TxQueue)), strconv.Itoa(len(txExcuted)), strconv.Itoa(int(bim.Relay1TxNum))})
@@ -172,7 +182,11 @@ func (cphm *CLPAPbftInsideExtraHandleMod) HandleforSequentialRequest(som *messag
 			r := som.OldRequest[height-som.SeqStartHeight]
 			if r.RequestType == message.BlockRequest {
 				b := core.DecodeB(r.Msg.Content)
-				cphm.pbftNode.CurChain.AddBlock(b)
+				if err := cphm.pbftNode.CurChain.AddBlock(b); err != nil { //添加区块失败时，从该高度开始重新追赶
+					cphm.pbftNode.pl.Plog.Printf("S%dN%d : cannot add the old block %d: %v\n", cphm.pbftNode.ShardID, cphm.pbftNode.NodeID, height, err)
+					cphm.pbftNode.sequenceID = height
+					return false
+				}
 			} else {
 				atm := message.DecodeAccountTransferMsg(r.Msg.Content)
 				cphm.accountTransfer_do(atm)
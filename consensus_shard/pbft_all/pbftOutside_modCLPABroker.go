@@ -53,8 +53,8 @@ func (cbom *CLPABrokerOutsideModule) handleInjectTx(content []byte) { //handleIn
 	if err != nil {
 		log.Panic(err)
 	}
-	cbom.pbftNode.CurChain.Txpool.AddTxs2Pool(it.Txs)
-	cbom.pbftNode.pl.Plog.Printf("S%dN%d : has handled injected txs msg, txs: %d \n", cbom.pbftNode.ShardID, cbom.pbftNode.NodeID, len(it.Txs))
+	rejected := cbom.pbftNode.CurChain.Txpool.AddTxs2Pool(it.Txs)
+	cbom.pbftNode.pl.Plog.Printf("S%dN%d : has handled injected txs msg, txs: %d, rejected or evicted: %d \n", cbom.pbftNode.ShardID, cbom.pbftNode.NodeID, len(it.Txs), rejected)
 }
 
 // the leader received the partition message from listener/decider,
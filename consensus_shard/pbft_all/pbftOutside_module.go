@@ -2,6 +2,7 @@ package pbft_all
 
 import (
 	"blockEmulator/message"
+	"blockEmulator/params"
 	"encoding/json"
 	"log"
 )
@@ -32,11 +33,18 @@ func (rrom *RawRelayOutsideModule) handleRelay(content []byte) { //handleRelay
 		log.Panic(err)
 	}
 	rrom.pbftNode.pl.Plog.Printf("S%dN%d : has received relay txs from shard %d, the senderSeq is %d\n", rrom.pbftNode.ShardID, rrom.pbftNode.NodeID, relay.SenderShardID, relay.SenderSeq) //打印日志，指示该函数已收到中继事务。日志消息包含有关分片和发送者序列的信息
-	rrom.pbftNode.CurChain.Txpool.AddTxs2Pool(relay.Txs)                                                                                                                                    //将交易添加到交易池中
-	rrom.pbftNode.seqMapLock.Lock()                                                                                                                                                         //使用互斥锁
-	rrom.pbftNode.seqIDMap[relay.SenderShardID] = relay.SenderSeq                                                                                                                           //将发送方的分片ID和序列ID添加到seqIDMap中
-	rrom.pbftNode.seqMapLock.Unlock()                                                                                                                                                       //解锁
-	rrom.pbftNode.pl.Plog.Printf("S%dN%d : has handled relay txs msg\n", rrom.pbftNode.ShardID, rrom.pbftNode.NodeID)                                                                       //打印日志，指示中继事务已被处理
+	txs := relay.Txs
+	if params.RelayProof { //验证源分片的提交证书以及每笔交易的默克尔证明
+		if txs, err = rrom.pbftNode.verifyRelay(relay); err != nil {
+			rrom.pbftNode.pl.Plog.Printf("S%dN%d : cannot verify the relay txs from shard %d: %v, refuse them\n", rrom.pbftNode.ShardID, rrom.pbftNode.NodeID, relay.SenderShardID, err)
+			return
+		}
+	}
+	rrom.pbftNode.CurChain.Txpool.AddTxs2Pool(txs)                                                                    //将交易添加到交易池中
+	rrom.pbftNode.seqMapLock.Lock()                                                                                   //使用互斥锁
+	rrom.pbftNode.seqIDMap[relay.SenderShardID] = relay.SenderSeq                                                     //将发送方的分片ID和序列ID添加到seqIDMap中
+	rrom.pbftNode.seqMapLock.Unlock()                                                                                 //解锁
+	rrom.pbftNode.pl.Plog.Printf("S%dN%d : has handled relay txs msg\n", rrom.pbftNode.ShardID, rrom.pbftNode.NodeID) //打印日志，指示中继事务已被处理
 }
 
 //该函数负责接收中继的交易，将其添加到交易池中，并管理与发送者分片相关的序列信息。
@@ -47,8 +55,8 @@ func (rrom *RawRelayOutsideModule) handleInjectTx(content []byte) { //handleInje
 	if err != nil {
 		log.Panic(err)
 	}
-	rrom.pbftNode.CurChain.Txpool.AddTxs2Pool(it.Txs)                                                                                           //将交易添加到交易池中
-	rrom.pbftNode.pl.Plog.Printf("S%dN%d : has handled injected txs msg, txs: %d \n", rrom.pbftNode.ShardID, rrom.pbftNode.NodeID, len(it.Txs)) //打印日志，指示注入的交易已被处理，日志消息包括有关分片和处理的事务数量的信息
+	rejected := rrom.pbftNode.CurChain.Txpool.AddTxs2Pool(it.Txs)                                                                                                                  //将交易添加到交易池中，不合法、重复或超过容量的交易被丢弃
+	rrom.pbftNode.pl.Plog.Printf("S%dN%d : has handled injected txs msg, txs: %d, rejected or evicted: %d \n", rrom.pbftNode.ShardID, rrom.pbftNode.NodeID, len(it.Txs), rejected) //打印日志，指示注入的交易已被处理，日志消息包括有关分片和处理的事务数量的信息
 }
 
 //该函数负责处理外部生成的交易并将其添加到交易池中。这是区块链系统中的一种常见机制，允许外部实体提交新交易以包含在区块链中。该函数对注入的交易执行必要的反序列化，并将它们添加到池中以供后续验证并包含在区块链中
@@ -44,6 +44,6 @@ func (rrom *RawBrokerOutsideModule) handleInjectTx(content []byte) {
 	if err != nil {
 		log.Panic(err)
 	}
-	rrom.pbftNode.CurChain.Txpool.AddTxs2Pool(it.Txs)
-	rrom.pbftNode.pl.Plog.Printf("S%dN%d : has handled injected txs msg, txs: %d \n", rrom.pbftNode.ShardID, rrom.pbftNode.NodeID, len(it.Txs))
+	rejected := rrom.pbftNode.CurChain.Txpool.AddTxs2Pool(it.Txs)
+	rrom.pbftNode.pl.Plog.Printf("S%dN%d : has handled injected txs msg, txs: %d, rejected or evicted: %d \n", rrom.pbftNode.ShardID, rrom.pbftNode.NodeID, len(it.Txs), rejected)
 }
@@ -3,6 +3,7 @@ package pbft_all
 import (
 	"blockEmulator/consensus_shard/pbft_all/dataSupport"
 	"blockEmulator/message"
+	"blockEmulator/params"
 	"encoding/json"
 	"log"
 )
@@ -41,7 +42,14 @@ func (crom *CLPARelayOutsideModule) handleRelay(content []byte) {
 		log.Panic(err)
 	}
 	crom.pbftNode.pl.Plog.Printf("S%dN%d : has received relay txs from shard %d, the senderSeq is %d\n", crom.pbftNode.ShardID, crom.pbftNode.NodeID, relay.SenderShardID, relay.SenderSeq)
-	crom.pbftNode.CurChain.Txpool.AddTxs2Pool(relay.Txs)
+	txs := relay.Txs
+	if params.RelayProof { //验证源分片的提交证书以及每笔交易的默克尔证明
+		if txs, err = crom.pbftNode.verifyRelay(relay); err != nil {
+			crom.pbftNode.pl.Plog.Printf("S%dN%d : cannot verify the relay txs from shard %d: %v, refuse them\n", crom.pbftNode.ShardID, crom.pbftNode.NodeID, relay.SenderShardID, err)
+			return
+		}
+	}
+	crom.pbftNode.CurChain.Txpool.AddTxs2Pool(txs)
 	crom.pbftNode.seqMapLock.Lock()
 	crom.pbftNode.seqIDMap[relay.SenderShardID] = relay.SenderSeq
 	crom.pbftNode.seqMapLock.Unlock()
@@ -54,8 +62,8 @@ func (crom *CLPARelayOutsideModule) handleInjectTx(content []byte) {
 	if err != nil {
 		log.Panic(err)
 	}
-	crom.pbftNode.CurChain.Txpool.AddTxs2Pool(it.Txs)
-	crom.pbftNode.pl.Plog.Printf("S%dN%d : has handled injected txs msg, txs: %d \n", crom.pbftNode.ShardID, crom.pbftNode.NodeID, len(it.Txs))
+	rejected := crom.pbftNode.CurChain.Txpool.AddTxs2Pool(it.Txs)
+	crom.pbftNode.pl.Plog.Printf("S%dN%d : has handled injected txs msg, txs: %d, rejected or evicted: %d \n", crom.pbftNode.ShardID, crom.pbftNode.NodeID, len(it.Txs), rejected)
 }
 
 // 领导者收到来自监听器/决策者的分区消息，
@@ -0,0 +1,106 @@
+package pbft_all
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"blockEmulator/message"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var ErrRelayProofMissing = errors.New("the relay message does not carry the block header or the merkle proofs")
+
+// 请求中区块的哈希，非区块请求返回空
+func (p *PbftConsensusNode) requestBlockHash(digest string) []byte {
+	r, ok := p.requestPool[digest]
+	if !ok || r.RequestType != message.BlockRequest {
+		return nil
+	}
+	return core.DecodeB(r.Msg.Content).Header.Hash()
+}
+
+// 保存带签名的提交消息，调用者持有 p.lock
+func (p *PbftConsensusNode) recordCommit(cmsg *message.Commit, signed []byte) {
+	if signed == nil || cmsg.BlockHash == nil {
+		return
+	}
+	digest := string(cmsg.Digest)
+	if _, ok := p.commitCerts[digest]; !ok {
+		p.commitCerts[digest] = make(map[uint64][]byte)
+	}
+	p.commitCerts[digest][cmsg.SenderNode.NodeID] = signed
+}
+
+// 区块的提交证书：本节点的提交消息加上收到的其他节点对该区块的提交消息，调用者持有 p.lock
+func (p *PbftConsensusNode) commitCertificate(cmsg *message.Commit, block *core.Block) [][]byte {
+	own := message.Commit{
+		Digest:     cmsg.Digest,
+		SeqID:      cmsg.SeqID,
+		SenderNode: p.RunningNode,
+		BlockHash:  block.Header.Hash(),
+	}
+	ownByte, err := json.Marshal(own)
+	if err != nil {
+		log.Panic(err)
+	}
+	_, signed := message.SplitMessage(p.signMessage(message.CCommit, ownByte))
+	cert := [][]byte{signed}
+	for nid, signed := range p.commitCerts[string(cmsg.Digest)] {
+		if nid != p.NodeID {
+			cert = append(cert, signed)
+		}
+	}
+	return cert
+}
+
+// 源分片为中继交易附上的证明：区块头、提交证书以及交易树
+type relayProof struct {
+	header []byte
+	cert   [][]byte
+	tree   *chain.TxTree
+}
+
+// 必须在区块中的交易被标记为中继交易之前调用，否则交易的编码与区块中的不同
+func (p *PbftConsensusNode) newRelayProof(cmsg *message.Commit, block *core.Block) *relayProof {
+	return &relayProof{
+		header: block.Header.Encode(),
+		cert:   p.commitCertificate(cmsg, block),
+		tree:   chain.NewTxTree(block.Body),
+	}
+}
+
+func (rp *relayProof) attach(relay *message.Relay) {
+	relay.Header = rp.header
+	relay.Certificate = rp.cert
+	relay.Proofs = make([][][]byte, len(relay.Txs))
+	for i, tx := range relay.Txs {
+		relay.Proofs[i] = rp.tree.Prove(tx.TxHash)
+	}
+}
+
+// 目标分片验证中继消息：轻客户端中已经同步的区块头或者附带的提交证书证明区块头已经被源分片提交，默克尔证明证明每笔交易都在该区块中。
+// 返回区块中的交易（标记为中继交易），任何一项验证失败时拒绝整条消息
+func (p *PbftConsensusNode) verifyRelay(relay *message.Relay) ([]*core.Transaction, error) {
+	if len(relay.Txs) == 0 {
+		return relay.Txs, nil
+	}
+	if relay.Header == nil || len(relay.Proofs) != len(relay.Txs) {
+		return nil, ErrRelayProofMissing
+	}
+	header, err := p.CurChain.LightClient.CheckHeader(relay.SenderShardID, relay.Header, relay.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*core.Transaction, 0, len(relay.Txs))
+	for i, tx := range relay.Txs {
+		ptx, err := chain.VerifyTxProof(header.TxRoot, tx.TxHash, relay.Proofs[i])
+		if err != nil {
+			return nil, fmt.Errorf("tx %x: %w", tx.TxHash, err)
+		}
+		ptx.Relayed = true
+		txs = append(txs, ptx)
+	}
+	return txs, nil
+}
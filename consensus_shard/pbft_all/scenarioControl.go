@@ -0,0 +1,91 @@
+// 实验场景的控制：主管节点可以让节点与某些节点隔离（网络分区），或者让节点崩溃后再重启。
+// 崩溃的节点保留已经提交的状态，重启后相当于从本地存储恢复，通过追赶机制同步错过的区块和视图。
+
+package pbft_all
+
+import (
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"blockEmulator/shard"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// 判断是否为主管节点发来的控制消息
+func isControlMessage(msgType message.MessageType) bool {
+	return msgType == message.CIsolate || msgType == message.CHeal || msgType == message.CCrash || msgType == message.CRestart
+}
+
+// 处理主管节点发来的控制消息
+func (p *PbftConsensusNode) handleControlMessage(msgType message.MessageType, content []byte) {
+	p.ctrlLock.Lock()
+	defer p.ctrlLock.Unlock()
+	switch msgType {
+	case message.CIsolate, message.CHeal:
+		im := new(message.IsolateMsg)
+		err := json.Unmarshal(content, im)
+		if err != nil {
+			log.Panic(err)
+		}
+		isolate := msgType == message.CIsolate
+		if len(im.Peers) == 0 {
+			p.isolateAll = isolate
+			if !isolate {
+				p.isolated = make(map[string]bool)
+			}
+		}
+		for _, ip := range im.Peers {
+			if isolate {
+				p.isolated[ip] = true
+			} else {
+				delete(p.isolated, ip)
+			}
+		}
+		p.pl.Plog.Printf("S%dN%d : %s, peers = %v\n", p.ShardID, p.NodeID, msgType, im.Peers)
+	case message.CCrash:
+		p.crashed = true
+		p.pl.Plog.Printf("S%dN%d : crashed\n", p.ShardID, p.NodeID)
+	case message.CRestart:
+		if p.crashed {
+			p.crashed = false
+			p.lastCommitTime = time.Now()
+			p.pl.Plog.Printf("S%dN%d : restarted\n", p.ShardID, p.NodeID)
+		}
+	}
+}
+
+// 判断节点是否处于崩溃状态
+func (p *PbftConsensusNode) isCrashed() bool {
+	p.ctrlLock.Lock()
+	defer p.ctrlLock.Unlock()
+	return p.crashed
+}
+
+// 判断节点是否受到了场景控制（崩溃或者被隔离）
+func (p *PbftConsensusNode) isDisturbed() bool {
+	p.ctrlLock.Lock()
+	defer p.ctrlLock.Unlock()
+	return p.crashed || p.isolateAll || len(p.isolated) > 0
+}
+
+// 判断与该地址之间的通信是否被阻断，与主管节点之间的通信不会被阻断
+func (p *PbftConsensusNode) isBlocked(addr string) bool {
+	p.ctrlLock.Lock()
+	defer p.ctrlLock.Unlock()
+	if p.crashed {
+		return true
+	}
+	if addr == p.ip_nodeTable[params.DeciderShard][0] {
+		return false
+	}
+	return p.isolateAll || p.isolated[addr]
+}
+
+// 判断是否需要丢弃该签名者发来的消息
+func (p *PbftConsensusNode) isBlockedFrom(signer *shard.Node) bool {
+	if signer == nil {
+		return p.isCrashed()
+	}
+	return p.isBlocked(p.ip_nodeTable[signer.ShardID][signer.NodeID])
+}
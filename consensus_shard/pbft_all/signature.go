@@ -0,0 +1,36 @@
+package pbft_all
+
+import (
+	"blockEmulator/message"
+	"blockEmulator/shard"
+	"encoding/json"
+)
+
+// 对需要签名的消息用本节点的私钥签名，其他消息直接合并消息类型和内容
+func (p *PbftConsensusNode) signMessage(msgType message.MessageType, content []byte) []byte {
+	if !message.NeedSignature(msgType) {
+		return message.MergeMessage(msgType, content)
+	}
+	return message.MergeSignedMessage(msgType, content, p.RunningNode, p.priKey)
+}
+
+// 检查签名者是否有权发送该消息：分片内的共识消息只能来自本分片的节点，PrePrepare 只能来自该视图的主节点，LeaderInfo 只能来自新主节点自己
+func (p *PbftConsensusNode) checkSigner(msgType message.MessageType, content []byte, signer *shard.Node) bool {
+	switch msgType {
+	case message.CPrePrepare:
+		ppmsg := new(message.PrePrepare)
+		if err := json.Unmarshal(content, ppmsg); err != nil {
+			return false
+		}
+		return signer.ShardID == p.ShardID && signer.NodeID == ppmsg.View%p.node_nums
+	case message.CLeaderInfo:
+		li := new(message.LeaderInfo)
+		if err := json.Unmarshal(content, li); err != nil {
+			return false
+		}
+		return signer.ShardID == li.ShardID && signer.NodeID == li.LeaderID
+	case message.CPrepare, message.CCommit, message.CCheckpoint, message.CViewChange, message.CNewView, message.CRequestOldrequest, message.CSendOldrequest:
+		return signer.ShardID == p.ShardID
+	}
+	return true
+}
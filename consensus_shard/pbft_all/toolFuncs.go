@@ -14,17 +14,18 @@ import (
 
 // 设置2d地图，仅适用于pbft地图，如果第一个参数为true，则设置cntPrepareConfirm地图，
 // 否则，将设置 cntCommitConfirm 映射
+// 消息的签名已经在 handleMessage 中验证过，因此按发送者的节点ID计票，同一个节点只计一票
 func (p *PbftConsensusNode) set2DMap(isPrePareConfirm bool, key string, val *shard.Node) { //set2DMap方法用于设置2d地图，仅适用于pbft地图
 	if isPrePareConfirm {
 		if _, ok := p.cntPrepareConfirm[key]; !ok {
-			p.cntPrepareConfirm[key] = make(map[*shard.Node]bool)
+			p.cntPrepareConfirm[key] = make(map[uint64]bool)
 		}
-		p.cntPrepareConfirm[key][val] = true
+		p.cntPrepareConfirm[key][val.NodeID] = true
 	} else {
 		if _, ok := p.cntCommitConfirm[key]; !ok {
-			p.cntCommitConfirm[key] = make(map[*shard.Node]bool)
+			p.cntCommitConfirm[key] = make(map[uint64]bool)
 		}
-		p.cntCommitConfirm[key][val] = true
+		p.cntCommitConfirm[key][val.NodeID] = true
 	}
 }
 
@@ -77,6 +78,12 @@ func (p *PbftConsensusNode) writeCSVline(str []string) { //writeCSVline方法用
 	f.Close()
 }
 
+// 在区块信息中填写交易池的状态，主管节点据此决定是否暂停向本分片注入交易
+func (p *PbftConsensusNode) fillTxPoolStats(bim *message.BlockInfoMsg) {
+	bim.TxPoolSize, bim.TxPoolDropped = p.CurChain.Txpool.Stats()
+	bim.TxPoolCapacity = params.TxPoolCapacity
+}
+
 // 获取请求的摘要
 func getDigest(r *message.Request) []byte {
 	b, err := json.Marshal(r)
@@ -0,0 +1,365 @@
+// 视图切换（view change）：当主节点崩溃或长时间不出块时，从节点发起视图切换，
+// 新视图的主节点为 view % node_nums，从而保证分片在主节点失效后仍然可以继续出块。
+
+package pbft_all
+
+import (
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"blockEmulator/shard"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// 收到的视图切换消息以及带签名的原始消息，新主节点把带签名的消息放到新视图消息中作为切换的证明
+type signedViewChange struct {
+	vc     *message.ViewChange
+	signed []byte
+}
+
+// 获取当前视图ID
+func (p *PbftConsensusNode) getView() uint64 {
+	p.viewLock.Lock()
+	defer p.viewLock.Unlock()
+	return p.view
+}
+
+// 获取当前视图中主节点的ID
+func (p *PbftConsensusNode) getLeaderID() uint64 {
+	return p.getView() % p.node_nums
+}
+
+// 判断本节点是否为当前视图的主节点
+func (p *PbftConsensusNode) isLeader() bool {
+	return p.getLeaderID() == p.NodeID
+}
+
+// 获取某个分片主节点的ip地址，本分片使用本地视图，其他分片使用 LeaderInfo 消息更新的记录
+func (p *PbftConsensusNode) getLeaderIP(sid uint64) string {
+	if sid == p.ShardID {
+		return p.ip_nodeTable[sid][p.getLeaderID()]
+	}
+	return p.ip_nodeTable[sid][params.GetShardLeader(sid)]
+}
+
+// 判断 Propose 协程是否正在运行
+func (p *PbftConsensusNode) isProposing() bool {
+	p.viewLock.Lock()
+	defer p.viewLock.Unlock()
+	return p.proposing
+}
+
+// 记录主节点当前持有 sequenceLock 的提案摘要
+func (p *PbftConsensusNode) setProposingDigest(digest string) {
+	p.viewLock.Lock()
+	defer p.viewLock.Unlock()
+	p.proposingDigest = digest
+}
+
+// 如果 digest 是主节点正在等待提交的提案，则清除记录并返回 true，此时调用者需要释放 sequenceLock
+func (p *PbftConsensusNode) takeProposingDigest(digest string) bool {
+	p.viewLock.Lock()
+	defer p.viewLock.Unlock()
+	if p.proposingDigest == "" || p.proposingDigest != digest {
+		return false
+	}
+	p.proposingDigest = ""
+	return true
+}
+
+// 视图切换计时器，若超过 ViewChangeTimeOut 没有提交区块，则发起视图切换。
+// 主节点自己也会超时，这样崩溃重启或者被隔离的旧主节点可以发现自己已经落后，并从新主节点获取新视图
+func (p *PbftConsensusNode) ViewChangeTimer() {
+	timeOut := time.Duration(params.ViewChangeTimeOut) * time.Millisecond
+	for !p.getStopSignal() {
+		time.Sleep(time.Second)
+		p.tcpPoolLock.Lock()
+		if !p.getStopSignal() && !p.isCrashed() && time.Since(p.lastCommitTime) >= timeOut {
+			next := p.getView() + 1
+			if p.viewChanging { // 上一次视图切换也超时了，则继续切换到下一个视图
+				next = p.targetView + 1
+			}
+			p.pl.Plog.Printf("S%dN%d : no block is committed for %v, start view change to view %d\n", p.ShardID, p.NodeID, timeOut, next)
+			p.lastCommitTime = time.Now()
+			p.startViewChange(next)
+		}
+		p.tcpPoolLock.Unlock()
+	}
+}
+
+// 进入视图切换状态，并广播 ViewChange 消息
+func (p *PbftConsensusNode) startViewChange(nextView uint64) {
+	if nextView <= p.targetView || nextView <= p.getView() {
+		return
+	}
+	p.targetView = nextView
+	p.viewChanging = true
+
+	vc := &message.ViewChange{
+		NextView:   nextView,
+		LastSeqID:  p.sequenceID - 1,
+		SenderNode: p.RunningNode,
+	}
+	// 如果本节点已经广播了 commit 但还未提交，则把该请求带上，新主节点需要在新视图中重新提议
+	if digest, ok := p.height2Digest[p.sequenceID]; ok && p.isCommitBordcast[digest] && !p.isReply[digest] {
+		vc.PreparedSeqID = p.sequenceID
+		vc.PreparedReq = p.requestPool[digest]
+	}
+	vcByte, err := json.Marshal(vc)
+	if err != nil {
+		log.Panic()
+	}
+	msg_send := p.signMessage(message.CViewChange, vcByte)
+	_, signed := message.SplitMessage(msg_send)
+	p.recordViewChange(vc, signed)
+	p.broadcast(p.getNeighborNodes(), msg_send)
+	p.pl.Plog.Printf("S%dN%d : has broadcast the view change message, next view = %d\n", p.ShardID, p.NodeID, nextView)
+
+	p.checkViewChangeQuorum(nextView)
+}
+
+// 记录一条视图切换消息，每个节点在每个视图中只计一票，signed 为带签名的原始消息
+func (p *PbftConsensusNode) recordViewChange(vc *message.ViewChange, signed []byte) {
+	if _, ok := p.viewChangeVotes[vc.NextView]; !ok {
+		p.viewChangeVotes[vc.NextView] = make(map[uint64]*signedViewChange)
+	}
+	p.viewChangeVotes[vc.NextView][vc.SenderNode.NodeID] = &signedViewChange{vc: vc, signed: signed}
+}
+
+func (p *PbftConsensusNode) handleViewChange(content, signed []byte) {
+	vc := new(message.ViewChange)
+	err := json.Unmarshal(content, vc)
+	if err != nil {
+		log.Panic(err)
+	}
+	if vc.SenderNode == nil || vc.SenderNode.ShardID != p.ShardID {
+		return
+	}
+	if vc.NextView <= p.getView() { // 发送者落后于当前视图，主节点把自己发出的新视图消息发给它
+		if p.isLeader() && p.newViewMsg != nil {
+			p.sendMessage(p.newViewMsg, p.ip_nodeTable[p.ShardID][vc.SenderNode.NodeID])
+		}
+		return
+	}
+	p.pl.Plog.Printf("S%dN%d : received the view change message (view %d) from ...%d\n", p.ShardID, p.NodeID, vc.NextView, vc.SenderNode.NodeID)
+	p.recordViewChange(vc, signed)
+
+	// 收到 f+1 个节点切换到更高视图的请求，说明至少有一个诚实节点认为主节点失效，本节点也加入视图切换
+	if len(p.viewChangeVotes[vc.NextView]) >= int(p.malicious_nums)+1 && vc.NextView > p.targetView {
+		p.startViewChange(vc.NextView)
+		return
+	}
+	p.checkViewChangeQuorum(vc.NextView)
+}
+
+// 新主节点收集到 2f+1 条视图切换消息后，发出新视图消息
+func (p *PbftConsensusNode) checkViewChangeQuorum(view uint64) {
+	if view%p.node_nums != p.NodeID || p.pendingNewView == view {
+		return
+	}
+	if len(p.viewChangeVotes[view]) < int(2*p.malicious_nums)+1 {
+		return
+	}
+	p.prepareNewView(view)
+}
+
+// 新主节点首先需要追上其他节点已经提交的区块，然后才可以发出新视图消息
+func (p *PbftConsensusNode) prepareNewView(view uint64) {
+	maxSeq, server := uint64(0), uint64(0)
+	for nid, svc := range p.viewChangeVotes[view] {
+		if svc.vc.LastSeqID > maxSeq {
+			maxSeq, server = svc.vc.LastSeqID, nid
+		}
+	}
+	if p.sequenceID-1 < maxSeq {
+		p.pendingNewView = view
+		if !p.askForLock.TryLock() { // 已经在请求区块，等待返回后再发出新视图消息
+			return
+		}
+		p.askForTime.Store(time.Now().UnixNano())
+		orequest := message.RequestOldMessage{
+			SeqStartHeight: p.sequenceID,
+			SeqEndHeight:   maxSeq,
+			ServerNode: &shard.Node{
+				NodeID:  server,
+				ShardID: p.ShardID,
+				IPaddr:  p.ip_nodeTable[p.ShardID][server],
+			},
+			SenderNode: p.RunningNode,
+		}
+		bromyte, err := json.Marshal(orequest)
+		if err != nil {
+			log.Panic()
+		}
+		p.pl.Plog.Printf("S%dN%d : is now requesting message (seq %d to %d) before the new view ... \n", p.ShardID, p.NodeID, orequest.SeqStartHeight, orequest.SeqEndHeight)
+		msg_send := p.signMessage(message.CRequestOldrequest, bromyte)
+		p.sendMessage(msg_send, orequest.ServerNode.IPaddr)
+		return
+	}
+	p.pendingNewView = 0
+
+	vcs := make([]*message.ViewChange, 0, len(p.viewChangeVotes[view]))
+	proofs := make([][]byte, 0, len(p.viewChangeVotes[view]))
+	for _, svc := range p.viewChangeVotes[view] {
+		vcs = append(vcs, svc.vc)
+		proofs = append(proofs, svc.signed)
+	}
+	nv := &message.NewView{
+		View:        view,
+		ViewChanges: proofs,
+		Reproposal:  selectReproposal(view, vcs),
+		SenderNode:  p.RunningNode,
+	}
+	nvByte, err := json.Marshal(nv)
+	if err != nil {
+		log.Panic()
+	}
+	msg_send := p.signMessage(message.CNewView, nvByte)
+	p.newViewMsg = msg_send
+	p.broadcast(p.getNeighborNodes(), msg_send)
+	p.pl.Plog.Printf("S%dN%d : has broadcast the new view message, view = %d\n", p.ShardID, p.NodeID, view)
+	p.installNewView(nv)
+}
+
+// 从视图切换消息中选出需要在新视图中重新提议的请求，即已经 prepare 但尚未提交的下一个序列
+func selectReproposal(view uint64, vcs []*message.ViewChange) *message.PrePrepare {
+	maxSeq := uint64(0)
+	for _, vc := range vcs {
+		if vc.LastSeqID > maxSeq {
+			maxSeq = vc.LastSeqID
+		}
+	}
+	for _, vc := range vcs {
+		if vc.PreparedReq != nil && vc.PreparedSeqID == maxSeq+1 {
+			return &message.PrePrepare{
+				RequestMsg: vc.PreparedReq,
+				Digest:     getDigest(vc.PreparedReq),
+				SeqID:      vc.PreparedSeqID,
+				View:       view,
+			}
+		}
+	}
+	return nil
+}
+
+func (p *PbftConsensusNode) handleNewView(content []byte) {
+	nv := new(message.NewView)
+	err := json.Unmarshal(content, nv)
+	if err != nil {
+		log.Panic(err)
+	}
+	p.pl.Plog.Printf("S%dN%d : received the new view message (view %d)\n", p.ShardID, p.NodeID, nv.View)
+	if nv.View <= p.getView() || nv.SenderNode == nil || nv.SenderNode.NodeID != nv.View%p.node_nums {
+		p.pl.Plog.Printf("S%dN%d : the new view message is stale or not from the new leader, refuse it\n", p.ShardID, p.NodeID)
+		return
+	}
+	// 检查新视图消息是否携带了 2f+1 个不同节点签名的视图切换消息，只有签名有效的视图切换消息才被计入
+	vcs := message.VerifyViewChanges(nv.ViewChanges, p.ShardID, nv.View)
+	if len(vcs) < int(2*p.malicious_nums)+1 {
+		p.pl.Plog.Printf("S%dN%d : the new view message has not enough view change proofs, refuse it\n", p.ShardID, p.NodeID)
+		return
+	}
+	expected := selectReproposal(nv.View, vcs)
+	if (expected == nil) != (nv.Reproposal == nil) || (expected != nil && string(expected.Digest) != string(nv.Reproposal.Digest)) {
+		p.pl.Plog.Printf("S%dN%d : the reproposal in new view message is not consistent, refuse it\n", p.ShardID, p.NodeID)
+		return
+	}
+	p.installNewView(nv)
+}
+
+// 切换到新的视图
+func (p *PbftConsensusNode) installNewView(nv *message.NewView) {
+	wasLeader := p.isLeader()
+	p.viewLock.Lock()
+	p.view = nv.View
+	p.proposingDigest = ""
+	p.viewLock.Unlock()
+	if p.targetView < nv.View {
+		p.targetView = nv.View
+	}
+	p.viewChanging = false
+	p.pendingNewView = 0
+	p.lastCommitTime = time.Now()
+	for view := range p.viewChangeVotes {
+		if view <= nv.View {
+			delete(p.viewChangeVotes, view)
+		}
+	}
+	// 原主节点释放 sequenceLock，使其 Propose 协程可以退出
+	if wasLeader {
+		p.sequenceLock.TryLock()
+		p.sequenceLock.Unlock()
+	}
+	leader := nv.View % p.node_nums
+	params.SetShardLeader(p.ShardID, leader, nv.View)
+	p.pl.Plog.Printf("S%dN%d : view changed to %d, the new leader is node %d\n", p.ShardID, p.NodeID, nv.View, leader)
+
+	if nv.Reproposal != nil {
+		digest := string(nv.Reproposal.Digest)
+		if !p.isReply[digest] { // 旧视图中的投票作废，在新视图中重新投票
+			delete(p.isCommitBordcast, digest)
+			delete(p.cntPrepareConfirm, digest)
+			delete(p.cntCommitConfirm, digest)
+			delete(p.commitCerts, digest)
+		}
+	}
+
+	if p.isLeader() {
+		p.broadcastLeaderInfo()
+		if nv.Reproposal != nil && p.sequenceLock.TryLock() {
+			p.requestPool[string(nv.Reproposal.Digest)] = nv.Reproposal.RequestMsg
+			p.height2Digest[nv.Reproposal.SeqID] = string(nv.Reproposal.Digest)
+			p.setProposingDigest(string(nv.Reproposal.Digest))
+			ppbyte, err := json.Marshal(nv.Reproposal)
+			if err != nil {
+				log.Panic()
+			}
+			msg_send := p.signMessage(message.CPrePrepare, ppbyte)
+			p.broadcast(p.getNeighborNodes(), msg_send)
+		}
+		go p.Propose()
+	} else if nv.Reproposal != nil {
+		p.prePrepareProcess(nv.Reproposal)
+	}
+}
+
+// 新主节点通知主管节点和其他分片的节点本分片的主节点已经改变
+func (p *PbftConsensusNode) broadcastLeaderInfo() {
+	li := message.LeaderInfo{
+		ShardID:  p.ShardID,
+		LeaderID: p.NodeID,
+		View:     p.getView(),
+	}
+	liByte, err := json.Marshal(li)
+	if err != nil {
+		log.Panic()
+	}
+	msg_send := p.signMessage(message.CLeaderInfo, liByte)
+	receivers := []string{p.ip_nodeTable[params.DeciderShard][0]}
+	for sid := uint64(0); sid < p.pbftChainConfig.ShardNums; sid++ {
+		if sid == p.ShardID {
+			continue
+		}
+		for nid := uint64(0); nid < p.node_nums; nid++ {
+			receivers = append(receivers, p.ip_nodeTable[sid][nid])
+		}
+	}
+	p.broadcast(receivers, msg_send)
+}
+
+func (p *PbftConsensusNode) handleLeaderInfo(content []byte) {
+	li := new(message.LeaderInfo)
+	err := json.Unmarshal(content, li)
+	if err != nil {
+		log.Panic(err)
+	}
+	if li.ShardID == p.ShardID {
+		return
+	}
+	if li.LeaderID != li.View%p.node_nums || !params.SetShardLeader(li.ShardID, li.LeaderID, li.View) { //主节点必须是该视图的主节点，并且视图不能比已经记录的旧
+		p.pl.Plog.Printf("S%dN%d : the leader info of shard %d (view %d) is stale or inconsistent, refuse it\n", p.ShardID, p.NodeID, li.ShardID, li.View)
+		return
+	}
+	p.pl.Plog.Printf("S%dN%d : the leader of shard %d is node %d now\n", p.ShardID, p.NodeID, li.ShardID, li.LeaderID)
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/gob"
+	"fmt"
 	"log"
 	"math/big"
 )
@@ -24,6 +25,13 @@ type AccountState struct { //AccountState结构包含账户状态的各种信息
 	Balance     *big.Int      //Balance：该变量似乎代表账户的余额
 	StorageRoot []byte        //代表账户的存储根，仅适用于智能合约账户
 	CodeHash    []byte        //代表账户的代码哈希，仅适用于智能合约账户
+
+	TwoPCLock []byte //锁定该账户的 2PC 交易对应的原始交易的哈希，没有锁定时为空
+}
+
+// 节点收取手续费的账户地址。该账户只记录在节点所在分片的状态树中，不属于任何交易负载
+func MinerAddress(shardID, nodeID uint64) utils.Address {
+	return fmt.Sprintf("ffffffffffffffffffffffff%08x%08x", shardID, nodeID)
 }
 
 // 减少账户余额
@@ -0,0 +1,86 @@
+// 交易索引和交易收据：节点添加区块时为区块中的每笔交易记录它所在的区块和位置，以及执行结果和跨分片交易的阶段
+
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log"
+	"time"
+)
+
+// 交易在跨分片交易中的阶段
+const (
+	TxStageInner   = "inner"   //片内交易
+	TxStageRelay1  = "relay1"  //中继交易在发送者分片中执行（扣款）
+	TxStageRelay2  = "relay2"  //中继交易在接收者分片中执行（入账）
+	TxStageBroker1 = "broker1" //broker 交易的第一步，原始发送者转账给 broker
+	TxStageBroker2 = "broker2" //broker 交易的第二步，broker 转账给最终接收者
+
+	TxStageTwoPCPrepare = "2pc-prepare" //2PC 交易的准备
+	TxStageTwoPCCommit  = "2pc-commit"  //2PC 交易的提交
+	TxStageTwoPCAbort   = "2pc-abort"   //2PC 交易的中止
+)
+
+// 交易收据的状态
+const (
+	ReceiptFailed  = uint8(0) //交易在区块中但没有被执行（nonce 错误、余额不足等）
+	ReceiptSuccess = uint8(1)
+)
+
+// 交易所在的区块和在区块中的位置
+type TxLookup struct {
+	BlockHash   []byte
+	BlockNumber uint64
+	Index       uint64
+}
+
+// 交易收据
+type Receipt struct {
+	TxHash       []byte
+	Status       uint8     //ReceiptSuccess 或 ReceiptFailed
+	Reason       string    //交易没有被执行的原因
+	ShardID      uint64    //执行交易的分片
+	BlockHash    []byte    //交易所在的区块
+	BlockNumber  uint64    //交易所在区块的高度
+	Index        uint64    //交易在区块中的位置
+	ExecutedTime time.Time //区块被添加到链上的时间
+	Stage        string    //交易在跨分片交易中的阶段，TxStage 之一
+	RawTxHash    []byte    //broker 交易或 2PC 交易对应的原始交易的哈希
+}
+
+// 对交易位置进行编码以便存储
+func (tl *TxLookup) Encode() []byte {
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(tl); err != nil {
+		log.Panic(err)
+	}
+	return buff.Bytes()
+}
+
+// 解码交易位置
+func DecodeTxLookup(b []byte) *TxLookup {
+	var tl TxLookup
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&tl); err != nil {
+		log.Panic(err)
+	}
+	return &tl
+}
+
+// 对收据进行编码以便存储
+func (r *Receipt) Encode() []byte {
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(r); err != nil {
+		log.Panic(err)
+	}
+	return buff.Bytes()
+}
+
+// 解码收据
+func DecodeReceipt(b []byte) *Receipt {
+	var r Receipt
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&r); err != nil {
+		log.Panic(err)
+	}
+	return &r
+}
@@ -3,6 +3,7 @@
 package core
 
 import (
+	"blockEmulator/params"
 	"blockEmulator/utils"
 	"bytes"
 	"crypto/sha256"
@@ -20,6 +21,8 @@ type Transaction struct { //Transaction结构包含交易的各种信息
 	Signature []byte        //Signature：该变量似乎代表交易的签名
 	Value     *big.Int      //Value：该变量似乎代表交易的价值
 	TxHash    []byte        //TxHash：该变量似乎代表交易的哈希值
+	GasPrice  *big.Int      //GasPrice：每单位 gas 的价格，手续费为 params.TxGas * GasPrice
+	GasLimit  uint64        //GasLimit：交易愿意消耗的最大 gas，小于 params.TxGas 的交易不能执行
 
 	Time time.Time //交易添加到池的时间
 
@@ -31,6 +34,9 @@ type Transaction struct { //Transaction结构包含交易的各种信息
 	OriginalSender utils.Address
 	FinalRecipient utils.Address
 	RawTxHash      []byte
+
+	//用于两阶段提交（2PC）的跨分片交易，普通交易为 TwoPCNone，2PC 交易的 RawTxHash 是原始交易的哈希
+	TwoPCPhase uint8
 }
 
 func (tx *Transaction) PrintTx() string { //PrintTx方法用于打印交易
@@ -44,6 +50,25 @@ func (tx *Transaction) PrintTx() string { //PrintTx方法用于打印交易
 	return res
 }
 
+// 判断交易是否由 broker 发出。broker 账户在每个分片中都有一份状态，它发出的交易不检查也不增加 nonce：
+// broker2 交易的 nonce 是原始交易的 nonce，已经由原始发送者分片中的 broker1 交易检查过
+func (tx *Transaction) IsBrokerSent() bool {
+	return (tx.HasBroker && tx.SenderIsBroker) || (tx.OriginalSender != "" && tx.Sender != tx.OriginalSender)
+}
+
+// 交易的手续费：转账交易消耗 params.TxGas 的 gas
+func (tx *Transaction) Fee() *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(params.TxGas), tx.GetGasPrice())
+}
+
+// 交易的 gas 价格，没有设置时为 0
+func (tx *Transaction) GetGasPrice() *big.Int {
+	if tx.GasPrice == nil {
+		return new(big.Int)
+	}
+	return tx.GasPrice
+}
+
 // 对交易进行编码以进行存储
 func (tx *Transaction) Encode() []byte {
 	var buff bytes.Buffer
@@ -70,14 +95,21 @@ func DecodeTx(to_decode []byte) *Transaction {
 	return &tx
 }
 
-// new a transaction
+// new a transaction，使用默认的 gas 价格和 gas 上限
 func NewTransaction(sender, recipient string, value *big.Int, nonce uint64) *Transaction {
+	return NewTransactionWithFee(sender, recipient, value, nonce, big.NewInt(params.DefaultGasPrice), params.TxGas)
+}
+
+// 创建指定 gas 价格和 gas 上限的交易
+func NewTransactionWithFee(sender, recipient string, value *big.Int, nonce uint64, gasPrice *big.Int, gasLimit uint64) *Transaction {
 	//NewTransaction方法用于创建交易
 	tx := &Transaction{ //创建交易
 		Sender:    sender, //Sender: sender 中的冒号(:) 是用于创建一个结构体（struct）字面量的语法。它表示将一个字段名与相应的值关联在一起，以初始化结构体的字段。在这种情况下，Sender 是结构体的字段名，而 sender 是赋给该字段的值
 		Recipient: recipient,
 		Value:     value,
 		Nonce:     nonce,
+		GasPrice:  gasPrice,
+		GasLimit:  gasLimit,
 	}
 
 	hash := sha256.Sum256(tx.Encode()) //对交易进行哈希
@@ -92,3 +124,12 @@ func NewTransaction(sender, recipient string, value *big.Int, nonce uint64) *Tra
 }
 
 //上面的函数本质上是使用提供的发送者、接收者、值和随机数创建并初始化交易，然后计算交易数据的哈希值。生成的Transaction实例已准备好在您的区块链或加密货币系统中使用。
+
+// 为 broker 交易设置原始交易的哈希，并把它加入 broker 交易的哈希中：
+// 不同原始交易产生的 broker 交易可能有相同的发送者、接收者、金额和 nonce，哈希不能相同
+func (tx *Transaction) SetRawTxHash(rawTxHash []byte) {
+	tx.RawTxHash = make([]byte, len(rawTxHash))
+	copy(tx.RawTxHash, rawTxHash)
+	hash := sha256.Sum256(append(append([]byte{}, tx.TxHash...), rawTxHash...))
+	tx.TxHash = hash[:]
+}
@@ -0,0 +1,75 @@
+// 两阶段提交（2PC）的跨分片交易：协调者（主管节点）把原始交易的准备交易发送到发送者和接收者所在的分片，
+// 两个分片都准备成功（锁定账户，发送者分片扣款）后发送提交交易，否则或者超时后发送中止交易，发送者分片退回金额。
+// 准备交易带有原始交易发送者的签名；提交和中止交易由协调者用自己的节点密钥签名，分片只执行协调者签名的决定
+
+package core
+
+import (
+	"blockEmulator/params"
+	"blockEmulator/shard"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var ErrTwoPCDecisionSignature = errors.New("the 2PC decision is not signed by the coordinator")
+
+// 2PC 交易的阶段
+const (
+	TwoPCNone    = uint8(0) //不是 2PC 交易
+	TwoPCPrepare = uint8(1) //准备：锁定账户，发送者一侧检查 nonce 并扣款
+	TwoPCCommit  = uint8(2) //提交：接收者一侧入账，两侧解锁
+	TwoPCAbort   = uint8(3) //中止：发送者一侧退回金额（手续费不退），两侧解锁
+)
+
+// 由原始交易生成某一阶段的 2PC 交易。nonce 等字段与原始交易相同，准备交易使用原始交易的签名，决定交易需要协调者签名，
+// 哈希由原始交易的哈希和阶段决定，同一原始交易在两个分片中的同一阶段的交易哈希相同
+func NewTwoPCTx(raw *Transaction, phase uint8) *Transaction {
+	tx := &Transaction{
+		Sender:     raw.Sender,
+		Recipient:  raw.Recipient,
+		Nonce:      raw.Nonce,
+		Value:      new(big.Int).Set(raw.Value),
+		GasPrice:   raw.GasPrice,
+		GasLimit:   raw.GasLimit,
+		Time:       raw.Time,
+		TwoPCPhase: phase,
+	}
+	tx.RawTxHash = make([]byte, len(raw.TxHash))
+	copy(tx.RawTxHash, raw.TxHash)
+	hash := sha256.Sum256(append(append([]byte{}, raw.TxHash...), phase))
+	tx.TxHash = hash[:]
+	if phase == TwoPCPrepare {
+		tx.Signature = raw.Signature
+	}
+	return tx
+}
+
+// 判断交易是否是 2PC 的决定（提交或中止）。决定完成已经准备的交易，不检查 nonce，也不收取手续费
+func (tx *Transaction) IsTwoPCDecision() bool {
+	return tx.TwoPCPhase == TwoPCCommit || tx.TwoPCPhase == TwoPCAbort
+}
+
+// 决定交易被签名的内容：交易的各个字段、原始交易的哈希以及阶段
+func (tx *Transaction) twoPCDecisionSigHash() []byte {
+	b := append(tx.SigHash(), tx.RawTxHash...)
+	return crypto.Keccak256(binary.BigEndian.AppendUint16(b, uint16(tx.TwoPCPhase)))
+}
+
+// 协调者用自己的节点密钥对决定交易签名
+func (tx *Transaction) SignTwoPCDecision(key ed25519.PrivateKey) {
+	tx.Signature = ed25519.Sign(key, tx.twoPCDecisionSigHash())
+}
+
+// 用协调者的公钥验证决定交易的签名
+func (tx *Transaction) VerifyTwoPCDecision() error {
+	pub, err := shard.GetNodePublicKey(params.DeciderShard, 0)
+	if err != nil || !ed25519.Verify(pub, tx.twoPCDecisionSigHash(), tx.Signature) {
+		return ErrTwoPCDecisionSignature
+	}
+	return nil
+}
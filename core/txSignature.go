@@ -0,0 +1,111 @@
+// 交易签名：使用 secp256k1 上的 ECDSA 对交易签名，发送者地址由公钥推导，与以太坊相同。
+// 重放 CSV 数据集时无法得到原始账户的私钥，因此为数据集中的每个地址确定性地生成一个私钥，
+// 并把该地址替换为由这个私钥推导出的地址，交易图的结构保持不变。
+
+package core
+
+import (
+	"blockEmulator/params"
+	"blockEmulator/utils"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrTxUnsigned     = errors.New("the transaction is not signed")
+	ErrTxBadSignature = errors.New("the signature of the transaction is invalid")
+	ErrTxWrongSender  = errors.New("the transaction is not signed by its sender")
+)
+
+var (
+	workloadKeys  = make(map[utils.Address]*ecdsa.PrivateKey) //推导出的地址 -> 私钥
+	workloadAddrs = make(map[string]utils.Address)            //数据集中的地址 -> 推导出的地址
+	workloadLock  sync.Mutex                                  //保护 workloadKeys 和 workloadAddrs
+)
+
+// 由公钥推导地址，格式与数据集中的地址相同（不含 0x 的十六进制）
+func PubkeyToAddress(pub ecdsa.PublicKey) utils.Address {
+	return hex.EncodeToString(crypto.PubkeyToAddress(pub).Bytes())
+}
+
+// 为数据集中的地址确定性地生成私钥，所有进程得到的私钥相同
+func workloadKey(raw string) *ecdsa.PrivateKey {
+	for i := uint64(0); ; i++ {
+		seed := crypto.Keccak256([]byte("blockEmulator workload key"), []byte(raw), binary.BigEndian.AppendUint64(nil, i))
+		if key, err := crypto.ToECDSA(seed); err == nil { //种子不在曲线的阶以内时换一个计数器重试
+			return key
+		}
+	}
+}
+
+// 返回交易负载中使用的地址。开启交易签名时，返回由该地址的确定性私钥推导出的地址，否则原样返回
+func WorkloadAddress(raw string) utils.Address {
+	if !params.SignTxs {
+		return raw
+	}
+	workloadLock.Lock()
+	defer workloadLock.Unlock()
+	if addr, ok := workloadAddrs[raw]; ok {
+		return addr
+	}
+	key := workloadKey(raw)
+	addr := PubkeyToAddress(key.PublicKey)
+	workloadAddrs[raw] = addr
+	workloadKeys[addr] = key
+	return addr
+}
+
+// 用发送者的确定性私钥对交易签名，发送者必须由 WorkloadAddress 得到
+func (tx *Transaction) SignWithWorkloadKey() {
+	workloadLock.Lock()
+	key, ok := workloadKeys[tx.Sender]
+	workloadLock.Unlock()
+	if !ok {
+		log.Panic("no workload key for the sender ", tx.Sender)
+	}
+	tx.Sign(key)
+}
+
+// 被签名的内容：发送者、接收者、金额、gas 价格、nonce 和 gas 上限，每个字段前加上长度避免歧义。中继、broker 等字段在传递过程中会被修改，不参与签名
+func (tx *Transaction) SigHash() []byte {
+	b := make([]byte, 0, 160)
+	for _, f := range [][]byte{[]byte(tx.Sender), []byte(tx.Recipient), tx.Value.Bytes(), tx.GetGasPrice().Bytes()} {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(f)))
+		b = append(b, f...)
+	}
+	b = binary.BigEndian.AppendUint64(b, tx.Nonce)
+	return crypto.Keccak256(binary.BigEndian.AppendUint64(b, tx.GasLimit))
+}
+
+// 用私钥对交易签名，签名为 65 字节的可恢复签名
+func (tx *Transaction) Sign(key *ecdsa.PrivateKey) {
+	sig, err := crypto.Sign(tx.SigHash(), key)
+	if err != nil {
+		log.Panic(err)
+	}
+	tx.Signature = sig
+}
+
+// 从签名中恢复公钥，检查由公钥推导的地址是否就是发送者
+func (tx *Transaction) VerifySignature() error {
+	if len(tx.Signature) == 0 {
+		return ErrTxUnsigned
+	}
+	if len(tx.Signature) != crypto.SignatureLength {
+		return ErrTxBadSignature
+	}
+	pub, err := crypto.SigToPub(tx.SigHash(), tx.Signature)
+	if err != nil {
+		return ErrTxBadSignature
+	}
+	if PubkeyToAddress(*pub) != tx.Sender {
+		return ErrTxWrongSender
+	}
+	return nil
+}
@@ -3,9 +3,10 @@
 package core
 
 import (
+	"blockEmulator/params"
 	"blockEmulator/utils"
+	"errors"
 	"sync"
-	"time"
 )
 
 type TxPool struct { //TxPool结构包含交易池的各种信息
@@ -13,37 +14,99 @@ type TxPool struct { //TxPool结构包含交易池的各种信息
 	RelayPool map[uint64][]*Transaction //中继池，专为分片区块链设计，来自 Monride
 	lock      sync.Mutex                //锁
 	// The pending list is ignored
+
+	hashes  map[string]bool // TxQueue 中交易的哈希，用于检测重复的交易
+	senders map[string]int  // TxQueue 中每个发送者受容量限制的交易数量
+	dropped uint64          // 因为重复、超过容量而被拒绝或被驱逐的交易总数
+
+	queues     map[string]*senderQueue // TxQueue 中的交易按发送者分成的队列，只在按手续费打包时维护
+	fee        feeHeap                 // 按队首交易的 gas 价格排序的队列
+	queueOrder uint64                  // 下一个新建队列的顺序
 }
 
 func NewTxPool() *TxPool { //NewTxPool函数创建并返回一个交易池
 	return &TxPool{
 		TxQueue:   make([]*Transaction, 0),         //它被初始化为指向 Transaction 的空指针切片，长度为0.用于保存事务队列。
 		RelayPool: make(map[uint64][]*Transaction), //它被初始化为一个空的map，其中包含 uint64 键和指向 Transaction 的指针片段作为值 ，用于保存中继池。
+		hashes:    make(map[string]bool),
+		senders:   make(map[string]int),
+		queues:    make(map[string]*senderQueue),
 	}
 }
 
-// 将交易添加到池中（仅考虑队列）
-func (txpool *TxPool) AddTx2Pool(tx *Transaction) {
+var (
+	ErrTxGasLimit       = errors.New("the gas limit of the transaction is less than the gas of a transfer")
+	ErrTxDuplicate      = errors.New("the transaction is already in the pool")
+	ErrTxSenderCapacity = errors.New("the sender has too many transactions in the pool")
+	ErrTxPoolFull       = errors.New("the pool is full")
+)
+
+// 开启交易签名时检查交易的签名，签名不合法的交易不能进入交易池；收取手续费时 gas 上限不足的交易也不能进入交易池。
+// 2PC 的决定总是需要协调者的签名
+func checkTx(tx *Transaction) error {
+	if tx.IsTwoPCDecision() {
+		return tx.VerifyTwoPCDecision()
+	}
+	if params.TxFee && !tx.IsBrokerSent() && tx.GasLimit < params.TxGas {
+		return ErrTxGasLimit
+	}
+	if !params.SignTxs {
+		return nil
+	}
+	return tx.VerifySignature()
+}
+
+// 将交易添加到池中（仅考虑队列），签名或 gas 上限不合法、重复、超过容量时返回错误
+func (txpool *TxPool) AddTx2Pool(tx *Transaction) error {
+	if err := checkTx(tx); err != nil { //验签在加锁之前进行，不阻塞其他操作
+		return err
+	}
 	txpool.lock.Lock()         //锁定交易池
 	defer txpool.lock.Unlock() //设置延迟函数调用，以便在函数返回后解锁交易池
-	if tx.Time.IsZero() {      //
-		tx.Time = time.Now()
-	} //如果Time交易字段是零，表示之前尚未设置，将该字段设置为当前时间time.Now()。该时间戳可用于记录交易何时被添加到池中。
-	txpool.TxQueue = append(txpool.TxQueue, tx) //将交易添加到交易队列中
+	if err := txpool.push(tx); err != nil {
+		txpool.dropped++
+		return err
+	}
+	txpool.evict()
+	if !txpool.hashes[string(tx.TxHash)] { //新交易本身被驱逐
+		return ErrTxPoolFull
+	}
+	return nil
 }
 
 //通过使用锁，该方法确保事务以安全且同步的方式添加到池中。如果事务还没有时间戳，它还会为事务添加时间戳，然后将其附加到池的队列中。
 
-// 将交易列表添加到池中
-func (txpool *TxPool) AddTxs2Pool(txs []*Transaction) { //
+// 将交易列表添加到池中，签名或 gas 上限不合法、重复、超过发送者容量的交易被丢弃，交易池超过容量时按驱逐策略驱逐交易，
+// 返回被丢弃和被驱逐的交易数量
+func (txpool *TxPool) AddTxs2Pool(txs []*Transaction) int { //
+	valid := make([]*Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if checkTx(tx) == nil {
+			valid = append(valid, tx)
+		}
+	}
+	txpool.lock.Lock()
+	defer txpool.lock.Unlock()
+	rejected := len(txs) - len(valid)
+	for _, tx := range valid {
+		if txpool.push(tx) != nil {
+			txpool.dropped++
+			rejected++
+		}
+	}
+	return rejected + txpool.evict()
+}
+
+// 将等待前面 nonce 的交易放回池尾，这些交易已经检查过签名
+func (txpool *TxPool) RequeueTxs(txs []*Transaction) {
 	txpool.lock.Lock()
 	defer txpool.lock.Unlock()
 	for _, tx := range txs {
-		if tx.Time.IsZero() {
-			tx.Time = time.Now()
+		if txpool.push(tx) != nil {
+			txpool.dropped++
 		}
-		txpool.TxQueue = append(txpool.TxQueue, tx)
 	}
+	txpool.evict()
 }
 
 // 将交易添加到池头
@@ -51,18 +114,28 @@ func (txpool *TxPool) AddTxs2Pool_Head(tx []*Transaction) {
 	txpool.lock.Lock()
 	defer txpool.lock.Unlock()
 	txpool.TxQueue = append(tx, txpool.TxQueue...)
+	for _, htx := range tx {
+		txpool.index(htx)
+	}
+	txpool.evict()
 }
 
 // 打包提案的交易
 func (txpool *TxPool) PackTxs(max_txs uint64) []*Transaction { //PackTxs()函数用于打包交易。它需要一个参数： max_txs（类型为uint64）：这是一个无符号整数，表示要打包的最大交易数。它返回一个指向 Transaction 的指针切片，表示打包的交易。
 	txpool.lock.Lock()
 	defer txpool.lock.Unlock()
+	if feePolicy() { //按 gas 价格从高到低打包
+		return txpool.packTxsByFee(max_txs)
+	}
 	txNum := max_txs
 	if uint64(len(txpool.TxQueue)) < txNum {
 		txNum = uint64(len(txpool.TxQueue))
 	}
 	txs_Packed := txpool.TxQueue[:txNum]
 	txpool.TxQueue = txpool.TxQueue[txNum:]
+	for _, tx := range txs_Packed {
+		txpool.unindex(tx)
+	}
 	return txs_Packed
 }
 
@@ -87,6 +160,19 @@ func (txpool *TxPool) GetUnlocked() { //GetUnlocked()函数用于解锁交易池
 	txpool.lock.Unlock()
 }
 
+// 交易池中的交易数量，以及因为重复、超过容量而被拒绝或被驱逐的交易总数
+func (txpool *TxPool) Stats() (int, uint64) {
+	txpool.lock.Lock()
+	defer txpool.lock.Unlock()
+	return len(txpool.TxQueue), txpool.dropped
+}
+
+// 替换交易队列并重建索引，用于直接修改 TxQueue 的操作（例如账户迁移），调用者持有锁
+func (txpool *TxPool) ResetTxQueue(txs []*Transaction) {
+	txpool.TxQueue = txs
+	txpool.rebuildIndex()
+}
+
 // get the length of tx queue
 func (txpool *TxPool) GetTxQueueLen() int { //GetTxQueueLen()函数用于获取交易队列的长度
 	txpool.lock.Lock()
@@ -148,7 +234,7 @@ func (txpool *TxPool) TransferTxs(addr utils.Address) []*Transaction {
 			}
 		}
 	}
-	txpool.TxQueue = newTxQueue     //将 newTxQueue 赋值给交易队列
+	txpool.ResetTxQueue(newTxQueue) //将 newTxQueue 赋值给交易队列
 	txpool.RelayPool = newRelayPool //将 newRelayPool 赋值给中继池
 	return txTransfered             //返回需要转移的交易
 }
@@ -0,0 +1,134 @@
+// 按手续费排序的交易池：交易按发送者分成按 nonce 排序的队列，每个队列以队首交易的 gas 价格放入最大堆，
+// 打包时每次取出 gas 价格最高的队首交易，同一发送者的交易仍然按 nonce 顺序打包。
+// 交易仍然保存在 TxQueue 中（账户迁移等操作通过 ResetTxQueue 修改 TxQueue），队列和堆在交易加入和移出交易池时更新。
+// 队列和堆只在 params.TxPoolPolicy 为 fee 时维护，按到达顺序打包时不产生额外的开销。
+
+package core
+
+import (
+	"blockEmulator/params"
+	"container/heap"
+	"sort"
+)
+
+// 同一发送者的交易，按 nonce 排序
+type senderQueue struct {
+	key   string //队列在 TxPool.queues 中的键
+	txs   []*Transaction
+	order uint64 //队列创建的顺序，gas 价格和到达时间都相同时先创建的先打包
+	index int    //队列在堆中的位置
+}
+
+// 队首交易的 gas 价格最高的队列在堆顶
+type feeHeap []*senderQueue
+
+func (h feeHeap) Len() int { return len(h) }
+func (h feeHeap) Less(i, j int) bool {
+	a, b := h[i].txs[0], h[j].txs[0]
+	if c := a.GetGasPrice().Cmp(b.GetGasPrice()); c != 0 {
+		return c > 0
+	}
+	if !a.Time.Equal(b.Time) {
+		return a.Time.Before(b.Time)
+	}
+	return h[i].order < h[j].order
+}
+func (h feeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *feeHeap) Push(x interface{}) {
+	q := x.(*senderQueue)
+	q.index = len(*h)
+	*h = append(*h, q)
+}
+func (h *feeHeap) Pop() interface{} {
+	old := *h
+	q := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return q
+}
+
+// 是否按手续费打包，此时才维护队列和堆
+func feePolicy() bool {
+	return params.TxPoolPolicy == "fee"
+}
+
+// 交易是否需要按发送者的 nonce 顺序执行。中继到本分片的交易、broker 发出的交易和 2PC 的决定不检查 nonce，各自单独排序
+func nonceOrdered(tx *Transaction) bool {
+	return !tx.Relayed && !tx.IsBrokerSent() && !tx.IsTwoPCDecision()
+}
+
+// 交易所在队列的键：需要按 nonce 顺序执行的交易按发送者分组，其他交易各自单独成为一个队列
+func queueKey(tx *Transaction) string {
+	if nonceOrdered(tx) {
+		return tx.Sender
+	}
+	return "tx:" + string(tx.TxHash)
+}
+
+// 把交易按 nonce 插入所在的队列，队首变化时调整堆，调用者持有锁
+func (txpool *TxPool) enqueue(tx *Transaction) {
+	key := queueKey(tx)
+	q, ok := txpool.queues[key]
+	if !ok {
+		q = &senderQueue{key: key, order: txpool.queueOrder}
+		txpool.queueOrder++
+		txpool.queues[key] = q
+	}
+	i := sort.Search(len(q.txs), func(i int) bool { return q.txs[i].Nonce > tx.Nonce }) //nonce 相同时先到达的在前
+	q.txs = append(q.txs, nil)
+	copy(q.txs[i+1:], q.txs[i:])
+	q.txs[i] = tx
+	if !ok {
+		heap.Push(&txpool.fee, q)
+	} else if i == 0 {
+		heap.Fix(&txpool.fee, q.index)
+	}
+}
+
+// 把交易从所在的队列中移出，队列为空时从堆中删除，调用者持有锁
+func (txpool *TxPool) dequeue(tx *Transaction) {
+	q, ok := txpool.queues[queueKey(tx)]
+	if !ok {
+		return
+	}
+	for i, qtx := range q.txs {
+		if qtx != tx {
+			continue
+		}
+		q.txs = append(q.txs[:i], q.txs[i+1:]...)
+		if len(q.txs) == 0 {
+			heap.Remove(&txpool.fee, q.index)
+			delete(txpool.queues, q.key)
+		} else if i == 0 {
+			heap.Fix(&txpool.fee, q.index)
+		}
+		return
+	}
+}
+
+// 按 gas 价格打包最多 max_txs 笔交易，调用者持有锁
+func (txpool *TxPool) packTxsByFee(max_txs uint64) []*Transaction {
+	packed := make([]*Transaction, 0, max_txs)
+	for uint64(len(packed)) < max_txs && txpool.fee.Len() > 0 {
+		tx := txpool.fee[0].txs[0]
+		packed = append(packed, tx)
+		txpool.unindex(tx)
+	}
+
+	// 没有被打包的交易按原来的顺序留在 TxQueue 中
+	isPacked := make(map[*Transaction]bool, len(packed))
+	for _, tx := range packed {
+		isPacked[tx] = true
+	}
+	remain := make([]*Transaction, 0, len(txpool.TxQueue)-len(packed))
+	for _, tx := range txpool.TxQueue {
+		if !isPacked[tx] {
+			remain = append(remain, tx)
+		}
+	}
+	txpool.TxQueue = remain
+	return packed
+}
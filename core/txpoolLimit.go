@@ -0,0 +1,106 @@
+// 有界的交易池：限制交易池和每个发送者的交易数量，按哈希检测重复的交易，超过容量时按驱逐策略驱逐交易。
+// 中继交易、broker 发出的交易和 2PC 的决定完成已经开始执行的跨分片交易，它们不受容量限制，也不会被驱逐。
+
+package core
+
+import (
+	"blockEmulator/params"
+	"sort"
+	"time"
+)
+
+// 交易是否受容量限制，即由主管节点注入的交易
+func bounded(tx *Transaction) bool {
+	return nonceOrdered(tx)
+}
+
+// 把交易加入队尾并更新索引，重复的交易和超过发送者容量的交易被拒绝，调用者持有锁
+func (txpool *TxPool) push(tx *Transaction) error {
+	if txpool.hashes[string(tx.TxHash)] {
+		return ErrTxDuplicate
+	}
+	if bounded(tx) && params.TxPoolSenderCapacity > 0 && txpool.senders[tx.Sender] >= params.TxPoolSenderCapacity {
+		return ErrTxSenderCapacity
+	}
+	if tx.Time.IsZero() { //如果Time交易字段是零，表示之前尚未设置，将该字段设置为当前时间，记录交易何时被添加到池中
+		tx.Time = time.Now()
+	}
+	txpool.TxQueue = append(txpool.TxQueue, tx)
+	txpool.index(tx)
+	return nil
+}
+
+func (txpool *TxPool) index(tx *Transaction) {
+	txpool.hashes[string(tx.TxHash)] = true
+	if bounded(tx) {
+		txpool.senders[tx.Sender]++
+	}
+	if feePolicy() {
+		txpool.enqueue(tx)
+	}
+}
+
+func (txpool *TxPool) unindex(tx *Transaction) {
+	delete(txpool.hashes, string(tx.TxHash))
+	if bounded(tx) {
+		if txpool.senders[tx.Sender]--; txpool.senders[tx.Sender] <= 0 {
+			delete(txpool.senders, tx.Sender)
+		}
+	}
+	if feePolicy() {
+		txpool.dequeue(tx)
+	}
+}
+
+// 根据 TxQueue 重建索引，调用者持有锁
+func (txpool *TxPool) rebuildIndex() {
+	txpool.hashes = make(map[string]bool, len(txpool.TxQueue))
+	txpool.senders = make(map[string]int)
+	txpool.queues = make(map[string]*senderQueue)
+	txpool.fee = txpool.fee[:0]
+	for _, tx := range txpool.TxQueue {
+		txpool.index(tx)
+	}
+}
+
+// 交易池超过容量时按驱逐策略驱逐交易：oldest 驱逐最早到达的交易，lowest-fee 驱逐 gas 价格最低的交易（价格相同时驱逐较晚到达的），
+// 返回被驱逐的交易数量，调用者持有锁
+func (txpool *TxPool) evict() int {
+	excess := len(txpool.TxQueue) - params.TxPoolCapacity
+	if params.TxPoolCapacity <= 0 || excess <= 0 {
+		return 0
+	}
+	candidates := make([]*Transaction, 0, len(txpool.TxQueue))
+	for _, tx := range txpool.TxQueue {
+		if bounded(tx) {
+			candidates = append(candidates, tx)
+		}
+	}
+	if params.TxPoolEviction == "lowest-fee" {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if c := candidates[i].GetGasPrice().Cmp(candidates[j].GetGasPrice()); c != 0 {
+				return c < 0
+			}
+			return candidates[i].Time.After(candidates[j].Time)
+		})
+	} else {
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Time.Before(candidates[j].Time) })
+	}
+	if excess > len(candidates) {
+		excess = len(candidates)
+	}
+	evicted := make(map[*Transaction]bool, excess)
+	for _, tx := range candidates[:excess] {
+		evicted[tx] = true
+		txpool.unindex(tx)
+	}
+	remain := txpool.TxQueue[:0]
+	for _, tx := range txpool.TxQueue {
+		if !evicted[tx] {
+			remain = append(remain, tx)
+		}
+	}
+	txpool.TxQueue = remain
+	txpool.dropped += uint64(excess)
+	return excess
+}
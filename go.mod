@@ -3,9 +3,11 @@ module blockEmulator
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/boltdb/bolt v1.3.1
 	github.com/ethereum/go-ethereum v1.11.6
 	github.com/spf13/pflag v1.0.5
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -2,6 +2,8 @@ package main
 
 import (
 	"blockEmulator/build"
+	"blockEmulator/params"
+	"log"
 
 	"github.com/spf13/pflag"
 )
@@ -17,11 +19,20 @@ var (
 	modID    int
 	isClient bool
 	isGen    bool
+	isLocal  bool
+
+	faultBehavior string
+	faultyNum     int
+
+	configPath string
 )
 
 /*定义全局变量：
 shardNum、nodeNum、shardID、nodeID、modID：这些变量保存系统的各种配置参数，例如分片数量、节点数量、分片和节点 ID 以及选择委员会方法 ID。
-isClient和isGen：这些布尔变量指示节点是否是客户端或者是否要生成某种批处理文件。*/
+isClient和isGen：这些布尔变量指示节点是否是客户端或者是否要生成某种批处理文件。
+isLocal：该布尔变量指示是否以单进程模式运行，主管节点和所有节点作为协程运行在同一个进程中。
+faultBehavior和faultyNum：用于拜占庭故障注入实验，分别表示注入的拜占庭行为，以及生成批处理文件时每个分片中被注入该行为的节点数量。
+configPath：实验配置文件的路径（yaml、json 或 toml），为空时使用 params 中的默认配置。*/
 
 func main() {
 	pflag.IntVarP(&shardNum, "shardNum", "S", 2, "indicate that how many shards are deployed")
@@ -34,18 +45,72 @@ func main() {
 	pflag.IntVarP(&nodeNum, "nodeNum", "N", 4, "indicate how many nodes of each shard are deployed")
 	pflag.IntVarP(&shardID, "shardID", "s", 0, "id of the shard to which this node belongs, for example, 0")
 	pflag.IntVarP(&nodeID, "nodeID", "n", 0, "id of this node, for example, 0")
-	pflag.IntVarP(&modID, "modID", "m", 3, "choice Committee Method,for example, 0, [CLPA_Broker,CLPA,Broker,Relay] ")
+	pflag.IntVarP(&modID, "modID", "m", 3, "choice Committee Method,for example, 0, [CLPA_Broker,CLPA,Broker,Relay,2PC] ")
 	pflag.BoolVarP(&isClient, "client", "c", false, "whether this node is a client")
 	pflag.BoolVarP(&isGen, "gen", "g", false, "generation bat")
+	pflag.BoolVarP(&isLocal, "local", "l", false, "run the supervisor and all nodes in this process, they communicate over an in-memory network")
+	pflag.StringVarP(&faultBehavior, "fault", "f", "", "byzantine behavior of this node, empty for an honest node, for example, Equivocation, [Equivocation,WrongVote,Drop,Delay,InvalidBlock,Silent]")
+	pflag.IntVarP(&faultyNum, "faultyNum", "F", 0, "used with -g, -l or launch, the nodes whose id is less than faultyNum in each shard are given the -f behavior")
+	pflag.StringVarP(&configPath, "config", "C", "", "path of the experiment config file (.yaml, .yml, .json or .toml), the -S, -N and -m flags given explicitly override the values in it")
 	pflag.Parse()
 
+	loadExperimentConfig()
+
+	if pflag.NArg() > 0 && pflag.Arg(0) == "launch" { //启动器子命令：在本机启动所有节点进程和主管节点进程，等待实验结束
+		if err := build.LaunchExperiment(nodeNum, shardNum, modID, faultyNum, faultBehavior, configPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if isGen { //是否生成批处理文件
-		build.GenerateBatFile(nodeNum, shardNum, modID) //传入参数：节点数量、分片数量、委员会方法 ID
+		build.GenerateBatFile(nodeNum, shardNum, modID, faultyNum, faultBehavior, configPath) //传入参数：节点数量、分片数量、委员会方法 ID、每个分片的拜占庭节点数量、拜占庭行为、实验配置文件
+		return
+	}
+	if isLocal { //是否以单进程模式运行
+		build.RunLocalCluster(uint64(nodeNum), uint64(shardNum), uint64(modID), faultyNum, faultBehavior) //传入参数：节点数量、分片数量、委员会方法 ID、每个分片的拜占庭节点数量、拜占庭行为
 		return
 	}
 	if isClient { //是否是客户端
 		build.BuildSupervisor(uint64(nodeNum), uint64(shardNum), uint64(modID)) //传入参数：节点数量、分片数量、委员会方法 ID
 	} else {
-		build.BuildNewPbftNode(uint64(nodeID), uint64(nodeNum), uint64(shardID), uint64(shardNum), uint64(modID)) //传入参数：节点 ID、节点数量、分片 ID、分片数量、委员会方法 ID
+		build.BuildNewPbftNode(uint64(nodeID), uint64(nodeNum), uint64(shardID), uint64(shardNum), uint64(modID), faultBehavior) //传入参数：节点 ID、节点数量、分片 ID、分片数量、委员会方法 ID、拜占庭行为
+	}
+}
+
+// 读取实验配置文件并写入 params 中的全局变量。没有配置文件时分片数量、节点数量和委员会方法由命令行参数决定；
+// 有配置文件时只有显式给出的命令行参数才覆盖配置文件中的值。主管节点会把实际使用的配置写入测量结果的目录
+func loadExperimentConfig() {
+	cfg := params.DefaultExperimentConfig()
+	if configPath != "" {
+		var err error
+		if cfg, err = params.LoadExperimentConfig(configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if configPath == "" || pflag.CommandLine.Changed("shardNum") {
+		cfg.ShardNum = shardNum
+	}
+	if configPath == "" || pflag.CommandLine.Changed("nodeNum") {
+		cfg.NodesInShard = nodeNum
+	}
+	if configPath == "" || pflag.CommandLine.Changed("modID") {
+		if modID < 0 || modID >= len(params.CommitteeMethod) {
+			log.Fatalf("modID should be in [0, %d), got %d", len(params.CommitteeMethod), modID)
+		}
+		cfg.CommitteeMethod = params.CommitteeMethod[modID]
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	cfg.Apply()
+	shardNum, nodeNum, modID = cfg.ShardNum, cfg.NodesInShard, cfg.ModID()
+
+	if isClient || isLocal {
+		path, err := cfg.Dump()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("the effective experiment config is written to", path)
 	}
 }
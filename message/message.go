@@ -18,6 +18,7 @@ const (
 	CRequestOldrequest MessageType = "requestOldrequest" //表示请求旧请求消息
 	CSendOldrequest    MessageType = "sendOldrequest"    //表示发送旧请求消息
 	CStop              MessageType = "stop"              //表示停止消息
+	CCheckpoint        MessageType = "checkpoint"        //表示检查点消息
 
 	CRelay  MessageType = "relay"  //表示中继消息
 	CInject MessageType = "inject" //表示注入消息
@@ -46,6 +47,7 @@ type PrePrepare struct { //PrePrepare结构包含预准备消息的各种信息
 	RequestMsg *Request //指向请求消息的指针
 	Digest     []byte   //该请求的摘要，这是唯一的标识符
 	SeqID      uint64   //序列ID
+	View       uint64   //提出该请求时的视图ID
 }
 
 type Prepare struct { //Prepare结构包含准备消息的各种信息
@@ -58,6 +60,14 @@ type Commit struct { //Commit结构包含提交消息的各种信息
 	Digest     []byte      //该请求的摘要，这是唯一的标识符
 	SeqID      uint64      //序列ID
 	SenderNode *shard.Node //发送此消息的节点
+	BlockHash  []byte      //该请求中区块的哈希，非区块请求为空。2f+1 个签名的提交消息组成该区块的提交证书
+}
+
+type Checkpoint struct { //Checkpoint结构包含检查点消息的各种信息
+	SeqID      uint64      //检查点对应的序列ID
+	StateRoot  []byte      //提交该序列后的状态树根
+	BlockHash  []byte      //提交该序列后的最新区块哈希
+	SenderNode *shard.Node //发送此消息的节点
 }
 
 type Reply struct { //Reply结构包含响应消息的各种信息
@@ -103,6 +113,11 @@ type BlockInfoMsg struct { //BlockInfoMsg结构包含区块信息消息的各种
 	Broker1Txs   []*core.Transaction // cross transactions at first time by broker
 	Broker2TxNum uint64              // the number of broker 2
 	Broker2Txs   []*core.Transaction // cross transactions at second time by broker
+
+	// 交易池的状态，主管节点据此调整注入交易的速度（背压）
+	TxPoolSize     int    //交易池中的交易数量
+	TxPoolCapacity int    //交易池的容量，0 表示不限制
+	TxPoolDropped  uint64 //交易池因为重复、超过容量而拒绝或驱逐的交易总数
 }
 
 type SeqIDinfo struct { //SeqIDinfo结构包含序列ID信息消息的各种信息
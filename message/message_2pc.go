@@ -0,0 +1,14 @@
+package message
+
+// 两阶段提交（2PC）：分片主节点提交区块后，把区块中 2PC 交易的执行结果报告给协调者（主管节点）。
+// 协调者收到两个分片的赞成票后提交，收到反对票或者超时后中止，决定作为提交或中止交易注入两个分片
+var CTwoPCVote MessageType = "TwoPCVote" //表示 2PC 投票消息
+
+type TwoPCVote struct { //TwoPCVote结构包含 2PC 投票消息的各种信息
+	Prepared      [][]byte //准备成功的原始交易哈希，即赞成票
+	Refused       [][]byte //准备失败的原始交易哈希，即反对票
+	LockConflicts int      //因为账户被其他 2PC 交易锁定而准备失败的交易数量
+	Committed     int      //本分片作为发送者分片执行的提交交易数量
+	Aborted       int      //本分片作为发送者分片执行的中止交易数量
+	SenderShardID uint64   //发送此消息的分片ID
+}
@@ -0,0 +1,15 @@
+package message
+
+import "time"
+
+var CCommitInfo MessageType = "CommitInfo" //表示节点提交请求后向主管节点报告的消息，用于测量拜占庭实验中的安全性和活性
+
+type CommitInfo struct { //CommitInfo结构包含节点提交请求的各种信息
+	ShardID    uint64    //分片ID
+	NodeID     uint64    //节点ID
+	SeqID      uint64    //提交的序列ID
+	View       uint64    //提交时的视图ID
+	Digest     []byte    //提交的请求摘要
+	Faulty     bool      //发送者是否被注入了拜占庭行为，安全性只在诚实节点之间检查
+	CommitTime time.Time //提交时间
+}
@@ -0,0 +1,10 @@
+package message
+
+// 轻客户端的区块头同步：分片主节点提交区块后，把区块头和提交证书发给其他分片的节点
+var CHeaderSync MessageType = "HeaderSync" //表示区块头同步消息
+
+type HeaderSync struct { //HeaderSync结构包含区块头同步消息的各种信息
+	Header        []byte   //编码后的区块头
+	Certificate   [][]byte //提交证书，即 2f+1 个节点对该区块签名的提交消息
+	SenderShardID uint64   //发送此消息的分片ID
+}
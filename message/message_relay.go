@@ -1,10 +1,42 @@
 package message
 
-import "blockEmulator/core"
+import (
+	"blockEmulator/core"
+	"bytes"
+	"encoding/json"
+)
 
 // 如果使用事务中继，该消息也用于发送序列ID
 type Relay struct { //Relay结构包含中继消息的各种信息
 	Txs           []*core.Transaction //指向交易的指针
 	SenderShardID uint64              //发送此消息的分片ID
 	SenderSeq     uint64              //发送此消息的序列ID
+
+	// 开启 RelayProof 时，源分片附上包含这些交易的区块头、该区块的提交证书以及每笔交易的默克尔证明
+	Header      []byte     //编码后的区块头（json 会改变区块头中时间的时区，使区块头的哈希改变）
+	Certificate [][]byte   //提交证书，即源分片 2f+1 个节点对该区块签名的提交消息（SignedMessage）
+	Proofs      [][][]byte //每笔交易相对于区块头中交易树根的默克尔证明，顺序与 Txs 相同
+}
+
+// 验证提交证书：证书中至少有 quorum 个 shardID 分片的不同节点对同一个请求签名了提交消息，并且消息中的区块哈希为 blockHash
+func VerifyCommitCertificate(cert [][]byte, shardID uint64, blockHash []byte, quorum int) bool {
+	var digest []byte
+	signers := make(map[uint64]bool)
+	for _, signed := range cert {
+		msgType, content, signer, ok := OpenSignedMessage(signed)
+		if !ok || msgType != CCommit || signer.ShardID != shardID {
+			continue
+		}
+		c := new(Commit)
+		if err := json.Unmarshal(content, c); err != nil || !bytes.Equal(c.BlockHash, blockHash) {
+			continue
+		}
+		if digest == nil {
+			digest = c.Digest
+		}
+		if bytes.Equal(c.Digest, digest) {
+			signers[signer.NodeID] = true
+		}
+	}
+	return len(signers) >= quorum
 }
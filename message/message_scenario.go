@@ -0,0 +1,13 @@
+package message
+
+// 主管节点的实验场景发给节点的控制消息
+var (
+	CIsolate MessageType = "Isolate" //表示隔离消息，节点丢弃与指定节点之间的所有通信
+	CHeal    MessageType = "Heal"    //表示恢复消息，节点恢复与指定节点之间的通信
+	CCrash   MessageType = "Crash"   //表示崩溃消息，节点停止参与共识，只响应恢复消息
+	CRestart MessageType = "Restart" //表示重启消息，崩溃的节点恢复运行，并通过追赶机制同步错过的区块
+)
+
+type IsolateMsg struct { //IsolateMsg结构用于隔离消息和恢复消息
+	Peers []string //对端节点的地址，为空表示所有节点（主管节点除外）
+}
@@ -0,0 +1,112 @@
+package message
+
+import (
+	"blockEmulator/shard"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+)
+
+var CSigned MessageType = "Signed" //表示带签名的消息，里面包装了一条共识消息或跨分片消息
+
+// 节点之间的共识消息和跨分片消息必须签名，没有签名的这类消息会被拒绝
+var signedMessageTypes = map[MessageType]bool{
+	CPrePrepare:                true,
+	CPrepare:                   true,
+	CCommit:                    true,
+	CCheckpoint:                true,
+	CRequestOldrequest:         true,
+	CSendOldrequest:            true,
+	CViewChange:                true,
+	CNewView:                   true,
+	CLeaderInfo:                true,
+	CRelay:                     true,
+	CHeaderSync:                true,
+	CTwoPCVote:                 true,
+	AccountState_and_TX:        true,
+	CPartitionReady:            true,
+	CAccountTransferMsg_broker: true,
+	CSeqIDinfo:                 true,
+	CCommitInfo:                true,
+}
+
+type SignedMessage struct { //SignedMessage结构包含带签名的消息的各种信息
+	MsgType    MessageType //被包装的消息类型
+	Content    []byte      //被包装的消息内容
+	SenderNode *shard.Node //签名的节点
+	Signature  []byte      //签名
+}
+
+// 判断该类型的消息是否必须签名
+func NeedSignature(msgType MessageType) bool {
+	return signedMessageTypes[msgType]
+}
+
+// 被签名的内容：消息类型、消息内容以及签名者的分片ID和节点ID
+func (sm *SignedMessage) signingBytes() []byte {
+	b := MergeMessage(sm.MsgType, sm.Content)
+	b = binary.BigEndian.AppendUint64(b, sm.SenderNode.ShardID)
+	return binary.BigEndian.AppendUint64(b, sm.SenderNode.NodeID)
+}
+
+// 用节点的私钥对消息签名，返回可以直接发送的消息
+func MergeSignedMessage(msgType MessageType, content []byte, sender *shard.Node, key ed25519.PrivateKey) []byte {
+	sm := &SignedMessage{
+		MsgType:    msgType,
+		Content:    content,
+		SenderNode: sender,
+	}
+	sm.Signature = ed25519.Sign(key, sm.signingBytes())
+	smByte, err := json.Marshal(sm)
+	if err != nil {
+		log.Panic(err)
+	}
+	return MergeMessage(CSigned, smByte)
+}
+
+// 用签名者的公钥验证签名
+func (sm *SignedMessage) Verify() bool {
+	if sm.SenderNode == nil {
+		return false
+	}
+	pub, err := shard.GetNodePublicKey(sm.SenderNode.ShardID, sm.SenderNode.NodeID)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pub, sm.signingBytes(), sm.Signature)
+}
+
+// 检查被包装的消息中声明的发送者是否与签名者一致，防止节点冒充其他节点或其他分片
+func (sm *SignedMessage) CheckSender() bool {
+	declared := new(struct {
+		SenderNode    *shard.Node
+		SenderShardID *uint64
+		FromShard     *uint64
+	})
+	if err := json.Unmarshal(sm.Content, declared); err != nil {
+		return true // 非 json 编码的消息没有声明发送者
+	}
+	if declared.SenderNode != nil && (declared.SenderNode.ShardID != sm.SenderNode.ShardID || declared.SenderNode.NodeID != sm.SenderNode.NodeID) {
+		return false
+	}
+	if declared.SenderShardID != nil && *declared.SenderShardID != sm.SenderNode.ShardID {
+		return false
+	}
+	if declared.FromShard != nil && *declared.FromShard != sm.SenderNode.ShardID {
+		return false
+	}
+	return true
+}
+
+// 解析并验证带签名的消息，返回被包装的消息类型、内容以及签名者
+func OpenSignedMessage(content []byte) (MessageType, []byte, *shard.Node, bool) {
+	sm := new(SignedMessage)
+	if err := json.Unmarshal(content, sm); err != nil {
+		return "", nil, nil, false
+	}
+	if !sm.Verify() || !sm.CheckSender() {
+		return sm.MsgType, nil, sm.SenderNode, false
+	}
+	return sm.MsgType, sm.Content, sm.SenderNode, true
+}
@@ -0,0 +1,53 @@
+package message
+
+import (
+	"blockEmulator/shard"
+	"encoding/json"
+)
+
+// 视图切换相关的消息，当主节点崩溃或长时间不出块时使用
+var (
+	CViewChange MessageType = "ViewChange" //表示视图切换消息
+	CNewView    MessageType = "NewView"    //表示新视图消息
+	CLeaderInfo MessageType = "LeaderInfo" //表示分片主节点变更的通知消息
+)
+
+type ViewChange struct { //ViewChange结构包含视图切换消息的各种信息
+	NextView      uint64      //希望切换到的视图ID
+	LastSeqID     uint64      //发送者已经提交的最大序列ID
+	PreparedSeqID uint64      //发送者已经 prepare（广播过commit）但尚未提交的序列ID
+	PreparedReq   *Request    //与 PreparedSeqID 对应的请求，若没有则为空
+	SenderNode    *shard.Node //发送此消息的节点
+}
+
+type NewView struct { //NewView结构包含新视图消息的各种信息
+	View        uint64      //新的视图ID
+	ViewChanges [][]byte    //新主节点收集到的 2f+1 条带签名的视图切换消息（SignedMessage），作为切换的证明
+	Reproposal  *PrePrepare //需要在新视图中重新提议的请求，若没有则为空
+	SenderNode  *shard.Node //发送此消息的节点（新主节点）
+}
+
+type LeaderInfo struct { //LeaderInfo结构用于通知其他分片以及主管节点某个分片的主节点已经改变
+	ShardID  uint64 //分片ID
+	LeaderID uint64 //新的主节点ID
+	View     uint64 //当前视图ID
+}
+
+// 验证新视图消息中的视图切换证明，返回其中签名有效、由 shardID 分片的节点发出并且切换到 view 的视图切换消息，每个节点只计一条
+func VerifyViewChanges(proofs [][]byte, shardID, view uint64) []*ViewChange {
+	vcs := make([]*ViewChange, 0, len(proofs))
+	senders := make(map[uint64]bool)
+	for _, signed := range proofs {
+		msgType, content, signer, ok := OpenSignedMessage(signed)
+		if !ok || msgType != CViewChange || signer.ShardID != shardID || senders[signer.NodeID] {
+			continue
+		}
+		vc := new(ViewChange)
+		if err := json.Unmarshal(content, vc); err != nil || vc.NextView != view || vc.SenderNode == nil {
+			continue
+		}
+		senders[signer.NodeID] = true
+		vcs = append(vcs, vc)
+	}
+	return vcs
+}
@@ -1,2 +0,0 @@
-package networks
-procedure Selectshard (S，k，m)
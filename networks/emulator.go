@@ -0,0 +1,147 @@
+// 网络仿真：为节点之间的每条链路加上时延、带宽限制和丢包，
+// 不需要 tc 等外部工具就可以在本机模拟广域网环境。
+
+package networks
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// 链路的参数，时延的单位是毫秒，带宽的单位是字节每秒
+type LinkProfile struct {
+	Latency   float64 //单向时延的均值（毫秒）
+	Jitter    float64 //时延的抖动（毫秒），含义由 Dist 决定
+	Dist      string  //时延的分布：constant、uniform（Latency±Jitter）、normal（标准差为 Jitter）、exponential（Latency 加上均值为 Jitter 的指数分布）
+	Bandwidth float64 //链路带宽（字节每秒），0 表示不限制
+	LossRate  float64 //丢包率，TCP 会重传丢失的包，因此丢包表现为额外的重传时延
+}
+
+const minRTO = 200 * time.Millisecond //TCP 最小重传超时时间
+
+// 网络拓扑：区域之间的链路参数，从 json 文件中读取
+type Topology struct {
+	Default LinkProfile       //没有单独配置的链路使用的参数
+	Regions map[string]string //分片（分片ID，主管节点为 "supervisor"）所在的区域，没有配置的分片单独作为一个区域，区域名为分片ID
+	Links   []struct {
+		From, To string      //区域名
+		Both     bool        //是否同时配置反方向的链路
+		Profile  LinkProfile //链路参数
+	}
+}
+
+// 从 json 文件中读取网络拓扑
+func LoadTopology(path string) (*Topology, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tp := new(Topology)
+	if err := json.Unmarshal(b, tp); err != nil {
+		return nil, err
+	}
+	return tp, nil
+}
+
+// 网络仿真器，所有发出的消息都由它计算到达时间
+type NetworkEmulator struct {
+	lock        sync.Mutex
+	defaultLink LinkProfile
+	regionOf    map[string]string                 //节点地址 -> 区域
+	links       map[string]map[string]LinkProfile //发送区域 -> 接收区域 -> 链路参数
+	busyUntil   map[string]time.Time              //每条链路的带宽被占用到的时间
+	lastArrival map[string]time.Time              //每条链路上最后一条消息的到达时间，保证同一条链路上的消息按顺序到达
+	rng         *rand.Rand
+}
+
+func NewNetworkEmulator(defaultLink LinkProfile) *NetworkEmulator {
+	return &NetworkEmulator{
+		defaultLink: defaultLink,
+		regionOf:    make(map[string]string),
+		links:       make(map[string]map[string]LinkProfile),
+		busyUntil:   make(map[string]time.Time),
+		lastArrival: make(map[string]time.Time),
+		rng:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// 设置节点所在的区域
+func (ne *NetworkEmulator) SetRegion(addr, region string) {
+	ne.lock.Lock()
+	defer ne.lock.Unlock()
+	ne.regionOf[addr] = region
+}
+
+// 设置从区域 from 到区域 to 的链路参数（单向）
+func (ne *NetworkEmulator) SetLink(from, to string, lp LinkProfile) {
+	ne.lock.Lock()
+	defer ne.lock.Unlock()
+	if _, ok := ne.links[from]; !ok {
+		ne.links[from] = make(map[string]LinkProfile)
+	}
+	ne.links[from][to] = lp
+}
+
+// 获取两个节点之间的链路参数
+func (ne *NetworkEmulator) getLink(src, dst string) LinkProfile {
+	if lp, ok := ne.links[ne.regionOf[src]][ne.regionOf[dst]]; ok {
+		return lp
+	}
+	return ne.defaultLink
+}
+
+// 按照分布抽取一次单向时延
+func (ne *NetworkEmulator) sampleLatency(lp LinkProfile) time.Duration {
+	ms := lp.Latency
+	switch lp.Dist {
+	case "", "constant":
+	case "uniform":
+		ms += (ne.rng.Float64()*2 - 1) * lp.Jitter
+	case "normal":
+		ms += ne.rng.NormFloat64() * lp.Jitter
+	case "exponential":
+		ms += ne.rng.ExpFloat64() * lp.Jitter
+	default:
+		log.Panic("unknown delay distribution: ", lp.Dist)
+	}
+	return time.Duration(math.Max(ms, 0) * float64(time.Millisecond))
+}
+
+// 计算从 src 发往 dst 的 size 字节消息的到达时间，并占用链路的带宽
+func (ne *NetworkEmulator) Schedule(src, dst string, size int) time.Time {
+	ne.lock.Lock()
+	defer ne.lock.Unlock()
+	lp := ne.getLink(src, dst)
+	key := src + "->" + dst
+	now := time.Now()
+
+	depart := now // 带宽限制：消息排队等待链路空闲，然后按带宽发送
+	if lp.Bandwidth > 0 {
+		if ne.busyUntil[key].After(depart) {
+			depart = ne.busyUntil[key]
+		}
+		depart = depart.Add(time.Duration(float64(size) / lp.Bandwidth * float64(time.Second)))
+		ne.busyUntil[key] = depart
+	}
+
+	latency := ne.sampleLatency(lp)
+	for lp.LossRate > 0 && ne.rng.Float64() < lp.LossRate { // 每丢一次包，多等一个重传超时
+		rto := 2 * time.Duration(lp.Latency*float64(time.Millisecond))
+		if rto < minRTO {
+			rto = minRTO
+		}
+		latency += rto
+	}
+
+	arrival := depart.Add(latency)
+	if arrival.Before(ne.lastArrival[key]) { // 同一条链路上不会乱序
+		arrival = ne.lastArrival[key]
+	}
+	ne.lastArrival[key] = arrival
+	return arrival
+}
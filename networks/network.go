@@ -0,0 +1,114 @@
+// 底层网络：节点通过它监听和建立连接。默认使用 TCP，
+// 单进程模式下所有节点运行在同一个进程中，使用进程内的内存网络。
+
+package networks
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// 底层网络的接口
+type Network interface {
+	Listen(addr string) (net.Listener, error)                  //在 addr 上监听
+	Dial(addr string, timeout time.Duration) (net.Conn, error) //与 addr 建立连接
+}
+
+type tcpNetwork struct{}
+
+func (tcpNetwork) Listen(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+func (tcpNetwork) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("tcp", addr, timeout)
+}
+
+var TCPNetwork Network = tcpNetwork{} //默认的 TCP 网络
+
+var ErrConnRefused = errors.New("connection refused")
+
+// 进程内的内存网络，连接由 net.Pipe 实现，监听器通过 channel 把新连接交给 Accept
+type MemNetwork struct {
+	lock      sync.Mutex
+	listeners map[string]*memListener
+}
+
+func NewMemNetwork() *MemNetwork {
+	return &MemNetwork{
+		listeners: make(map[string]*memListener),
+	}
+}
+
+func (mn *MemNetwork) Listen(addr string) (net.Listener, error) {
+	mn.lock.Lock()
+	defer mn.lock.Unlock()
+	if _, ok := mn.listeners[addr]; ok {
+		return nil, errors.New("address already in use: " + addr)
+	}
+	ml := &memListener{
+		mn:     mn,
+		addr:   memAddr(addr),
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+	mn.listeners[addr] = ml
+	return ml, nil
+}
+
+func (mn *MemNetwork) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	mn.lock.Lock()
+	ml, ok := mn.listeners[addr]
+	mn.lock.Unlock()
+	if !ok {
+		return nil, ErrConnRefused
+	}
+	local, remote := net.Pipe()
+	select {
+	case ml.conns <- remote:
+		return local, nil
+	case <-ml.closed:
+	case <-time.After(timeout):
+	}
+	local.Close()
+	remote.Close()
+	return nil, ErrConnRefused
+}
+
+type memListener struct {
+	mn     *MemNetwork
+	addr   memAddr
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (ml *memListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-ml.conns:
+		return conn, nil
+	case <-ml.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (ml *memListener) Close() error {
+	ml.once.Do(func() {
+		close(ml.closed)
+		ml.mn.lock.Lock()
+		delete(ml.mn.listeners, string(ml.addr))
+		ml.mn.lock.Unlock()
+	})
+	return nil
+}
+
+func (ml *memListener) Addr() net.Addr {
+	return ml.addr
+}
+
+type memAddr string
+
+func (ma memAddr) Network() string { return "mem" }
+func (ma memAddr) String() string  { return string(ma) }
@@ -3,31 +3,54 @@
 package networks
 
 import (
-	"log"
 	"net"
 	"sync"
 )
 
-var connMaplock sync.Mutex                        //互斥锁
-var connectionPool = make(map[string]net.Conn, 0) //
-
-func TcpDial(context []byte, addr string) { //TcpDial函数用于建立TCP连接
-	var conn net.Conn
-	connMaplock.Lock() //加锁
-	defer connMaplock.Unlock()
-	if connectionPool[addr] == nil { //如果连接池中没有该连接，则建立连接
-		conn, err := net.Dial("tcp", addr) //建立TCP连接
-		if err != nil {
-			log.Println("connect error", err)
-			return
-		}
-		connectionPool[addr] = conn //将连接存入连接池
-	}
-	conn = connectionPool[addr] //如果连接池中有该连接，则直接使用
+var transportLock sync.Mutex          //互斥锁
+var defaultTransport = NewTransport() //节点默认使用的长连接传输
+var curNetwork = TCPNetwork           //当前使用的底层网络
 
-	_, err := conn.Write(append(context, '\n')) //发送消息
-	if err != nil {
-		return
+func getTransport() *Transport {
+	transportLock.Lock()
+	defer transportLock.Unlock()
+	return defaultTransport
+}
+
+func getNetwork() Network {
+	transportLock.Lock()
+	defer transportLock.Unlock()
+	return curNetwork
+}
+
+// 在当前的底层网络上监听，节点和主管节点都通过它监听
+func Listen(addr string) (net.Listener, error) {
+	return getNetwork().Listen(addr)
+}
+
+// 切换底层网络，之后发出的消息都使用新的网络，原来的连接被关闭
+func SetNetwork(nw Network) {
+	transportLock.Lock()
+	curNetwork = nw
+	old := replaceTransport()
+	transportLock.Unlock()
+	old.Close()
+}
+
+// 用新的传输替换默认传输，保留原来的设置，调用者需要持有 transportLock
+func replaceTransport() *Transport {
+	old := defaultTransport
+	defaultTransport = NewTransportOn(curNetwork)
+	old.lock.Lock()
+	defaultTransport.onError = old.onError
+	defaultTransport.emulator, defaultTransport.localAddr = old.emulator, old.localAddr
+	old.lock.Unlock()
+	return old
+}
+
+func TcpDial(context []byte, addr string) { //TcpDial函数用于发送消息，消息放入对端的发送队列，由长连接发送
+	if err := getTransport().Send(context, addr); err != nil {
+		getTransport().reportError(addr, err)
 	}
 }
 
@@ -36,15 +59,27 @@ func Broadcast(sender string, receivers []string, msg []byte) { //Broadcast函
 		if ip == sender { //如果遍历到的接收者为发送者，则跳过
 			continue
 		}
-		go TcpDial(msg, ip) //建立TCP连接
+		go TcpDial(msg, ip) //发送消息
 	}
 }
 
-func CloseAllConnInPool() { //CloseAllConnInPool函数用于关闭连接池中的所有连接
-	for _, conn := range connectionPool {
-		conn.Close()
-	}
+// 设置发送失败时的回调，默认打印日志
+func SetSendErrorHandler(h SendErrorHandler) {
+	getTransport().SetErrorHandler(h)
 }
 
-// todo
-// long connect (not close immediately) ...
+// 设置网络仿真器，之后 TcpDial 和 Broadcast 发出的消息都经过它，localAddr 为本机地址
+func SetNetworkEmulator(ne *NetworkEmulator, localAddr string) {
+	getTransport().SetEmulator(ne, localAddr)
+}
+
+func CloseAllConnInPool() { //CloseAllConnInPool函数用于关闭所有连接，之后发送的消息使用新的连接
+	transportLock.Lock()
+	if _, ok := curNetwork.(*MemNetwork); ok { //单进程模式下所有节点共用一个传输，由 SetNetwork 统一关闭
+		transportLock.Unlock()
+		return
+	}
+	old := replaceTransport()
+	transportLock.Unlock()
+	old.Close()
+}
@@ -0,0 +1,244 @@
+// 基于长度前缀的长连接传输：每条消息前面加上 4 字节的长度，
+// 每个对端有一个发送队列和一个发送协程，连接断开后按退避时间自动重连。
+
+package networks
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	MaxFrameSize   = 64 << 20               //单条消息的最大长度
+	sendQueueSize  = 4096                   //每个对端发送队列的长度
+	dialTimeout    = 3 * time.Second        //建立连接的超时时间
+	minBackoff     = 50 * time.Millisecond  //重连的初始等待时间
+	maxBackoff     = 2 * time.Second        //重连的最长等待时间
+	maxSendRetries = 8                      //一条消息最多尝试发送的次数
+	writeTimeout   = 10 * time.Second       //写入一条消息的超时时间
+	closeWait      = 500 * time.Millisecond //关闭时等待发送队列清空的最长时间
+)
+
+var (
+	ErrTransportClosed = errors.New("transport is closed")
+	ErrFrameTooLarge   = errors.New("frame is too large")
+)
+
+// 写入一帧：4 字节大端序长度 + 消息内容
+func WriteFrame(w io.Writer, msg []byte) error {
+	if len(msg) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	buf := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(buf, uint32(len(msg)))
+	copy(buf[4:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+// 读取一帧，返回消息内容
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var head [4]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(head[:])
+	if size > MaxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// 发送失败时的回调，addr 为对端地址
+type SendErrorHandler func(addr string, err error)
+
+// 长连接传输，发往同一个对端的消息按顺序发送
+type Transport struct {
+	lock     sync.Mutex
+	peers    map[string]*peerConn
+	onError  SendErrorHandler
+	closed   bool
+	wg       sync.WaitGroup
+	stopChan chan struct{}
+
+	network   Network          //底层网络
+	emulator  *NetworkEmulator //网络仿真器，为空表示不模拟网络
+	localAddr string           //本机地址，用于确定网络仿真中的链路
+}
+
+// 等待发送的消息
+type frame struct {
+	msg       []byte
+	deliverAt time.Time //网络仿真中消息到达对端的时间
+}
+
+func NewTransport() *Transport {
+	return NewTransportOn(TCPNetwork)
+}
+
+// 在指定的底层网络上创建传输
+func NewTransportOn(nw Network) *Transport {
+	return &Transport{
+		network:  nw,
+		peers:    make(map[string]*peerConn),
+		onError:  func(addr string, err error) { log.Printf("send to %s error: %v\n", addr, err) },
+		stopChan: make(chan struct{}),
+	}
+}
+
+// 设置网络仿真器，localAddr 为本机地址
+func (t *Transport) SetEmulator(ne *NetworkEmulator, localAddr string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.emulator = ne
+	t.localAddr = localAddr
+}
+
+// 设置发送失败时的回调
+func (t *Transport) SetErrorHandler(h SendErrorHandler) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.onError = h
+}
+
+func (t *Transport) reportError(addr string, err error) {
+	t.lock.Lock()
+	h := t.onError
+	t.lock.Unlock()
+	if h != nil {
+		h(addr, err)
+	}
+}
+
+// 把消息放入对端的发送队列，队列满时阻塞，传输关闭后返回错误
+func (t *Transport) Send(msg []byte, addr string) error {
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return ErrTransportClosed
+	}
+	pc, ok := t.peers[addr]
+	if !ok {
+		pc = &peerConn{
+			addr:      addr,
+			transport: t,
+			queue:     make(chan frame, sendQueueSize),
+		}
+		t.peers[addr] = pc
+		t.wg.Add(1)
+		go pc.sendLoop()
+	}
+	f := frame{msg: msg}
+	if t.emulator != nil {
+		f.deliverAt = t.emulator.Schedule(t.localAddr, addr, len(msg))
+	}
+	t.lock.Unlock()
+
+	select {
+	case pc.queue <- f:
+		return nil
+	case <-t.stopChan:
+		return ErrTransportClosed
+	}
+}
+
+// 关闭传输：等待发送队列清空（最多 closeWait），然后关闭所有连接
+func (t *Transport) Close() {
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return
+	}
+	t.closed = true
+	t.lock.Unlock()
+
+	deadline := time.Now().Add(closeWait)
+	for _, pc := range t.peers {
+		for len(pc.queue) > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	close(t.stopChan)
+	t.wg.Wait()
+}
+
+// 一个对端的连接和发送队列
+type peerConn struct {
+	addr      string
+	transport *Transport
+	queue     chan frame
+	conn      net.Conn
+}
+
+func (pc *peerConn) sendLoop() {
+	defer pc.transport.wg.Done()
+	defer pc.closeConn()
+	for {
+		select {
+		case f := <-pc.queue:
+			if wait := time.Until(f.deliverAt); wait > 0 { // 网络仿真：等到消息应该到达的时间再发送
+				select {
+				case <-time.After(wait):
+				case <-pc.transport.stopChan:
+					return
+				}
+			}
+			pc.send(f.msg)
+		case <-pc.transport.stopChan:
+			return
+		}
+	}
+}
+
+// 发送一条消息，失败时关闭连接，按退避时间重连后重试
+func (pc *peerConn) send(msg []byte) {
+	backoff := minBackoff
+	var err error
+	for try := 0; try < maxSendRetries; try++ {
+		if try > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-pc.transport.stopChan:
+				return
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		if pc.conn == nil {
+			if pc.conn, err = pc.transport.network.Dial(pc.addr, dialTimeout); err != nil {
+				pc.conn = nil
+				continue
+			}
+		}
+		pc.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err = WriteFrame(pc.conn, msg); err == nil {
+			return
+		}
+		if err == ErrFrameTooLarge {
+			break
+		}
+		pc.closeConn()
+	}
+	pc.transport.reportError(pc.addr, fmt.Errorf("message dropped: %w", err))
+}
+
+func (pc *peerConn) closeConn() {
+	if pc.conn != nil {
+		pc.conn.Close()
+		pc.conn = nil
+	}
+}
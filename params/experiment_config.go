@@ -0,0 +1,323 @@
+// 实验配置文件：把 global_config.go 中的全局变量以及分片数量、节点数量、委员会方法放到一个文件中，
+// 支持 yaml、json 和 toml 格式，修改实验参数不需要重新编译。文件中没有出现的参数保持默认值。
+
+package params
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type ExperimentConfig struct { //ExperimentConfig结构包含一次实验的所有参数，字段名与全局变量的名称相同
+	ShardNum        int    `yaml:"ShardNum" toml:"ShardNum"`
+	NodesInShard    int    `yaml:"NodesInShard" toml:"NodesInShard"`
+	CommitteeMethod string `yaml:"CommitteeMethod" toml:"CommitteeMethod"` //CLPA_Broker、CLPA、Broker、Relay 或 2PC
+
+	Block_Interval      int     `yaml:"Block_Interval" toml:"Block_Interval"`
+	MaxBlockSize_global int     `yaml:"MaxBlockSize_global" toml:"MaxBlockSize_global"`
+	InjectSpeed         int     `yaml:"InjectSpeed" toml:"InjectSpeed"`
+	TotalDataSize       int     `yaml:"TotalDataSize" toml:"TotalDataSize"`
+	BatchSize           int     `yaml:"BatchSize" toml:"BatchSize"`
+	BrokerNum           int     `yaml:"BrokerNum" toml:"BrokerNum"`
+	CLPA_Frequency      int     `yaml:"CLPA_Frequency" toml:"CLPA_Frequency"`
+	CLPA_WeightPenalty  float64 `yaml:"CLPA_WeightPenalty" toml:"CLPA_WeightPenalty"`
+	CLPA_MaxIterations  int     `yaml:"CLPA_MaxIterations" toml:"CLPA_MaxIterations"`
+	ViewChangeTimeOut   int     `yaml:"ViewChangeTimeOut" toml:"ViewChangeTimeOut"`
+	CheckpointPeriod    int     `yaml:"CheckpointPeriod" toml:"CheckpointPeriod"`
+	WaterMarkWindow     int     `yaml:"WaterMarkWindow" toml:"WaterMarkWindow"`
+
+	DataWrite_path string `yaml:"DataWrite_path" toml:"DataWrite_path"`
+	LogWrite_path  string `yaml:"LogWrite_path" toml:"LogWrite_path"`
+	KeyStore_path  string `yaml:"KeyStore_path" toml:"KeyStore_path"`
+	SupervisorAddr string `yaml:"SupervisorAddr" toml:"SupervisorAddr"`
+	FileInput      string `yaml:"FileInput" toml:"FileInput"`
+
+	FaultDropRate float64 `yaml:"FaultDropRate" toml:"FaultDropRate"`
+	FaultDelay    int     `yaml:"FaultDelay" toml:"FaultDelay"`
+
+	NetLatency       float64 `yaml:"NetLatency" toml:"NetLatency"`
+	NetJitter        float64 `yaml:"NetJitter" toml:"NetJitter"`
+	NetDelayDist     string  `yaml:"NetDelayDist" toml:"NetDelayDist"`
+	NetBandwidth     float64 `yaml:"NetBandwidth" toml:"NetBandwidth"`
+	NetLossRate      float64 `yaml:"NetLossRate" toml:"NetLossRate"`
+	NetTopology_path string  `yaml:"NetTopology_path" toml:"NetTopology_path"`
+	Scenario_path    string  `yaml:"Scenario_path" toml:"Scenario_path"`
+
+	SignTxs     bool     `yaml:"SignTxs" toml:"SignTxs"`
+	CheckNonce  bool     `yaml:"CheckNonce" toml:"CheckNonce"`
+	MeasureMods []string `yaml:"MeasureMods" toml:"MeasureMods"`
+
+	TxFee           bool   `yaml:"TxFee" toml:"TxFee"`
+	TxPoolPolicy    string `yaml:"TxPoolPolicy" toml:"TxPoolPolicy"` //fifo 或 fee
+	DefaultGasPrice int64  `yaml:"DefaultGasPrice" toml:"DefaultGasPrice"`
+
+	TxPoolCapacity        int     `yaml:"TxPoolCapacity" toml:"TxPoolCapacity"`
+	TxPoolSenderCapacity  int     `yaml:"TxPoolSenderCapacity" toml:"TxPoolSenderCapacity"`
+	TxPoolEviction        string  `yaml:"TxPoolEviction" toml:"TxPoolEviction"` //oldest 或 lowest-fee
+	BackPressureThreshold float64 `yaml:"BackPressureThreshold" toml:"BackPressureThreshold"`
+
+	RelayProof bool `yaml:"RelayProof" toml:"RelayProof"`
+	HeaderSync bool `yaml:"HeaderSync" toml:"HeaderSync"`
+
+	TwoPCTimeout int `yaml:"TwoPCTimeout" toml:"TwoPCTimeout"`
+
+	CLPA_EdgeWeight   string  `yaml:"CLPA_EdgeWeight" toml:"CLPA_EdgeWeight"`   //count 或 value
+	CLPA_GraphWindow  string  `yaml:"CLPA_GraphWindow" toml:"CLPA_GraphWindow"` //reset、window、decay 或 window-decay
+	CLPA_WindowBlocks int     `yaml:"CLPA_WindowBlocks" toml:"CLPA_WindowBlocks"`
+	CLPA_DecayFactor  float64 `yaml:"CLPA_DecayFactor" toml:"CLPA_DecayFactor"`
+
+	CLPA_MigrationWeight float64 `yaml:"CLPA_MigrationWeight" toml:"CLPA_MigrationWeight"`
+	CLPA_MigrationBudget float64 `yaml:"CLPA_MigrationBudget" toml:"CLPA_MigrationBudget"`
+	CLPA_StateCost       float64 `yaml:"CLPA_StateCost" toml:"CLPA_StateCost"`
+
+	CLPA_GeoPenalty      float64 `yaml:"CLPA_GeoPenalty" toml:"CLPA_GeoPenalty"`
+	AccountLocation_path string  `yaml:"AccountLocation_path" toml:"AccountLocation_path"`
+
+	PartitionAlgorithm string `yaml:"PartitionAlgorithm" toml:"PartitionAlgorithm"`
+}
+
+// 使用当前全局变量的值作为默认配置
+func DefaultExperimentConfig() *ExperimentConfig {
+	return &ExperimentConfig{
+		ShardNum:            ShardNum,
+		NodesInShard:        NodesInShard,
+		CommitteeMethod:     "Relay",
+		Block_Interval:      Block_Interval,
+		MaxBlockSize_global: MaxBlockSize_global,
+		InjectSpeed:         InjectSpeed,
+		TotalDataSize:       TotalDataSize,
+		BatchSize:           BatchSize,
+		BrokerNum:           BrokerNum,
+		CLPA_Frequency:      CLPA_Frequency,
+		CLPA_WeightPenalty:  CLPA_WeightPenalty,
+		CLPA_MaxIterations:  CLPA_MaxIterations,
+		ViewChangeTimeOut:   ViewChangeTimeOut,
+		CheckpointPeriod:    CheckpointPeriod,
+		WaterMarkWindow:     WaterMarkWindow,
+		DataWrite_path:      DataWrite_path,
+		LogWrite_path:       LogWrite_path,
+		KeyStore_path:       KeyStore_path,
+		SupervisorAddr:      SupervisorAddr,
+		FileInput:           FileInput,
+		FaultDropRate:       FaultDropRate,
+		FaultDelay:          FaultDelay,
+		NetLatency:          NetLatency,
+		NetJitter:           NetJitter,
+		NetDelayDist:        NetDelayDist,
+		NetBandwidth:        NetBandwidth,
+		NetLossRate:         NetLossRate,
+		NetTopology_path:    NetTopology_path,
+		Scenario_path:       Scenario_path,
+		SignTxs:             SignTxs,
+		CheckNonce:          CheckNonce,
+		MeasureMods:         append([]string{}, MeasureMods...),
+		TxFee:               TxFee,
+		TxPoolPolicy:        TxPoolPolicy,
+		DefaultGasPrice:     DefaultGasPrice,
+
+		TxPoolCapacity:        TxPoolCapacity,
+		TxPoolSenderCapacity:  TxPoolSenderCapacity,
+		TxPoolEviction:        TxPoolEviction,
+		BackPressureThreshold: BackPressureThreshold,
+
+		RelayProof: RelayProof,
+		HeaderSync: HeaderSync,
+
+		TwoPCTimeout: TwoPCTimeout,
+
+		CLPA_EdgeWeight:   CLPA_EdgeWeight,
+		CLPA_GraphWindow:  CLPA_GraphWindow,
+		CLPA_WindowBlocks: CLPA_WindowBlocks,
+		CLPA_DecayFactor:  CLPA_DecayFactor,
+
+		CLPA_MigrationWeight: CLPA_MigrationWeight,
+		CLPA_MigrationBudget: CLPA_MigrationBudget,
+		CLPA_StateCost:       CLPA_StateCost,
+
+		CLPA_GeoPenalty:      CLPA_GeoPenalty,
+		AccountLocation_path: AccountLocation_path,
+
+		PartitionAlgorithm: PartitionAlgorithm,
+	}
+}
+
+// 读取实验配置文件，根据后缀名（.yaml/.yml、.json、.toml）选择格式，文件中出现未知的参数时返回错误
+func LoadExperimentConfig(path string) (*ExperimentConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ec := DefaultExperimentConfig()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(ec)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(ec)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(b), ec)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", md.Undecoded())
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format %q, it should be .yaml, .yml, .json or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse the config file %s: %v", path, err)
+	}
+	return ec, nil
+}
+
+// 检查配置是否合法，返回所有不合法的参数
+func (ec *ExperimentConfig) Validate() error {
+	errs := make([]string, 0)
+	check := func(ok bool, format string, a ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, a...))
+		}
+	}
+	check(ec.ShardNum >= 1, "ShardNum should be at least 1, got %d", ec.ShardNum)
+	check(ec.NodesInShard >= 1, "NodesInShard should be at least 1, got %d", ec.NodesInShard)
+	check(indexOf(CommitteeMethod, ec.CommitteeMethod) >= 0, "CommitteeMethod should be one of %v, got %q", CommitteeMethod, ec.CommitteeMethod)
+	check(ec.Block_Interval > 0, "Block_Interval should be positive, got %d", ec.Block_Interval)
+	check(ec.MaxBlockSize_global > 0, "MaxBlockSize_global should be positive, got %d", ec.MaxBlockSize_global)
+	check(ec.InjectSpeed > 0, "InjectSpeed should be positive, got %d", ec.InjectSpeed)
+	check(ec.TotalDataSize > 0, "TotalDataSize should be positive, got %d", ec.TotalDataSize)
+	check(ec.BatchSize >= ec.InjectSpeed, "BatchSize (%d) should not be less than InjectSpeed (%d)", ec.BatchSize, ec.InjectSpeed)
+	check(ec.BrokerNum >= 0, "BrokerNum should not be negative, got %d", ec.BrokerNum)
+	check(ec.CLPA_Frequency > 0, "CLPA_Frequency should be positive, got %d", ec.CLPA_Frequency)
+	check(ec.CLPA_WeightPenalty >= 0, "CLPA_WeightPenalty should not be negative, got %v", ec.CLPA_WeightPenalty)
+	check(ec.CLPA_MaxIterations > 0, "CLPA_MaxIterations should be positive, got %d", ec.CLPA_MaxIterations)
+	check(ec.ViewChangeTimeOut > ec.Block_Interval, "ViewChangeTimeOut (%d) should be larger than Block_Interval (%d)", ec.ViewChangeTimeOut, ec.Block_Interval)
+	check(ec.CheckpointPeriod > 0, "CheckpointPeriod should be positive, got %d", ec.CheckpointPeriod)
+	check(ec.WaterMarkWindow >= ec.CheckpointPeriod, "WaterMarkWindow (%d) should not be less than CheckpointPeriod (%d), otherwise no checkpoint can become stable", ec.WaterMarkWindow, ec.CheckpointPeriod)
+	check(ec.DataWrite_path != "", "DataWrite_path should not be empty")
+	check(ec.LogWrite_path != "", "LogWrite_path should not be empty")
+	check(ec.KeyStore_path != "", "KeyStore_path should not be empty")
+	check(ec.SupervisorAddr != "", "SupervisorAddr should not be empty")
+	check(ec.FileInput != "", "FileInput should not be empty")
+	check(ec.FaultDropRate >= 0 && ec.FaultDropRate <= 1, "FaultDropRate should be in [0, 1], got %v", ec.FaultDropRate)
+	check(ec.FaultDelay >= 0, "FaultDelay should not be negative, got %d", ec.FaultDelay)
+	check(ec.NetLatency >= 0, "NetLatency should not be negative, got %v", ec.NetLatency)
+	check(ec.NetJitter >= 0, "NetJitter should not be negative, got %v", ec.NetJitter)
+	check(indexOf([]string{"constant", "uniform", "normal", "exponential"}, ec.NetDelayDist) >= 0, "NetDelayDist should be constant, uniform, normal or exponential, got %q", ec.NetDelayDist)
+	check(ec.NetBandwidth >= 0, "NetBandwidth should not be negative, got %v", ec.NetBandwidth)
+	check(ec.NetLossRate >= 0 && ec.NetLossRate < 1, "NetLossRate should be in [0, 1), got %v", ec.NetLossRate)
+	check(indexOf([]string{"fifo", "fee"}, ec.TxPoolPolicy) >= 0, "TxPoolPolicy should be fifo or fee, got %q", ec.TxPoolPolicy)
+	check(ec.DefaultGasPrice >= 0, "DefaultGasPrice should not be negative, got %d", ec.DefaultGasPrice)
+	check(ec.TxPoolCapacity >= 0, "TxPoolCapacity should not be negative, got %d", ec.TxPoolCapacity)
+	check(ec.TxPoolSenderCapacity >= 0, "TxPoolSenderCapacity should not be negative, got %d", ec.TxPoolSenderCapacity)
+	check(indexOf([]string{"oldest", "lowest-fee"}, ec.TxPoolEviction) >= 0, "TxPoolEviction should be oldest or lowest-fee, got %q", ec.TxPoolEviction)
+	check(ec.BackPressureThreshold >= 0 && ec.BackPressureThreshold <= 1, "BackPressureThreshold should be in [0, 1], got %v", ec.BackPressureThreshold)
+	check(ec.TwoPCTimeout > 0, "TwoPCTimeout should be positive, got %d", ec.TwoPCTimeout)
+	check(indexOf([]string{"count", "value"}, ec.CLPA_EdgeWeight) >= 0, "CLPA_EdgeWeight should be count or value, got %q", ec.CLPA_EdgeWeight)
+	check(indexOf([]string{"reset", "window", "decay", "window-decay"}, ec.CLPA_GraphWindow) >= 0, "CLPA_GraphWindow should be reset, window, decay or window-decay, got %q", ec.CLPA_GraphWindow)
+	check(ec.CLPA_WindowBlocks > 0, "CLPA_WindowBlocks should be positive, got %d", ec.CLPA_WindowBlocks)
+	check(ec.CLPA_DecayFactor >= 0 && ec.CLPA_DecayFactor <= 1, "CLPA_DecayFactor should be in [0, 1], got %v", ec.CLPA_DecayFactor)
+	check(ec.CLPA_MigrationWeight >= 0, "CLPA_MigrationWeight should not be negative, got %v", ec.CLPA_MigrationWeight)
+	check(ec.CLPA_MigrationBudget >= 0, "CLPA_MigrationBudget should not be negative, got %v", ec.CLPA_MigrationBudget)
+	check(ec.CLPA_StateCost >= 0, "CLPA_StateCost should not be negative, got %v", ec.CLPA_StateCost)
+	check(ec.CLPA_GeoPenalty >= 0, "CLPA_GeoPenalty should not be negative, got %v", ec.CLPA_GeoPenalty)
+	check(indexOf(PartitionAlgorithms, ec.PartitionAlgorithm) >= 0, "PartitionAlgorithm should be one of %v, got %q", PartitionAlgorithms, ec.PartitionAlgorithm)
+	known := append(append(append(append(append([]string{}, MeasureBrokerMod...), MeasureRelayMod...), MeasureTwoPCMod...), MeasureFaultMod...), MeasureGeoMod...)
+	for _, mm := range ec.MeasureMods {
+		check(indexOf(known, mm) >= 0, "unknown measure module %q, it should be one of %v", mm, known)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid experiment config:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// 把配置写入全局变量
+func (ec *ExperimentConfig) Apply() {
+	ShardNum = ec.ShardNum
+	NodesInShard = ec.NodesInShard
+	Block_Interval = ec.Block_Interval
+	MaxBlockSize_global = ec.MaxBlockSize_global
+	InjectSpeed = ec.InjectSpeed
+	TotalDataSize = ec.TotalDataSize
+	BatchSize = ec.BatchSize
+	BrokerNum = ec.BrokerNum
+	CLPA_Frequency = ec.CLPA_Frequency
+	CLPA_WeightPenalty = ec.CLPA_WeightPenalty
+	CLPA_MaxIterations = ec.CLPA_MaxIterations
+	ViewChangeTimeOut = ec.ViewChangeTimeOut
+	CheckpointPeriod = ec.CheckpointPeriod
+	WaterMarkWindow = ec.WaterMarkWindow
+	DataWrite_path = ec.DataWrite_path
+	LogWrite_path = ec.LogWrite_path
+	KeyStore_path = ec.KeyStore_path
+	SupervisorAddr = ec.SupervisorAddr
+	FileInput = ec.FileInput
+	FaultDropRate = ec.FaultDropRate
+	FaultDelay = ec.FaultDelay
+	NetLatency = ec.NetLatency
+	NetJitter = ec.NetJitter
+	NetDelayDist = ec.NetDelayDist
+	NetBandwidth = ec.NetBandwidth
+	NetLossRate = ec.NetLossRate
+	NetTopology_path = ec.NetTopology_path
+	Scenario_path = ec.Scenario_path
+	SignTxs = ec.SignTxs
+	CheckNonce = ec.CheckNonce
+	MeasureMods = append([]string{}, ec.MeasureMods...)
+	TxFee = ec.TxFee
+	TxPoolPolicy = ec.TxPoolPolicy
+	DefaultGasPrice = ec.DefaultGasPrice
+	TxPoolCapacity = ec.TxPoolCapacity
+	TxPoolSenderCapacity = ec.TxPoolSenderCapacity
+	TxPoolEviction = ec.TxPoolEviction
+	BackPressureThreshold = ec.BackPressureThreshold
+	RelayProof = ec.RelayProof
+	HeaderSync = ec.HeaderSync
+	TwoPCTimeout = ec.TwoPCTimeout
+	CLPA_EdgeWeight = ec.CLPA_EdgeWeight
+	CLPA_GraphWindow = ec.CLPA_GraphWindow
+	CLPA_WindowBlocks = ec.CLPA_WindowBlocks
+	CLPA_DecayFactor = ec.CLPA_DecayFactor
+	CLPA_MigrationWeight = ec.CLPA_MigrationWeight
+	CLPA_MigrationBudget = ec.CLPA_MigrationBudget
+	CLPA_StateCost = ec.CLPA_StateCost
+	CLPA_GeoPenalty = ec.CLPA_GeoPenalty
+	AccountLocation_path = ec.AccountLocation_path
+	PartitionAlgorithm = ec.PartitionAlgorithm
+}
+
+// 委员会方法的编号，即在 CommitteeMethod 中的下标
+func (ec *ExperimentConfig) ModID() int {
+	return indexOf(CommitteeMethod, ec.CommitteeMethod)
+}
+
+// 把实际使用的配置写入测量结果的目录，便于复现实验
+func (ec *ExperimentConfig) Dump() (string, error) {
+	if err := os.MkdirAll(ec.DataWrite_path, os.ModePerm); err != nil {
+		return "", err
+	}
+	b, err := json.MarshalIndent(ec, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(ec.DataWrite_path, "effective_config.json")
+	return path, os.WriteFile(path, b, 0644)
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -7,12 +7,58 @@ var (
 	TotalDataSize       = 100000 // the total number of txs
 	BatchSize           = 16000  // supervisor read a batch of txs then send them, it should be larger than inject speed
 	BrokerNum           = 10
+	CLPA_Frequency      = 80    // the supervisor runs CLPA once every CLPA_Frequency seconds
+	CLPA_WeightPenalty  = 0.5   // the weight penalty of CLPA
+	CLPA_MaxIterations  = 100   // the maximum number of iterations of CLPA
+	ViewChangeTimeOut   = 30000 // if no block is committed within this interval (ms), the followers start a view change
+	CheckpointPeriod    = 10    // a checkpoint is made every CheckpointPeriod sequences
+	WaterMarkWindow     = 40    // the leader only proposes sequences in (stable checkpoint, stable checkpoint + WaterMarkWindow]
 	NodesInShard        = 4
 	ShardNum            = 4
 	DataWrite_path      = "./result/"                                                                                    // measurement data result output path
 	LogWrite_path       = "./log"                                                                                        // log output path
+	FaultDropRate       = 0.3                                                                                            // the probability that a node with the Drop behavior drops a message
+	FaultDelay          = 3000                                                                                           // the delay (ms) of every message sent by a node with the Delay behavior
+	KeyStore_path       = "./keystore/"                                                                                  // node key pairs, a node generates its key pair at startup if it is not in this directory
+	NetLatency          = 0.0                                                                                            // network emulation: the mean one-way latency (ms) of every link, 0 means no emulation
+	NetJitter           = 0.0                                                                                            // network emulation: the latency jitter (ms), its meaning depends on NetDelayDist
+	NetDelayDist        = "constant"                                                                                     // network emulation: the latency distribution, constant / uniform / normal / exponential
+	NetBandwidth        = 0.0                                                                                            // network emulation: the bandwidth (bytes per second) of every link, 0 means unlimited
+	NetLossRate         = 0.0                                                                                            // network emulation: the packet loss rate of every link
+	NetTopology_path    = ""                                                                                             // network emulation: a json file of per-region (or per-shard-pair) links, it overrides the settings above
+	Scenario_path       = ""                                                                                             // a json file of scripted events (isolate / crash nodes), empty means no scenario
 	SupervisorAddr      = "127.0.0.1:18800"                                                                              //supervisor ip address
 	FileInput           = `D:\\GolandProjects\\2000000to2999999_BlockTransaction\\2000000to2999999_BlockTransaction.csv` //the raw BlockTransaction data path
+	SignTxs             = false                                                                                          // sign the replayed transactions with secp256k1 keys derived from their addresses, and verify the signatures in the tx pool and blocks
+	CheckNonce          = false                                                                                          // reject replayed txs and queue out-of-order txs by the per-account nonce, the records of previous runs (./record) must be removed first
+	MeasureMods         []string                                                                                         // the measure modules used by the supervisor, empty means the default modules of the committee method
+	TxFee               = false                                                                                          // charge every tx a fee of TxGas * GasPrice from its sender and pay the fees of a block to its proposer
+	TxPoolPolicy        = "fifo"                                                                                         // the order in which the tx pool packs txs, fifo / fee (higher gas price first, per-sender nonce order)
+	DefaultGasPrice     = int64(1)                                                                                       // the gas price of the txs whose gas price is not given by the dataset
+
+	TxPoolCapacity        = 0        // the maximum number of injected txs in the tx pool of a node, 0 means unlimited
+	TxPoolSenderCapacity  = 0        // the maximum number of txs of one sender in the tx pool, 0 means unlimited
+	TxPoolEviction        = "oldest" // which txs are evicted when the tx pool is full, oldest / lowest-fee
+	BackPressureThreshold = 0.8      // the supervisor holds the injection to a shard whose tx pool is fuller than this fraction of TxPoolCapacity
+
+	RelayProof = false // attach the block header, its commit certificate and merkle proofs to relay messages, and verify them in the destination shard
+	HeaderSync = false // shard leaders send every committed block header with its commit certificate to the nodes of other shards (light clients)
+
+	TwoPCTimeout = 20000 // 2PC: the coordinator aborts a cross-shard tx if both shards have not voted yes within this time (ms)
+
+	CLPA_EdgeWeight   = "count" // the edge weight of the CLPA tx graph, count (number of txs) / value (total tx value)
+	CLPA_GraphWindow  = "reset" // which txs the CLPA tx graph keeps after a run, reset (none) / window (the last CLPA_WindowBlocks blocks) / decay / window-decay
+	CLPA_WindowBlocks = 100     // the number of recent blocks kept in the CLPA tx graph by the window policies
+	CLPA_DecayFactor  = 0.5     // the decay policies multiply every edge weight of the CLPA tx graph by this factor after each run
+
+	CLPA_MigrationWeight = 0.0 // the cross-shard edge weight that one unit of migration cost is worth, 0 means CLPA ignores the migration cost
+	CLPA_MigrationBudget = 0.0 // the maximum total migration cost of the accounts moved by one CLPA run, 0 means unlimited
+	CLPA_StateCost       = 1.0 // the migration cost of an account state, the cost of each pending tx of the account is 1
+
+	CLPA_GeoPenalty      = 0.0 // the weight of the locality term of CLPA, which penalises placing an account in a shard far from its region, 0 ignores geography
+	AccountLocation_path = ""  // a csv file of account address and region, the accounts not in it get a region by the hash of the address
+
+	PartitionAlgorithm = "CLPA" // the account partition algorithm used by the CLPA and CLPA_Broker committees, one of PartitionAlgorithms
 )
 
 /*这些是 Go 代码中定义的全局变量。它们似乎是区块链仿真或模拟中使用的配置参数和常量。
@@ -22,10 +68,56 @@ InjectSpeed：该变量设置为 2000，代表交易注入区块链网络的速
 TotalDataSize：此变量设置为 100000，似乎表示模拟将处理的事务总数或数据大小。
 BatchSize：该变量设置为 16000，可能表示主管节点读取和发送的一批交易的大小。它应该大于注入速度，表明它控制一次处理和发送的交易数量。
 BrokerNum：此变量设置为 10，可能代表模拟中代理或中介组件的数量。
+CLPA_Frequency、CLPA_WeightPenalty、CLPA_MaxIterations：CLPA 的参数，分别表示主管节点运行 CLPA 的间隔（秒）、权重惩罚和最大迭代次数。
+ViewChangeTimeOut：该变量设置为 30000，表示从节点在这段时间（毫秒）内没有提交新区块时，认为主节点失效并发起视图切换。
+CheckpointPeriod：该变量设置为 10，表示每提交 10 个序列生成一次检查点，得到 2f+1 个节点确认后成为稳定检查点，之前的请求和投票记录会被清除。
+WaterMarkWindow：该变量设置为 40，表示主节点只能提议序列号在（稳定检查点，稳定检查点 + 40] 之间的请求，即高低水位线。
 NodesInShard：此变量设置为 4，表示区块链网络中每个分片内的节点数。
 ShardNum：此变量设置为 4，可能代表区块链网络中的分片总数。
 DataWrite_path：该变量设置为“./result/”，表示测量数据结果的输出路径。
 LogWrite_path：该变量设置为“./log”，表示日志文件所在的位置。
+FaultDropRate：该变量设置为 0.3，表示被注入 Drop 拜占庭行为的节点丢弃每条消息的概率。
+FaultDelay：该变量设置为 3000，表示被注入 Delay 拜占庭行为的节点发送每条消息之前的延迟（毫秒）。
+KeyStore_path：该变量设置为“./keystore/”，表示节点密钥对的存放目录，节点启动时如果目录中没有自己的密钥，则生成一个新的密钥对并写入该目录，其他节点从该目录读取公钥来验证签名。
+NetLatency、NetJitter、NetDelayDist、NetBandwidth、NetLossRate：网络仿真的参数，分别表示每条链路的单向时延均值（毫秒）、时延抖动（毫秒）、时延分布、带宽（字节每秒）和丢包率，全部为 0 时不模拟网络。
+NetTopology_path：网络拓扑文件（json），可以为每个区域（或每对分片）之间的链路设置不同的参数，设置之后以它为准。
+Scenario_path：实验场景文件（json），可以在指定的时间或区块高度让节点与其他节点隔离或者崩溃，持续一段时间后再恢复，事件的时间线与测量结果一起输出。
 SupervisorAddr：该变量设置为“127.0.0.1:18800”，似乎代表模拟中管理节点的 IP 地址和端口。
 FileInput：此变量设置为特定文件路径 ( ../2000000to2999999_Block_Info.csv)，可能代表模拟的某些原始数据输入的路径。
+SignTxs：是否对交易签名。开启后数据集中的每个地址都被替换为由该地址确定性生成的私钥推导出的地址，主管节点用这些私钥对交易签名，
+节点在交易池和区块验证中检查签名，签名的开销会体现在 TPS 等测量结果中。所有进程必须使用相同的设置。
+CheckNonce：是否检查交易的 nonce。主管节点为每个发送者分配从 0 开始连续的 nonce，账户状态中记录下一个 nonce；开启后 nonce 过小（重放）的交易被丢弃，
+nonce 过大的交易留在交易池中等待前面的交易，可以用于重放和双花实验。./record 中保存了之前实验的状态，开启前需要删除该目录。
+MeasureMods：主管节点使用的测量模块，为空时使用委员会方法对应的默认测量模块。
+TxFee：是否收取手续费。开启后每笔交易在发送者一侧扣除 TxGas * GasPrice 的手续费（broker 发出的交易不收取，原始发送者已经在 broker1 交易中支付），
+区块中的手续费奖励给提出该区块的节点的账户（MinerAddress），gas 上限小于 TxGas 的交易不能进入交易池。
+TxPoolPolicy：交易池打包交易的顺序。fifo 按到达顺序打包；fee 把交易按发送者分成按 nonce 排序的队列，用堆每次取出队首 gas 价格最高的交易，
+gas 价格相同时先到达的先打包。中继交易和 broker 交易也按 gas 价格排序，可以用来研究手续费市场对跨分片交易的影响。
+DefaultGasPrice：数据集中没有 gas 价格时使用的 gas 价格。
+TxPoolCapacity、TxPoolSenderCapacity、TxPoolEviction：交易池的容量、每个发送者的容量和驱逐策略，为 0 时不限制。重复的交易（哈希相同）和超过发送者容量的交易被拒绝，
+交易池超过容量时驱逐最早到达（oldest）或 gas 价格最低（lowest-fee）的交易。中继交易和 broker 发出的交易不受容量限制，也不会被驱逐。
+BackPressureThreshold：背压阈值。分片在区块信息中报告交易池的大小，交易池中的交易数量超过容量的这一比例时，主管节点暂停向该分片注入交易，
+直到交易池的负载降到阈值以下，为 0 时不暂停。
+RelayProof：是否验证中继交易。开启后源分片的主节点在中继消息中附上包含这些交易的区块头、由 2f+1 个节点签名的提交消息组成的提交证书，
+以及每笔交易相对于区块头中交易树根的默克尔证明，目标分片验证通过后才把交易加入交易池，否则拒绝整条中继消息。
+HeaderSync：是否同步区块头。开启后分片主节点每提交一个区块，就把区块头和提交证书发给其他分片的所有节点，节点验证提交证书以及与相邻高度区块头的链接关系后，
+把区块头按分片保存在 storage 中（轻客户端），跨分片验证可以据此判断其他分片的区块头是否已经提交。验证中继交易时，已经同步的区块头不需要再验证提交证书。
+TwoPCTimeout：两阶段提交的超时时间（毫秒）。委员会方法为 2PC 时，主管节点作为协调者把跨分片交易的准备交易发给发送者和接收者所在的分片，
+两个分片都准备成功（锁定账户，发送者分片扣款）后提交，有分片准备失败或者超过这一时间还没有收到两个分片的赞成票时中止，发送者分片退回金额。
+CLPA_EdgeWeight：CLPA 交易图中边的权重。同一对账户之间的交易合并为一条带权的边，count 表示权重为交易数量，value 表示权重为交易金额之和，
+此时 CLPA 减少的是跨分片转移的金额而不是跨分片交易的数量。
+CLPA_GraphWindow、CLPA_WindowBlocks、CLPA_DecayFactor：每次运行 CLPA 之后交易图的处理方式。reset 清空交易图（只根据上一个周期的交易划分）；
+window 只保留最近 CLPA_WindowBlocks 个区块的交易；decay 保留所有交易，但每次运行之后边的权重乘以 CLPA_DecayFactor；window-decay 同时使用两者。
+后三种方式使划分能够跟上负载的变化，同时不会忘记长期稳定的交易关系。
+CLPA_MigrationWeight、CLPA_MigrationBudget、CLPA_StateCost：账户移动到其他分片时需要迁移账户状态和交易池中的交易，迁移代价为 CLPA_StateCost 加上
+该账户还没有执行的交易数量。CLPA_MigrationWeight 大于 0 时，CLPA 只在账户减少的跨分片边的权重超过迁移代价乘以 CLPA_MigrationWeight 时移动账户，
+整个划分减少的跨分片边的权重不足以抵消迁移代价时不采用这次划分；CLPA_MigrationBudget 限制一次划分移动的账户的迁移代价之和。
+主管节点的日志中记录每次划分移动的账户数、迁移代价和跨分片边的权重的变化。
+CLPA_GeoPenalty、AccountLocation_path：地理位置感知的划分。每个分片位于网络拓扑文件（NetTopology_path）中配置的区域（没有配置的分片单独作为一个区域），
+区域之间的距离由它们之间的时延归一化得到。账户所在的区域来自账户位置文件（每一行为账户地址和区域），文件中没有的账户由地址的哈希值决定。
+CLPA_GeoPenalty 大于 0 时，CLPA 的分数减去 CLPA_GeoPenalty 乘以账户所在区域到分片所在区域的距离，并且测量跨区域的交易比例（CrossRegionTx）。
+PartitionAlgorithm：CLPA 和 CLPA_Broker 委员会使用的账户划分算法。CLPA 为标签传播；Hash 把账户放回由地址决定的分片，作为不做划分的基准；
+Metis 为多层 k 路划分；Louvain 为社区发现，再把社区放到分片中；Fennel 为流式划分。所有算法使用同一个交易图，
+交易图的窗口（CLPA_GraphWindow）和迁移代价（CLPA_MigrationWeight 等）对所有算法都有效，CLPA_GeoPenalty 只对 CLPA 有效。
+这些变量都可以通过实验配置文件（-C，支持 yaml、json 和 toml）修改，不需要重新编译，实际使用的配置会写入测量结果的目录中。
 这些变量为您的区块链模拟提供必要的配置和参数，允许您控制模拟的各个方面，例如块生成间隔、事务批量大小、网络拓扑和数据路径。它们通常在整个代码中使用来配置模拟的行为。*/
@@ -1,6 +1,9 @@
 package params
 
-import "math/big"
+import (
+	"math/big"
+	"sync"
+)
 
 type ChainConfig struct { //ChainConfig结构包含用于区块链仿真或模拟的各种配置参数
 	ChainID        uint64 //ChainID：该变量似乎代表区块链网络的 ID
@@ -17,12 +20,53 @@ type ChainConfig struct { //ChainConfig结构包含用于区块链仿真或模
 }
 
 var (
+	TxGas           = uint64(21000)                                                               //一笔转账交易消耗的 gas，与以太坊的转账交易相同
 	DeciderShard    = uint64(0xffffffff)                                                          //该变量被分配了最大可能uint64值（0xffffffff），它可以用作代码中的哨兵或特殊值来指示特定分片，可能是决策分片。
 	Init_Balance, _ = new(big.Int).SetString("100000000000000000000000000000000000000000000", 10) //该变量可能代表分配给区块链模拟中的账户或参与者的初始余额或代币金额
 	IPmap_nodeTable = make(map[uint64]map[uint64]string)                                          //IPmap_nodeTable：该变量代表区块链模拟中节点的 IP 地址映射，IPmap_nodeTable它是一个映射，其中键是uint64值，
 	// 并且每个键都映射到另一个映射。内部映射又使用uint64键来映射到string值
 	//外部映射允许您通过某些唯一标识符（例如节点的 ID）对节点进行索引，内部映射用于存储与这些标识符对应的节点的 IP 地址。
-	CommitteeMethod  = []string{"CLPA_Broker", "CLPA", "Broker", "Relay"}                                 //该变量似乎代表委员会方法
+	CommitteeMethod  = []string{"CLPA_Broker", "CLPA", "Broker", "Relay", "2PC"}                          //该变量似乎代表委员会方法，2PC 表示跨分片交易使用两阶段提交
 	MeasureBrokerMod = []string{"TPS_Broker", "TCL_Broker", "CrossTxRate_Broker", "TxNumberCount_Broker"} //包含特定于“代理”机制的各种测量方法。这些方法似乎是用于测量区块链模拟中的各种度量的方法
 	MeasureRelayMod  = []string{"TPS_Relay", "TCL_Relay", "CrossTxRate_Relay", "TxNumberCount_Relay"}     //包含特定于“Relay”机制的各种测量方法
+	MeasureFaultMod  = []string{"SafetyViolation", "CommitGap", "ViewChangeCount"}                        //用于拜占庭故障注入实验的测量方法，分别测量安全性违反次数、出块间隔（活性）和视图切换次数
+	FaultBehaviors   = []string{"Equivocation", "WrongVote", "Drop", "Delay", "InvalidBlock", "Silent"}   //可以注入的拜占庭行为
+
+	MeasureTwoPCMod = []string{"TPS_Relay", "TCL_Relay", "TxNumberCount_Relay", "AbortRate_2PC", "LockContention_2PC"} //两阶段提交使用的测量方法，另外测量中止率和锁冲突率
+	MeasureGeoMod   = []string{"CrossRegionTx"}                                                                        //地理位置感知的划分使用的测量方法，测量跨区域的交易比例
+
+	PartitionAlgorithms = []string{"CLPA", "Hash", "Metis", "Louvain", "Fennel"} //可以选择的账户划分算法
+)
+
+var (
+	shardLeaderMap  = make(map[uint64]uint64) //记录每个分片当前主节点的ID，视图切换后由 LeaderInfo 消息更新，缺省为 0
+	shardLeaderView = make(map[uint64]uint64) //记录 shardLeaderMap 中主节点所在的视图ID
+	shardLeaderLock sync.RWMutex              //保护 shardLeaderMap 和 shardLeaderView
 )
+
+// 获取分片当前的主节点ID（如果没有记录，则默认是 0 号节点）
+func GetShardLeader(sid uint64) uint64 {
+	shardLeaderLock.RLock()
+	defer shardLeaderLock.RUnlock()
+	return shardLeaderMap[sid]
+}
+
+// 更新分片当前的主节点ID，view 为该主节点所在的视图。视图比已经记录的视图旧时不更新，返回 false
+func SetShardLeader(sid, nid, view uint64) bool {
+	shardLeaderLock.Lock()
+	defer shardLeaderLock.Unlock()
+	if view < shardLeaderView[sid] {
+		return false
+	}
+	shardLeaderMap[sid] = nid
+	shardLeaderView[sid] = view
+	return true
+}
+
+// 清除所有分片的主节点记录，新的实验从视图 0 开始
+func ResetShardLeaders() {
+	shardLeaderLock.Lock()
+	defer shardLeaderLock.Unlock()
+	shardLeaderMap = make(map[uint64]uint64)
+	shardLeaderView = make(map[uint64]uint64)
+}
@@ -0,0 +1,178 @@
+// 地理位置：每个分片位于一个区域（网络拓扑文件中的 Regions，没有配置的分片单独作为一个区域），
+// 每个账户也位于一个区域（Vertex.Location），来自账户位置文件，文件中没有的账户由地址的哈希值决定
+package partition
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/networks"
+	"blockEmulator/params"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Geography struct {
+	ShardRegion []string                      // 分片 -> 区域
+	Distance    map[string]map[string]float64 // 区域之间的距离，归一化到 [0, 1]，同一区域为 0
+	regions     []string                      // 所有区域，按名称排序
+	locations   map[string]string             // 账户位置文件中的账户地址 -> 区域
+	hashedLock  sync.Mutex
+	hashed      map[string]string // 由地址的哈希值决定区域的账户地址 -> 区域，每个地址只计算一次
+}
+
+// 根据分片所在的区域和区域之间的时延创建地理位置，latency 中没有的区域对使用 defaultLatency。
+// 区域之间的距离为时延除以最大的时延，时延都为 0 时不同区域之间的距离为 1
+func NewGeography(shardRegion []string, latency map[string]map[string]float64, defaultLatency float64) *Geography {
+	g := &Geography{
+		ShardRegion: shardRegion,
+		Distance:    make(map[string]map[string]float64),
+		locations:   make(map[string]string),
+		hashed:      make(map[string]string),
+	}
+	seen := make(map[string]bool)
+	for _, r := range shardRegion {
+		if !seen[r] {
+			seen[r] = true
+			g.regions = append(g.regions, r)
+		}
+	}
+	sort.Strings(g.regions)
+
+	maxLatency := 0.0
+	for _, a := range g.regions {
+		g.Distance[a] = make(map[string]float64)
+		for _, b := range g.regions {
+			if a == b {
+				continue
+			}
+			l, ok := latency[a][b]
+			if !ok {
+				l = defaultLatency
+			}
+			g.Distance[a][b] = l
+			if l > maxLatency {
+				maxLatency = l
+			}
+		}
+	}
+	for _, a := range g.regions {
+		for b, l := range g.Distance[a] {
+			if maxLatency > 0 {
+				g.Distance[a][b] = l / maxLatency
+			} else {
+				g.Distance[a][b] = 1
+			}
+		}
+	}
+	return g
+}
+
+// 根据网络拓扑文件（params.NetTopology_path）和账户位置文件（params.AccountLocation_path）创建 shardNum 个分片的地理位置
+func LoadGeography(shardNum int) (*Geography, error) {
+	tp := &networks.Topology{Default: networks.LinkProfile{Latency: params.NetLatency}}
+	if params.NetTopology_path != "" {
+		var err error
+		if tp, err = networks.LoadTopology(params.NetTopology_path); err != nil {
+			return nil, err
+		}
+	}
+	shardRegion := make([]string, shardNum)
+	for sid := range shardRegion {
+		key := strconv.Itoa(sid)
+		if r, ok := tp.Regions[key]; ok {
+			shardRegion[sid] = r
+		} else {
+			shardRegion[sid] = key
+		}
+	}
+	latency := make(map[string]map[string]float64)
+	setLatency := func(from, to string, l float64) {
+		if latency[from] == nil {
+			latency[from] = make(map[string]float64)
+		}
+		latency[from][to] = l
+	}
+	for _, l := range tp.Links {
+		setLatency(l.From, l.To, l.Profile.Latency)
+		if l.Both {
+			setLatency(l.To, l.From, l.Profile.Latency)
+		}
+	}
+	g := NewGeography(shardRegion, latency, tp.Default.Latency)
+	if params.AccountLocation_path != "" {
+		if err := g.LoadLocations(params.AccountLocation_path); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// 读取账户位置文件，每一行为账户地址和区域，地址可以带 0x 前缀
+func (g *Geography) LoadLocations(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = 2
+	for {
+		data, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		addr, region := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(data[0])), "0x"), strings.TrimSpace(data[1])
+		if _, ok := g.Distance[region]; !ok {
+			return fmt.Errorf("the region %q of account %s is not the region of any shard", region, addr)
+		}
+		g.locations[core.WorkloadAddress(addr)] = region
+	}
+}
+
+// 账户所在的区域，g 为 nil 时返回空字符串（不考虑地理位置）
+func (g *Geography) Location(addr string) string {
+	if g == nil || len(g.regions) == 0 {
+		return ""
+	}
+	if r, ok := g.locations[addr]; ok {
+		return r
+	}
+	g.hashedLock.Lock()
+	defer g.hashedLock.Unlock()
+	if r, ok := g.hashed[addr]; ok {
+		return r
+	}
+	h := sha256.Sum256([]byte(addr))
+	r := g.regions[binary.BigEndian.Uint64(h[:8])%uint64(len(g.regions))]
+	g.hashed[addr] = r
+	return r
+}
+
+// 创建带有地理位置的节点
+func (g *Geography) Vertex(addr string) Vertex {
+	return Vertex{Addr: addr, Location: g.Location(addr)}
+}
+
+// 位于 location 的账户放到分片 shard 中的距离，location 为空时为 0
+func (g *Geography) ShardDistance(location string, shard int) float64 {
+	if g == nil || location == "" || shard >= len(g.ShardRegion) {
+		return 0
+	}
+	return g.Distance[location][g.ShardRegion[shard]]
+}
+
+// 设置地理位置和地域项的权重
+func (cs *CLPAState) SetGeography(g *Geography, penalty float64) {
+	cs.Geo = g
+	cs.NetGraph.GeographicalConstraint = penalty
+}
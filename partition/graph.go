@@ -12,15 +12,11 @@ type Vertex struct {
 
 // 描述当前区块链交易集合的图，代表区块链中整个交易图
 type Graph struct {
-	VertexSet map[Vertex]bool     // 节点集合，其实是 set
-	EdgeSet   map[Vertex][]Vertex // 存储顶点之间的邻接信息的映射。表示记录节点与节点间是否存在交易，它是作为邻接列表实现的
-	//map[Vertex][]Vertex是一个具有 Vertex 类型的键和 []Vertex 类型的值的映射，[]Vertex是 Vertex 类型的切片。
-	//[]Vertex表示map中每个key关联的值是Vertex的切片。 Go 中的切片是动态大小的、有序的元素集合，在这种情况下，映射中的每个键都可以与多个顶点值关联。
-	// 因此，该数据结构用于表示 Vertex 对象之间的关系，允许每个 Vertex 与多个其他 Vertex 实例关联。
-	//它通常用于实现图的邻接列表，其中给定的顶点具有与其连接的其他顶点的列表。这对于表示各种类型网络中的关系非常有用，包括区块链网络，其中 Vertex 可能表示帐户或节点，而 Vertex 的切片表示它们之间的连接或关系。
+	VertexSet map[Vertex]bool               // 节点集合，其实是 set
+	EdgeSet   map[Vertex]map[Vertex]float64 // 带权的邻接表，邻居 -> 边的权重（两个账户之间的交易数量或交易金额），同一对账户之间的多笔交易只保存一条边
 	// lock      sync.RWMutex       // 锁，但是每个储存节点各自存储一份图，不需要此
 	// 根据地理分片需要添加其他字段
-	GeographicalConstraint float64 // 地理邻近度或约束的某种度量
+	GeographicalConstraint float64 // 地理邻近度或约束的某种度量，即 CLPA 分数中地域项的权重
 }
 
 // 创建节点，允许创建一个新的Vertex并使用地址对其进行初始化
@@ -36,9 +32,13 @@ func (g *Graph) AddVertex(v Vertex) {
 	g.VertexSet[v] = true
 }
 
-// 增加图中的边。此方法在两个顶点之间添加一条边（事务）。如果图中不存在任一顶点，则首先添加它们
+// 增加图中的边。此方法在两个顶点之间添加一条边（事务），边的权重加 1。如果图中不存在任一顶点，则首先添加它们
 func (g *Graph) AddEdge(u, v Vertex) {
-	// 如果没有点，则增加边，权恒定为 1
+	g.AddWeightedEdge(u, v, 1)
+}
+
+// 增加图中的边，已经存在的边的权重加上 w
+func (g *Graph) AddWeightedEdge(u, v Vertex, w float64) {
 	if _, ok := g.VertexSet[u]; !ok {
 		g.AddVertex(u)
 	}
@@ -46,11 +46,31 @@ func (g *Graph) AddEdge(u, v Vertex) {
 		g.AddVertex(v)
 	}
 	if g.EdgeSet == nil {
-		g.EdgeSet = make(map[Vertex][]Vertex)
+		g.EdgeSet = make(map[Vertex]map[Vertex]float64)
+	}
+	for _, x := range []Vertex{u, v} {
+		if g.EdgeSet[x] == nil {
+			g.EdgeSet[x] = make(map[Vertex]float64)
+		}
 	}
 	// 无向图，使用双向边。假设该图表示无向图，因此当从 u 到 v 添加一条边时，也会从 v 到 u 添加一条对应的边。
-	g.EdgeSet[u] = append(g.EdgeSet[u], v) //将顶点v添加到顶点u的邻接列表中
-	g.EdgeSet[v] = append(g.EdgeSet[v], u) //将顶点u添加到顶点v的邻接列表中
+	g.EdgeSet[u][v] += w //增加顶点u的邻接表中顶点v的权重
+	g.EdgeSet[v][u] += w //增加顶点v的邻接表中顶点u的权重
+}
+
+// 删除图中的边，不删除边的端点
+func (g *Graph) RemoveEdge(u, v Vertex) {
+	delete(g.EdgeSet[u], v)
+	delete(g.EdgeSet[v], u)
+}
+
+// 顶点的带权度数，即与该顶点相连的所有边的权重之和
+func (g *Graph) WeightedDegree(v Vertex) float64 {
+	d := 0.0
+	for _, w := range g.EdgeSet[v] {
+		d += w
+	}
+	return d
 }
 
 // 复制图。此方法允许您创建图形的副本。它将源图的顶点和边复制到目标图
@@ -60,10 +80,12 @@ func (dst *Graph) CopyGraph(src Graph) {
 		dst.VertexSet[v] = true
 	}
 	if src.EdgeSet != nil {
-		dst.EdgeSet = make(map[Vertex][]Vertex)
+		dst.EdgeSet = make(map[Vertex]map[Vertex]float64)
 		for v := range src.VertexSet {
-			dst.EdgeSet[v] = make([]Vertex, len(src.EdgeSet[v]))
-			copy(dst.EdgeSet[v], src.EdgeSet[v])
+			dst.EdgeSet[v] = make(map[Vertex]float64, len(src.EdgeSet[v]))
+			for u, w := range src.EdgeSet[v] {
+				dst.EdgeSet[v][u] = w
+			}
 		}
 	}
 }
@@ -73,8 +95,8 @@ func (g Graph) PrintGraph() {
 	for v := range g.VertexSet {
 		print(v.Addr, " ")
 		print("edge:")
-		for _, u := range g.EdgeSet[v] {
-			print(" ", u.Addr, "\t")
+		for u, w := range g.EdgeSet[v] {
+			print(" ", u.Addr, ":", w, "\t")
 		}
 		println()
 	}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
 )
 
@@ -15,14 +16,26 @@ import (
 type CLPAState struct { //CLPAState结构包含CLPA算法的各种信息
 	NetGraph          Graph          // 需运行CLPA算法的图
 	PartitionMap      map[Vertex]int // 记录分片信息的 map，某个节点属于哪个分片
-	Edges2Shard       []int          // Shard 相邻接的边数，对应论文中的 total weight of edges associated with label k
+	Edges2Shard       []float64      // Shard 相邻接的边的权重之和，对应论文中的 total weight of edges associated with label k
 	VertexsNumInShard []int          // 分片（Shard） 内节点的数目
 	WeightPenalty     float64        // 权重惩罚，对应论文中的 beta
-	MinEdges2Shard    int            // 最少的 Shard 邻接边数，最小的 total weight of edges associated with label k
+	MinEdges2Shard    float64        // 最少的 Shard 邻接边的权重之和，最小的 total weight of edges associated with label k
 	MaxIterations     int            // 最大迭代次数，constraint，对应论文中的\tau
-	CrossShardEdgeNum int            // 跨分片边的总数
+	CrossShardEdgeNum float64        // 跨分片边的总权重
 	ShardNum          int            // 分片数目
 	GraphHash         []byte         // 图的哈希值
+	WindowBlocks      int            // 交易图只保留最近 WindowBlocks 个区块中的交易，0 表示保留所有区块
+	DecayFactor       float64        // 每个 epoch 结束时边的权重乘以 DecayFactor，1 表示不衰减
+
+	MigrationCost   map[Vertex]float64 // 账户移动到其他分片的迁移代价（账户状态和交易池中的交易），没有设置的账户为 1
+	MigrationBudget float64            // 一次划分中移动的账户的迁移代价之和的上限，0 表示不限制
+	MigrationWeight float64            // 一单位迁移代价相当于多少跨分片边的权重，0 表示不考虑迁移代价
+	LastMigration   MigrationReport    // 上一次划分的迁移报告
+
+	Geo *Geography // 分片和账户的地理位置，为 nil 时不考虑地理位置，地域项的权重为 NetGraph.GeographicalConstraint
+
+	blocks []*windowBlock // 窗口内的区块中加入的边
+	epoch  int            // 当前的 epoch，即调用 NextEpoch 的次数
 }
 
 //CLPA 算法应用到分片区块链场景下时，顶点（Vertex）指的是账户（account），边（edge）指的是交易（transaction），
@@ -60,13 +73,19 @@ func (cs *CLPAState) AddVertex(v Vertex) { //AddVertex方法将节点加入图
 // 加入边，需要将它的端点（如果不存在）默认归到一个分片中
 func (cs *CLPAState) AddEdge(u, v Vertex) { //AddEdge方法在两个顶点之间添加一条边（事务）
 	// 如果没有点，则增加边，权恒定为 1
+	cs.AddWeightedEdge(u, v, 1)
+}
+
+// 加入带权的边，权重为交易数量或交易金额，同一对账户之间的边的权重累加
+func (cs *CLPAState) AddWeightedEdge(u, v Vertex, w float64) {
 	if _, ok := cs.NetGraph.VertexSet[u]; !ok { //如果节点u不在图中，则将其加入图中
 		cs.AddVertex(u)
 	}
 	if _, ok := cs.NetGraph.VertexSet[v]; !ok {
 		cs.AddVertex(v)
 	}
-	cs.NetGraph.AddEdge(u, v) //调用Graph的AddEdge方法，在两个顶点之间添加一条边（事务）
+	cs.NetGraph.AddWeightedEdge(u, v, w) //调用Graph的AddWeightedEdge方法，在两个顶点之间添加一条边（事务）
+	cs.recordWindowEdge(u, v, w)         //记录到当前区块中，区块移出窗口时删除
 	// 可以批处理完之后再修改 Edges2Shard 等参数
 	// 当然也可以不处理，因为 CLPA 算法运行前会更新最新的参数
 }
@@ -78,13 +97,13 @@ func (dst *CLPAState) CopyCLPA(src CLPAState) { //CopyCLPA方法允许您创建C
 	for v := range src.PartitionMap {       //遍历源CLPA状态的分片信息
 		dst.PartitionMap[v] = src.PartitionMap[v] //将源CLPA状态的分片信息复制到目标CLPA状态
 	}
-	dst.Edges2Shard = make([]int, src.ShardNum)   //创建一个切片，用于记录分片相邻接的边数
-	copy(dst.Edges2Shard, src.Edges2Shard)        //将源CLPA状态的分片相邻接的边数复制到目标CLPA状态
-	dst.VertexsNumInShard = src.VertexsNumInShard //记录分片内节点的数目
-	dst.WeightPenalty = src.WeightPenalty         //记录权重惩罚
-	dst.MinEdges2Shard = src.MinEdges2Shard       //记录最少的分片邻接边数
-	dst.MaxIterations = src.MaxIterations         //记录最大迭代次数
-	dst.ShardNum = src.ShardNum                   //记录分片数目
+	dst.Edges2Shard = make([]float64, src.ShardNum) //创建一个切片，用于记录分片相邻接的边数
+	copy(dst.Edges2Shard, src.Edges2Shard)          //将源CLPA状态的分片相邻接的边数复制到目标CLPA状态
+	dst.VertexsNumInShard = src.VertexsNumInShard   //记录分片内节点的数目
+	dst.WeightPenalty = src.WeightPenalty           //记录权重惩罚
+	dst.MinEdges2Shard = src.MinEdges2Shard         //记录最少的分片邻接边数
+	dst.MaxIterations = src.MaxIterations           //记录最大迭代次数
+	dst.ShardNum = src.ShardNum                     //记录分片数目
 }
 
 // 输出CLPA
@@ -102,9 +121,9 @@ func (cs *CLPAState) PrintCLPA() {
 
 // 根据当前划分，计算 Wk，即 Edges2Shard
 func (cs *CLPAState) ComputeEdges2Shard() { //ComputeEdges2Shard方法用于计算Wk，即Edges2Shard
-	cs.Edges2Shard = make([]int, cs.ShardNum)
-	interEdge := make([]int, cs.ShardNum)
-	cs.MinEdges2Shard = 0x7fffffff // INT_MAX
+	cs.Edges2Shard = make([]float64, cs.ShardNum)
+	interEdge := make([]float64, cs.ShardNum)
+	cs.MinEdges2Shard = math.MaxFloat64
 
 	for idx := 0; idx < cs.ShardNum; idx++ {
 		cs.Edges2Shard[idx] = 0
@@ -114,15 +133,15 @@ func (cs *CLPAState) ComputeEdges2Shard() { //ComputeEdges2Shard方法用于计
 	for v, lst := range cs.NetGraph.EdgeSet {
 		// 获取节点 v 所属的shard
 		vShard := cs.PartitionMap[v]
-		for _, u := range lst {
+		for u, w := range lst {
 			// 同上，获取节点 u 所属的shard
 			uShard := cs.PartitionMap[u]
 			if vShard != uShard {
-				// 判断节点 v, u 不属于同一分片，则对应的 Edges2Shard 加一
+				// 判断节点 v, u 不属于同一分片，则对应的 Edges2Shard 加上边的权重
 				// 仅计算入度，这样不会重复计算
-				cs.Edges2Shard[uShard] += 1
+				cs.Edges2Shard[uShard] += w
 			} else {
-				interEdge[uShard]++
+				interEdge[uShard] += w
 			}
 		}
 	}
@@ -147,20 +166,20 @@ func (cs *CLPAState) ComputeEdges2Shard() { //ComputeEdges2Shard方法用于计
 // 在账户所属分片变动时，重新计算各个参数，faster
 func (cs *CLPAState) changeShardRecompute(v Vertex, old int) {
 	new := cs.PartitionMap[v]
-	for _, u := range cs.NetGraph.EdgeSet[v] {
+	for u, w := range cs.NetGraph.EdgeSet[v] {
 		neighborShard := cs.PartitionMap[u]
 		if neighborShard != new && neighborShard != old {
-			cs.Edges2Shard[new]++
-			cs.Edges2Shard[old]--
+			cs.Edges2Shard[new] += w
+			cs.Edges2Shard[old] -= w
 		} else if neighborShard == new {
-			cs.Edges2Shard[old]--
-			cs.CrossShardEdgeNum--
+			cs.Edges2Shard[old] -= w
+			cs.CrossShardEdgeNum -= w
 		} else {
-			cs.Edges2Shard[new]++
-			cs.CrossShardEdgeNum++
+			cs.Edges2Shard[new] += w
+			cs.CrossShardEdgeNum += w
 		}
 	}
-	cs.MinEdges2Shard = 0x7ffffffff
+	cs.MinEdges2Shard = math.MaxFloat64
 	// 修改 MinEdges2Shard, CrossShardEdgeNum
 	for _, val := range cs.Edges2Shard {
 		if cs.MinEdges2Shard > val {
@@ -176,6 +195,7 @@ func (cs *CLPAState) Init_CLPAState(wp float64, mIter, sn int) {
 	cs.ShardNum = sn                                // 分片数目
 	cs.VertexsNumInShard = make([]int, cs.ShardNum) // 分片内节点数目
 	cs.PartitionMap = make(map[Vertex]int)          // 节点所属分片
+	cs.DecayFactor = 1                              // 默认不衰减
 }
 
 // 初始化划分，使用节点地址的尾数划分，应该保证初始化的时候不会出现空分片
@@ -216,23 +236,29 @@ func (cs *CLPAState) Stable_Init_Partition() error { //Stable_Init_Partition方
 // 计算 将节点 v 放入 uShard 所产生的 score
 func (cs *CLPAState) getShard_score(v Vertex, uShard int) float64 { //getShard_score方法用于计算将节点v放入uShard所产生的score
 	var score float64
-	// 节点 v 的出度
-	v_outdegree := len(cs.NetGraph.EdgeSet[v])
-	// uShard 与节点 v 相连的边数
-	Edgesto_uShard := 0
-	for _, item := range cs.NetGraph.EdgeSet[v] {
+	// 节点 v 的带权出度
+	v_outdegree := cs.NetGraph.WeightedDegree(v)
+	if v_outdegree == 0 { //相连的边的权重都为 0（例如按金额计算权重时金额都为 0），不移动该节点
+		return 0
+	}
+	// uShard 与节点 v 相连的边的权重之和
+	Edgesto_uShard := 0.0
+	for item, w := range cs.NetGraph.EdgeSet[v] {
 		if cs.PartitionMap[item] == uShard {
-			Edgesto_uShard += 1
+			Edgesto_uShard += w
 		}
 	}
-	score = float64(Edgesto_uShard) / float64(v_outdegree) * (1 - cs.WeightPenalty*float64(cs.Edges2Shard[uShard])/float64(cs.MinEdges2Shard))
+	score = Edgesto_uShard / v_outdegree * (1 - cs.WeightPenalty*cs.Edges2Shard[uShard]/cs.MinEdges2Shard)
+	// 地域项：惩罚把账户放到远离其所在区域的分片
+	score -= cs.NetGraph.GeographicalConstraint * cs.Geo.ShardDistance(v.Location, uShard)
 	return score
 }
 
 // CLPA 划分算法
-func (cs *CLPAState) CLPA_Partition() (map[string]uint64, int) { //实现基于图的网络的 CLPA（约束标签传播算法）分区算法
+func (cs *CLPAState) CLPA_Partition() (map[string]uint64, float64) { //实现基于图的网络的 CLPA（约束标签传播算法）分区算法
 	cs.ComputeEdges2Shard()                             //调用该函数来计算分片之间的边，确定有多少条边连接不同分片中的顶点。结果存储在cs.CrossShardEdgeNum中，它表示连接不同分片的边数。
 	fmt.Println(cs.CrossShardEdgeNum)                   //打印连接不同分片的边数
+	plan := cs.newMigrationPlan()                       //记录划分之前账户所属的分片和迁移代价
 	res := make(map[string]uint64)                      //创建一个map，用于记录节点所属分片
 	updateTreshold := make(map[string]int)              //创建一个map，用于记录节点更新的次数
 	for iter := 0; iter < cs.MaxIterations; iter += 1 { //进入一个控制 CLPA 算法迭代次数的循环。该循环最多运行 cs.MaxIterations 次。
@@ -243,7 +269,7 @@ func (cs *CLPAState) CLPA_Partition() (map[string]uint64, int) { //实现基于
 			neighborShardScore := make(map[int]float64)                         //创建一个map，用于记录邻居分片的分数
 			max_score := -9999.0                                                //创建一个变量，用于记录最大分数
 			vNowShard, max_scoreShard := cs.PartitionMap[v], cs.PartitionMap[v] //创建两个变量，分别用于记录节点当前所属分片和最大分数的分片
-			for _, u := range cs.NetGraph.EdgeSet[v] {                          //遍历节点v的邻居
+			for u := range cs.NetGraph.EdgeSet[v] {                             //遍历节点v的邻居
 				uShard := cs.PartitionMap[u] //获取节点u所属分片.
 				// 对于属于 uShard 的邻居，仅需计算一次
 				if _, computed := neighborShardScore[uShard]; !computed { //如果节点u所属分片的分数没有被计算过，则计算节点u所属分片的分数
@@ -254,7 +280,12 @@ func (cs *CLPAState) CLPA_Partition() (map[string]uint64, int) { //实现基于
 					}
 				}
 			}
-			if vNowShard != max_scoreShard && cs.VertexsNumInShard[vNowShard] > 1 { //如果节点v当前所属分片不等于最大分数的分片且节点v当前所属分片的节点数目大于1
+			if _, computed := neighborShardScore[vNowShard]; !computed && cs.NetGraph.GeographicalConstraint > 0 { //考虑地理位置时当前分片也是候选分片，地域项可能使账户留在当前分片
+				if score := cs.getShard_score(v, vNowShard); score >= max_score {
+					max_score, max_scoreShard = score, vNowShard
+				}
+			}
+			if vNowShard != max_scoreShard && cs.VertexsNumInShard[vNowShard] > 1 && cs.allowMove(plan, v, vNowShard, max_scoreShard) { //如果节点v当前所属分片不等于最大分数的分片且节点v当前所属分片的节点数目大于1，并且迁移代价允许移动
 				cs.PartitionMap[v] = max_scoreShard
 				res[v.Addr] = uint64(max_scoreShard)
 				updateTreshold[v.Addr]++
@@ -272,9 +303,11 @@ func (cs *CLPAState) CLPA_Partition() (map[string]uint64, int) { //实现基于
 
 	cs.ComputeEdges2Shard() //调用该函数来计算分片之间的边，确定有多少条边连接不同分片中的顶点。结果存储在cs.CrossShardEdgeNum中，它表示连接不同分片的边数。
 	fmt.Println(cs.CrossShardEdgeNum)
-	return res, cs.CrossShardEdgeNum //返回节点所属分片和连接不同分片的边数
+	// 生成迁移报告，跨分片边的减少不足以抵消迁移代价时撤销这次划分
+	res = cs.finishMigration(plan)
+	return res, cs.CrossShardEdgeNum //返回节点所属分片和连接不同分片的边的总权重
 }
 
 func (cs *CLPAState) EraseEdges() { //EraseEdges方法用于擦除边
-	cs.NetGraph.EdgeSet = make(map[Vertex][]Vertex)
+	cs.NetGraph.EdgeSet = make(map[Vertex]map[Vertex]float64)
 }
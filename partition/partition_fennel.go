@@ -0,0 +1,73 @@
+// Fennel 流式划分：按地址顺序依次放置账户，每个账户放到与它相连的边的权重减去分片大小代价之后得分最高的分片，
+// 大小代价为 alpha * gamma / 2 * |P|^(gamma-1)，使各分片的账户数保持平衡。
+// 第一轮中先放置的账户看不到之后的邻居，因此重复流式放置若干轮，之后的每一轮使用邻居在上一轮中的分片
+package partition
+
+import "math"
+
+const (
+	fennelGamma     = 1.5 // 分片大小代价的指数
+	fennelImbalance = 1.1 // 每个分片的账户数不超过平均值的 fennelImbalance 倍
+	fennelPass      = 5   // 流式放置的最大轮数
+)
+
+func fennelAssign(cs *CLPAState) map[Vertex]int {
+	g, vs := newCompactGraph(cs)
+	n, k := len(vs), cs.ShardNum
+	if n == 0 {
+		return nil
+	}
+	m := 0.0 //边的总权重
+	for _, lst := range g.adj {
+		for _, w := range lst {
+			m += w / 2
+		}
+	}
+	alpha := math.Sqrt(float64(k)) * m / math.Pow(float64(n), fennelGamma)
+	capacity := int(float64(n)/float64(k)*fennelImbalance) + 1
+
+	part := make([]int, n)
+	for i := range part {
+		part[i] = -1
+	}
+	size := make([]int, k)
+	for pass := 0; pass < fennelPass; pass++ {
+		moved := false
+		for i := 0; i < n; i++ {
+			from := part[i]
+			if from >= 0 {
+				size[from]--
+			}
+			conn := make([]float64, k) //与已经放置的邻居之间的边的权重
+			for j, w := range g.adj[i] {
+				if part[j] >= 0 {
+					conn[part[j]] += w
+				}
+			}
+			best, bestScore := -1, 0.0
+			for p := 0; p < k; p++ {
+				if size[p] >= capacity {
+					continue
+				}
+				score := conn[p] - alpha*fennelGamma/2*math.Pow(float64(size[p]), fennelGamma-1)
+				if best < 0 || score > bestScore {
+					best, bestScore = p, score
+				}
+			}
+			part[i] = best
+			size[best]++
+			if best != from {
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+
+	parts := make(map[Vertex]int, n)
+	for i, v := range vs {
+		parts[v] = part[i]
+	}
+	return relabel(cs, parts)
+}
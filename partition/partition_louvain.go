@@ -0,0 +1,129 @@
+// Louvain 社区发现：逐层移动节点使模块度增加最多，再把每个社区合并为一个节点，直到社区不再变化。
+// 得到的社区按大小从大到小放到分片中，优先放到社区中多数账户当前所在的分片，分片满时把社区拆到其他分片
+package partition
+
+import "sort"
+
+const (
+	louvainPass      = 20  // 每一层移动节点的最大轮数
+	louvainImbalance = 1.1 // 每个分片的账户数不超过平均值的 louvainImbalance 倍
+)
+
+func louvainAssign(cs *CLPAState) map[Vertex]int {
+	g, vs := newCompactGraph(cs)
+	if len(vs) == 0 {
+		return nil
+	}
+	comm := make([]int, len(vs)) //原图中每个节点所属的社区
+	for i := range comm {
+		comm[i] = i
+	}
+	for {
+		cmap, cn := louvainLevel(g)
+		if cn == len(g.adj) {
+			break
+		}
+		for i := range comm {
+			comm[i] = cmap[comm[i]]
+		}
+		g = g.coarsen(cmap, cn)
+	}
+
+	// 把社区放到分片中
+	members := make(map[int][]int)
+	for i, c := range comm {
+		members[c] = append(members[c], i)
+	}
+	cids := make([]int, 0, len(members))
+	for c := range members {
+		cids = append(cids, c)
+	}
+	sort.Slice(cids, func(a, b int) bool {
+		if len(members[cids[a]]) != len(members[cids[b]]) {
+			return len(members[cids[a]]) > len(members[cids[b]])
+		}
+		return cids[a] < cids[b]
+	})
+	k := cs.ShardNum
+	capacity := int(float64(len(vs))/float64(k)*louvainImbalance) + 1
+	load := make([]int, k)
+	target := make(map[Vertex]int, len(vs))
+	for _, c := range cids {
+		votes := make([]int, k)
+		for _, i := range members[c] {
+			votes[cs.PartitionMap[vs[i]]]++
+		}
+		for _, i := range members[c] {
+			s := -1
+			for p := 0; p < k; p++ { //社区中多数账户所在的分片，其次是负载最小的分片
+				if load[p] < capacity && (s < 0 || votes[p] > votes[s] || votes[p] == votes[s] && load[p] < load[s]) {
+					s = p
+				}
+			}
+			if s < 0 {
+				s = 0
+			}
+			votes[s] += len(members[c]) //社区中剩下的账户跟随放到同一个分片
+			target[vs[i]] = s
+			load[s]++
+		}
+	}
+	return target
+}
+
+// Louvain 的一层：每个节点移动到使模块度增加最多的相邻社区，返回节点所属的社区（重新编号）和社区数
+func louvainLevel(g *compactGraph) ([]int, int) {
+	n := len(g.adj)
+	degree := make([]float64, n) //节点的带权度数，自环计两次
+	m2 := 0.0                    //所有节点的度数之和，即边的总权重的两倍
+	for i, lst := range g.adj {
+		degree[i] = 2 * g.self[i]
+		for _, w := range lst {
+			degree[i] += w
+		}
+		m2 += degree[i]
+	}
+	comm := make([]int, n)
+	tot := make([]float64, n) //社区中节点的度数之和
+	for i := range comm {
+		comm[i] = i
+		tot[i] = degree[i]
+	}
+	if m2 == 0 {
+		return comm, n
+	}
+	for pass := 0; pass < louvainPass; pass++ {
+		moved := false
+		for i := 0; i < n; i++ {
+			links := make(map[int]float64) //节点 i 与各个相邻社区之间的边的权重
+			for j, w := range g.adj[i] {
+				links[comm[j]] += w
+			}
+			old := comm[i]
+			tot[old] -= degree[i]
+			best, bestGain := old, links[old]-tot[old]*degree[i]/m2
+			for c, w := range links {
+				if gain := w - tot[c]*degree[i]/m2; gain > bestGain || gain == bestGain && c < best && c != old {
+					best, bestGain = c, gain
+				}
+			}
+			comm[i] = best
+			tot[best] += degree[i]
+			if best != old {
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+	// 重新编号
+	id := make(map[int]int)
+	for i, c := range comm {
+		if _, ok := id[c]; !ok {
+			id[c] = len(id)
+		}
+		comm[i] = id[c]
+	}
+	return comm, len(id)
+}
@@ -0,0 +1,164 @@
+// METIS 风格的多层 k 路划分：重边匹配逐层粗化交易图，在最粗的图上用区域生长得到初始划分，
+// 然后逐层投影回原图，每一层用边界贪心细化减少跨分片边的权重，同时保持各分片的账户数平衡
+package partition
+
+import "sort"
+
+const (
+	metisImbalance   = 1.05 // 每个分片的账户数不超过平均值的 metisImbalance 倍
+	metisRefinePass  = 8    // 每一层细化的最大轮数
+	metisCoarsenSize = 20   // 粗化到节点数不超过 metisCoarsenSize * 分片数，或者无法继续粗化
+)
+
+func metisAssign(cs *CLPAState) map[Vertex]int {
+	g, vs := newCompactGraph(cs)
+	k := cs.ShardNum
+	if len(vs) == 0 || k <= 1 {
+		return nil
+	}
+	// 粗化
+	levels := []*compactGraph{g}
+	cmaps := make([][]int, 0)
+	for len(g.adj) > metisCoarsenSize*k {
+		cmap, cn := heavyEdgeMatching(g)
+		if cn > len(g.adj)*9/10 { //粗化的效果不明显，停止
+			break
+		}
+		g = g.coarsen(cmap, cn)
+		levels = append(levels, g)
+		cmaps = append(cmaps, cmap)
+	}
+	total := 0
+	for _, w := range levels[0].vwgt {
+		total += w
+	}
+	maxWeight := int(float64(total)/float64(k)*metisImbalance) + 1
+
+	// 初始划分和逐层细化
+	part := growPartition(g, k, total)
+	refine(g, part, k, maxWeight)
+	for l := len(levels) - 2; l >= 0; l-- {
+		fine := make([]int, len(levels[l].adj))
+		for i := range fine {
+			fine[i] = part[cmaps[l][i]]
+		}
+		part = fine
+		refine(levels[l], part, k, maxWeight)
+	}
+
+	parts := make(map[Vertex]int, len(vs))
+	for i, v := range vs {
+		parts[v] = part[i]
+	}
+	return relabel(cs, parts)
+}
+
+// 重边匹配：按度数从小到大访问节点，与权重最大的边相连的未匹配邻居合并
+func heavyEdgeMatching(g *compactGraph) ([]int, int) {
+	n := len(g.adj)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return len(g.adj[order[a]]) < len(g.adj[order[b]]) })
+	cmap := make([]int, n)
+	for i := range cmap {
+		cmap[i] = -1
+	}
+	cn := 0
+	for _, i := range order {
+		if cmap[i] >= 0 {
+			continue
+		}
+		best, bestW := -1, 0.0
+		for j, w := range g.adj[i] {
+			if cmap[j] < 0 && (w > bestW || w == bestW && j < best) {
+				best, bestW = j, w
+			}
+		}
+		cmap[i] = cn
+		if best >= 0 {
+			cmap[best] = cn
+		}
+		cn++
+	}
+	return cmap, cn
+}
+
+// 区域生长的初始划分：每个部分从权重最大的未分配节点开始，不断加入与该部分相连的边的权重最大的节点，直到达到平均大小
+func growPartition(g *compactGraph, k, total int) []int {
+	n := len(g.adj)
+	part := make([]int, n)
+	for i := range part {
+		part[i] = -1
+	}
+	assigned, weight := 0, 0
+	for p := 0; p < k && assigned < n; p++ {
+		target := (total - weight) / (k - p)
+		pw := 0
+		conn := make(map[int]float64) //未分配的节点与该部分相连的边的权重
+		for pw < target && assigned < n {
+			next := -1
+			for i, w := range conn {
+				if next < 0 || w > conn[next] || w == conn[next] && i < next {
+					next = i
+				}
+			}
+			if next < 0 { //该部分没有相连的未分配节点，选择权重最大的未分配节点
+				for i := 0; i < n; i++ {
+					if part[i] < 0 && (next < 0 || g.vwgt[i] > g.vwgt[next]) {
+						next = i
+					}
+				}
+			}
+			part[next] = p
+			delete(conn, next)
+			pw += g.vwgt[next]
+			assigned++
+			for j, w := range g.adj[next] {
+				if part[j] < 0 {
+					conn[j] += w
+				}
+			}
+		}
+		weight += pw
+	}
+	for i := range part { //剩余的节点放到最后一个部分
+		if part[i] < 0 {
+			part[i] = k - 1
+		}
+	}
+	return part
+}
+
+// 边界贪心细化：把节点移动到相连的边的权重最大的部分，移动后的部分大小不超过 maxWeight
+func refine(g *compactGraph, part []int, k, maxWeight int) {
+	pw := make([]int, k)
+	for i, p := range part {
+		pw[p] += g.vwgt[i]
+	}
+	for pass := 0; pass < metisRefinePass; pass++ {
+		moved := false
+		for i := range g.adj {
+			conn := make([]float64, k)
+			for j, w := range g.adj[i] {
+				conn[part[j]] += w
+			}
+			from, best := part[i], part[i]
+			for p := 0; p < k; p++ {
+				if p != from && conn[p] > conn[best] && pw[p]+g.vwgt[i] <= maxWeight {
+					best = p
+				}
+			}
+			if best != from {
+				part[i] = best
+				pw[from] -= g.vwgt[i]
+				pw[best] += g.vwgt[i]
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// 考虑迁移代价的划分：账户移动到其他分片时，源分片要把账户状态和交易池中该账户的交易迁移到目标分片，
+// 只有跨分片边的减少足以抵消迁移代价时才移动账户
+package partition
+
+import "fmt"
+
+// 一次划分的迁移报告
+type MigrationReport struct {
+	MovedAccounts    int     // 移动到其他分片的账户数
+	MigrationCost    float64 // 移动的账户的迁移代价之和
+	CrossShardBefore float64 // 划分之前跨分片边的总权重
+	CrossShardAfter  float64 // 划分之后跨分片边的总权重
+	Applied          bool    // 是否采用了这次划分，跨分片边的减少不足以抵消迁移代价时撤销划分
+}
+
+// 跨分片边的权重的减少量
+func (r MigrationReport) Reduction() float64 {
+	return r.CrossShardBefore - r.CrossShardAfter
+}
+
+func (r MigrationReport) String() string {
+	return fmt.Sprintf("moved accounts %d, migration cost %.2f, cross-shard weight %.2f -> %.2f (reduction %.2f), applied %v",
+		r.MovedAccounts, r.MigrationCost, r.CrossShardBefore, r.CrossShardAfter, r.Reduction(), r.Applied)
+}
+
+// 一次划分中的迁移计划
+type migrationPlan struct {
+	origin     map[Vertex]int // 划分之前账户所属的分片
+	cost       float64        // 已经移动的账户的迁移代价之和
+	crossShard float64        // 划分之前跨分片边的总权重
+}
+
+// 设置账户的迁移代价
+func (cs *CLPAState) SetMigrationCost(v Vertex, cost float64) {
+	if cs.MigrationCost == nil {
+		cs.MigrationCost = make(map[Vertex]float64)
+	}
+	cs.MigrationCost[v] = cost
+}
+
+// 设置迁移代价的参数，并用 cost 计算交易图中每个账户的迁移代价，cost 的参数为账户和它当前所在的分片
+func (cs *CLPAState) SetMigrationCosts(cost func(v Vertex, shard int) float64, weight, budget float64) {
+	cs.MigrationWeight = weight
+	cs.MigrationBudget = budget
+	cs.MigrationCost = make(map[Vertex]float64, len(cs.NetGraph.VertexSet))
+	for v := range cs.NetGraph.VertexSet {
+		cs.MigrationCost[v] = cost(v, cs.PartitionMap[v])
+	}
+}
+
+// 上一次划分的迁移报告
+func (cs *CLPAState) LastReport() MigrationReport {
+	return cs.LastMigration
+}
+
+func (cs *CLPAState) migrationCost(v Vertex) float64 {
+	if c, ok := cs.MigrationCost[v]; ok {
+		return c
+	}
+	return 1
+}
+
+// 节点 v 与分片 shard 中的节点相连的边的权重之和
+func (cs *CLPAState) weightToShard(v Vertex, shard int) float64 {
+	w := 0.0
+	for u, uw := range cs.NetGraph.EdgeSet[v] {
+		if cs.PartitionMap[u] == shard {
+			w += uw
+		}
+	}
+	return w
+}
+
+func (cs *CLPAState) newMigrationPlan() *migrationPlan {
+	p := &migrationPlan{
+		origin:     make(map[Vertex]int, len(cs.NetGraph.VertexSet)),
+		crossShard: cs.CrossShardEdgeNum,
+	}
+	for v := range cs.NetGraph.VertexSet {
+		p.origin[v] = cs.PartitionMap[v]
+	}
+	return p
+}
+
+// 判断是否允许把节点 v 从分片 from 移动到分片 to，允许时更新已经使用的迁移代价
+func (cs *CLPAState) allowMove(p *migrationPlan, v Vertex, from, to int) bool {
+	c := cs.migrationCost(v)
+	if to == p.origin[v] { //回到原来的分片，不需要迁移
+		p.cost -= c
+		return true
+	}
+	if from != p.origin[v] { //已经离开原来的分片，迁移代价已经计算过
+		return true
+	}
+	if cs.MigrationBudget > 0 && p.cost+c > cs.MigrationBudget {
+		return false
+	}
+	if cs.MigrationWeight > 0 && cs.weightToShard(v, to)-cs.weightToShard(v, from) <= c*cs.MigrationWeight {
+		return false
+	}
+	p.cost += c
+	return true
+}
+
+// 生成迁移报告，去掉回到原来分片的账户。考虑迁移代价时，如果跨分片边的减少不足以抵消迁移代价，撤销这次划分
+func (cs *CLPAState) finishMigration(p *migrationPlan) map[string]uint64 {
+	r := MigrationReport{
+		MigrationCost:    p.cost,
+		CrossShardBefore: p.crossShard,
+		CrossShardAfter:  cs.CrossShardEdgeNum,
+		Applied:          true,
+	}
+	moved := make(map[string]uint64)
+	for v, s := range p.origin {
+		if cs.PartitionMap[v] != s {
+			moved[v.Addr] = uint64(cs.PartitionMap[v])
+		}
+	}
+	r.MovedAccounts = len(moved)
+	if cs.MigrationWeight > 0 && r.MovedAccounts > 0 && r.Reduction() <= r.MigrationCost*cs.MigrationWeight {
+		r.Applied = false
+		cs.VertexsNumInShard = make([]int, cs.ShardNum)
+		for v, s := range p.origin {
+			cs.PartitionMap[v] = s
+			cs.VertexsNumInShard[s] += 1
+		}
+		cs.ComputeEdges2Shard()
+		moved = make(map[string]uint64)
+	}
+	cs.LastMigration = r
+	return moved
+}
@@ -0,0 +1,105 @@
+// 交易图的窗口：只保留最近若干个区块中的交易，并且每个 epoch 衰减边的权重，
+// 使 CLPA 的划分能够跟上负载的变化，同时不会立刻忘记长期稳定的交易关系
+package partition
+
+import "math"
+
+const negligibleWeight = 1e-9 // 边的权重与参照的权重之比低于该值时删除这条边
+
+type windowEdge struct {
+	u, v Vertex
+	w    float64
+}
+
+// 窗口内的一个区块，记录该区块加入的边以及加入时的 epoch
+type windowBlock struct {
+	edges []windowEdge
+	epoch int
+}
+
+// 设置交易图的窗口，blocks 为 0 表示保留所有区块中的交易，decay 为 1 表示不衰减
+func (cs *CLPAState) SetGraphWindow(blocks int, decay float64) {
+	cs.WindowBlocks = blocks
+	cs.DecayFactor = decay
+}
+
+// 开始记录一个新区块中的交易，超出窗口的最早的区块中的交易从图中删除
+func (cs *CLPAState) NewBlock() {
+	if cs.WindowBlocks <= 0 {
+		return
+	}
+	cs.blocks = append(cs.blocks, &windowBlock{epoch: cs.epoch})
+	for len(cs.blocks) > cs.WindowBlocks {
+		old := cs.blocks[0]
+		cs.blocks = cs.blocks[1:]
+		// 区块中的边在之后的每个 epoch 都衰减过
+		f := math.Pow(cs.DecayFactor, float64(cs.epoch-old.epoch))
+		for _, e := range old.edges {
+			cs.subEdgeWeight(e.u, e.v, e.w*f)
+		}
+	}
+}
+
+// 一个 epoch 结束（运行 CLPA 之后），所有边的权重乘以 DecayFactor，
+// 相对于权重最大的边可以忽略的边被删除，长期没有交易的账户因此会离开交易图
+func (cs *CLPAState) NextEpoch() {
+	cs.epoch++
+	if cs.DecayFactor == 1 {
+		return
+	}
+	maxWeight := 0.0
+	for _, lst := range cs.NetGraph.EdgeSet {
+		for v, w := range lst {
+			lst[v] = w * cs.DecayFactor
+			maxWeight = math.Max(maxWeight, lst[v])
+		}
+	}
+	for u, lst := range cs.NetGraph.EdgeSet {
+		for v, w := range lst {
+			if w <= maxWeight*negligibleWeight {
+				cs.removeEdge(u, v)
+			}
+		}
+	}
+}
+
+// 把加入的边记录到窗口的当前区块中
+func (cs *CLPAState) recordWindowEdge(u, v Vertex, w float64) {
+	if cs.WindowBlocks <= 0 {
+		return
+	}
+	if len(cs.blocks) == 0 {
+		cs.NewBlock()
+	}
+	b := cs.blocks[len(cs.blocks)-1]
+	b.edges = append(b.edges, windowEdge{u: u, v: v, w: w})
+}
+
+// 减少边的权重，权重可以忽略时删除这条边
+func (cs *CLPAState) subEdgeWeight(u, v Vertex, w float64) {
+	old, ok := cs.NetGraph.EdgeSet[u][v]
+	if !ok {
+		return
+	}
+	if u == v { //自环加入时权重加了两次（Graph.AddWeightedEdge 的两个方向是同一个条目）
+		w *= 2
+	}
+	if rest := old - w; rest > old*negligibleWeight {
+		cs.NetGraph.EdgeSet[u][v] = rest
+		cs.NetGraph.EdgeSet[v][u] = rest
+		return
+	}
+	cs.removeEdge(u, v)
+}
+
+// 删除边，没有边的节点从图中删除，但保留它所属的分片
+func (cs *CLPAState) removeEdge(u, v Vertex) {
+	cs.NetGraph.RemoveEdge(u, v)
+	for _, x := range []Vertex{u, v} {
+		if _, ok := cs.NetGraph.VertexSet[x]; ok && len(cs.NetGraph.EdgeSet[x]) == 0 {
+			delete(cs.NetGraph.EdgeSet, x)
+			delete(cs.NetGraph.VertexSet, x)
+			cs.VertexsNumInShard[cs.PartitionMap[x]] -= 1
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// 可替换的划分算法：委员会模块通过 Partitioner 观察交易、计算新的账户划分，并在每次划分之后重置交易图。
+// 交易图、当前的划分、窗口、迁移代价和地理位置都保存在 CLPAState 中，CLPA 以外的算法只负责计算每个账户的目标分片。
+// 委员会模块只通过接口设置迁移代价和读取迁移报告，不直接修改 CLPAState
+package partition
+
+import (
+	"blockEmulator/utils"
+	"fmt"
+	"sort"
+)
+
+type Partitioner interface {
+	NewBlock()                              // 开始观察一个新区块中的交易
+	AddWeightedEdge(u, v Vertex, w float64) // 观察一笔交易，w 为边的权重
+	Partition() map[string]uint64           // 计算新的划分，返回所属分片改变的账户及其新的分片
+	Reset()                                 // 一次划分之后调用，按窗口和衰减策略处理交易图
+
+	// 在 Partition 之前设置迁移代价的参数，cost 根据账户和它当前所在的分片计算账户的迁移代价
+	SetMigrationCosts(cost func(v Vertex, shard int) float64, weight, budget float64)
+	LastReport() MigrationReport // 上一次划分的迁移报告
+}
+
+// 根据算法的名称创建划分算法，cs 为已经初始化的状态
+func NewPartitioner(algorithm string, cs *CLPAState) (Partitioner, error) {
+	switch algorithm {
+	case "CLPA":
+		return cs, nil
+	case "Hash":
+		return &graphPartitioner{CLPAState: cs, assign: hashAssign}, nil
+	case "Metis":
+		return &graphPartitioner{CLPAState: cs, assign: metisAssign}, nil
+	case "Louvain":
+		return &graphPartitioner{CLPAState: cs, assign: louvainAssign}, nil
+	case "Fennel":
+		return &graphPartitioner{CLPAState: cs, assign: fennelAssign}, nil
+	}
+	return nil, fmt.Errorf("unknown partition algorithm %q", algorithm)
+}
+
+// CLPA 划分
+func (cs *CLPAState) Partition() map[string]uint64 {
+	res, _ := cs.CLPA_Partition()
+	return res
+}
+
+// 进入下一个 epoch，窗口外和衰减到可以忽略的交易从图中删除
+func (cs *CLPAState) Reset() {
+	cs.NextEpoch()
+}
+
+// 基于交易图的划分算法，assign 根据交易图和当前的划分计算每个节点的目标分片
+type graphPartitioner struct {
+	*CLPAState
+	assign func(cs *CLPAState) map[Vertex]int
+}
+
+// 计算目标分片，在迁移代价和迁移预算允许的范围内移动账户
+func (gp *graphPartitioner) Partition() map[string]uint64 {
+	cs := gp.CLPAState
+	cs.ComputeEdges2Shard()
+	plan := cs.newMigrationPlan()
+	target := gp.assign(cs)
+	for _, v := range sortedVertices(cs) {
+		to, ok := target[v]
+		from := cs.PartitionMap[v]
+		if !ok || to == from || !cs.allowMove(plan, v, from, to) {
+			continue
+		}
+		cs.PartitionMap[v] = to
+		cs.VertexsNumInShard[from] -= 1
+		cs.VertexsNumInShard[to] += 1
+		cs.changeShardRecompute(v, from)
+	}
+	cs.ComputeEdges2Shard()
+	return cs.finishMigration(plan)
+}
+
+// 按地址排序的节点，使算法的结果是确定的
+func sortedVertices(cs *CLPAState) []Vertex {
+	vs := make([]Vertex, 0, len(cs.NetGraph.VertexSet))
+	for v := range cs.NetGraph.VertexSet {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i].Addr < vs[j].Addr })
+	return vs
+}
+
+// 哈希划分：每个账户回到由地址尾数决定的分片，作为不做划分的基准
+func hashAssign(cs *CLPAState) map[Vertex]int {
+	target := make(map[Vertex]int, len(cs.NetGraph.VertexSet))
+	for v := range cs.NetGraph.VertexSet {
+		target[v] = utils.Addr2Shard(v.Addr)
+	}
+	return target
+}
+
+// 把算法得到的 k 个部分对应到分片上，使尽量多的账户留在当前的分片，减少迁移
+func relabel(cs *CLPAState, parts map[Vertex]int) map[Vertex]int {
+	k := cs.ShardNum
+	overlap := make([][]int, k) // 部分 -> 分片 -> 两者共有的节点数
+	for p := range overlap {
+		overlap[p] = make([]int, k)
+	}
+	for v, p := range parts {
+		overlap[p][cs.PartitionMap[v]]++
+	}
+	type pair struct{ p, s, n int }
+	pairs := make([]pair, 0, k*k)
+	for p := 0; p < k; p++ {
+		for s := 0; s < k; s++ {
+			pairs = append(pairs, pair{p, s, overlap[p][s]})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].n > pairs[j].n })
+	shardOf, used := make([]int, k), make([]bool, k)
+	for p := range shardOf {
+		shardOf[p] = -1
+	}
+	for _, pr := range pairs {
+		if shardOf[pr.p] < 0 && !used[pr.s] {
+			shardOf[pr.p], used[pr.s] = pr.s, true
+		}
+	}
+	target := make(map[Vertex]int, len(parts))
+	for v, p := range parts {
+		target[v] = shardOf[p]
+	}
+	return target
+}
+
+// 交易图的紧凑表示，节点编号为 0..n-1，self 为自环的权重，vwgt 为节点包含的账户数
+type compactGraph struct {
+	adj  []map[int]float64
+	self []float64
+	vwgt []int
+}
+
+// 把交易图转换为紧凑表示，返回按地址排序的节点
+func newCompactGraph(cs *CLPAState) (*compactGraph, []Vertex) {
+	vs := sortedVertices(cs)
+	idx := make(map[Vertex]int, len(vs))
+	for i, v := range vs {
+		idx[v] = i
+	}
+	g := &compactGraph{
+		adj:  make([]map[int]float64, len(vs)),
+		self: make([]float64, len(vs)),
+		vwgt: make([]int, len(vs)),
+	}
+	for i, v := range vs {
+		g.adj[i] = make(map[int]float64, len(cs.NetGraph.EdgeSet[v]))
+		g.vwgt[i] = 1
+		for u, w := range cs.NetGraph.EdgeSet[v] {
+			if u == v {
+				g.self[i] += w / 2 //AddWeightedEdge 对自环加了两次
+			} else {
+				g.adj[i][idx[u]] = w
+			}
+		}
+	}
+	return g, vs
+}
+
+// 按照 cmap（节点 -> 粗化后的节点）合并节点，得到粗化的图
+func (g *compactGraph) coarsen(cmap []int, cn int) *compactGraph {
+	c := &compactGraph{
+		adj:  make([]map[int]float64, cn),
+		self: make([]float64, cn),
+		vwgt: make([]int, cn),
+	}
+	for i := range c.adj {
+		c.adj[i] = make(map[int]float64)
+	}
+	for i, lst := range g.adj {
+		ci := cmap[i]
+		c.vwgt[ci] += g.vwgt[i]
+		c.self[ci] += g.self[i]
+		for j, w := range lst {
+			if cj := cmap[j]; cj != ci {
+				c.adj[ci][cj] += w
+			} else {
+				c.self[ci] += w / 2 //合并到同一个节点的边在两端各出现一次
+			}
+		}
+	}
+	return c
+}
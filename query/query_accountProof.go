@@ -0,0 +1,70 @@
+package query
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"bytes"
+	"errors"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	ErrBlockNotFound     = errors.New("the block is not found on the chain of the shard")
+	ErrAccountMismatched = errors.New("the account state is not the one proved by the merkle proof")
+)
+
+// 账户在某个区块的状态以及相对于该区块状态树根的默克尔证明，外部工具可以据此跨分片审计余额
+type AccountProof struct {
+	ShardID     uint64
+	BlockNumber uint64
+	BlockHash   []byte
+	StateRoot   []byte //区块头中的状态树根
+	Address     string
+	Account     *core.AccountState //账户不在状态树中时为空，余额为初始余额
+	Proof       [][]byte           //状态树中从根到该账户的路径上的节点
+}
+
+func QueryAccountProof(ShardID, NodeID, Number uint64, address string) (*AccountProof, error) { //QueryAccountProof函数用于查询账户在高度为 Number 的区块中的状态以及默克尔证明
+	block := QueryBlock(ShardID, NodeID, Number)
+	if block.Header == nil {
+		return nil, ErrBlockNotFound
+	}
+	fp := "./record/ldb/s" + strconv.FormatUint(ShardID, 10) + "/n" + strconv.FormatUint(NodeID, 10)
+	db, err := rawdb.NewLevelDBDatabase(fp, 0, 1, "accountState", true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	triedb := trie.NewDatabaseWithConfig(db, &trie.Config{
+		Cache:     0,
+		Preimages: true,
+	})
+	as, proof, err := chain.ProveAccount(triedb, block.Header.StateRoot, address)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountProof{
+		ShardID:     ShardID,
+		BlockNumber: Number,
+		BlockHash:   block.Hash,
+		StateRoot:   block.Header.StateRoot,
+		Address:     address,
+		Account:     as,
+		Proof:       proof,
+	}, nil
+}
+
+// 用可信的状态树根（例如轻客户端中已经提交的区块头的状态树根）验证账户证明，证明中的账户状态必须就是证明的账户状态
+func VerifyAccountProof(ap *AccountProof, stateRoot []byte) error {
+	as, err := chain.VerifyAccountProof(stateRoot, ap.Address, ap.Proof)
+	if err != nil {
+		return err
+	}
+	if (as == nil) != (ap.Account == nil) || (as != nil && !bytes.Equal(as.Encode(), ap.Account.Encode())) {
+		return ErrAccountMismatched
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package query
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"sort"
+)
+
+func QueryTx(ShardID, NodeID uint64, txHash []byte) (*core.Transaction, *core.Receipt) { //QueryTx函数用于根据哈希查询交易和它的收据，交易不在该分片的链上时返回 nil
+	storage := initStorage(ShardID, NodeID)
+	defer storage.DataBase.Close()
+	tl, err := storage.GetTxLookup(txHash) //通过交易索引找到交易所在的区块和位置，不需要遍历所有区块
+	if err != nil {
+		return nil, nil
+	}
+	block, err := storage.GetBlock(tl.BlockHash)
+	if err != nil {
+		return nil, nil
+	}
+	receipt, _ := storage.GetReceipt(txHash)
+	return block.Body[tl.Index], receipt
+}
+
+func QueryTxLifecycle(NodeID uint64, txHash []byte) []*core.Receipt { //QueryTxLifecycle函数用于查询一笔交易在所有分片中的收据，即跨分片交易的生命周期，按执行时间排序
+	//中继交易在发送者分片和接收者分片中的哈希相同；broker 交易的哈希不同，通过原始交易的哈希找到
+	receipts := make([]*core.Receipt, 0)
+	for sid := uint64(0); sid < uint64(params.ShardNum); sid++ {
+		storage := initStorage(sid, NodeID)
+		if r, err := storage.GetReceipt(txHash); err == nil {
+			receipts = append(receipts, r)
+		}
+		for _, h := range storage.GetBrokerTxHashes(txHash) {
+			if r, err := storage.GetReceipt(h); err == nil {
+				receipts = append(receipts, r)
+			}
+		}
+		storage.DataBase.Close()
+	}
+	sort.SliceStable(receipts, func(i, j int) bool { return receipts[i].ExecutedTime.Before(receipts[j].ExecutedTime) })
+	return receipts
+}
@@ -0,0 +1,101 @@
+// 节点的密钥对，用于对共识消息和跨分片消息签名
+
+package shard
+
+import (
+	"blockEmulator/params"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	pubKeyCache = make(map[string]ed25519.PublicKey) //已经读取过的节点公钥，键为密钥文件的绝对路径，KeyStore_path 为相对路径时不同工作目录中的密钥不会混在一起
+	pubKeyLock  sync.Mutex                           //保护 pubKeyCache
+)
+
+func keyFileName(sid, nid uint64) string { //密钥文件的名称（不含后缀）
+	return params.KeyStore_path + fmt.Sprintf("S%dN%d", sid, nid)
+}
+
+// 写入文件，先写入临时文件再重命名，避免其他节点读到不完整的密钥
+func writeKeyFile(path string, key []byte, perm os.FileMode) {
+	err := os.WriteFile(path+".tmp", []byte(hex.EncodeToString(key)), perm)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimSpace(string(b)))
+}
+
+// 读取节点的私钥，如果 keystore 目录中没有，则生成新的密钥对并写入该目录
+func LoadOrGenerateNodeKey(sid, nid uint64) ed25519.PrivateKey {
+	fn := keyFileName(sid, nid)
+	if b, err := readKeyFile(fn + ".key"); err == nil && len(b) == ed25519.PrivateKeySize {
+		pri := ed25519.PrivateKey(b)
+		if _, err := os.Stat(fn + ".pub"); err != nil {
+			writeKeyFile(fn+".pub", pri.Public().(ed25519.PublicKey), 0644)
+		}
+		return pri
+	}
+	err := os.MkdirAll(params.KeyStore_path, os.ModePerm)
+	if err != nil {
+		log.Panic(err)
+	}
+	pub, pri, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Panic(err)
+	}
+	writeKeyFile(fn+".key", pri, 0600)
+	writeKeyFile(fn+".pub", pub, 0644)
+	pubKeyLock.Lock()
+	defer pubKeyLock.Unlock()
+	pubKeyCache[cacheKey(fn)] = pub //覆盖之前读取的同一个文件中的公钥
+	return pri
+}
+
+// 公钥缓存的键，即密钥文件的绝对路径
+func cacheKey(fn string) string {
+	abs, err := filepath.Abs(fn)
+	if err != nil {
+		return fn
+	}
+	return abs
+}
+
+// 获取节点的公钥，从 keystore 目录中读取
+func GetNodePublicKey(sid, nid uint64) (ed25519.PublicKey, error) {
+	fn := keyFileName(sid, nid)
+	key := cacheKey(fn)
+	pubKeyLock.Lock()
+	defer pubKeyLock.Unlock()
+	if pub, ok := pubKeyCache[key]; ok {
+		return pub, nil
+	}
+	b, err := readKeyFile(fn + ".pub")
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, errors.New("the public key of " + fn + " is broken")
+	}
+	pubKeyCache[key] = ed25519.PublicKey(b)
+	return pubKeyCache[key], nil
+}
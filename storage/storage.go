@@ -10,6 +10,9 @@ package storage
 import (
 	"blockEmulator/core"
 	"blockEmulator/params"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
 	"errors"
 	"fmt"
 	"log"
@@ -24,6 +27,11 @@ type Storage struct { //Storage结构包含用于区块链存储的各种配置
 	blockBucket           string   // 代表 Bolt 数据库中存储桶的名称。在 Bolt 中，存储桶是一种键值存储，允许您分层组织数据。
 	blockHeaderBucket     string   // Bolt 数据库中另一个存储桶的名称。它可以专门用于存储块头数据。
 	newestBlockHashBucket string   // 代表 Bolt 数据库中另一个存储桶的名称。该存储桶可用于存储区块链中最新（最新）块的哈希值。
+	txIndexBucket         string   // 交易哈希 -> 交易所在的区块和位置
+	receiptBucket         string   // 交易哈希 -> 交易收据
+	rawTxIndexBucket      string   // 原始交易哈希 -> 对应的 broker 交易的哈希
+	shardHeaderBucket     string   // 分片ID + 区块高度 -> 其他分片已经提交的区块头（轻客户端）
+	shardCertBucket       string   // 分片ID + 区块高度 -> 该区块头的提交证书
 	DataBase              *bolt.DB //该字段是指向 Bolt 数据库的指针。它用于保存对区块链系统将用于存储的实际 Bolt 数据库实例的引用。
 }
 
@@ -49,6 +57,11 @@ func NewStorage(cc *params.ChainConfig) *Storage { //NewStorage()函数用于创
 		blockBucket:           "block",                                                                                                  //该变量似乎代表 Bolt 数据库中存储桶的名称。在 Bolt 中，存储桶是一种键值存储，允许您分层组织数据。
 		blockHeaderBucket:     "blockHeader",
 		newestBlockHashBucket: "newestBlockHash",
+		txIndexBucket:         "txIndex",
+		receiptBucket:         "receipt",
+		rawTxIndexBucket:      "rawTxIndex",
+		shardHeaderBucket:     "shardHeader",
+		shardCertBucket:       "shardCert",
 	}
 
 	db, err := bolt.Open(s.dbFilePath, 0600, nil) //它使用 Bolt.Open 打开 Bolt 数据库文件。 0600是文件模式，nil是可选选项。如果在打开数据库文件期间出现错误，它会出现紧急情况并记录错误。
@@ -74,6 +87,12 @@ func NewStorage(cc *params.ChainConfig) *Storage { //NewStorage()函数用于创
 			log.Panic("create newestBlockHashBucket failed")
 		}
 
+		for _, bucket := range []string{s.txIndexBucket, s.receiptBucket, s.rawTxIndexBucket, s.shardHeaderBucket, s.shardCertBucket} {
+			if _, err = tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				log.Panic("create " + bucket + " failed")
+			}
+		}
+
 		return nil
 	})
 	s.DataBase = db //将指向 Bolt 数据库的指针保存到 Storage 结构中的 DataBase 字段中
@@ -176,3 +195,144 @@ func (s *Storage) GetNewestBlockHash() ([]byte, error) { //GetNewestBlockHash()
 	})
 	return nhb, err
 }
+
+// 为区块中的交易建立索引并保存收据，receipts 与区块中的交易一一对应
+func (s *Storage) AddReceipts(b *core.Block, receipts []*core.Receipt) {
+	err := s.DataBase.Update(func(tx *bolt.Tx) error {
+		txIndex := tx.Bucket([]byte(s.txIndexBucket))
+		receiptBucket := tx.Bucket([]byte(s.receiptBucket))
+		rawTxIndex := tx.Bucket([]byte(s.rawTxIndexBucket))
+		for i, ptx := range b.Body {
+			tl := &core.TxLookup{BlockHash: b.Hash, BlockNumber: b.Header.Number, Index: uint64(i)}
+			if err := txIndex.Put(ptx.TxHash, tl.Encode()); err != nil {
+				return err
+			}
+			if err := receiptBucket.Put(ptx.TxHash, receipts[i].Encode()); err != nil {
+				return err
+			}
+			if ptx.RawTxHash == nil {
+				continue
+			}
+			hashes := decodeHashes(rawTxIndex.Get(ptx.RawTxHash))
+			if err := rawTxIndex.Put(ptx.RawTxHash, encodeHashes(append(hashes, ptx.TxHash))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 读取交易所在的区块和位置
+func (s *Storage) GetTxLookup(txHash []byte) (*core.TxLookup, error) {
+	var res *core.TxLookup
+	err := s.DataBase.View(func(tx *bolt.Tx) error {
+		tl_encoded := tx.Bucket([]byte(s.txIndexBucket)).Get(txHash)
+		if tl_encoded == nil {
+			return errors.New("the transaction is not existed")
+		}
+		res = core.DecodeTxLookup(tl_encoded)
+		return nil
+	})
+	return res, err
+}
+
+// 读取交易收据
+func (s *Storage) GetReceipt(txHash []byte) (*core.Receipt, error) {
+	var res *core.Receipt
+	err := s.DataBase.View(func(tx *bolt.Tx) error {
+		r_encoded := tx.Bucket([]byte(s.receiptBucket)).Get(txHash)
+		if r_encoded == nil {
+			return errors.New("the receipt is not existed")
+		}
+		res = core.DecodeReceipt(r_encoded)
+		return nil
+	})
+	return res, err
+}
+
+// 读取原始交易对应的 broker 交易的哈希
+func (s *Storage) GetBrokerTxHashes(rawTxHash []byte) [][]byte {
+	var res [][]byte
+	s.DataBase.View(func(tx *bolt.Tx) error {
+		res = decodeHashes(tx.Bucket([]byte(s.rawTxIndexBucket)).Get(rawTxHash))
+		return nil
+	})
+	return res
+}
+
+// 其他分片的区块头的键：分片ID + 区块高度，按高度排序
+func shardHeaderKey(shardID, number uint64) []byte {
+	return binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, shardID), number)
+}
+
+// 保存其他分片已经提交的区块头（编码后）及其提交证书
+func (s *Storage) AddShardHeader(shardID, number uint64, header []byte, cert [][]byte) {
+	err := s.DataBase.Update(func(tx *bolt.Tx) error {
+		key := shardHeaderKey(shardID, number)
+		if err := tx.Bucket([]byte(s.shardHeaderBucket)).Put(key, header); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(s.shardCertBucket)).Put(key, encodeHashes(cert))
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 读取其他分片在某一高度的区块头（编码后）及其提交证书
+func (s *Storage) GetShardHeader(shardID, number uint64) ([]byte, [][]byte, error) {
+	var header []byte
+	var cert [][]byte
+	err := s.DataBase.View(func(tx *bolt.Tx) error {
+		key := shardHeaderKey(shardID, number)
+		header = tx.Bucket([]byte(s.shardHeaderBucket)).Get(key)
+		if header == nil {
+			return errors.New("the shard header is not existed")
+		}
+		header = append([]byte{}, header...) // bolt 返回的切片只在事务中有效
+		cert = decodeHashes(tx.Bucket([]byte(s.shardCertBucket)).Get(key))
+		return nil
+	})
+	return header, cert, err
+}
+
+// 已经保存的其他分片的最高区块高度，没有保存任何区块头时返回 0
+func (s *Storage) GetShardHeight(shardID uint64) uint64 {
+	var number uint64
+	s.DataBase.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(s.shardHeaderBucket)).Cursor()
+		k, _ := c.Seek(shardHeaderKey(shardID+1, 0))
+		if k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+		if k != nil && binary.BigEndian.Uint64(k[:8]) == shardID {
+			number = binary.BigEndian.Uint64(k[8:])
+		}
+		return nil
+	})
+	return number
+}
+
+func encodeHashes(hashes [][]byte) []byte {
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(hashes); err != nil {
+		log.Panic(err)
+	}
+	return buff.Bytes()
+}
+
+func decodeHashes(b []byte) [][]byte {
+	var hashes [][]byte
+	if b == nil {
+		return hashes
+	}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&hashes); err != nil {
+		log.Panic(err)
+	}
+	return hashes
+}
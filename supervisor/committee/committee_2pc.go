@@ -0,0 +1,229 @@
+package committee
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/message"
+	"blockEmulator/networks"
+	"blockEmulator/params"
+	"blockEmulator/shard"
+	"blockEmulator/supervisor/signal"
+	"blockEmulator/supervisor/supervisor_log"
+	"blockEmulator/utils"
+	"crypto/ed25519"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// 两阶段提交（2PC）的委员会模块，主管节点作为协调者：跨分片交易的准备交易发往发送者和接收者所在的分片，
+// 两个分片都投赞成票后提交，有分片投反对票或者 params.TwoPCTimeout 毫秒内没有收到两张赞成票时中止
+type TwoPCCommitteeModule struct {
+	csvPath      string                        //csv文件路径
+	dataTotalNum int                           //数据总数
+	nowDataNum   int                           //当前数据量
+	batchDataNum int                           //批次中的数据记录数
+	IpNodeTable  map[uint64]map[uint64]string  //节点的 IP 地址映射
+	sl           *supervisor_log.SupervisorLog //主管日志
+	Ss           *signal.StopSignal            //负责全局网络的节点的终止信息分送
+	bp           *backPressure                 //分片交易池的背压
+	nonces       *senderNonces                 //每个发送者的下一个 nonce
+	key          ed25519.PrivateKey            //协调者的密钥，用于对提交和中止交易签名
+
+	pending map[string]*twoPCState //还没有决定的跨分片交易，原始交易哈希 -> 状态
+	plock   sync.Mutex             //保护 pending
+}
+
+// 一笔还没有决定的跨分片交易
+type twoPCState struct {
+	raw    *core.Transaction
+	shards [2]uint64       //发送者和接收者所在的分片
+	votes  map[uint64]bool //已经投赞成票的分片
+	timer  *time.Timer     //超时后中止
+}
+
+func NewTwoPCCommitteeModule(Ip_nodeTable map[uint64]map[uint64]string, Ss *signal.StopSignal, slog *supervisor_log.SupervisorLog, csvFilePath string, dataNum, batchNum int) *TwoPCCommitteeModule {
+	return &TwoPCCommitteeModule{
+		csvPath:      csvFilePath,
+		dataTotalNum: dataNum,
+		batchDataNum: batchNum,
+		nowDataNum:   0,
+		IpNodeTable:  Ip_nodeTable,
+		Ss:           Ss,
+		sl:           slog,
+		bp:           newBackPressure(),
+		nonces:       newSenderNonces(),
+		key:          shard.LoadOrGenerateNodeKey(params.DeciderShard, 0),
+		pending:      make(map[string]*twoPCState),
+	}
+}
+
+// 处理分片主节点的投票
+func (tpcm *TwoPCCommitteeModule) HandleOtherMessage(msg []byte) {
+	msgType, content := message.SplitMessage(msg)
+	if msgType != message.CTwoPCVote {
+		return
+	}
+	vote := new(message.TwoPCVote)
+	if err := json.Unmarshal(content, vote); err != nil {
+		log.Panic(err)
+	}
+	decisions := make([]*core.Transaction, 0)
+	tpcm.plock.Lock()
+	for _, h := range vote.Refused {
+		if tx := tpcm.decide(string(h), false); tx != nil {
+			decisions = append(decisions, tx)
+		}
+	}
+	for _, h := range vote.Prepared {
+		st, ok := tpcm.pending[string(h)]
+		if !ok {
+			continue
+		}
+		st.votes[vote.SenderShardID] = true
+		if st.votes[st.shards[0]] && st.votes[st.shards[1]] {
+			decisions = append(decisions, tpcm.decide(string(h), true))
+		}
+	}
+	tpcm.plock.Unlock()
+	tpcm.sl.Slog.Printf("received 2PC votes from shard %d, prepared: %d, refused: %d, lock conflicts: %d\n", vote.SenderShardID, len(vote.Prepared), len(vote.Refused), vote.LockConflicts)
+	tpcm.sendDecisions(decisions)
+}
+
+// 决定提交或中止一笔跨分片交易，返回决定交易，交易已经决定时返回空。调用者持有 plock
+func (tpcm *TwoPCCommitteeModule) decide(rawHash string, commit bool) *core.Transaction {
+	st, ok := tpcm.pending[rawHash]
+	if !ok {
+		return nil
+	}
+	delete(tpcm.pending, rawHash)
+	st.timer.Stop()
+	phase := core.TwoPCAbort
+	if commit {
+		phase = core.TwoPCCommit
+	}
+	tx := core.NewTwoPCTx(st.raw, phase)
+	tx.SignTwoPCDecision(tpcm.key)
+	return tx
+}
+
+// 把决定交易发给发送者和接收者所在分片的主节点，决定交易不等待背压
+func (tpcm *TwoPCCommitteeModule) sendDecisions(txs []*core.Transaction) {
+	sendToShard := make(map[uint64][]*core.Transaction)
+	for _, tx := range txs {
+		ssid, rsid := uint64(utils.Addr2Shard(tx.Sender)), uint64(utils.Addr2Shard(tx.Recipient))
+		sendToShard[ssid] = append(sendToShard[ssid], tx)
+		sendToShard[rsid] = append(sendToShard[rsid], tx)
+	}
+	tpcm.inject(sendToShard)
+}
+
+// 把交易注入各个分片
+func (tpcm *TwoPCCommitteeModule) inject(sendToShard map[uint64][]*core.Transaction) {
+	for sid, txs := range sendToShard {
+		it := message.InjectTxs{
+			Txs:       txs,
+			ToShardID: sid,
+		}
+		itByte, err := json.Marshal(it)
+		if err != nil {
+			log.Panic(err)
+		}
+		send_msg := message.MergeMessage(message.CInject, itByte)
+		go networks.TcpDial(send_msg, tpcm.IpNodeTable[sid][params.GetShardLeader(sid)])
+	}
+}
+
+// 超时的跨分片交易被中止
+func (tpcm *TwoPCCommitteeModule) timeout(rawHash string) {
+	tpcm.plock.Lock()
+	tx := tpcm.decide(rawHash, false)
+	tpcm.plock.Unlock()
+	if tx != nil {
+		tpcm.sl.Slog.Printf("the 2PC tx %x timed out, abort it\n", tx.RawTxHash)
+		tpcm.sendDecisions([]*core.Transaction{tx})
+	}
+}
+
+// 片内交易直接发往所在的分片，跨分片交易开始两阶段提交，准备交易发往两个分片
+func (tpcm *TwoPCCommitteeModule) txSending(txlist []*core.Transaction) {
+	sendToShard := make(map[uint64][]*core.Transaction)
+	prepared := make([]*twoPCState, 0)
+	for idx := 0; idx <= len(txlist); idx++ {
+		if idx > 0 && (idx%params.InjectSpeed == 0 || idx == len(txlist)) {
+			tpcm.bp.wait(sendToShard, tpcm.sl)
+			tpcm.plock.Lock()
+			for _, st := range prepared { //准备交易发出后开始计时
+				rawHash := string(st.raw.TxHash)
+				st.timer = time.AfterFunc(time.Duration(params.TwoPCTimeout)*time.Millisecond, func() { tpcm.timeout(rawHash) })
+				tpcm.pending[rawHash] = st
+			}
+			tpcm.plock.Unlock()
+			tpcm.inject(sendToShard)
+			sendToShard = make(map[uint64][]*core.Transaction)
+			prepared = make([]*twoPCState, 0)
+			time.Sleep(time.Second)
+		}
+		if idx == len(txlist) {
+			break
+		}
+		tx := txlist[idx]
+		ssid, rsid := uint64(utils.Addr2Shard(tx.Sender)), uint64(utils.Addr2Shard(tx.Recipient))
+		if ssid == rsid {
+			sendToShard[ssid] = append(sendToShard[ssid], tx)
+			continue
+		}
+		tx.Time = time.Now() //准备交易和决定交易都记录跨分片交易开始的时间，用于测量延迟
+		ptx := core.NewTwoPCTx(tx, core.TwoPCPrepare)
+		sendToShard[ssid] = append(sendToShard[ssid], ptx)
+		sendToShard[rsid] = append(sendToShard[rsid], ptx)
+		prepared = append(prepared, &twoPCState{
+			raw:    tx,
+			shards: [2]uint64{ssid, rsid},
+			votes:  make(map[uint64]bool),
+		})
+	}
+}
+
+// 读取交易，交易数量为 -batchDataNum
+func (tpcm *TwoPCCommitteeModule) TxHandling() {
+	txfile, err := os.Open(tpcm.csvPath)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer txfile.Close()
+	reader := csv.NewReader(txfile)
+	txlist := make([]*core.Transaction, 0)
+
+	for {
+		data, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Panic(err)
+		}
+		if tx, ok := data2tx(data, tpcm.nonces); ok {
+			txlist = append(txlist, tx)
+			tpcm.nowDataNum++
+		}
+
+		if len(txlist) == int(tpcm.batchDataNum) || tpcm.nowDataNum == tpcm.dataTotalNum {
+			tpcm.txSending(txlist)
+			txlist = make([]*core.Transaction, 0)
+			tpcm.Ss.StopGap_Reset()
+		}
+
+		if tpcm.nowDataNum == tpcm.dataTotalNum {
+			break
+		}
+	}
+}
+
+func (tpcm *TwoPCCommitteeModule) AdjustByBlockInfos(b *message.BlockInfoMsg) {
+	tpcm.bp.update(b)
+	tpcm.sl.Slog.Printf("received from shard %d in epoch %d.\n", b.SenderShardID, b.Epoch)
+}
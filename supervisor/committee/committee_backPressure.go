@@ -0,0 +1,83 @@
+package committee
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"blockEmulator/supervisor/supervisor_log"
+	"sync"
+	"time"
+)
+
+// 交易池的背压：分片的主节点在每个区块的区块信息中报告交易池的大小和容量，
+// 委员会模块向分片注入交易之前，等待该分片交易池的负载降到 params.BackPressureThreshold 以下
+type backPressure struct {
+	lock     sync.Mutex
+	size     map[uint64]int    //分片报告的交易池中的交易数量
+	capacity map[uint64]int    //分片报告的交易池容量，0 表示不限制
+	sent     map[uint64]int    //上次报告之后向分片注入的交易数量，报告中还没有包含这些交易
+	dropped  map[uint64]uint64 //分片报告的交易池拒绝或驱逐的交易总数
+}
+
+func newBackPressure() *backPressure {
+	return &backPressure{
+		size:     make(map[uint64]int),
+		capacity: make(map[uint64]int),
+		sent:     make(map[uint64]int),
+		dropped:  make(map[uint64]uint64),
+	}
+}
+
+// 根据区块信息更新分片交易池的状态
+func (bp *backPressure) update(b *message.BlockInfoMsg) {
+	bp.lock.Lock()
+	defer bp.lock.Unlock()
+	bp.size[b.SenderShardID] = b.TxPoolSize
+	bp.capacity[b.SenderShardID] = b.TxPoolCapacity
+	bp.sent[b.SenderShardID] = 0
+	bp.dropped[b.SenderShardID] = b.TxPoolDropped
+}
+
+// 分片交易池的负载（交易数量 / 容量），容量不限制时为 0
+func (bp *backPressure) load(sid uint64) float64 {
+	bp.lock.Lock()
+	defer bp.lock.Unlock()
+	if bp.capacity[sid] <= 0 {
+		return 0
+	}
+	return float64(bp.size[sid]+bp.sent[sid]) / float64(bp.capacity[sid])
+}
+
+// 分片交易池中的交易数量：上次报告的数量加上之后注入的交易数量
+func (bp *backPressure) pooled(sid uint64) int {
+	bp.lock.Lock()
+	defer bp.lock.Unlock()
+	return bp.size[sid] + bp.sent[sid]
+}
+
+// 等待要注入交易的分片的交易池负载降到阈值以下，然后记录注入的交易数量。
+// 只有由主管节点注入的交易需要等待，broker 发出的交易和 2PC 的决定完成已经开始的跨分片交易，不等待
+func (bp *backPressure) wait(sendToShard map[uint64][]*core.Transaction, sl *supervisor_log.SupervisorLog) {
+	for sid, txs := range sendToShard {
+		injected := 0
+		for _, tx := range txs {
+			if !tx.Relayed && !tx.IsBrokerSent() && !tx.IsTwoPCDecision() {
+				injected++
+			}
+		}
+		if injected == 0 {
+			continue
+		}
+		if params.BackPressureThreshold > 0 && bp.load(sid) >= params.BackPressureThreshold {
+			sl.Slog.Printf("the tx pool of shard %d is %.0f%% full, hold the injection\n", sid, bp.load(sid)*100)
+			start := time.Now()
+			for bp.load(sid) >= params.BackPressureThreshold {
+				time.Sleep(100 * time.Millisecond)
+			}
+			sl.Slog.Printf("the tx pool of shard %d is %.0f%% full, resume the injection after %v\n", sid, bp.load(sid)*100, time.Since(start))
+		}
+		bp.lock.Lock()
+		bp.sent[sid] += injected
+		bp.lock.Unlock()
+	}
+}
@@ -40,6 +40,8 @@ type BrokerCommitteeMod struct {
 	// control components
 	Ss          *signal.StopSignal // to control the stop message sending
 	IpNodeTable map[uint64]map[uint64]string
+	bp          *backPressure // 分片交易池的背压
+	nonces      *senderNonces //每个发送者的下一个 nonce
 }
 
 func NewBrokerCommitteeMod(Ip_nodeTable map[uint64]map[uint64]string, Ss *signal.StopSignal, sl *supervisor_log.SupervisorLog, csvFilePath string, dataNum, batchNum int) *BrokerCommitteeMod {
@@ -59,6 +61,8 @@ func NewBrokerCommitteeMod(Ip_nodeTable map[uint64]map[uint64]string, Ss *signal
 		IpNodeTable:        Ip_nodeTable,
 		Ss:                 Ss,
 		sl:                 sl,
+		bp:                 newBackPressure(),
+		nonces:             newSenderNonces(),
 	}
 
 }
@@ -76,6 +80,7 @@ func (bcm *BrokerCommitteeMod) txSending(txlist []*core.Transaction) {
 	for idx := 0; idx <= len(txlist); idx++ {
 		if idx > 0 && (idx%params.InjectSpeed == 0 || idx == len(txlist)) {
 			// send to shard
+			bcm.bp.wait(sendToShard, bcm.sl) //等待交易池负载过高的分片
 			for sid := uint64(0); sid < uint64(params.ShardNum); sid++ {
 				it := message.InjectTxs{
 					Txs:       sendToShard[sid],
@@ -86,7 +91,7 @@ func (bcm *BrokerCommitteeMod) txSending(txlist []*core.Transaction) {
 					log.Panic(err)
 				}
 				send_msg := message.MergeMessage(message.CInject, itByte)
-				go networks.TcpDial(send_msg, bcm.IpNodeTable[sid][0])
+				go networks.TcpDial(send_msg, bcm.IpNodeTable[sid][params.GetShardLeader(sid)])
 			}
 			sendToShard = make(map[uint64][]*core.Transaction)
 			time.Sleep(time.Second)
@@ -121,7 +126,7 @@ func (bcm *BrokerCommitteeMod) TxHandling() {
 		if err != nil {
 			log.Panic(err)
 		}
-		if tx, ok := data2tx(data, uint64(bcm.nowDataNum)); ok {
+		if tx, ok := data2tx(data, bcm.nonces); ok {
 			txlist = append(txlist, tx)
 			bcm.nowDataNum++
 		} else {
@@ -146,6 +151,7 @@ func (bcm *BrokerCommitteeMod) TxHandling() {
 }
 
 func (bcm *BrokerCommitteeMod) AdjustByBlockInfos(b *message.BlockInfoMsg) {
+	bcm.bp.update(b)
 	bcm.sl.Slog.Printf("received from shard %d in epoch %d.\n", b.SenderShardID, b.Epoch)
 	if b.BlockBodyLength == 0 {
 		return
@@ -211,11 +217,13 @@ func (bcm *BrokerCommitteeMod) handleBrokerType1Mes(brokerType1Megs []*message.B
 	tx1s := make([]*core.Transaction, 0)
 	for _, brokerType1Meg := range brokerType1Megs {
 		ctx := brokerType1Meg.RawMeg.Tx
-		tx1 := core.NewTransaction(ctx.Sender, brokerType1Meg.Broker, ctx.Value, ctx.Nonce)
+		tx1 := core.NewTransactionWithFee(ctx.Sender, brokerType1Meg.Broker, ctx.Value, ctx.Nonce, ctx.GasPrice, ctx.GasLimit)
 		tx1.OriginalSender = ctx.Sender
 		tx1.FinalRecipient = ctx.Recipient
-		tx1.RawTxHash = make([]byte, len(ctx.TxHash))
-		copy(tx1.RawTxHash, ctx.TxHash)
+		tx1.SetRawTxHash(ctx.TxHash)
+		if params.SignTxs { //主管节点代替原始发送者签名
+			tx1.SignWithWorkloadKey()
+		}
 		tx1s = append(tx1s, tx1)
 		confirm1 := &message.Mag1Confirm{
 			RawMeg:  brokerType1Meg.RawMeg,
@@ -233,11 +241,13 @@ func (bcm *BrokerCommitteeMod) handleBrokerType2Mes(brokerType2Megs []*message.B
 	tx2s := make([]*core.Transaction, 0)
 	for _, mes := range brokerType2Megs {
 		ctx := mes.RawMeg.Tx
-		tx2 := core.NewTransaction(mes.Broker, ctx.Recipient, ctx.Value, ctx.Nonce)
+		tx2 := core.NewTransactionWithFee(mes.Broker, ctx.Recipient, ctx.Value, ctx.Nonce, ctx.GasPrice, ctx.GasLimit)
 		tx2.OriginalSender = ctx.Sender
 		tx2.FinalRecipient = ctx.Recipient
-		tx2.RawTxHash = make([]byte, len(ctx.TxHash))
-		copy(tx2.RawTxHash, ctx.TxHash)
+		tx2.SetRawTxHash(ctx.TxHash)
+		if params.SignTxs { //主管节点代替 broker 签名
+			tx2.SignWithWorkloadKey()
+		}
 		tx2s = append(tx2s, tx2)
 
 		confirm2 := &message.Mag2Confirm{
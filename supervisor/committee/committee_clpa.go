@@ -13,6 +13,7 @@ import (
 	"encoding/json"
 	"io"
 	"log"
+	"math/big"
 	"os"
 	"sync"
 	"time"
@@ -27,10 +28,11 @@ type CLPACommitteeModule struct { //CLPACommitteeModule结构包含 CLPA 委员
 
 	// additional variants
 	clpaLock            sync.Mutex
-	clpaGraph           *partition.CLPAState
+	partitioner         partition.Partitioner // 账户划分算法，由 params.PartitionAlgorithm 选择
 	modifiedMap         map[string]uint64
 	clpaLastRunningTime time.Time
 	clpaFreq            int
+	geo                 *partition.Geography // 分片和账户的地理位置，不考虑地理位置时为 nil
 
 	// logger module
 	sl *supervisor_log.SupervisorLog
@@ -38,23 +40,37 @@ type CLPACommitteeModule struct { //CLPACommitteeModule结构包含 CLPA 委员
 	// control components
 	Ss          *signal.StopSignal // to control the stop message sending
 	IpNodeTable map[uint64]map[uint64]string
+	bp          *backPressure // 分片交易池的背压
+	nonces      *senderNonces //每个发送者的下一个 nonce
+	pending     *pendingTxs   // 账户还没有执行的交易数量，用于估计迁移代价
 }
 
 func NewCLPACommitteeModule(Ip_nodeTable map[uint64]map[uint64]string, Ss *signal.StopSignal, sl *supervisor_log.SupervisorLog, csvFilePath string, dataNum, batchNum, clpaFrequency int) *CLPACommitteeModule { //NewCLPACommitteeModule方法用于创建和配置 CLPA 委员会模块，参数分别代表节点总数、分片总数、委员会方法、委员会模块的日志、csv文件路径、数据总数、批次中的数据记录数、CLPA算法的频率
 	cg := new(partition.CLPAState)
-	cg.Init_CLPAState(0.5, 100, params.ShardNum)
+	cg.Init_CLPAState(params.CLPA_WeightPenalty, params.CLPA_MaxIterations, params.ShardNum)
+	cg.SetGraphWindow(clpaGraphWindow())
+	geo := clpaGeography()
+	cg.SetGeography(geo, params.CLPA_GeoPenalty)
+	partitioner, err := partition.NewPartitioner(params.PartitionAlgorithm, cg)
+	if err != nil {
+		log.Panic(err)
+	}
 	return &CLPACommitteeModule{
 		csvPath:             csvFilePath,
 		dataTotalNum:        dataNum,
 		batchDataNum:        batchNum,
 		nowDataNum:          0,
-		clpaGraph:           cg,
+		partitioner:         partitioner,
 		modifiedMap:         make(map[string]uint64),
 		clpaFreq:            clpaFrequency,
 		clpaLastRunningTime: time.Time{},
 		IpNodeTable:         Ip_nodeTable,
 		Ss:                  Ss,
 		sl:                  sl,
+		bp:                  newBackPressure(),
+		nonces:              newSenderNonces(),
+		pending:             newPendingTxs(),
+		geo:                 geo,
 	}
 }
 
@@ -71,10 +87,12 @@ func (ccm *CLPACommitteeModule) fetchModifiedMap(key string) uint64 { //fetchMod
 func (ccm *CLPACommitteeModule) txSending(txlist []*core.Transaction) { //txSending方法用于将给定的交易列表发送到相应的分片
 	// the txs will be sent
 	sendToShard := make(map[uint64][]*core.Transaction)
+	ccm.pending.inject(txlist)
 
 	for idx := 0; idx <= len(txlist); idx++ {
 		if idx > 0 && (idx%params.InjectSpeed == 0 || idx == len(txlist)) {
 			// send to shard
+			ccm.bp.wait(sendToShard, ccm.sl) //等待交易池负载过高的分片
 			for sid := uint64(0); sid < uint64(params.ShardNum); sid++ {
 				it := message.InjectTxs{
 					Txs:       sendToShard[sid],
@@ -85,7 +103,7 @@ func (ccm *CLPACommitteeModule) txSending(txlist []*core.Transaction) { //txSend
 					log.Panic(err)
 				}
 				send_msg := message.MergeMessage(message.CInject, itByte)
-				go networks.TcpDial(send_msg, ccm.IpNodeTable[sid][0])
+				go networks.TcpDial(send_msg, ccm.IpNodeTable[sid][params.GetShardLeader(sid)])
 			}
 			sendToShard = make(map[uint64][]*core.Transaction)
 			time.Sleep(time.Second)
@@ -116,7 +134,7 @@ func (ccm *CLPACommitteeModule) TxHandling() { //TxHandling()方法用于处理
 		if err != nil {
 			log.Panic(err)
 		}
-		if tx, ok := data2tx(data, uint64(ccm.nowDataNum)); ok {
+		if tx, ok := data2tx(data, ccm.nonces); ok {
 			txlist = append(txlist, tx)
 			ccm.nowDataNum++
 		} else {
@@ -138,7 +156,7 @@ func (ccm *CLPACommitteeModule) TxHandling() { //TxHandling()方法用于处理
 
 		if !ccm.clpaLastRunningTime.IsZero() && time.Since(ccm.clpaLastRunningTime) >= time.Duration(ccm.clpaFreq)*time.Second { //
 			ccm.clpaLock.Lock()
-			mmap, _ := ccm.clpaGraph.CLPA_Partition()
+			mmap := runCLPA(ccm.partitioner, ccm.pending, ccm.bp, ccm.sl)
 			ccm.clpaMapSend(mmap)
 			for key, val := range mmap {
 				ccm.modifiedMap[key] = val
@@ -159,7 +177,7 @@ func (ccm *CLPACommitteeModule) TxHandling() { //TxHandling()方法用于处理
 		time.Sleep(time.Second)
 		if time.Since(ccm.clpaLastRunningTime) >= time.Duration(ccm.clpaFreq)*time.Second {
 			ccm.clpaLock.Lock()
-			mmap, _ := ccm.clpaGraph.CLPA_Partition()
+			mmap := runCLPA(ccm.partitioner, ccm.pending, ccm.bp, ccm.sl)
 			ccm.clpaMapSend(mmap)
 			for key, val := range mmap {
 				ccm.modifiedMap[key] = val
@@ -184,27 +202,72 @@ func (ccm *CLPACommitteeModule) clpaMapSend(m map[string]uint64) { //clpaMapSend
 	send_msg := message.MergeMessage(message.CPartitionMsg, pmByte)
 	// send to worker shards
 	for i := uint64(0); i < uint64(params.ShardNum); i++ {
-		networks.TcpDial(send_msg, ccm.IpNodeTable[i][0])
+		networks.TcpDial(send_msg, ccm.IpNodeTable[i][params.GetShardLeader(i)])
 	}
 	ccm.sl.Slog.Println("Supervisor: all partition map message has been sent. ")
 }
 
-func (ccm *CLPACommitteeModule) clpaReset() { //clpaReset方法用于重置委员会模块
-	ccm.clpaGraph = new(partition.CLPAState)
-	ccm.clpaGraph.Init_CLPAState(0.5, 100, params.ShardNum)
-	for key, val := range ccm.modifiedMap {
-		ccm.clpaGraph.PartitionMap[partition.Vertex{Addr: key}] = int(val)
-	}
+func (ccm *CLPACommitteeModule) clpaReset() { //clpaReset方法用于重置委员会模块，按交易图的窗口策略删除旧的交易，保留当前的划分
+	ccm.partitioner.Reset()
 }
 
 func (ccm *CLPACommitteeModule) AdjustByBlockInfos(b *message.BlockInfoMsg) {
+	ccm.bp.update(b)
 	ccm.sl.Slog.Printf("Supervisor: received from shard %d in epoch %d.\n", b.SenderShardID, b.Epoch)
 	if b.BlockBodyLength == 0 {
 		return
 	}
+	executed := append([]*core.Transaction{}, b.Relay1Txs...)
+	for _, tx := range b.ExcutedTxs {
+		if !tx.Relayed { //中继交易的前半部分已经在 Relay1Txs 中
+			executed = append(executed, tx)
+		}
+	}
+	ccm.pending.execute(executed)
+
 	ccm.clpaLock.Lock()
+	ccm.partitioner.NewBlock()
 	for _, tx := range b.ExcutedTxs {
-		ccm.clpaGraph.AddEdge(partition.Vertex{Addr: tx.Sender}, partition.Vertex{Addr: tx.Recipient})
+		ccm.partitioner.AddWeightedEdge(ccm.geo.Vertex(tx.Sender), ccm.geo.Vertex(tx.Recipient), clpaEdgeWeight(tx.Value))
 	}
 	ccm.clpaLock.Unlock()
 }
+
+// 交易在 CLPA 交易图中的边的权重，params.CLPA_EdgeWeight 为 value 时是交易金额，否则是 1（交易数量）
+func clpaEdgeWeight(value *big.Int) float64 {
+	if params.CLPA_EdgeWeight != "value" {
+		return 1
+	}
+	if value == nil {
+		return 0
+	}
+	w, _ := new(big.Float).SetInt(value).Float64()
+	return w
+}
+
+// 考虑地理位置（params.CLPA_GeoPenalty 大于 0）时读取分片和账户的地理位置，否则返回 nil
+func clpaGeography() *partition.Geography {
+	if params.CLPA_GeoPenalty <= 0 {
+		return nil
+	}
+	geo, err := partition.LoadGeography(params.ShardNum)
+	if err != nil {
+		log.Panic(err)
+	}
+	return geo
+}
+
+// 根据 params.CLPA_GraphWindow 得到 CLPA 交易图的窗口：保留的区块数（0 表示不限制）和每个 epoch 的衰减系数，
+// reset 的衰减系数为 0，每次划分之后清空交易图
+func clpaGraphWindow() (int, float64) {
+	switch params.CLPA_GraphWindow {
+	case "window":
+		return params.CLPA_WindowBlocks, 1
+	case "decay":
+		return 0, params.CLPA_DecayFactor
+	case "window-decay":
+		return params.CLPA_WindowBlocks, params.CLPA_DecayFactor
+	default:
+		return 0, 0
+	}
+}
@@ -30,10 +30,11 @@ type CLPACommitteeMod_Broker struct { //CLPACommitteeMod_Broker结构包含 CLPA
 
 	// additional variants
 	clpaLock            sync.Mutex
-	clpaGraph           *partition.CLPAState
+	partitioner         partition.Partitioner // 账户划分算法，由 params.PartitionAlgorithm 选择
 	modifiedMap         map[string]uint64
 	clpaLastRunningTime time.Time
 	clpaFreq            int
+	geo                 *partition.Geography // 分片和账户的地理位置，不考虑地理位置时为 nil
 
 	//broker related  attributes avatar
 	broker             *broker.Broker
@@ -42,17 +43,31 @@ type CLPACommitteeMod_Broker struct { //CLPACommitteeMod_Broker结构包含 CLPA
 	brokerTxPool       []*core.Transaction
 	brokerModuleLock   sync.Mutex
 
+	// 账户迁移之后分片退回的交易，由 TxHandling 重新注入，处理消息时不能等待背压
+	returnedTxs     []*core.Transaction
+	returnedTxsLock sync.Mutex
+
 	// logger module
 	sl *supervisor_log.SupervisorLog
 
 	// control components
 	Ss          *signal.StopSignal // to control the stop message sending
 	IpNodeTable map[uint64]map[uint64]string
+	bp          *backPressure // 分片交易池的背压
+	nonces      *senderNonces //每个发送者的下一个 nonce
+	pending     *pendingTxs   // 账户还没有执行的交易数量，用于估计迁移代价
 }
 
 func NewCLPACommitteeMod_Broker(Ip_nodeTable map[uint64]map[uint64]string, Ss *signal.StopSignal, sl *supervisor_log.SupervisorLog, csvFilePath string, dataNum, batchNum, clpaFrequency int) *CLPACommitteeMod_Broker {
 	cg := new(partition.CLPAState)
-	cg.Init_CLPAState(0.5, 100, params.ShardNum)
+	cg.Init_CLPAState(params.CLPA_WeightPenalty, params.CLPA_MaxIterations, params.ShardNum)
+	cg.SetGraphWindow(clpaGraphWindow())
+	geo := clpaGeography()
+	cg.SetGeography(geo, params.CLPA_GeoPenalty)
+	partitioner, err := partition.NewPartitioner(params.PartitionAlgorithm, cg)
+	if err != nil {
+		log.Panic(err)
+	}
 
 	broker := new(broker.Broker)
 	broker.NewBroker(nil)
@@ -62,7 +77,7 @@ func NewCLPACommitteeMod_Broker(Ip_nodeTable map[uint64]map[uint64]string, Ss *s
 		dataTotalNum:        dataNum,
 		batchDataNum:        batchNum,
 		nowDataNum:          0,
-		clpaGraph:           cg,
+		partitioner:         partitioner,
 		modifiedMap:         make(map[string]uint64),
 		clpaFreq:            clpaFrequency,
 		clpaLastRunningTime: time.Time{},
@@ -73,6 +88,10 @@ func NewCLPACommitteeMod_Broker(Ip_nodeTable map[uint64]map[uint64]string, Ss *s
 		IpNodeTable:         Ip_nodeTable,
 		Ss:                  Ss,
 		sl:                  sl,
+		bp:                  newBackPressure(),
+		nonces:              newSenderNonces(),
+		pending:             newPendingTxs(),
+		geo:                 geo,
 	}
 }
 
@@ -87,8 +106,18 @@ func (ccm *CLPACommitteeMod_Broker) HandleOtherMessage(msg []byte) {
 	if err != nil {
 		log.Panic()
 	}
-	itxs := ccm.dealTxByBroker(itct.Txs)
-	ccm.txSending(itxs)
+	ccm.returnedTxsLock.Lock()
+	ccm.returnedTxs = append(ccm.returnedTxs, itct.Txs...)
+	ccm.returnedTxsLock.Unlock()
+}
+
+// 取出分片退回的交易
+func (ccm *CLPACommitteeMod_Broker) takeReturnedTxs() []*core.Transaction {
+	ccm.returnedTxsLock.Lock()
+	defer ccm.returnedTxsLock.Unlock()
+	txs := ccm.returnedTxs
+	ccm.returnedTxs = nil
+	return txs
 }
 
 func (ccm *CLPACommitteeMod_Broker) fetchModifiedMap(key string) uint64 {
@@ -102,10 +131,12 @@ func (ccm *CLPACommitteeMod_Broker) fetchModifiedMap(key string) uint64 {
 func (ccm *CLPACommitteeMod_Broker) txSending(txlist []*core.Transaction) {
 	// the txs will be sent
 	sendToShard := make(map[uint64][]*core.Transaction)
+	ccm.pending.inject(txlist)
 
 	for idx := 0; idx <= len(txlist); idx++ {
 		if idx > 0 && (idx%params.InjectSpeed == 0 || idx == len(txlist)) {
 			// send to shard
+			ccm.bp.wait(sendToShard, ccm.sl) //等待交易池负载过高的分片
 			for sid := uint64(0); sid < uint64(params.ShardNum); sid++ {
 				it := message.InjectTxs{
 					Txs:       sendToShard[sid],
@@ -116,7 +147,7 @@ func (ccm *CLPACommitteeMod_Broker) txSending(txlist []*core.Transaction) {
 					log.Panic(err)
 				}
 				send_msg := message.MergeMessage(message.CInject, itByte)
-				go networks.TcpDial(send_msg, ccm.IpNodeTable[sid][0])
+				go networks.TcpDial(send_msg, ccm.IpNodeTable[sid][params.GetShardLeader(sid)])
 			}
 			sendToShard = make(map[uint64][]*core.Transaction)
 			time.Sleep(time.Second)
@@ -154,7 +185,7 @@ func (ccm *CLPACommitteeMod_Broker) TxHandling() {
 		if err != nil {
 			log.Panic(err)
 		}
-		if tx, ok := data2tx(data, uint64(ccm.nowDataNum)); ok {
+		if tx, ok := data2tx(data, ccm.nonces); ok {
 			txlist = append(txlist, tx)
 			ccm.nowDataNum++
 		} else {
@@ -168,7 +199,7 @@ func (ccm *CLPACommitteeMod_Broker) TxHandling() {
 				ccm.clpaLastRunningTime = time.Now()
 			}
 
-			itx := ccm.dealTxByBroker(txlist)
+			itx := ccm.dealTxByBroker(append(txlist, ccm.takeReturnedTxs()...))
 
 			ccm.txSending(itx)
 
@@ -179,7 +210,7 @@ func (ccm *CLPACommitteeMod_Broker) TxHandling() {
 
 		if !ccm.clpaLastRunningTime.IsZero() && time.Since(ccm.clpaLastRunningTime) >= time.Duration(ccm.clpaFreq)*time.Second {
 			ccm.clpaLock.Lock()
-			mmap, _ := ccm.clpaGraph.CLPA_Partition()
+			mmap := runCLPA(ccm.partitioner, ccm.pending, ccm.bp, ccm.sl)
 			ccm.clpaMapSend(mmap)
 			for key, val := range mmap {
 				ccm.modifiedMap[key] = val
@@ -198,9 +229,13 @@ func (ccm *CLPACommitteeMod_Broker) TxHandling() {
 	// all transactions are sent. keep sending partition message...
 	for !ccm.Ss.GapEnough() { // wait all txs to be handled
 		time.Sleep(time.Second)
+		if txs := ccm.takeReturnedTxs(); len(txs) > 0 {
+			ccm.txSending(ccm.dealTxByBroker(txs))
+			ccm.Ss.StopGap_Reset()
+		}
 		if time.Since(ccm.clpaLastRunningTime) >= time.Duration(ccm.clpaFreq)*time.Second {
 			ccm.clpaLock.Lock()
-			mmap, _ := ccm.clpaGraph.CLPA_Partition()
+			mmap := runCLPA(ccm.partitioner, ccm.pending, ccm.bp, ccm.sl)
 			ccm.clpaMapSend(mmap)
 			for key, val := range mmap {
 				ccm.modifiedMap[key] = val
@@ -225,20 +260,17 @@ func (ccm *CLPACommitteeMod_Broker) clpaMapSend(m map[string]uint64) {
 	send_msg := message.MergeMessage(message.CPartitionMsg, pmByte)
 	// send to worker shards
 	for i := uint64(0); i < uint64(params.ShardNum); i++ {
-		networks.TcpDial(send_msg, ccm.IpNodeTable[i][0])
+		networks.TcpDial(send_msg, ccm.IpNodeTable[i][params.GetShardLeader(i)])
 	}
 	ccm.sl.Slog.Println("Supervisor: all partition map message has been sent. ")
 }
 
 func (ccm *CLPACommitteeMod_Broker) clpaReset() {
-	ccm.clpaGraph = new(partition.CLPAState)
-	ccm.clpaGraph.Init_CLPAState(0.5, 100, params.ShardNum)
-	for key, val := range ccm.modifiedMap {
-		ccm.clpaGraph.PartitionMap[partition.Vertex{Addr: key}] = int(val)
-	}
+	ccm.partitioner.Reset()
 }
 
 func (ccm *CLPACommitteeMod_Broker) AdjustByBlockInfos(b *message.BlockInfoMsg) {
+	ccm.bp.update(b)
 	ccm.sl.Slog.Printf("received from shard %d in epoch %d.\n", b.SenderShardID, b.Epoch)
 	if b.BlockBodyLength == 0 {
 		return
@@ -249,16 +281,19 @@ func (ccm *CLPACommitteeMod_Broker) AdjustByBlockInfos(b *message.BlockInfoMsg)
 	txs = append(txs, b.Broker1Txs...)
 	txs = append(txs, b.Broker2Txs...)
 	ccm.createConfirm(txs)
+	ccm.pending.execute(b.ExcutedTxs)
+	ccm.pending.execute(b.Broker1Txs)
 
 	ccm.clpaLock.Lock()
+	ccm.partitioner.NewBlock()
 	for _, tx := range b.ExcutedTxs {
 		if tx.HasBroker {
 			continue
 		}
-		ccm.clpaGraph.AddEdge(partition.Vertex{Addr: tx.Sender}, partition.Vertex{Addr: tx.Recipient})
+		ccm.partitioner.AddWeightedEdge(ccm.geo.Vertex(tx.Sender), ccm.geo.Vertex(tx.Recipient), clpaEdgeWeight(tx.Value))
 	}
 	for _, b1tx := range b.Broker1Txs {
-		ccm.clpaGraph.AddEdge(partition.Vertex{Addr: b1tx.OriginalSender}, partition.Vertex{Addr: b1tx.FinalRecipient})
+		ccm.partitioner.AddWeightedEdge(ccm.geo.Vertex(b1tx.OriginalSender), ccm.geo.Vertex(b1tx.FinalRecipient), clpaEdgeWeight(b1tx.Value))
 	}
 	ccm.clpaLock.Unlock()
 }
@@ -318,11 +353,13 @@ func (ccm *CLPACommitteeMod_Broker) handleBrokerType1Mes(brokerType1Megs []*mess
 	tx1s := make([]*core.Transaction, 0)
 	for _, brokerType1Meg := range brokerType1Megs {
 		ctx := brokerType1Meg.RawMeg.Tx
-		tx1 := core.NewTransaction(ctx.Sender, brokerType1Meg.Broker, ctx.Value, ctx.Nonce)
+		tx1 := core.NewTransactionWithFee(ctx.Sender, brokerType1Meg.Broker, ctx.Value, ctx.Nonce, ctx.GasPrice, ctx.GasLimit)
 		tx1.OriginalSender = ctx.Sender
 		tx1.FinalRecipient = ctx.Recipient
-		tx1.RawTxHash = make([]byte, len(ctx.TxHash))
-		copy(tx1.RawTxHash, ctx.TxHash)
+		tx1.SetRawTxHash(ctx.TxHash)
+		if params.SignTxs { //主管节点代替原始发送者签名
+			tx1.SignWithWorkloadKey()
+		}
 		tx1s = append(tx1s, tx1)
 		confirm1 := &message.Mag1Confirm{
 			RawMeg:  brokerType1Meg.RawMeg,
@@ -340,11 +377,13 @@ func (ccm *CLPACommitteeMod_Broker) handleBrokerType2Mes(brokerType2Megs []*mess
 	tx2s := make([]*core.Transaction, 0)
 	for _, mes := range brokerType2Megs {
 		ctx := mes.RawMeg.Tx
-		tx2 := core.NewTransaction(mes.Broker, ctx.Recipient, ctx.Value, ctx.Nonce)
+		tx2 := core.NewTransactionWithFee(mes.Broker, ctx.Recipient, ctx.Value, ctx.Nonce, ctx.GasPrice, ctx.GasLimit)
 		tx2.OriginalSender = ctx.Sender
 		tx2.FinalRecipient = ctx.Recipient
-		tx2.RawTxHash = make([]byte, len(ctx.TxHash))
-		copy(tx2.RawTxHash, ctx.TxHash)
+		tx2.SetRawTxHash(ctx.TxHash)
+		if params.SignTxs { //主管节点代替 broker 签名
+			tx2.SignWithWorkloadKey()
+		}
 		tx2s = append(tx2s, tx2)
 
 		confirm2 := &message.Mag2Confirm{
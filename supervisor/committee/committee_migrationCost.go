@@ -0,0 +1,70 @@
+package committee
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"blockEmulator/partition"
+	"blockEmulator/supervisor/supervisor_log"
+	"sync"
+)
+
+// 账户的迁移代价：账户状态的代价 params.CLPA_StateCost 加上交易池中该账户发送的交易数量，
+// 交易池中的交易数量用主管节点注入、但分片还没有报告执行的交易数量估计。
+// 分片的交易池拒绝或驱逐的交易不会被报告，所以估计值不超过分片报告的交易池大小
+type pendingTxs struct {
+	lock    sync.Mutex
+	pending map[string]int //发送者 -> 还没有执行的交易数量
+}
+
+func newPendingTxs() *pendingTxs {
+	return &pendingTxs{pending: make(map[string]int)}
+}
+
+// 主管节点注入了这些交易
+func (pt *pendingTxs) inject(txs []*core.Transaction) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+	for _, tx := range txs {
+		pt.pending[tx.Sender]++
+	}
+}
+
+// 发送者所在的分片报告执行了这些交易
+func (pt *pendingTxs) execute(txs []*core.Transaction) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+	for _, tx := range txs {
+		if pt.pending[tx.Sender] <= 1 {
+			delete(pt.pending, tx.Sender)
+		} else {
+			pt.pending[tx.Sender]--
+		}
+	}
+}
+
+// 为交易图中的所有账户设置迁移代价，以及迁移代价的参数。
+// 账户的交易数量不超过所在分片的交易池中的交易数量，超过的部分已经被交易池拒绝或驱逐，从计数中删除
+func (pt *pendingTxs) setMigrationCosts(p partition.Partitioner, bp *backPressure) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+	p.SetMigrationCosts(func(v partition.Vertex, shard int) float64 {
+		n := pt.pending[v.Addr]
+		if pooled := bp.pooled(uint64(shard)); n > pooled {
+			n = pooled
+			if n == 0 {
+				delete(pt.pending, v.Addr)
+			} else {
+				pt.pending[v.Addr] = n
+			}
+		}
+		return params.CLPA_StateCost + float64(n)
+	}, params.CLPA_MigrationWeight, params.CLPA_MigrationBudget)
+}
+
+// 设置迁移代价后运行划分算法，在日志中记录迁移报告
+func runCLPA(p partition.Partitioner, pt *pendingTxs, bp *backPressure, sl *supervisor_log.SupervisorLog) map[string]uint64 {
+	pt.setMigrationCosts(p, bp)
+	mmap := p.Partition()
+	sl.Slog.Printf("Supervisor: %s %v\n", params.PartitionAlgorithm, p.LastReport())
+	return mmap
+}
@@ -14,6 +14,8 @@ import (
 	"log"
 	"math/big"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -25,6 +27,8 @@ type RelayCommitteeModule struct { //RelayCommitteeModule结构包含中继委
 	IpNodeTable  map[uint64]map[uint64]string  //区块链模拟中节点的 IP 地址映射，IpNodeTable它是一个两级映射，其中外部映射具有 uint64 类型的键，可以表示分片 ID，内部映射也具有 uint64 类型的键并映射到字符串值，表示 IP 地址。此映射允许模块根据节点的分片和节点 ID 确定节点的 IP 地址。
 	sl           *supervisor_log.SupervisorLog //主管日志
 	Ss           *signal.StopSignal            //负责全局网络的节点的终止信息分送，用于表示某些进程或操作的终止
+	bp           *backPressure                 //分片交易池的背压
+	nonces       *senderNonces                 //每个发送者的下一个 nonce
 }
 
 func NewRelayCommitteeModule(Ip_nodeTable map[uint64]map[uint64]string, Ss *signal.StopSignal, slog *supervisor_log.SupervisorLog, csvFilePath string, dataNum, batchNum int) *RelayCommitteeModule {
@@ -37,15 +41,36 @@ func NewRelayCommitteeModule(Ip_nodeTable map[uint64]map[uint64]string, Ss *sign
 		IpNodeTable:  Ip_nodeTable,
 		Ss:           Ss,
 		sl:           slog,
+		bp:           newBackPressure(),
+		nonces:       newSenderNonces(),
 	}
 }
 
+// 每个发送者的下一个 nonce，每个委员会模块各有一份，同一进程中的下一次运行从 0 开始
+type senderNonces struct {
+	lock sync.Mutex
+	next map[utils.Address]uint64
+}
+
+func newSenderNonces() *senderNonces {
+	return &senderNonces{next: make(map[utils.Address]uint64)}
+}
+
+// 为发送者分配下一个 nonce，同一发送者的交易 nonce 从 0 开始连续递增，节点按 nonce 顺序执行
+func (sn *senderNonces) nextNonce(sender utils.Address) uint64 {
+	sn.lock.Lock()
+	defer sn.lock.Unlock()
+	n := sn.next[sender]
+	sn.next[sender] = n + 1
+	return n
+}
+
 // transfrom, data to transaction
 // 检查是否是合法的txs消息。如果是，则读取txs并将其放入txlist中
-func data2tx(data []string, nonce uint64) (*core.Transaction, bool) {
+func data2tx(data []string, nonces *senderNonces) (*core.Transaction, bool) {
 	//检查给定的数据集是否代表有效的交易消息，如果是，则将数据转换为 core.Transaction 对象。
 	// data：这是一个字符串片段，包含各种信息，可能代表交易消息。
-	//nonce：这是一个无符号 64 位整数，表示随机数值。它在创建交易时使用。
+	//交易的 nonce 是发送者的下一个 nonce。
 	if data[6] == "0" && data[7] == "0" && len(data[3]) > 16 && len(data[4]) > 16 && data[3] != data[4] { //检查交易是否有效，
 		// 它检查 data[6] 和 data[7] 是否都等于“0”，这可能表明使消息有效的某些条件。
 		//它检查 data[3] 和 data[4] 的长度是否大于 16，这可能是验证标准的一部分。
@@ -54,13 +79,32 @@ func data2tx(data []string, nonce uint64) (*core.Transaction, bool) {
 		if !ok {                                       //检查转换是否成功。
 			log.Panic("new int failed\n")
 		}
-		tx := core.NewTransaction(data[3][2:], data[4][2:], val, nonce) //通过调用 core.NewTransaction(data[3][2:], data[4][2:], val, nonce) 创建一个 core.Transaction 对象 (tx)。 data[3][2:]和data[4][2:]可能表示带有“0x”前缀的十六进制地址，val表示交易值。nonce为交易提供随机数值。
+		gasPrice, gasLimit := dataGas(data)
+		sender := core.WorkloadAddress(data[3][2:])
+		tx := core.NewTransactionWithFee(sender, core.WorkloadAddress(data[4][2:]), val, nonces.nextNonce(sender), gasPrice, gasLimit) //创建一个 core.Transaction 对象 (tx)。 data[3][2:]和data[4][2:]可能表示带有“0x”前缀的十六进制地址，开启交易签名时被替换为由确定性私钥推导出的地址，val表示交易值，nonce为发送者的下一个 nonce，gas 价格和 gas 上限来自数据集。
+		if params.SignTxs {
+			tx.SignWithWorkloadKey()
+		}
 		return tx, true
 	}
 	return &core.Transaction{}, false
 	//如果数据表示有效交易，则该函数将返回 tx 对象以及 true。否则，它返回一个空的 core.Transaction 对象和 false 来指示该数据不是有效的交易。
 }
 
+// 数据集中的 gas 上限（data[9]）和 gas 价格（data[10]），没有或无法解析时使用默认值
+func dataGas(data []string) (*big.Int, uint64) {
+	gasPrice, gasLimit := big.NewInt(params.DefaultGasPrice), params.TxGas
+	if len(data) > 10 {
+		if gl, err := strconv.ParseUint(data[9], 10, 64); err == nil {
+			gasLimit = gl
+		}
+		if gp, ok := new(big.Int).SetString(data[10], 10); ok {
+			gasPrice = gp
+		}
+	}
+	return gasPrice, gasLimit
+}
+
 //上面的函数负责解析和验证交易数据，并在数据有效时创建交易对象。它通常在区块链系统中用于处理传入的交易消息。
 
 func (rthm *RelayCommitteeModule) HandleOtherMessage([]byte) {} //HandleOtherMessage()函数用于处理其他消息
@@ -76,6 +120,7 @@ func (rthm *RelayCommitteeModule) txSending(txlist []*core.Transaction) { //txSe
 			//idx > 0 表示已经处理了一定数量的交易。
 			//(idx % params.InjectSpeed == 0 || idx == len(txlist)) 表示已经处理了 params.InjectSpeed 定义的特定数量的交易，或者已经处理完了所有交易。
 			// 如果上述条件满足，就会将交易发送到各个分片
+			rthm.bp.wait(sendToShard, rthm.sl)                           //等待交易池负载过高的分片
 			for sid := uint64(0); sid < uint64(params.ShardNum); sid++ { //循环遍历各个分片，其中 sid 表示分片的ID
 				it := message.InjectTxs{ //创建一个新的 InjectTxs 对象 (it)，其中包含要发送到分片的交易列表 (Txs) 和目标分片 ID (ToShardID)
 					Txs:       sendToShard[sid],
@@ -85,8 +130,8 @@ func (rthm *RelayCommitteeModule) txSending(txlist []*core.Transaction) { //txSe
 				if err != nil {
 					log.Panic(err)
 				}
-				send_msg := message.MergeMessage(message.CInject, itByte) //创建一个消息 send_msg，将消息类型设为 message.CInject，并将 itByte 数据合并进来
-				go networks.TcpDial(send_msg, rthm.IpNodeTable[sid][0])   //通过调用 networks.TcpDial(send_msg, rthm.IpNodeTable[sid][0]) 将消息发送到目标分片中的第一个节点 (rthm.IpNodeTable[sid][0])。
+				send_msg := message.MergeMessage(message.CInject, itByte)                        //创建一个消息 send_msg，将消息类型设为 message.CInject，并将 itByte 数据合并进来
+				go networks.TcpDial(send_msg, rthm.IpNodeTable[sid][params.GetShardLeader(sid)]) //通过调用 networks.TcpDial 将消息发送到目标分片当前的主节点。
 			}
 			sendToShard = make(map[uint64][]*core.Transaction) //发送完交易后，重置 sendToShard 映射，以便在下一批次中使用。
 			time.Sleep(time.Second)                            //在每批交易之间添加一秒的延迟，以控制发送交易的速率
@@ -122,7 +167,7 @@ func (rthm *RelayCommitteeModule) TxHandling() { //TxHandling()函数用于处
 		if err != nil { //如果读取过程中发生错误，则打印错误日志并退出循环
 			log.Panic(err)
 		}
-		if tx, ok := data2tx(data, uint64(rthm.nowDataNum)); ok { //检查读取的数据是否有效：调用 data2tx(data, uint64(rthm.nowDataNum)) 函数将读取的数据转换为交易对象，并检查交易是否有效。如果有效，将其添加到 txlist 中，同时将 rthm.nowDataNum 递增。
+		if tx, ok := data2tx(data, rthm.nonces); ok { //检查读取的数据是否有效：调用 data2tx(data) 函数将读取的数据转换为交易对象，并检查交易是否有效。如果有效，将其添加到 txlist 中，同时将 rthm.nowDataNum 递增。
 			txlist = append(txlist, tx)
 			rthm.nowDataNum++
 		}
@@ -143,5 +188,6 @@ func (rthm *RelayCommitteeModule) TxHandling() { //TxHandling()函数用于处
 
 // no operation here
 func (rthm *RelayCommitteeModule) AdjustByBlockInfos(b *message.BlockInfoMsg) { //AdjustByBlockInfos()函数用于根据区块信息调整委员会模块，b表示区块信息消息
+	rthm.bp.update(b)
 	rthm.sl.Slog.Printf("received from shard %d in epoch %d.\n", b.SenderShardID, b.Epoch) //打印日志
 }
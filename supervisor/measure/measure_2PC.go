@@ -0,0 +1,60 @@
+package measure
+
+import (
+	"blockEmulator/message"
+	"encoding/json"
+)
+
+// 从分片主节点的 2PC 投票中解析投票，其他消息返回 false
+func decodeTwoPCVote(msg []byte) (*message.TwoPCVote, bool) {
+	msgType, content := message.SplitMessage(msg)
+	if msgType != message.CTwoPCVote {
+		return nil, false
+	}
+	vote := new(message.TwoPCVote)
+	if err := json.Unmarshal(content, vote); err != nil {
+		return nil, false
+	}
+	return vote, true
+}
+
+// 按分片统计 2PC 交易中某一类交易（例如被中止的交易）所占的比例
+type twoPCRatio struct {
+	total    map[uint64]float64 //每个分片统计的交易数量
+	part     map[uint64]float64 //每个分片中该类交易的数量
+	maxShard uint64             //出现过的最大分片ID
+}
+
+func newTwoPCRatio() *twoPCRatio {
+	return &twoPCRatio{
+		total: make(map[uint64]float64),
+		part:  make(map[uint64]float64),
+	}
+}
+
+func (r *twoPCRatio) add(sid uint64, total, part float64) {
+	if sid > r.maxShard {
+		r.maxShard = sid
+	}
+	r.total[sid] += total
+	r.part[sid] += part
+}
+
+// 输出每个分片的比例以及总的比例，没有交易的分片比例为 0
+func (r *twoPCRatio) output() (perShard []float64, tot float64) {
+	perShard = make([]float64, 0)
+	totAll, totPart := 0.0, 0.0
+	for sid := uint64(0); sid <= r.maxShard && len(r.total) > 0; sid++ {
+		perShard = append(perShard, ratio(r.total[sid], r.part[sid]))
+		totAll += r.total[sid]
+		totPart += r.part[sid]
+	}
+	return perShard, ratio(totAll, totPart)
+}
+
+func ratio(total, part float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return part / total
+}
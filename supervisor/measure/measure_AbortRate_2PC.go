@@ -0,0 +1,32 @@
+package measure
+
+import (
+	"blockEmulator/message"
+)
+
+// to test the abort rate of two-phase commit, count the decisions executed in the shard of the sender
+type TestAbortRate_2PC struct { //TestAbortRate_2PC结构用于统计每个分片作为发送者分片的跨分片交易中被中止的比例
+	decisions *twoPCRatio //每个分片执行的决定交易数量以及其中的中止交易数量
+}
+
+func NewTestAbortRate_2PC() *TestAbortRate_2PC {
+	return &TestAbortRate_2PC{
+		decisions: newTwoPCRatio(),
+	}
+}
+
+func (tar *TestAbortRate_2PC) OutputMetricName() string {
+	return "Abort_Rate_2PC"
+}
+
+func (tar *TestAbortRate_2PC) UpdateMeasureRecord(b *message.BlockInfoMsg) {} //只从 2PC 投票中统计
+
+func (tar *TestAbortRate_2PC) HandleExtraMessage(msg []byte) {
+	if vote, ok := decodeTwoPCVote(msg); ok {
+		tar.decisions.add(vote.SenderShardID, float64(vote.Committed+vote.Aborted), float64(vote.Aborted))
+	}
+}
+
+func (tar *TestAbortRate_2PC) OutputRecord() (perShardAbortRate []float64, totAbortRate float64) { //输出每个分片的中止率以及总的中止率
+	return tar.decisions.output()
+}
@@ -0,0 +1,65 @@
+package measure
+
+import (
+	"blockEmulator/message"
+	"encoding/json"
+	"time"
+)
+
+// to test liveness in byzantine experiments, record the longest interval between two committed sequences of each shard
+type TestCommitGap struct { //TestCommitGap结构用于统计每个分片两次提交之间的最长间隔
+	highestSeq map[uint64]uint64        //每个分片已经提交的最大序列ID
+	lastCommit map[uint64]time.Time     //每个分片最近一次提交新序列的时间
+	maxGap     map[uint64]time.Duration //每个分片两次提交之间的最长间隔
+	maxShard   uint64                   //出现过的最大分片ID
+}
+
+func NewTestCommitGap() *TestCommitGap { //NewTestCommitGap函数用于创建活性测量模块
+	return &TestCommitGap{
+		highestSeq: make(map[uint64]uint64),
+		lastCommit: make(map[uint64]time.Time),
+		maxGap:     make(map[uint64]time.Duration),
+	}
+}
+
+func (tcg *TestCommitGap) OutputMetricName() string {
+	return "Max_Commit_Gap"
+}
+
+func (tcg *TestCommitGap) UpdateMeasureRecord(b *message.BlockInfoMsg) {} //活性只从节点的提交报告中统计
+
+func (tcg *TestCommitGap) HandleExtraMessage(msg []byte) { //处理节点的提交报告，每个序列只按第一个诚实节点的提交时间计算
+	msgType, content := message.SplitMessage(msg)
+	if msgType != message.CCommitInfo {
+		return
+	}
+	ci := new(message.CommitInfo)
+	if err := json.Unmarshal(content, ci); err != nil || ci.Faulty {
+		return
+	}
+	if ci.ShardID > tcg.maxShard {
+		tcg.maxShard = ci.ShardID
+	}
+	last, ok := tcg.lastCommit[ci.ShardID]
+	if ok && ci.SeqID <= tcg.highestSeq[ci.ShardID] {
+		return
+	}
+	if ok && ci.CommitTime.Sub(last) > tcg.maxGap[ci.ShardID] {
+		tcg.maxGap[ci.ShardID] = ci.CommitTime.Sub(last)
+	}
+	tcg.highestSeq[ci.ShardID] = ci.SeqID
+	tcg.lastCommit[ci.ShardID] = ci.CommitTime
+}
+
+func (tcg *TestCommitGap) OutputRecord() (perShardGap []float64, maxGap float64) { //输出每个分片的最长提交间隔（秒）以及所有分片中的最大值
+	perShardGap = make([]float64, 0)
+	maxGap = 0
+	for sid := uint64(0); sid <= tcg.maxShard && len(tcg.lastCommit) > 0; sid++ {
+		gap := tcg.maxGap[sid].Seconds()
+		perShardGap = append(perShardGap, gap)
+		if gap > maxGap {
+			maxGap = gap
+		}
+	}
+	return perShardGap, maxGap
+}
@@ -0,0 +1,78 @@
+package measure
+
+import (
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"blockEmulator/partition"
+	"log"
+)
+
+// to test the cross-region traffic of geography-aware partitioning, count the txs that a shard executes for an account located in another region
+type TestCrossRegionTx struct { //TestCrossRegionTx结构用于统计每个分片执行的交易中，账户与分片不在同一个区域的比例
+	geo         *partition.Geography
+	txNum       map[uint64]float64 //每个分片执行的交易数量
+	crossRegion map[uint64]float64 //每个分片执行的账户位于其他区域的交易数量
+	maxShard    uint64             //出现过的最大分片ID
+}
+
+func NewTestCrossRegionTx() *TestCrossRegionTx {
+	geo, err := partition.LoadGeography(params.ShardNum)
+	if err != nil {
+		log.Panic(err)
+	}
+	return &TestCrossRegionTx{
+		geo:         geo,
+		txNum:       make(map[uint64]float64),
+		crossRegion: make(map[uint64]float64),
+	}
+}
+
+func (tcr *TestCrossRegionTx) OutputMetricName() string {
+	return "Cross_Region_Tx_Rate"
+}
+
+func (tcr *TestCrossRegionTx) UpdateMeasureRecord(b *message.BlockInfoMsg) {
+	if b.BlockBodyLength == 0 { // empty block
+		return
+	}
+	sid := b.SenderShardID
+	if sid > tcr.maxShard {
+		tcr.maxShard = sid
+	}
+	count := func(addr string) {
+		tcr.txNum[sid] += 1
+		if tcr.geo.ShardDistance(tcr.geo.Location(addr), int(sid)) > 0 {
+			tcr.crossRegion[sid] += 1
+		}
+	}
+	for _, tx := range b.ExcutedTxs {
+		if tx.Relayed || tx.SenderIsBroker { //中继交易的后半部分和 broker 发出的交易在接收者所在的分片执行
+			count(tx.Recipient)
+		} else {
+			count(tx.Sender)
+		}
+	}
+	for _, tx := range b.Relay1Txs {
+		count(tx.Sender)
+	}
+}
+
+func (tcr *TestCrossRegionTx) HandleExtraMessage([]byte) {}
+
+func (tcr *TestCrossRegionTx) OutputRecord() (perShardRate []float64, totRate float64) { //输出每个分片的跨区域交易比例以及总的跨区域交易比例
+	perShardRate = make([]float64, 0)
+	totTxs, totCross := 0.0, 0.0
+	for sid := uint64(0); sid <= tcr.maxShard && len(tcr.txNum) > 0; sid++ {
+		perShardRate = append(perShardRate, crossRegionRate(tcr.txNum[sid], tcr.crossRegion[sid]))
+		totTxs += tcr.txNum[sid]
+		totCross += tcr.crossRegion[sid]
+	}
+	return perShardRate, crossRegionRate(totTxs, totCross)
+}
+
+func crossRegionRate(txs, cross float64) float64 {
+	if txs == 0 {
+		return 0
+	}
+	return cross / txs
+}
@@ -0,0 +1,32 @@
+package measure
+
+import (
+	"blockEmulator/message"
+)
+
+// to test the lock contention of two-phase commit, count the prepares refused because the account is locked by another 2PC tx
+type TestLockContention_2PC struct { //TestLockContention_2PC结构用于统计每个分片的准备交易中因为锁冲突而失败的比例
+	prepares *twoPCRatio //每个分片执行的准备交易数量以及其中因为锁冲突而失败的数量
+}
+
+func NewTestLockContention_2PC() *TestLockContention_2PC {
+	return &TestLockContention_2PC{
+		prepares: newTwoPCRatio(),
+	}
+}
+
+func (tlc *TestLockContention_2PC) OutputMetricName() string {
+	return "Lock_Contention_2PC"
+}
+
+func (tlc *TestLockContention_2PC) UpdateMeasureRecord(b *message.BlockInfoMsg) {} //只从 2PC 投票中统计
+
+func (tlc *TestLockContention_2PC) HandleExtraMessage(msg []byte) {
+	if vote, ok := decodeTwoPCVote(msg); ok {
+		tlc.prepares.add(vote.SenderShardID, float64(len(vote.Prepared)+len(vote.Refused)), float64(vote.LockConflicts))
+	}
+}
+
+func (tlc *TestLockContention_2PC) OutputRecord() (perShardConflictRate []float64, totConflictRate float64) { //输出每个分片的锁冲突率以及总的锁冲突率
+	return tlc.prepares.output()
+}
@@ -0,0 +1,58 @@
+package measure
+
+import (
+	"blockEmulator/message"
+	"encoding/json"
+)
+
+// to test safety in byzantine experiments, count the sequences at which honest nodes commit different requests
+type TestSafetyViolation struct { //TestSafetyViolation结构用于统计诚实节点在同一序列上提交了不同请求的次数
+	committed  map[uint64]map[uint64]string //每个分片中每个序列第一个被诚实节点提交的请求摘要
+	violations map[uint64]float64           //每个分片的安全性违反次数
+	maxShard   uint64                       //出现过的最大分片ID
+}
+
+func NewTestSafetyViolation() *TestSafetyViolation { //NewTestSafetyViolation函数用于创建安全性测量模块
+	return &TestSafetyViolation{
+		committed:  make(map[uint64]map[uint64]string),
+		violations: make(map[uint64]float64),
+	}
+}
+
+func (tsv *TestSafetyViolation) OutputMetricName() string {
+	return "Safety_Violation"
+}
+
+func (tsv *TestSafetyViolation) UpdateMeasureRecord(b *message.BlockInfoMsg) {} //安全性只从节点的提交报告中统计
+
+func (tsv *TestSafetyViolation) HandleExtraMessage(msg []byte) { //处理节点的提交报告
+	msgType, content := message.SplitMessage(msg)
+	if msgType != message.CCommitInfo {
+		return
+	}
+	ci := new(message.CommitInfo)
+	if err := json.Unmarshal(content, ci); err != nil || ci.Faulty { //拜占庭节点的提交不计入
+		return
+	}
+	if ci.ShardID > tsv.maxShard {
+		tsv.maxShard = ci.ShardID
+	}
+	if _, ok := tsv.committed[ci.ShardID]; !ok {
+		tsv.committed[ci.ShardID] = make(map[uint64]string)
+	}
+	if digest, ok := tsv.committed[ci.ShardID][ci.SeqID]; !ok {
+		tsv.committed[ci.ShardID][ci.SeqID] = string(ci.Digest)
+	} else if digest != string(ci.Digest) {
+		tsv.violations[ci.ShardID]++
+	}
+}
+
+func (tsv *TestSafetyViolation) OutputRecord() (perShardViolations []float64, totViolations float64) { //输出每个分片的安全性违反次数以及总次数
+	perShardViolations = make([]float64, 0)
+	totViolations = 0
+	for sid := uint64(0); sid <= tsv.maxShard && len(tsv.committed) > 0; sid++ {
+		perShardViolations = append(perShardViolations, tsv.violations[sid])
+		totViolations += tsv.violations[sid]
+	}
+	return perShardViolations, totViolations
+}
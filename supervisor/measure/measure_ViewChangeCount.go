@@ -0,0 +1,51 @@
+package measure
+
+import (
+	"blockEmulator/message"
+	"encoding/json"
+)
+
+// to test liveness in byzantine experiments, count the view changes of each shard
+type TestViewChangeCount struct { //TestViewChangeCount结构用于统计每个分片的视图切换次数
+	maxView  map[uint64]uint64 //每个分片提交时出现过的最大视图ID，视图从 0 开始递增，因此等于视图切换的次数
+	maxShard uint64            //出现过的最大分片ID
+}
+
+func NewTestViewChangeCount() *TestViewChangeCount { //NewTestViewChangeCount函数用于创建视图切换次数的测量模块
+	return &TestViewChangeCount{
+		maxView: make(map[uint64]uint64),
+	}
+}
+
+func (tvc *TestViewChangeCount) OutputMetricName() string {
+	return "View_Change_Count"
+}
+
+func (tvc *TestViewChangeCount) UpdateMeasureRecord(b *message.BlockInfoMsg) {}
+
+func (tvc *TestViewChangeCount) HandleExtraMessage(msg []byte) { //处理节点的提交报告
+	msgType, content := message.SplitMessage(msg)
+	if msgType != message.CCommitInfo {
+		return
+	}
+	ci := new(message.CommitInfo)
+	if err := json.Unmarshal(content, ci); err != nil || ci.Faulty {
+		return
+	}
+	if ci.ShardID > tvc.maxShard {
+		tvc.maxShard = ci.ShardID
+	}
+	if ci.View > tvc.maxView[ci.ShardID] {
+		tvc.maxView[ci.ShardID] = ci.View
+	}
+}
+
+func (tvc *TestViewChangeCount) OutputRecord() (perShardViewChanges []float64, totViewChanges float64) { //输出每个分片的视图切换次数以及总次数
+	perShardViewChanges = make([]float64, 0)
+	totViewChanges = 0
+	for sid := uint64(0); sid <= tvc.maxShard && len(tvc.maxView) > 0; sid++ {
+		perShardViewChanges = append(perShardViewChanges, float64(tvc.maxView[sid]))
+		totViewChanges += float64(tvc.maxView[sid])
+	}
+	return perShardViewChanges, totViewChanges
+}
@@ -0,0 +1,208 @@
+// 实验场景：按照实验文件中的时间或者区块高度，让指定节点与其他节点隔离（网络分区）或者崩溃，
+// 一段时间后再恢复。执行过的事件按时间记录下来，与测量结果一起输出。
+
+package supervisor
+
+import (
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type ScenarioPeer struct { //ScenarioPeer结构表示场景中的一个节点
+	ShardID uint64
+	NodeID  uint64
+}
+
+type ScenarioEvent struct { //ScenarioEvent结构表示一个场景事件，例如“在 30s 到 60s 之间隔离分片 1 的 2 号节点”或者“在第 50 个区块让分片 0 的主节点崩溃”
+	Action   string         //事件类型：isolate（隔离）或 crash（崩溃）
+	ShardID  uint64         //目标节点所在的分片
+	NodeID   uint64         //目标节点的ID
+	Leader   bool           //为 true 时目标为事件发生时分片的主节点，忽略 NodeID
+	Peers    []ScenarioPeer //isolate 事件中被隔离的对端节点，为空表示与所有节点隔离
+	AtTime   float64        //事件发生的时间（实验开始后的秒数），AtBlock 为 0 时使用
+	AtBlock  uint64         //分片提交到该高度时发生事件
+	Duration float64        //事件持续的时间（秒），之后节点恢复，0 表示不恢复
+}
+
+type Scenario struct { //Scenario结构表示实验文件中的场景
+	Events []ScenarioEvent
+}
+
+// 从 json 文件中读取实验场景
+func LoadScenario(path string) (*Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc := new(Scenario)
+	if err := json.Unmarshal(b, sc); err != nil {
+		return nil, err
+	}
+	for _, ev := range sc.Events {
+		if ev.Action != "isolate" && ev.Action != "crash" {
+			return nil, fmt.Errorf("unknown scenario action: %s", ev.Action)
+		}
+	}
+	return sc, nil
+}
+
+// 场景引擎，负责在合适的时候触发事件，并记录时间线
+type scenarioEngine struct {
+	d        *Supervisor
+	events   []ScenarioEvent
+	fired    []bool
+	start    time.Time
+	heights  map[uint64]uint64 //每个分片已经提交的高度
+	timeline [][]string        //事件的时间线
+	lock     sync.Mutex
+	stop     chan struct{}
+}
+
+func newScenarioEngine(d *Supervisor, sc *Scenario) *scenarioEngine {
+	return &scenarioEngine{
+		d:        d,
+		events:   sc.Events,
+		fired:    make([]bool, len(sc.Events)),
+		heights:  make(map[uint64]uint64),
+		timeline: make([][]string, 0),
+		stop:     make(chan struct{}),
+	}
+}
+
+// 开始计时，按时间触发事件
+func (se *scenarioEngine) run() {
+	se.lock.Lock()
+	se.start = time.Now()
+	se.lock.Unlock()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			se.check()
+		case <-se.stop:
+			return
+		}
+	}
+}
+
+// 根据节点的提交报告更新分片的高度，按区块高度触发事件
+func (se *scenarioEngine) handleMessage(msg []byte) {
+	msgType, content := message.SplitMessage(msg)
+	if msgType != message.CCommitInfo {
+		return
+	}
+	ci := new(message.CommitInfo)
+	if err := json.Unmarshal(content, ci); err != nil {
+		return
+	}
+	se.lock.Lock()
+	if ci.SeqID > se.heights[ci.ShardID] {
+		se.heights[ci.ShardID] = ci.SeqID
+	}
+	se.lock.Unlock()
+	se.check()
+}
+
+// 检查是否有事件需要触发
+func (se *scenarioEngine) check() {
+	se.lock.Lock()
+	defer se.lock.Unlock()
+	if se.start.IsZero() {
+		return
+	}
+	elapsed := time.Since(se.start).Seconds()
+	for i, ev := range se.events {
+		if se.fired[i] {
+			continue
+		}
+		if (ev.AtBlock > 0 && se.heights[ev.ShardID] >= ev.AtBlock) || (ev.AtBlock == 0 && elapsed >= ev.AtTime) {
+			se.fired[i] = true
+			se.fire(ev)
+		}
+	}
+}
+
+// 触发一个事件，并在持续时间结束后恢复
+func (se *scenarioEngine) fire(ev ScenarioEvent) {
+	nid := ev.NodeID
+	if ev.Leader {
+		nid = params.GetShardLeader(ev.ShardID)
+	}
+	target := se.d.Ip_nodeTable[ev.ShardID][nid]
+	var startMsg, endMsg []byte
+	switch ev.Action {
+	case "isolate":
+		peers := make([]string, 0, len(ev.Peers))
+		for _, peer := range ev.Peers {
+			peers = append(peers, se.d.Ip_nodeTable[peer.ShardID][peer.NodeID])
+		}
+		imByte, err := json.Marshal(message.IsolateMsg{Peers: peers})
+		if err != nil {
+			log.Panic(err)
+		}
+		startMsg = message.MergeMessage(message.CIsolate, imByte)
+		endMsg = message.MergeMessage(message.CHeal, imByte)
+	case "crash":
+		startMsg = message.MergeMessage(message.CCrash, []byte("crash"))
+		endMsg = message.MergeMessage(message.CRestart, []byte("restart"))
+	}
+	se.d.sendControl(startMsg, target)
+	se.record(ev.Action, ev.ShardID, nid, ev.Peers)
+	if ev.Duration > 0 {
+		time.AfterFunc(time.Duration(ev.Duration*float64(time.Second)), func() {
+			se.d.sendControl(endMsg, target)
+			se.lock.Lock()
+			defer se.lock.Unlock()
+			if ev.Action == "isolate" {
+				se.record("heal", ev.ShardID, nid, ev.Peers)
+			} else {
+				se.record("restart", ev.ShardID, nid, nil)
+			}
+		})
+	}
+}
+
+// 记录一条时间线，调用者需要持有 se.lock
+func (se *scenarioEngine) record(action string, sid, nid uint64, peers []ScenarioPeer) {
+	peerStr := "all"
+	if len(peers) > 0 {
+		peerStr = fmt.Sprint(peers)
+	}
+	if action == "crash" || action == "restart" {
+		peerStr = ""
+	}
+	se.timeline = append(se.timeline, []string{
+		strconv.FormatFloat(time.Since(se.start).Seconds(), 'f', 3, 64),
+		action,
+		strconv.FormatUint(sid, 10),
+		strconv.FormatUint(nid, 10),
+		peerStr,
+		strconv.FormatUint(se.heights[sid], 10),
+	})
+	se.d.sl.Slog.Printf("Supervisor: scenario event %s on S%dN%d\n", action, sid, nid)
+}
+
+// 停止场景引擎，并把时间线写入 .csv 文件
+func (se *scenarioEngine) output(dirpath string) {
+	close(se.stop)
+	se.lock.Lock()
+	defer se.lock.Unlock()
+	file, err := os.Create(dirpath + "Scenario_Timeline.csv")
+	if err != nil {
+		log.Panic(err)
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	w.Write([]string{"Time(s)", "Action", "ShardID", "NodeID", "Peers", "ShardHeight"})
+	w.WriteAll(se.timeline)
+	w.Flush()
+}
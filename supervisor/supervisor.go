@@ -7,6 +7,7 @@ import (
 	"blockEmulator/message"
 	"blockEmulator/networks"
 	"blockEmulator/params"
+	"blockEmulator/shard"
 	"blockEmulator/supervisor/committee"
 	"blockEmulator/supervisor/measure"
 	"blockEmulator/supervisor/signal"
@@ -46,6 +47,9 @@ type Supervisor struct { //Supervisor结构包含客户端的各种信息
 	//测量模块
 	testMeasureMods []measure.MeasureModule //负责区块测试链的各类性能，如TPS、延迟、跨分片交易率等
 
+	//实验场景
+	scenario *scenarioEngine //负责按实验文件让节点隔离或崩溃，为空表示没有实验场景
+
 	//在此处添加更多结构或类
 }
 
@@ -61,11 +65,13 @@ func (d *Supervisor) NewSupervisor(ip string, pcc *params.ChainConfig, committee
 
 	switch committeeMethod {
 	case "CLPA_Broker":
-		d.comMod = committee.NewCLPACommitteeMod_Broker(d.Ip_nodeTable, d.Ss, d.sl, params.FileInput, params.TotalDataSize, params.BatchSize, 80)
+		d.comMod = committee.NewCLPACommitteeMod_Broker(d.Ip_nodeTable, d.Ss, d.sl, params.FileInput, params.TotalDataSize, params.BatchSize, params.CLPA_Frequency)
 	case "CLPA":
-		d.comMod = committee.NewCLPACommitteeModule(d.Ip_nodeTable, d.Ss, d.sl, params.FileInput, params.TotalDataSize, params.BatchSize, 80)
+		d.comMod = committee.NewCLPACommitteeModule(d.Ip_nodeTable, d.Ss, d.sl, params.FileInput, params.TotalDataSize, params.BatchSize, params.CLPA_Frequency)
 	case "Broker":
 		d.comMod = committee.NewBrokerCommitteeMod(d.Ip_nodeTable, d.Ss, d.sl, params.FileInput, params.TotalDataSize, params.BatchSize)
+	case "2PC":
+		d.comMod = committee.NewTwoPCCommitteeModule(d.Ip_nodeTable, d.Ss, d.sl, params.FileInput, params.TotalDataSize, params.BatchSize)
 	default:
 		d.comMod = committee.NewRelayCommitteeModule(d.Ip_nodeTable, d.Ss, d.sl, params.FileInput, params.TotalDataSize, params.BatchSize) //创建一个新的委员会模块
 	}
@@ -89,9 +95,29 @@ func (d *Supervisor) NewSupervisor(ip string, pcc *params.ChainConfig, committee
 			d.testMeasureMods = append(d.testMeasureMods, measure.NewTestTxNumCount_Relay())
 		case "TxNumberCount_Broker":
 			d.testMeasureMods = append(d.testMeasureMods, measure.NewTestTxNumCount_Broker())
+		case "SafetyViolation":
+			d.testMeasureMods = append(d.testMeasureMods, measure.NewTestSafetyViolation())
+		case "CommitGap":
+			d.testMeasureMods = append(d.testMeasureMods, measure.NewTestCommitGap())
+		case "ViewChangeCount":
+			d.testMeasureMods = append(d.testMeasureMods, measure.NewTestViewChangeCount())
+		case "AbortRate_2PC":
+			d.testMeasureMods = append(d.testMeasureMods, measure.NewTestAbortRate_2PC())
+		case "LockContention_2PC":
+			d.testMeasureMods = append(d.testMeasureMods, measure.NewTestLockContention_2PC())
+		case "CrossRegionTx":
+			d.testMeasureMods = append(d.testMeasureMods, measure.NewTestCrossRegionTx())
 		default:
 		}
 	}
+
+	if params.Scenario_path != "" { //读取实验场景
+		sc, err := LoadScenario(params.Scenario_path)
+		if err != nil {
+			log.Panic(err)
+		}
+		d.scenario = newScenarioEngine(d, sc)
+	}
 }
 
 // Supervisor收到Leader发来的区块信息，通过处理消息来衡量性能。
@@ -120,6 +146,9 @@ func (d *Supervisor) handleBlockInfos(content []byte) {
 
 // 从数据文件中读取交易。当数据量足够的时候，Supervisor 将进行重新分区并将partitionMSG 和txs 发送给领导者。
 func (d *Supervisor) SupervisorTxHandling() { //SupervisorTxHandling方法用于处理交易
+	if d.scenario != nil { //实验场景从开始发送交易时计时
+		go d.scenario.run()
+	}
 	d.comMod.TxHandling() //委员会模块处理交易
 
 	// TxHandling is end
@@ -144,23 +173,95 @@ func (d *Supervisor) SupervisorTxHandling() { //SupervisorTxHandling方法用于
 // 处理传入的消息。
 func (d *Supervisor) handleMessage(msg []byte) { //handleMessage方法用于处理消息，msg表示传入消息的字节片
 	msgType, content := message.SplitMessage(msg) //将消息拆分为消息类型和内容
-	switch msgType {                              //根据消息类型进行不同处理
+	if message.NeedSignature(msgType) {           //需要签名但没有签名的消息直接拒绝
+		d.sl.Slog.Printf("Supervisor: the %s message is not signed, refuse it\n", msgType)
+		return
+	}
+	switch msgType { //根据消息类型进行不同处理
 	case message.CBlockInfo: //如果消息类型为CBlockInfo，则调用d.handleBlockInfos(content)，这用于处理块信息消息
 		d.handleBlockInfos(content)
-		// add codes for more functionality
-	default: //否则，调用d.comMod.HandleOtherMessage(msg)，以使用委员会模块处理消息。然后，遍历d.testMeasureMods，调用mm.HandleExtraMessage(msg)以处理额外消息，这是处理非块信息的不同类型消息的通用机制。
-		d.comMod.HandleOtherMessage(msg)
-		for _, mm := range d.testMeasureMods { //遍历d.testMeasureMods
-			mm.HandleExtraMessage(msg) //处理额外消息
+	case message.CSigned: //带签名的消息，验证签名后处理被包装的消息
+		innerType, innerContent, signer, ok := message.OpenSignedMessage(content)
+		if !ok {
+			d.sl.Slog.Printf("Supervisor: the signature of the %s message is invalid, refuse it\n", innerType)
+			return
 		}
+		if innerType == message.CLeaderInfo { //分片发生了视图切换，记录新的主节点，之后的交易和控制消息都发送给新的主节点
+			d.handleLeaderInfo(innerContent, signer)
+			return
+		}
+		if innerType == message.CCommitInfo && !checkCommitInfoSigner(innerContent, signer) { //提交报告必须由报告的节点自己签名
+			d.sl.Slog.Println("Supervisor: the commit info is not signed by the reporting node, refuse it")
+			return
+		}
+		if innerType == message.CTwoPCVote && !checkTwoPCVoteSigner(innerContent, signer) { //分片只能为自己投票
+			d.sl.Slog.Println("Supervisor: the 2PC vote is not signed by a node of the voting shard, refuse it")
+			return
+		}
+		d.handleOtherMessage(message.MergeMessage(innerType, innerContent)) //签名已经验证，直接交给委员会模块和测量模块
+		// add codes for more functionality
+	default: //否则，调用d.handleOtherMessage(msg)
+		d.handleOtherMessage(msg)
 	}
 }
 
+// 调用d.comMod.HandleOtherMessage(msg)，以使用委员会模块处理消息。然后，遍历d.testMeasureMods，调用mm.HandleExtraMessage(msg)以处理额外消息，这是处理非块信息的不同类型消息的通用机制。
+func (d *Supervisor) handleOtherMessage(msg []byte) {
+	d.comMod.HandleOtherMessage(msg)
+	for _, mm := range d.testMeasureMods { //遍历d.testMeasureMods
+		mm.HandleExtraMessage(msg) //处理额外消息
+	}
+	if d.scenario != nil {
+		d.scenario.handleMessage(msg)
+	}
+}
+
+// 向节点发送场景控制消息
+func (d *Supervisor) sendControl(msg []byte, addr string) {
+	networks.TcpDial(msg, addr)
+}
+
+// 检查提交报告中的分片ID和节点ID是否与签名者一致
+func checkCommitInfoSigner(content []byte, signer *shard.Node) bool {
+	ci := new(message.CommitInfo)
+	if err := json.Unmarshal(content, ci); err != nil {
+		return false
+	}
+	return ci.ShardID == signer.ShardID && ci.NodeID == signer.NodeID
+}
+
+// 检查 2PC 投票中的分片ID是否与签名者所在的分片一致
+func checkTwoPCVoteSigner(content []byte, signer *shard.Node) bool {
+	vote := new(message.TwoPCVote)
+	if err := json.Unmarshal(content, vote); err != nil {
+		return false
+	}
+	return vote.SenderShardID == signer.ShardID
+}
+
+// 处理分片主节点变更的消息，该消息必须由新主节点自己签名
+func (d *Supervisor) handleLeaderInfo(content []byte, signer *shard.Node) {
+	li := new(message.LeaderInfo)
+	err := json.Unmarshal(content, li)
+	if err != nil {
+		log.Panic(err)
+	}
+	if signer.ShardID != li.ShardID || signer.NodeID != li.LeaderID {
+		d.sl.Slog.Println("Supervisor: the leader info is not signed by the new leader, refuse it")
+		return
+	}
+	if li.LeaderID != li.View%d.ChainConfig.Nodes_perShard || !params.SetShardLeader(li.ShardID, li.LeaderID, li.View) { //视图比已经记录的旧时不更新主节点
+		d.sl.Slog.Printf("Supervisor: the leader info of shard %d (view %d) is stale or inconsistent, refuse it\n", li.ShardID, li.View)
+		return
+	}
+	d.sl.Slog.Printf("Supervisor: the leader of shard %d is node %d now (view %d)\n", li.ShardID, li.LeaderID, li.View)
+}
+
 func (d *Supervisor) handleClientRequest(con net.Conn) { //handleClientRequest方法用于处理客户端请求，con表示客户端连接
 	defer con.Close()                    //延迟关闭连接
 	clientReader := bufio.NewReader(con) //创建一个新的缓冲读取器
 	for {
-		clientRequest, err := clientReader.ReadBytes('\n') //读取客户端请求
+		clientRequest, err := networks.ReadFrame(clientReader) //读取一条带长度前缀的客户端请求
 		switch err {
 		case nil: //如果没有错误，则调用d.handleMessage(clientRequest)以处理消息
 			d.tcpLock.Lock()
@@ -177,7 +278,7 @@ func (d *Supervisor) handleClientRequest(con net.Conn) { //handleClientRequest
 }
 
 func (d *Supervisor) TcpListen() { //TcpListen方法用于监听TCP连接
-	ln, err := net.Listen("tcp", d.IPaddr)
+	ln, err := networks.Listen(d.IPaddr) //单进程模式下使用内存网络
 	if err != nil {
 		log.Panic(err)
 	}
@@ -277,6 +378,9 @@ func (d *Supervisor) CloseSupervisor() { //CloseSupervisor方法用于关闭客
 		f.Close()
 		d.sl.Slog.Println(measureMod.OutputRecord())
 	}
+	if d.scenario != nil { //场景的时间线与测量结果放在一起
+		d.scenario.output(dirpath)
+	}
 	networks.CloseAllConnInPool()
 	d.tcpLn.Close()
 }
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"blockEmulator/query"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// 测试账户的默克尔证明：证明只对生成它的区块的状态树根有效，账户状态被修改后验证失败，不存在的账户也可以被证明
+func TestAccountProof(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil { //区块数据库和状态数据库写在临时目录中
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	shardNum := params.ShardNum
+	params.ShardNum = 1
+	defer func() { params.ShardNum = shardNum }()
+
+	db, err := rawdb.NewLevelDBDatabase("./record/ldb/s0/n0", 0, 1, "accountState", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcc := &params.ChainConfig{ShardID: 0, NodeID: 0, Nodes_perShard: 1, ShardNums: 1, BlockSize: 100}
+	bc, err := chain.NewBlockChain(pcc, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := "00000000000000000000000000000000000000a1"
+	recipient := "00000000000000000000000000000000000000b2"
+	roots := make([][]byte, 0)
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		bc.SendTx2Pool([]*core.Transaction{core.NewTransaction(sender, recipient, big.NewInt(10), nonce)})
+		if err := bc.AddBlock(bc.GenerateBlock()); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, bc.CurrentBlock.Header.StateRoot)
+	}
+	bc.CloseBlockChain()
+	db.Close()
+
+	ap, err := query.QueryAccountProof(0, 0, 1, sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ap.Account == nil || ap.Account.Balance.Cmp(new(big.Int).Sub(params.Init_Balance, big.NewInt(10))) != 0 {
+		t.Fatalf("the balance of the sender at block 1 should be debited once")
+	}
+	if err := query.VerifyAccountProof(ap, roots[0]); err != nil {
+		t.Errorf("the proof should be valid against the state root of block 1: %v", err)
+	}
+	if err := query.VerifyAccountProof(ap, roots[1]); err == nil {
+		t.Errorf("the proof of block 1 should be refused against the state root of block 2")
+	}
+	ap.Account.Balance = new(big.Int).Set(params.Init_Balance)
+	if err := query.VerifyAccountProof(ap, roots[0]); err != query.ErrAccountMismatched {
+		t.Errorf("a modified balance should be refused, got %v", err)
+	}
+
+	absent, err := query.QueryAccountProof(0, 0, 2, "00000000000000000000000000000000000000c3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if absent.Account != nil || query.VerifyAccountProof(absent, roots[1]) != nil {
+		t.Errorf("the proof should prove that the account is not in the state")
+	}
+	if _, err := query.QueryAccountProof(0, 0, 5, sender); err != query.ErrBlockNotFound {
+		t.Errorf("the proof of a missing block should not be generated, got %v", err)
+	}
+}
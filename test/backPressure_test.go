@@ -0,0 +1,90 @@
+package test
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"blockEmulator/supervisor/committee"
+	"blockEmulator/supervisor/signal"
+	"blockEmulator/supervisor/supervisor_log"
+	"blockEmulator/utils"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+// 测试交易池饱和时分片退回交易：处理 CInner2CrossTx 消息不等待背压，
+// 分片报告交易池的负载下降之后，退回的交易由 TxHandling 重新注入
+func TestBackPressureInner2CrossTx(t *testing.T) {
+	chdirTemp(t)
+	shardNum, threshold := params.ShardNum, params.BackPressureThreshold
+	t.Cleanup(func() { params.ShardNum, params.BackPressureThreshold = shardNum, threshold })
+	params.ShardNum, params.BackPressureThreshold = 2, 0.8
+	params.ResetShardLeaders()
+
+	ipTable, injected := leaderListeners(t, 2)
+
+	// 数据集中只有一笔片内交易，退回的交易也是片内交易，不经过 broker
+	sender := fmt.Sprintf("%040x", 2)
+	recipient := fmt.Sprintf("%040x", 4)
+	if utils.Addr2Shard(sender) != utils.Addr2Shard(recipient) {
+		t.Fatal("the txs should be inner-shard txs")
+	}
+	sid := uint64(utils.Addr2Shard(sender))
+	if err := os.WriteFile("txs.csv", []byte(fmt.Sprintf(",,,0x%s,0x%s,,0,0,1\n", sender, recipient)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ccm := committee.NewCLPACommitteeMod_Broker(ipTable, signal.NewStopSignal(0), supervisor_log.NewSupervisorLog(), "txs.csv", 1, 100, 1000)
+
+	// 分片报告交易池已满
+	ccm.AdjustByBlockInfos(&message.BlockInfoMsg{SenderShardID: sid, TxPoolSize: 10, TxPoolCapacity: 10})
+	returned := core.NewTransaction(recipient, sender, big.NewInt(1), 7)
+	itByte, err := json.Marshal(message.InnerTx2CrossTx{Txs: []*core.Transaction{returned}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handled := make(chan struct{})
+	go func() {
+		ccm.HandleOtherMessage(message.MergeMessage(message.CInner2CrossTx, itByte))
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handling CInner2CrossTx should not wait for the full tx pool")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ccm.TxHandling()
+		close(done)
+	}()
+	select {
+	case tx := <-injected:
+		t.Fatalf("tx %x is injected into the full tx pool", tx.TxHash)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// 分片报告交易池已经清空，数据集中的交易和退回的交易都被注入
+	ccm.AdjustByBlockInfos(&message.BlockInfoMsg{SenderShardID: sid, TxPoolSize: 0, TxPoolCapacity: 10})
+	got := make(map[string]bool)
+	for len(got) < 2 {
+		select {
+		case tx := <-injected:
+			got[string(tx.TxHash)] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d txs are injected after the tx pool drains, want 2", len(got))
+		}
+	}
+	if !got[string(returned.TxHash)] {
+		t.Error("the returned tx is not injected")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("TxHandling does not finish")
+	}
+}
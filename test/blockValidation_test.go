@@ -0,0 +1,108 @@
+package test
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// 测试区块验证：合法的区块通过验证，篡改过的区块返回对应的错误
+func TestBlockValidation(t *testing.T) {
+	bc := newTestChain(t)
+
+	sender := "00000000000000000000000000000000000000a1"
+	recipient := "00000000000000000000000000000000000000b2"
+	bc.SendTx2Pool([]*core.Transaction{
+		core.NewTransaction(sender, recipient, big.NewInt(1), 0),
+		core.NewTransaction(sender, recipient, big.NewInt(2), 1),
+	})
+	b := bc.GenerateBlock()
+	if err := bc.IsValidBlock(b); err != nil {
+		t.Fatalf("the generated block should be valid: %v", err)
+	}
+
+	// 复制区块并篡改，篡改区块头之后重新计算区块哈希
+	tamper := func(f func(nb *core.Block)) *core.Block {
+		h := *b.Header
+		nb := core.NewBlock(&h, append([]*core.Transaction{}, b.Body...))
+		f(nb)
+		nb.Hash = nb.Header.Hash()
+		return nb
+	}
+	cases := []struct {
+		name  string
+		block *core.Block
+		want  error
+	}{
+		{"height", tamper(func(nb *core.Block) { nb.Header.Number++ }), chain.ErrBlockHeight},
+		{"parent", tamper(func(nb *core.Block) { nb.Header.ParentBlockHash = []byte("parent") }), chain.ErrParentHash},
+		{"toolarge", tamper(func(nb *core.Block) {
+			for uint64(len(nb.Body)) <= bc.ChainConfig.BlockSize {
+				nb.Body = append(nb.Body, nb.Body[0])
+			}
+		}), chain.ErrBlockTooLarge},
+		{"duplicate", tamper(func(nb *core.Block) { nb.Body = append(nb.Body, nb.Body[0]) }), chain.ErrDuplicateTx},
+		{"txroot", tamper(func(nb *core.Block) { nb.Body = nb.Body[:1] }), chain.ErrTxRoot},
+		{"stateroot", tamper(func(nb *core.Block) { nb.Header.StateRoot = []byte("state") }), chain.ErrStateRoot},
+	}
+	for _, c := range cases {
+		err := bc.IsValidBlock(c.block)
+		var verr *chain.BlockValidationError
+		if !errors.Is(err, c.want) || !errors.As(err, &verr) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+	nb := tamper(func(nb *core.Block) {})
+	nb.Hash = []byte("hash")
+	if err := bc.IsValidBlock(nb); !errors.Is(err, chain.ErrBlockHash) {
+		t.Errorf("hash: got %v, want %v", err, chain.ErrBlockHash)
+	}
+	if err := bc.AddBlock(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 测试区块中有签名不合法的交易时，区块验证失败，区块也不能被添加
+func TestBlockValidationSignature(t *testing.T) {
+	params.SignTxs = true
+	defer func() { params.SignTxs = false }()
+	bc := newTestChain(t)
+
+	sender := core.WorkloadAddress("00000000000000000000000000000000000000a1")
+	recipient := core.WorkloadAddress("00000000000000000000000000000000000000b2")
+	txs := []*core.Transaction{
+		core.NewTransaction(sender, recipient, big.NewInt(1), 0),
+		core.NewTransaction(sender, recipient, big.NewInt(2), 1),
+	}
+	for _, tx := range txs {
+		tx.SignWithWorkloadKey()
+	}
+	bc.SendTx2Pool(txs)
+	b := bc.GenerateBlock()
+	if err := bc.IsValidBlock(b); err != nil {
+		t.Fatalf("the generated block should be valid: %v", err)
+	}
+
+	// 把第二笔交易换成签名被破坏的副本，交易哈希和交易树根不变
+	bad := core.DecodeTx(b.Body[1].Encode())
+	bad.Signature = append([]byte{}, bad.Signature...)
+	bad.Signature[len(bad.Signature)-1] = 4 //非法的恢复标识
+	h := *b.Header
+	nb := core.NewBlock(&h, []*core.Transaction{b.Body[0], bad})
+	nb.Header.TxRoot = chain.GetTxTreeRoot(nb.Body)
+	nb.Hash = nb.Header.Hash()
+	err := bc.IsValidBlock(nb)
+	var verr *chain.BlockValidationError
+	if !errors.Is(err, core.ErrTxBadSignature) || !errors.As(err, &verr) {
+		t.Fatalf("got %v, want %v", err, core.ErrTxBadSignature)
+	}
+	if err := bc.AddBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	if bc.CurrentBlock.Header.Number != b.Header.Number {
+		t.Errorf("the height is %d, want %d", bc.CurrentBlock.Header.Number, b.Header.Number)
+	}
+}
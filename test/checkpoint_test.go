@@ -0,0 +1,40 @@
+package test
+
+import (
+	"blockEmulator/build"
+	"blockEmulator/params"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 测试检查点：高水位线只比稳定检查点高几个序列，检查点不能稳定时主节点会停止提议，交易无法全部提交
+func TestCheckpoint(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the local cluster takes several seconds")
+	}
+	txNum := 200
+	setupLocalCluster(t, txNum)
+	period, window, blockSize := params.CheckpointPeriod, params.WaterMarkWindow, params.MaxBlockSize_global
+	t.Cleanup(func() {
+		params.CheckpointPeriod, params.WaterMarkWindow, params.MaxBlockSize_global = period, window, blockSize
+	})
+	params.CheckpointPeriod = 2
+	params.WaterMarkWindow = 4
+	params.MaxBlockSize_global = 10 //每个分片需要提交十几个区块，经过多个检查点
+
+	build.RunLocalCluster(4, 2, 3, 0, "")
+
+	if n := measureTotal(t, "Tx_number"); n != float64(txNum) {
+		t.Fatalf("all %d injected txs should be committed, got %v", txNum, n)
+	}
+	for _, fn := range []string{"/S0/N0.log", "/S1/N3.log"} {
+		b, err := os.ReadFile(params.LogWrite_path + fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "is stable now") || !strings.Contains(string(b), "requests before the checkpoint") {
+			t.Fatalf("the checkpoints of %s should become stable and clear the requests", fn)
+		}
+	}
+}
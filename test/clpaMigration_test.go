@@ -0,0 +1,49 @@
+package test
+
+import (
+	"blockEmulator/params"
+	"blockEmulator/partition"
+	"testing"
+)
+
+// 迁移代价超过减少的跨分片边的权重时不移动账户，迁移预算限制移动的账户
+func TestCLPAMigrationCost(t *testing.T) {
+	shardNum := params.ShardNum
+	params.ShardNum = 2
+	defer func() { params.ShardNum = shardNum }()
+
+	newState := func(cost, budget float64) *partition.CLPAState {
+		k := new(partition.CLPAState)
+		k.Init_CLPAState(0.5, 100, 2)
+		k.MigrationWeight = 1
+		k.MigrationBudget = budget
+		// 账户 1、3 在分片 1，账户 2、4 在分片 0，两对账户之间各有 10 笔交易
+		k.AddWeightedEdge(clpaVertex(1), clpaVertex(2), 10)
+		k.AddWeightedEdge(clpaVertex(3), clpaVertex(4), 10)
+		for i := 1; i <= 4; i++ {
+			k.SetMigrationCost(clpaVertex(i), cost)
+		}
+		return k
+	}
+
+	k := newState(20, 0)
+	if res, _ := k.CLPA_Partition(); len(res) != 0 || k.LastMigration.MovedAccounts != 0 {
+		t.Fatalf("no account is worth moving, got %v", res)
+	}
+	if k.LastMigration.CrossShardAfter != 20 {
+		t.Fatalf("the cross-shard weight should stay 20, got %v", k.LastMigration)
+	}
+
+	k = newState(1, 0)
+	res, cross := k.CLPA_Partition()
+	r := k.LastMigration
+	if cross != 0 || len(res) != 2 || !r.Applied || r.MovedAccounts != 2 || r.MigrationCost != 2 || r.Reduction() != 20 {
+		t.Fatalf("one account of each pair should move, got %v, report %v", res, r)
+	}
+
+	k = newState(1, 1)
+	res, cross = k.CLPA_Partition()
+	if len(res) != 1 || cross != 10 || k.LastMigration.MigrationCost != 1 {
+		t.Fatalf("the budget only allows moving one account, got %v, report %v", res, k.LastMigration)
+	}
+}
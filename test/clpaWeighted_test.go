@@ -0,0 +1,67 @@
+package test
+
+import (
+	"blockEmulator/params"
+	"blockEmulator/partition"
+	"fmt"
+	"testing"
+)
+
+func clpaVertex(i int) partition.Vertex {
+	return partition.Vertex{Addr: fmt.Sprintf("%040x", i)} //地址的尾数对分片数目取余，得到账户的初始分片
+}
+
+// 同一对账户之间的多笔交易合并为一条带权的边
+func TestCLPAWeightedEdges(t *testing.T) {
+	shardNum := params.ShardNum
+	params.ShardNum = 2
+	defer func() { params.ShardNum = shardNum }()
+
+	k := new(partition.CLPAState)
+	k.Init_CLPAState(0.5, 100, 2)
+	a, b := clpaVertex(1), clpaVertex(2)
+	for i := 0; i < 1000; i++ {
+		k.AddEdge(a, b)
+	}
+	k.AddEdge(clpaVertex(3), clpaVertex(4))
+	if len(k.NetGraph.EdgeSet[a]) != 1 || k.NetGraph.EdgeSet[a][b] != 1000 || k.NetGraph.EdgeSet[b][a] != 1000 {
+		t.Fatalf("the hot pair should be one edge of weight 1000, got %v", k.NetGraph.EdgeSet[a])
+	}
+	if d := k.NetGraph.WeightedDegree(a); d != 1000 {
+		t.Fatalf("the weighted degree should be 1000, got %v", d)
+	}
+
+	k.ComputeEdges2Shard()
+	if k.CrossShardEdgeNum != 1001 {
+		t.Fatalf("the cross-shard weight should be 1001, got %v", k.CrossShardEdgeNum)
+	}
+	k.CLPA_Partition()
+	if k.PartitionMap[a] != k.PartitionMap[b] {
+		t.Fatalf("CLPA should put the hot pair in the same shard")
+	}
+}
+
+// 账户 0 与账户 1 之间有一笔大额交易，与账户 2、4、6 之间各有一笔小额交易。
+// 不考虑权重惩罚时，按交易数量计算权重时账户 0 留在分片 0，按交易金额计算权重时账户 0 与账户 1 放到同一个分片
+func TestCLPAValueWeight(t *testing.T) {
+	shardNum := params.ShardNum
+	params.ShardNum = 2
+	defer func() { params.ShardNum = shardNum }()
+
+	for _, byValue := range []bool{false, true} {
+		k := new(partition.CLPAState)
+		k.Init_CLPAState(0, 100, 2)
+		large := 1.0
+		if byValue {
+			large = 1e6
+		}
+		k.AddWeightedEdge(clpaVertex(0), clpaVertex(1), large)
+		for _, i := range []int{2, 4, 6} {
+			k.AddWeightedEdge(clpaVertex(0), clpaVertex(i), 1)
+		}
+		k.CLPA_Partition()
+		if same := k.PartitionMap[clpaVertex(0)] == k.PartitionMap[clpaVertex(1)]; same != byValue {
+			t.Fatalf("weighted by value %v: accounts 0 and 1 in the same shard %v", byValue, same)
+		}
+	}
+}
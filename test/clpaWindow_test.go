@@ -0,0 +1,77 @@
+package test
+
+import (
+	"blockEmulator/params"
+	"blockEmulator/partition"
+	"testing"
+)
+
+// 交易图只保留窗口内的区块中的交易，每个 epoch 衰减边的权重
+func TestCLPAGraphWindow(t *testing.T) {
+	shardNum := params.ShardNum
+	params.ShardNum = 2
+	defer func() { params.ShardNum = shardNum }()
+
+	k := new(partition.CLPAState)
+	k.Init_CLPAState(0.5, 100, 2)
+	k.SetGraphWindow(2, 0.5)
+	a, b, c := clpaVertex(1), clpaVertex(2), clpaVertex(3)
+
+	k.NewBlock()
+	k.AddEdge(a, b)
+	k.AddEdge(a, b)
+	k.NextEpoch()
+	if w := k.NetGraph.EdgeSet[a][b]; w != 1 {
+		t.Fatalf("the edge should decay to 1, got %v", w)
+	}
+	k.NewBlock()
+	k.AddEdge(a, c)
+	k.AddEdge(a, b)
+	if w := k.NetGraph.EdgeSet[a][b]; w != 2 {
+		t.Fatalf("the edge weight should be 2, got %v", w)
+	}
+
+	// 第一个区块移出窗口，只剩下第二个区块中的交易
+	k.NewBlock()
+	if w := k.NetGraph.EdgeSet[a][b]; w != 1 {
+		t.Fatalf("the decayed txs of the first block should be removed, the edge weight is %v", w)
+	}
+	k.NewBlock()
+	if len(k.NetGraph.VertexSet) != 0 || len(k.NetGraph.EdgeSet) != 0 {
+		t.Fatalf("all txs are out of the window, got vertices %v", k.NetGraph.VertexSet)
+	}
+	if k.VertexsNumInShard[0]+k.VertexsNumInShard[1] != 0 {
+		t.Fatalf("the vertex numbers in shards should be 0, got %v", k.VertexsNumInShard)
+	}
+	if _, ok := k.PartitionMap[a]; !ok {
+		t.Fatalf("the shard of a removed account should be kept")
+	}
+}
+
+// 自环移出窗口时，加入时累加了两次的权重全部被删除
+func TestCLPAGraphWindowSelfLoop(t *testing.T) {
+	shardNum := params.ShardNum
+	params.ShardNum = 2
+	defer func() { params.ShardNum = shardNum }()
+
+	k := new(partition.CLPAState)
+	k.Init_CLPAState(0.5, 100, 2)
+	k.SetGraphWindow(1, 1)
+	a, b := clpaVertex(1), clpaVertex(2)
+
+	k.NewBlock()
+	k.AddEdge(a, a)
+	k.AddEdge(a, b)
+	k.NewBlock()
+	k.AddEdge(a, b)
+	if _, ok := k.NetGraph.EdgeSet[a][a]; ok {
+		t.Fatalf("the self-loop out of the window should be removed, weight %v", k.NetGraph.EdgeSet[a][a])
+	}
+	if w := k.NetGraph.EdgeSet[a][b]; w != 1 {
+		t.Fatalf("the edge weight should be 1, got %v", w)
+	}
+	k.NewBlock()
+	if len(k.NetGraph.VertexSet) != 0 || k.VertexsNumInShard[0]+k.VertexsNumInShard[1] != 0 {
+		t.Fatalf("all txs are out of the window, got vertices %v and vertex numbers %v", k.NetGraph.VertexSet, k.VertexsNumInShard)
+	}
+}
@@ -0,0 +1,51 @@
+package test
+
+import (
+	"blockEmulator/params"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 测试实验配置文件：三种格式得到相同的配置，未知参数和不合法的参数被拒绝
+func TestExperimentConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cfg.yaml": "ShardNum: 3\nCommitteeMethod: CLPA\nCLPA_Frequency: 40\nMeasureMods: [TPS_Relay]\n",
+		"cfg.json": `{"ShardNum": 3, "CommitteeMethod": "CLPA", "CLPA_Frequency": 40, "MeasureMods": ["TPS_Relay"]}`,
+		"cfg.toml": "ShardNum = 3\nCommitteeMethod = \"CLPA\"\nCLPA_Frequency = 40\nMeasureMods = [\"TPS_Relay\"]\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := params.LoadExperimentConfig(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cfg.ShardNum != 3 || cfg.ModID() != 1 || cfg.CLPA_Frequency != 40 || len(cfg.MeasureMods) != 1 {
+			t.Errorf("%s: wrong config %+v", name, cfg)
+		}
+		if cfg.Block_Interval != params.Block_Interval { //文件中没有的参数保持默认值
+			t.Errorf("%s: Block_Interval = %d, want the default %d", name, cfg.Block_Interval, params.Block_Interval)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	unknown := filepath.Join(dir, "unknown.yaml")
+	os.WriteFile(unknown, []byte("ShardNumber: 3\n"), 0644)
+	if _, err := params.LoadExperimentConfig(unknown); err == nil {
+		t.Error("the unknown key is accepted")
+	}
+
+	cfg := params.DefaultExperimentConfig()
+	cfg.InjectSpeed = cfg.BatchSize + 1
+	cfg.CommitteeMethod = "PoW"
+	cfg.NetLossRate = 2
+	if err := cfg.Validate(); err == nil {
+		t.Error("the invalid config is accepted")
+	}
+}
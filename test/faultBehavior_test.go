@@ -0,0 +1,75 @@
+package test
+
+import (
+	"blockEmulator/build"
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"blockEmulator/supervisor/measure"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func commitInfoMsg(t *testing.T, sid, nid, seq uint64, digest string, faulty bool, at time.Time) []byte {
+	ci := message.CommitInfo{ShardID: sid, NodeID: nid, SeqID: seq, Digest: []byte(digest), Faulty: faulty, CommitTime: at}
+	b, err := json.Marshal(ci)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return message.MergeMessage(message.CCommitInfo, b)
+}
+
+// 只有诚实节点在同一序列上提交了不同的请求才算安全性违反
+func TestSafetyViolationMeasure(t *testing.T) {
+	tsv := measure.NewTestSafetyViolation()
+	now := time.Now()
+	tsv.HandleExtraMessage(commitInfoMsg(t, 0, 0, 1, "a", false, now))
+	tsv.HandleExtraMessage(commitInfoMsg(t, 0, 1, 1, "a", false, now))
+	tsv.HandleExtraMessage(commitInfoMsg(t, 0, 2, 1, "b", true, now)) //拜占庭节点的提交不计入
+	tsv.HandleExtraMessage(commitInfoMsg(t, 1, 0, 1, "c", false, now))
+	tsv.HandleExtraMessage(commitInfoMsg(t, 1, 3, 1, "d", false, now))
+	per, tot := tsv.OutputRecord()
+	if tot != 1 || len(per) != 2 || per[0] != 0 || per[1] != 1 {
+		t.Fatalf("only shard 1 violates safety once, got %v %v", per, tot)
+	}
+}
+
+// 最长提交间隔按每个新序列第一次被诚实节点提交的时间计算
+func TestCommitGapMeasure(t *testing.T) {
+	tcg := measure.NewTestCommitGap()
+	now := time.Now()
+	tcg.HandleExtraMessage(commitInfoMsg(t, 0, 0, 1, "a", false, now))
+	tcg.HandleExtraMessage(commitInfoMsg(t, 0, 1, 1, "a", false, now.Add(5*time.Second))) //同一序列的其他提交不计入
+	tcg.HandleExtraMessage(commitInfoMsg(t, 0, 0, 2, "b", false, now.Add(2*time.Second)))
+	tcg.HandleExtraMessage(commitInfoMsg(t, 0, 3, 3, "c", true, now.Add(9*time.Second)))
+	tcg.HandleExtraMessage(commitInfoMsg(t, 0, 0, 3, "c", false, now.Add(3*time.Second)))
+	if per, gap := tcg.OutputRecord(); gap != 2 || len(per) != 1 {
+		t.Fatalf("the longest commit gap should be 2s, got %v %v", per, gap)
+	}
+}
+
+// 每个分片中一个节点被注入拜占庭行为，诚实节点之间不能出现安全性违反，并且仍然能够提交交易
+func TestFaultBehaviors(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the local cluster takes several seconds")
+	}
+	for _, fault := range []string{"Equivocation", "WrongVote", "InvalidBlock"} {
+		t.Run(fault, func(t *testing.T) {
+			setupLocalCluster(t, 200)
+			timeOut := params.ViewChangeTimeOut
+			t.Cleanup(func() { params.ViewChangeTimeOut = timeOut })
+			params.ViewChangeTimeOut = 2000
+			params.BatchSize = 20
+			params.InjectSpeed = 20
+
+			build.RunLocalCluster(4, 2, 3, 1, fault) //节点 0 是初始的主节点
+
+			if n := measureTotal(t, "Safety_Violation"); n != 0 {
+				t.Fatalf("the honest nodes should not commit different requests, got %v violations", n)
+			}
+			if n := measureTotal(t, "Tx_number"); n == 0 {
+				t.Fatalf("the honest nodes should still commit txs")
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"blockEmulator/params"
+	"blockEmulator/partition"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 区域之间的距离由时延归一化得到，账户的区域来自账户位置文件或地址的哈希值
+func TestGeography(t *testing.T) {
+	geo := partition.NewGeography([]string{"east", "west", "east"}, map[string]map[string]float64{"east": {"west": 100}}, 50)
+	if d := geo.ShardDistance("east", 1); d != 1 {
+		t.Fatalf("the distance from east to west should be 1, got %v", d)
+	}
+	if d := geo.ShardDistance("west", 0); d != 0.5 {
+		t.Fatalf("the distance from west to east should use the default latency, got %v", d)
+	}
+	if d := geo.ShardDistance("east", 2); d != 0 {
+		t.Fatalf("the distance in the same region should be 0, got %v", d)
+	}
+
+	path := filepath.Join(t.TempDir(), "locations.csv")
+	if err := os.WriteFile(path, []byte("0x"+clpaVertex(1).Addr+",west\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := geo.LoadLocations(path); err != nil {
+		t.Fatal(err)
+	}
+	if loc := geo.Location(clpaVertex(1).Addr); loc != "west" {
+		t.Fatalf("the location should come from the file, got %q", loc)
+	}
+	if loc := geo.Location(clpaVertex(2).Addr); loc != "east" && loc != "west" {
+		t.Fatalf("the location of an account not in the file should be a region chosen by its address, got %q", loc)
+	}
+	if err := os.WriteFile(path, []byte(clpaVertex(3).Addr+",north\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := geo.LoadLocations(path); err == nil {
+		t.Fatalf("an unknown region should be rejected")
+	}
+}
+
+// 地域项使账户留在其所在区域的分片中
+func TestCLPAGeoPenalty(t *testing.T) {
+	shardNum := params.ShardNum
+	params.ShardNum = 2
+	defer func() { params.ShardNum = shardNum }()
+
+	geo := partition.NewGeography([]string{"east", "west"}, nil, 0)
+	path := filepath.Join(t.TempDir(), "locations.csv")
+	// 账户 2、4、6 在分片 0（east），账户 1 在分片 1（west）
+	if err := os.WriteFile(path, []byte(clpaVertex(1).Addr+",west\n"+clpaVertex(2).Addr+",east\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := geo.LoadLocations(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, penalty := range []float64{0, 1} {
+		k := new(partition.CLPAState)
+		k.Init_CLPAState(0.5, 100, 2)
+		k.SetGeography(geo, penalty)
+		a, b := geo.Vertex(clpaVertex(2).Addr), geo.Vertex(clpaVertex(1).Addr)
+		k.AddEdge(a, b)
+		k.AddEdge(geo.Vertex(clpaVertex(4).Addr), geo.Vertex(clpaVertex(6).Addr))
+		k.CLPA_Partition()
+		if moved := k.PartitionMap[a] != 0; moved != (penalty == 0) {
+			t.Fatalf("geo penalty %v: the east account moved to the west shard %v", penalty, moved)
+		}
+	}
+}
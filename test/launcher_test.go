@@ -0,0 +1,117 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 编译可执行文件，并在临时目录中准备 2 个分片、每个分片 4 个节点的实验配置，返回可执行文件和实验目录
+func setupLaunch(t *testing.T, txNum int) (string, string) {
+	if testing.Short() {
+		t.Skip("the launcher starts a process for every node")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is needed to build the executable")
+	}
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "blockEmulator")
+	if out, err := exec.Command("go", "build", "-o", exe, "blockEmulator").CombinedOutput(); err != nil {
+		t.Fatalf("cannot build the executable: %v\n%s", err, out)
+	}
+	txs, err := os.Create(filepath.Join(dir, "txs.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < txNum; i++ {
+		fmt.Fprintf(txs, ",,,0x%040x,0x%040x,,0,0,1\n", i+1, i+1000)
+	}
+	txs.Close()
+	cfg := fmt.Sprintf("ShardNum: 2\nNodesInShard: 4\nCommitteeMethod: Relay\nTotalDataSize: %d\nBatchSize: %d\nInjectSpeed: %d\nBlock_Interval: 300\nFileInput: %s\n",
+		txNum, txNum, txNum, filepath.Join(dir, "txs.csv"))
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return exe, dir
+}
+
+// 测试启动器：所有节点进程和主管节点进程运行完实验后，测量结果和每个进程的输出被收集到 result 目录中
+func TestLaunch(t *testing.T) {
+	txNum := 200
+	exe, dir := setupLaunch(t, txNum)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, exe, "launch", "-C", "config.yaml")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("the launcher failed: %v\n%s", err, out)
+	}
+
+	dsts, _ := filepath.Glob(filepath.Join(dir, "result", "launch_*"))
+	if len(dsts) != 1 {
+		t.Fatalf("the results should be collected in one directory, got %v", dsts)
+	}
+	if n := measureTotalIn(t, filepath.Join(dsts[0], "supervisor_measureOutput"), "Tx_number"); n != float64(txNum) {
+		t.Fatalf("all %d txs should be committed, got %v", txNum, n)
+	}
+	for _, name := range []string{"Supervisor", "S0N0", "S1N3"} {
+		if _, err := os.Stat(filepath.Join(dsts[0], "output", name+".out")); err != nil {
+			t.Fatalf("the output of %s is not collected: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dsts[0], "effective_config.json")); err != nil {
+		t.Fatalf("the effective config is not collected: %v", err)
+	}
+}
+
+// 启动器被中断时结束所有进程，节点的端口被释放
+func TestLaunchInterrupt(t *testing.T) {
+	nodeAddr := "127.0.0.1:28800"      //分片 0 的 0 号节点的地址
+	exe, dir := setupLaunch(t, 100000) //交易足够多，实验不会在中断之前结束
+	cmd := exec.Command(exe, "launch", "-C", "config.yaml")
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	supervisorOut := filepath.Join(dir, "log", "launcher", "Supervisor.out")
+	for i := 0; ; i++ {
+		if _, err := os.Stat(supervisorOut); err == nil {
+			break
+		}
+		if i == 100 {
+			cmd.Process.Kill()
+			t.Fatal("the launcher does not start the supervisor")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(2 * time.Second) //等待节点开始监听
+	if ln, err := net.Listen("tcp", nodeAddr); err == nil {
+		ln.Close()
+		cmd.Process.Kill()
+		t.Fatal("the node S0N0 is not started")
+	}
+	cmd.Process.Signal(syscall.SIGINT)
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("the interrupted launcher should exit with an error")
+		}
+	case <-time.After(20 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("the launcher does not exit after the interrupt")
+	}
+	ln, err := net.Listen("tcp", nodeAddr)
+	if err != nil {
+		t.Fatalf("the nodes should be killed: %v", err)
+	}
+	ln.Close()
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"blockEmulator/storage"
+	"testing"
+	"time"
+)
+
+// 测试轻客户端：只保存有 2f+1 个签名且与相邻高度的区块头相连的区块头，同一高度不能有两个区块头
+func TestLightClient(t *testing.T) {
+	chdirTemp(t) //区块数据库写在临时目录中
+	keyStore := params.KeyStore_path
+	params.KeyStore_path = t.TempDir() + "/"
+	defer func() { params.KeyStore_path = keyStore }()
+
+	s := storage.NewStorage(&params.ChainConfig{ShardID: 0, NodeID: 0})
+	defer s.DataBase.Close()
+	lc := chain.NewLightClient(s, 4)
+	certify := func(bh *core.BlockHeader, signers ...uint64) [][]byte {
+		cert := make([][]byte, 0)
+		for _, nid := range signers {
+			cert = append(cert, signCommit(t, 1, nid, bh.Hash()))
+		}
+		return cert
+	}
+
+	h1 := &core.BlockHeader{ParentBlockHash: []byte("genesis"), Number: 1, Time: time.Now()}
+	h2 := &core.BlockHeader{ParentBlockHash: h1.Hash(), Number: 2, Time: time.Now()}
+	if _, err := lc.AddHeader(1, h2.Encode(), certify(h2, 0, 1)); err != chain.ErrHeaderCertificate {
+		t.Errorf("the header with 2 signatures should be refused, got %v", err)
+	}
+	if _, err := lc.AddHeader(1, h2.Encode(), certify(h2, 0, 1, 3)); err != nil { //区块头可以乱序到达
+		t.Fatal(err)
+	}
+	if _, err := lc.AddHeader(1, h1.Encode(), certify(h1, 1, 2, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if !lc.IsHeaderFinal(1, h1) || !lc.IsHeaderFinal(1, h2) || lc.IsHeaderFinal(0, h1) {
+		t.Errorf("the headers should be final in shard 1 only")
+	}
+	if lc.Height(1) != 2 || lc.Height(0) != 0 {
+		t.Errorf("the height of shard 1 is %d, shard 0 is %d, want 2 and 0", lc.Height(1), lc.Height(0))
+	}
+
+	fork := &core.BlockHeader{ParentBlockHash: h1.Hash(), Number: 2, Time: time.Now().Add(time.Second)}
+	if _, err := lc.AddHeader(1, fork.Encode(), certify(fork, 0, 1, 2)); err != chain.ErrHeaderConflict {
+		t.Errorf("another header of height 2 should be refused, got %v", err)
+	}
+	h3 := &core.BlockHeader{ParentBlockHash: fork.Hash(), Number: 3, Time: time.Now()}
+	if _, err := lc.AddHeader(1, h3.Encode(), certify(h3, 0, 1, 2)); err != chain.ErrHeaderParent {
+		t.Errorf("the header that does not link to height 2 should be refused, got %v", err)
+	}
+	if bh, err := lc.CheckHeader(1, h1.Encode(), nil); err != nil || bh.Number != 1 {
+		t.Errorf("a synced header should pass without the certificate, got %v", err)
+	}
+}
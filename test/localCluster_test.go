@@ -0,0 +1,90 @@
+package test
+
+import (
+	"blockEmulator/build"
+	"blockEmulator/params"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// 测试单进程模式：主管节点和所有节点在一个进程中通过内存网络完成一次完整的实验
+func TestLocalCluster(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the local cluster takes several seconds")
+	}
+	txNum := 200
+	setupLocalCluster(t, txNum)
+
+	build.RunLocalCluster(4, 2, 3, 0, "") //2 个分片，每个分片 4 个节点，使用 Relay 机制
+
+	if n := measureTotal(t, "Tx_number"); n != float64(txNum) {
+		t.Fatalf("all %d injected txs should be committed, got %v", txNum, n)
+	}
+}
+
+// 在临时目录中准备单进程模式的实验：写入 txNum 笔交易，修改的全局配置在测试结束后恢复。
+// 数据库、日志、密钥和测量结果都写到临时目录中
+func setupLocalCluster(t *testing.T, txNum int) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	fileInput, totalDataSize, batchSize, injectSpeed, blockInterval := params.FileInput, params.TotalDataSize, params.BatchSize, params.InjectSpeed, params.Block_Interval
+	shardNum, nodesInShard := params.ShardNum, params.NodesInShard
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		params.FileInput, params.TotalDataSize, params.BatchSize, params.InjectSpeed, params.Block_Interval = fileInput, totalDataSize, batchSize, injectSpeed, blockInterval
+		params.ShardNum, params.NodesInShard = shardNum, nodesInShard
+	})
+
+	file, err := os.Create(filepath.Join(dir, "txs.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := csv.NewWriter(file)
+	for i := 0; i < txNum; i++ { //只有第 3、4、6、7、8 列会被用到
+		w.Write([]string{"", "", "", fmt.Sprintf("0x%040x", i+1), fmt.Sprintf("0x%040x", i+1000), "", "0", "0", "1"})
+	}
+	w.Flush()
+	file.Close()
+
+	params.FileInput = filepath.Join(dir, "txs.csv")
+	params.TotalDataSize = txNum
+	params.BatchSize = txNum
+	params.InjectSpeed = txNum
+	params.Block_Interval = 300
+}
+
+// 读取测量结果 metric 的总数，即结果文件第二行的最后一列
+func measureTotal(t *testing.T, metric string) float64 {
+	return measureTotalIn(t, params.DataWrite_path+"supervisor_measureOutput/", metric)
+}
+
+// 读取 dir 目录中测量结果 metric 的总数
+func measureTotalIn(t *testing.T, dir, metric string) float64 {
+	file, err := os.Open(filepath.Join(dir, metric+".csv"))
+	if err != nil {
+		entries, _ := os.ReadDir(dir)
+		t.Fatalf("the measure output is not written: %v, %v", err, entries)
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1 //第一行只有测量方法的名称
+	records, err := r.ReadAll()
+	if err != nil || len(records) < 2 || len(records[1]) == 0 {
+		t.Fatalf("the measure output of %s is malformed: %v, %v", metric, records, err)
+	}
+	total, err := strconv.ParseFloat(records[1][len(records[1])-1], 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
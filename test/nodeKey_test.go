@@ -0,0 +1,33 @@
+package test
+
+import (
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"blockEmulator/shard"
+	"os"
+	"testing"
+)
+
+// 相对路径的 keystore 在不同的工作目录中是不同的密钥，验证签名时不能使用之前缓存的公钥
+func TestNodeKeyWorkingDir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyStore := params.KeyStore_path
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		params.KeyStore_path = keyStore
+	})
+	params.KeyStore_path = "./keystore/"
+	node := &shard.Node{ShardID: 0, NodeID: 0}
+	for i := 0; i < 2; i++ {
+		if err := os.Chdir(t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		_, signed := message.SplitMessage(message.MergeSignedMessage(message.CPrepare, []byte("{}"), node, shard.LoadOrGenerateNodeKey(0, 0)))
+		if _, _, _, ok := message.OpenSignedMessage(signed); !ok {
+			t.Fatalf("the message signed in working directory %d should be verified", i)
+		}
+	}
+}
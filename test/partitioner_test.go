@@ -0,0 +1,134 @@
+package test
+
+import (
+	"blockEmulator/params"
+	"blockEmulator/partition"
+	"blockEmulator/utils"
+	"testing"
+)
+
+// 账户 0-9 和账户 10-19 各自组成一个完全图，两个完全图之间只有一条边
+func twoClusterState() *partition.CLPAState {
+	k := new(partition.CLPAState)
+	k.Init_CLPAState(0.5, 100, 2)
+	for c := 0; c < 20; c += 10 {
+		for i := c; i < c+10; i++ {
+			for j := i + 1; j < c+10; j++ {
+				k.AddEdge(clpaVertex(i), clpaVertex(j))
+			}
+		}
+	}
+	k.AddEdge(clpaVertex(0), clpaVertex(10))
+	return k
+}
+
+// 基于交易图的算法都应当把两个完全图分到不同的分片
+func TestPartitioners(t *testing.T) {
+	shardNum := params.ShardNum
+	params.ShardNum = 2
+	defer func() { params.ShardNum = shardNum }()
+
+	for _, algorithm := range []string{"CLPA", "Metis", "Louvain", "Fennel"} {
+		k := twoClusterState()
+		p, err := partition.NewPartitioner(algorithm, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.NewBlock()
+		p.Partition()
+		if k.CrossShardEdgeNum != 1 {
+			t.Fatalf("%s: the cross-shard weight should be 1, got %v", algorithm, k.CrossShardEdgeNum)
+		}
+		if k.VertexsNumInShard[0] != 10 || k.VertexsNumInShard[1] != 10 {
+			t.Fatalf("%s: the shards should be balanced, got %v", algorithm, k.VertexsNumInShard)
+		}
+		p.Reset()
+	}
+}
+
+// 哈希划分把账户放回由地址决定的分片
+func TestHashPartitioner(t *testing.T) {
+	shardNum := params.ShardNum
+	params.ShardNum = 2
+	defer func() { params.ShardNum = shardNum }()
+
+	k := twoClusterState()
+	metis, _ := partition.NewPartitioner("Metis", k)
+	moved := metis.Partition()
+	if len(moved) == 0 {
+		t.Fatalf("Metis should move some accounts")
+	}
+	hash, _ := partition.NewPartitioner("Hash", k)
+	back := hash.Partition()
+	if len(back) != len(moved) {
+		t.Fatalf("Hash should move back the %d accounts moved by Metis, got %d", len(moved), len(back))
+	}
+	for v, s := range k.PartitionMap {
+		if s != utils.Addr2Shard(v.Addr) {
+			t.Fatalf("account %s should be in shard %d, got %d", v.Addr, utils.Addr2Shard(v.Addr), s)
+		}
+	}
+
+	if _, err := partition.NewPartitioner("Unknown", k); err == nil {
+		t.Fatalf("an unknown algorithm should be rejected")
+	}
+}
+
+// 通过接口设置的迁移代价超过跨分片边的减少时，任何算法都不移动账户
+func TestPartitionerMigrationCost(t *testing.T) {
+	shardNum := params.ShardNum
+	params.ShardNum = 2
+	defer func() { params.ShardNum = shardNum }()
+
+	for _, algorithm := range []string{"CLPA", "Metis", "Louvain", "Fennel"} {
+		k := twoClusterState()
+		p, _ := partition.NewPartitioner(algorithm, k)
+		p.SetMigrationCosts(func(v partition.Vertex, shard int) float64 {
+			if shard != k.PartitionMap[v] {
+				t.Fatalf("%s: account %s is in shard %d, got %d", algorithm, v.Addr, k.PartitionMap[v], shard)
+			}
+			return 1000
+		}, 1, 0)
+		if moved := p.Partition(); len(moved) != 0 {
+			t.Fatalf("%s: no account should move, got %v", algorithm, moved)
+		}
+		if r := p.LastReport(); r.MovedAccounts != 0 || r.CrossShardAfter != r.CrossShardBefore {
+			t.Fatalf("%s: the report should have no move, got %v", algorithm, r)
+		}
+	}
+}
+
+// 衰减因子为 0（CLPA_GraphWindow 为 reset）时，每次划分之后交易图被清空，账户保留划分的结果
+func TestPartitionerReset(t *testing.T) {
+	shardNum := params.ShardNum
+	params.ShardNum = 2
+	defer func() { params.ShardNum = shardNum }()
+
+	for _, algorithm := range []string{"CLPA", "Metis", "Louvain", "Fennel"} {
+		k := twoClusterState()
+		k.SetGraphWindow(0, 0)
+		p, _ := partition.NewPartitioner(algorithm, k)
+		p.NewBlock()
+		p.Partition()
+		shard := k.PartitionMap[clpaVertex(0)]
+		p.Reset()
+		if len(k.NetGraph.VertexSet) != 0 || len(k.NetGraph.EdgeSet) != 0 {
+			t.Fatalf("%s: the graph should be empty after reset, got %d vertices", algorithm, len(k.NetGraph.VertexSet))
+		}
+		if len(k.VertexsNumInShard) != 2 || k.VertexsNumInShard[0] != 0 || k.VertexsNumInShard[1] != 0 {
+			t.Fatalf("%s: the vertex numbers in shards should be 0, got %v", algorithm, k.VertexsNumInShard)
+		}
+
+		p.NewBlock()
+		p.AddWeightedEdge(clpaVertex(0), clpaVertex(1), 1)
+		if k.PartitionMap[clpaVertex(0)] != shard {
+			t.Fatalf("%s: account 0 should stay in shard %d after reset, got %d", algorithm, shard, k.PartitionMap[clpaVertex(0)])
+		}
+		want := make([]int, 2)
+		want[k.PartitionMap[clpaVertex(0)]]++
+		want[k.PartitionMap[clpaVertex(1)]]++
+		if k.VertexsNumInShard[0] != want[0] || k.VertexsNumInShard[1] != want[1] {
+			t.Fatalf("%s: the vertex numbers in shards should be %v, got %v", algorithm, want, k.VertexsNumInShard)
+		}
+	}
+}
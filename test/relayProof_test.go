@@ -0,0 +1,74 @@
+package test
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"blockEmulator/shard"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// 测试中继交易的证明：交易的默克尔证明只对区块中的交易和区块的交易树根有效，提交证书需要 2f+1 个不同节点的签名
+func TestRelayProof(t *testing.T) {
+	body := make([]*core.Transaction, 0)
+	for i := 0; i < 20; i++ {
+		recipient := fmt.Sprintf("%040x", 0xb0+i)
+		body = append(body, core.NewTransaction("00000000000000000000000000000000000000a1", recipient, big.NewInt(int64(i+1)), uint64(i)))
+	}
+	header := &core.BlockHeader{TxRoot: chain.GetTxTreeRoot(body), Number: 1, Time: time.Now()}
+	tree := chain.NewTxTree(body)
+
+	target := body[7]
+	proof := tree.Prove(target.TxHash)
+	body[7].Relayed = true // 区块提交之后修改交易不影响证明
+	tx, err := chain.VerifyTxProof(header.TxRoot, target.TxHash, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tx.TxHash) != string(target.TxHash) || tx.Recipient != target.Recipient || tx.Relayed {
+		t.Errorf("the proof should prove the tx in the block")
+	}
+	if _, err := chain.VerifyTxProof(header.TxRoot, body[8].TxHash, proof); err == nil {
+		t.Errorf("the proof of another tx should be refused")
+	}
+	if _, err := chain.VerifyTxProof(chain.GetTxTreeRoot(body[:10]), target.TxHash, proof); err == nil {
+		t.Errorf("the proof should be refused against another tx root")
+	}
+	outside := core.NewTransaction("00000000000000000000000000000000000000a1", "00000000000000000000000000000000000000c3", big.NewInt(1), 99)
+	if _, err := chain.VerifyTxProof(header.TxRoot, outside.TxHash, tree.Prove(outside.TxHash)); err == nil {
+		t.Errorf("a tx outside the block should be refused")
+	}
+
+	keyStore := params.KeyStore_path
+	params.KeyStore_path = t.TempDir() + "/"
+	defer func() { params.KeyStore_path = keyStore }()
+	hash := header.Hash()
+	cert := [][]byte{signCommit(t, 0, 0, hash), signCommit(t, 0, 1, hash), signCommit(t, 0, 1, hash), signCommit(t, 1, 2, hash), signCommit(t, 0, 3, []byte("other"))}
+	if message.VerifyCommitCertificate(cert, 0, hash, 3) {
+		t.Errorf("duplicated signers, signers of other shards and commits of other blocks should not be counted")
+	}
+	cert = append(cert, signCommit(t, 0, 2, hash))
+	if !message.VerifyCommitCertificate(cert, 0, hash, 3) {
+		t.Errorf("the certificate signed by 3 nodes of the shard should be accepted")
+	}
+	if message.VerifyCommitCertificate(cert, 0, (&core.BlockHeader{Number: 2}).Hash(), 3) {
+		t.Errorf("the certificate should not prove another block")
+	}
+}
+
+// 节点 nid 对区块签名的提交消息，密钥保存在 params.KeyStore_path 中
+func signCommit(t *testing.T, sid, nid uint64, blockHash []byte) []byte {
+	node := &shard.Node{ShardID: sid, NodeID: nid}
+	c := message.Commit{Digest: []byte("digest"), SeqID: 1, SenderNode: node, BlockHash: blockHash}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, signed := message.SplitMessage(message.MergeSignedMessage(message.CCommit, b, node, shard.LoadOrGenerateNodeKey(sid, nid)))
+	return signed
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"blockEmulator/build"
+	"blockEmulator/params"
+	"blockEmulator/supervisor"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadScenario(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "scenario.json")
+	os.WriteFile(fn, []byte(`{"Events": [
+		{"Action": "isolate", "ShardID": 1, "NodeID": 2, "Peers": [{"ShardID": 1, "NodeID": 0}], "AtTime": 30, "Duration": 30},
+		{"Action": "crash", "ShardID": 0, "Leader": true, "AtBlock": 50}
+	]}`), 0644)
+	sc, err := supervisor.LoadScenario(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.Events) != 2 || len(sc.Events[0].Peers) != 1 || sc.Events[0].Duration != 30 || !sc.Events[1].Leader || sc.Events[1].AtBlock != 50 {
+		t.Fatalf("the scenario is not parsed correctly: %+v", sc.Events)
+	}
+
+	os.WriteFile(fn, []byte(`{"Events": [{"Action": "explode", "ShardID": 0}]}`), 0644)
+	if _, err := supervisor.LoadScenario(fn); err == nil {
+		t.Fatal("an unknown action should be rejected")
+	}
+}
+
+// 分片 0 的主节点在第 2 个区块之后崩溃，其他节点切换视图后继续出块；分片 1 的 2 号节点被隔离一段时间。
+// 事件和恢复都记录在时间线中
+func TestScenario(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the local cluster takes several seconds")
+	}
+	setupLocalCluster(t, 200)
+	timeOut, scenarioPath := params.ViewChangeTimeOut, params.Scenario_path
+	t.Cleanup(func() { params.ViewChangeTimeOut, params.Scenario_path = timeOut, scenarioPath })
+	params.ViewChangeTimeOut = 2000
+	params.BatchSize = 20
+	params.InjectSpeed = 20
+	params.Scenario_path = "scenario.json"
+	os.WriteFile(params.Scenario_path, []byte(`{"Events": [
+		{"Action": "crash", "ShardID": 0, "Leader": true, "AtBlock": 2, "Duration": 4},
+		{"Action": "isolate", "ShardID": 1, "NodeID": 2, "AtTime": 1, "Duration": 2}
+	]}`), 0644)
+
+	build.RunLocalCluster(4, 2, 3, 0, "")
+
+	file, err := os.Open(params.DataWrite_path + "supervisor_measureOutput/Scenario_Timeline.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(map[string]bool)
+	for _, r := range records[1:] {
+		events[r[1]+" S"+r[2]+"N"+r[3]] = true
+	}
+	for _, ev := range []string{"crash S0N0", "restart S0N0", "isolate S1N2", "heal S1N2"} {
+		if !events[ev] {
+			t.Fatalf("the timeline should contain %q, got %v", ev, records)
+		}
+	}
+	if n := measureTotal(t, "View_Change_Count"); n < 1 {
+		t.Fatalf("shard 0 should change the view after its leader crashes")
+	}
+	if n := measureTotal(t, "Safety_Violation"); n != 0 {
+		t.Fatalf("the scenario should not violate safety, got %v", n)
+	}
+	b, err := os.ReadFile(params.LogWrite_path + "/S0/N0.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "crashed") || !strings.Contains(string(b), "restarted") {
+		t.Fatal("the leader of shard 0 should crash and restart")
+	}
+}
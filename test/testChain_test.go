@@ -0,0 +1,103 @@
+package test
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"blockEmulator/message"
+	"blockEmulator/networks"
+	"blockEmulator/params"
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// 切换到临时目录，区块数据库和账户记录写在临时目录中，测试结束后回到原来的目录
+func chdirTemp(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// 创建只有一个分片的测试链
+func newTestChain(t *testing.T) *chain.BlockChain {
+	return newTestChains(t, 1)[0]
+}
+
+// 为 shardNum 个分片各创建一条使用内存数据库的测试链，每个分片只有一个节点，测试结束后关闭并恢复 ShardNum
+func newTestChains(t *testing.T, shardNum int) []*chain.BlockChain {
+	chdirTemp(t)
+	oldShardNum := params.ShardNum
+	params.ShardNum = shardNum
+	t.Cleanup(func() { params.ShardNum = oldShardNum })
+
+	chains := make([]*chain.BlockChain, shardNum)
+	for sid := range chains {
+		pcc := &params.ChainConfig{
+			ShardID:        uint64(sid),
+			NodeID:         0,
+			Nodes_perShard: 1,
+			ShardNums:      uint64(shardNum),
+			BlockSize:      100,
+		}
+		bc, err := chain.NewBlockChain(pcc, rawdb.NewMemoryDatabase())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(bc.CloseBlockChain)
+		chains[sid] = bc
+	}
+	return chains
+}
+
+// 为 shardNum 个分片各创建一个只接收注入交易的监听器作为分片的主节点，返回节点地址表和收到的交易，测试结束后关闭
+func leaderListeners(t *testing.T, shardNum int) (map[uint64]map[uint64]string, chan *core.Transaction) {
+	injected := make(chan *core.Transaction, 16)
+	ipTable := make(map[uint64]map[uint64]string)
+	for sid := uint64(0); sid < uint64(shardNum); sid++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+		ipTable[sid] = map[uint64]string{0: ln.Addr().String()}
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					reader := bufio.NewReader(conn)
+					for {
+						msg, err := networks.ReadFrame(reader)
+						if err != nil {
+							return
+						}
+						msgType, content := message.SplitMessage(msg)
+						if msgType != message.CInject {
+							continue
+						}
+						it := new(message.InjectTxs)
+						if err := json.Unmarshal(content, it); err != nil {
+							t.Error(err)
+							return
+						}
+						for _, tx := range it.Txs {
+							injected <- tx
+						}
+					}
+				}()
+			}
+		}()
+	}
+	return ipTable, injected
+}
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"blockEmulator/networks"
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// 测试长度前缀的传输：包含换行符的二进制消息能够完整、按顺序到达
+func TestTransport(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0") //随机端口
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	msgs := [][]byte{
+		[]byte("hello"),
+		{0x00, '\n', 0xff, '\n', '\n'}, //包含换行符的二进制消息
+		bytes.Repeat([]byte{'\n'}, 1<<16),
+		{},
+	}
+	received := make(chan []byte, len(msgs))
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			msg, err := networks.ReadFrame(reader)
+			if err != nil {
+				return
+			}
+			received <- msg
+		}
+	}()
+
+	tp := networks.NewTransport()
+	defer tp.Close()
+	for _, msg := range msgs {
+		if err := tp.Send(msg, ln.Addr().String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, want := range msgs {
+		select {
+		case got := <-received:
+			if !bytes.Equal(got, want) {
+				t.Fatalf("message %d is broken, got %d bytes, want %d bytes", i, len(got), len(want))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %d is not received", i)
+		}
+	}
+
+	tp.Close()
+	if err := tp.Send([]byte("late"), ln.Addr().String()); err != networks.ErrTransportClosed {
+		t.Fatalf("send after close should fail, got %v", err)
+	}
+}
+
+// 测试网络仿真：时延和带宽限制会推迟消息的到达时间，同一条链路上的消息不会乱序
+func TestNetworkEmulator(t *testing.T) {
+	ne := networks.NewNetworkEmulator(networks.LinkProfile{})
+	ne.SetRegion("a", "asia")
+	ne.SetRegion("b", "europe")
+	ne.SetLink("asia", "europe", networks.LinkProfile{Latency: 100, Jitter: 50, Dist: "uniform", Bandwidth: 1000})
+
+	start := time.Now()
+	if d := ne.Schedule("b", "a", 1000).Sub(start); d > 10*time.Millisecond { //没有配置的链路不模拟
+		t.Fatalf("the default link should have no delay, got %v", d)
+	}
+	last := start
+	for i := 0; i < 10; i++ {
+		arrival := ne.Schedule("a", "b", 100) //每条消息需要 100ms 的发送时间
+		if arrival.Before(last) {
+			t.Fatalf("message %d arrives before the previous one", i)
+		}
+		last = arrival
+	}
+	if d := last.Sub(start); d < time.Second+50*time.Millisecond || d > time.Second+200*time.Millisecond {
+		t.Fatalf("the last message should arrive after about 1.1s, got %v", d)
+	}
+}
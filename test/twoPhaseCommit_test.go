@@ -0,0 +1,200 @@
+package test
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"blockEmulator/shard"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// 测试两阶段提交：准备交易锁定两个分片中的账户并在发送者分片扣款，提交后接收者入账，中止后发送者取回金额，
+// 先于准备交易到达的中止交易使之后的准备交易不再执行，中止完成之后状态树中不留下记录；决定交易必须由协调者签名
+func TestTwoPhaseCommit(t *testing.T) {
+	chains := newTestChains(t, 2)
+	key := shard.LoadOrGenerateNodeKey(params.DeciderShard, 0) //协调者的密钥
+	decide := func(raw *core.Transaction, phase uint8) *core.Transaction {
+		tx := core.NewTwoPCTx(raw, phase)
+		tx.SignTwoPCDecision(key)
+		return tx
+	}
+	root := func(sid int) string {
+		return string(chains[sid].CurrentBlock.Header.StateRoot)
+	}
+	addBlock := func(sid int, txs ...*core.Transaction) {
+		chains[sid].SendTx2Pool(txs)
+		if err := chains[sid].AddBlock(chains[sid].GenerateBlock()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reason := func(sid int, tx *core.Transaction) string {
+		_, r, err := chains[sid].GetTransaction(tx.TxHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Reason
+	}
+	balance := func(sid int, addr string) *big.Int {
+		return chains[sid].FetchAccounts([]string{addr})[0].Balance
+	}
+	diff := func(d int64) *big.Int {
+		return new(big.Int).Add(params.Init_Balance, big.NewInt(d))
+	}
+
+	sender := "00000000000000000000000000000000000000a2"    //分片 0
+	recipient := "00000000000000000000000000000000000000b3" //分片 1
+	raw1 := core.NewTransaction(sender, recipient, big.NewInt(5), 0)
+	raw2 := core.NewTransaction(sender, recipient, big.NewInt(7), 1)
+	prepare1, prepare2 := core.NewTwoPCTx(raw1, core.TwoPCPrepare), core.NewTwoPCTx(raw2, core.TwoPCPrepare)
+	if string(prepare1.TxHash) == string(core.NewTwoPCTx(raw1, core.TwoPCCommit).TxHash) {
+		t.Fatalf("the txs of different phases should have different hashes")
+	}
+
+	addBlock(0, prepare1)
+	addBlock(1, prepare1)
+	locked := root(0)
+	addBlock(0, prepare2)
+	if reason(0, prepare1) != "" || reason(0, prepare2) != chain.ErrTwoPCLocked.Error() {
+		t.Errorf("the second prepare should be refused by the lock, got %q", reason(0, prepare2))
+	}
+	if balance(0, sender).Cmp(diff(-5)) != 0 || balance(1, recipient).Cmp(params.Init_Balance) != 0 {
+		t.Errorf("only the sender should be debited after the prepare")
+	}
+	addBlock(0, decide(raw2, core.TwoPCAbort))
+	if root(0) != locked {
+		t.Errorf("the record of the refused prepare should be pruned after the abort")
+	}
+
+	// 没有签名、签名被冒充或者内容被篡改的决定交易不能进入交易池，也不能出现在区块中
+	_, forger, _ := ed25519.GenerateKey(rand.Reader)
+	forged := core.NewTwoPCTx(raw1, core.TwoPCAbort)
+	forged.SignTwoPCDecision(forger)
+	chains[0].SendTx2Pool([]*core.Transaction{core.NewTwoPCTx(raw1, core.TwoPCAbort), forged})
+	if n := chains[0].Txpool.GetTxQueueLen(); n != 0 {
+		t.Fatalf("the decisions not signed by the coordinator should be refused, %d txs in the pool", n)
+	}
+	commit1 := decide(raw1, core.TwoPCCommit)
+	chains[0].SendTx2Pool([]*core.Transaction{commit1})
+	b := chains[0].GenerateBlock()
+	tampered := core.DecodeTx(commit1.Encode())
+	tampered.Value = big.NewInt(500)
+	h := *b.Header
+	nb := core.NewBlock(&h, []*core.Transaction{tampered})
+	nb.Header.TxRoot = chain.GetTxTreeRoot(nb.Body)
+	nb.Hash = nb.Header.Hash()
+	if err := chains[0].IsValidBlock(nb); !errors.Is(err, core.ErrTwoPCDecisionSignature) {
+		t.Errorf("got %v, want %v", err, core.ErrTwoPCDecisionSignature)
+	}
+	if err := chains[0].AddBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	addBlock(1, commit1)
+	if balance(0, sender).Cmp(diff(-5)) != 0 || balance(1, recipient).Cmp(diff(5)) != 0 {
+		t.Errorf("the recipient should be credited after the commit")
+	}
+
+	raw3 := core.NewTransaction(sender, recipient, big.NewInt(9), 1)
+	addBlock(0, core.NewTwoPCTx(raw3, core.TwoPCPrepare))
+	before := root(1)
+	addBlock(1, decide(raw3, core.TwoPCAbort)) //协调者超时，中止交易先于准备交易到达
+	if root(1) == before {
+		t.Fatalf("the abort before the prepare should be recorded")
+	}
+	addBlock(1, core.NewTwoPCTx(raw3, core.TwoPCPrepare))
+	if r := reason(1, core.NewTwoPCTx(raw3, core.TwoPCPrepare)); r != chain.ErrTwoPCAborted.Error() {
+		t.Errorf("the prepare after the abort should be refused, got %q", r)
+	}
+	if root(1) != before {
+		t.Errorf("the record of the abort should be pruned after the prepare is refused")
+	}
+	addBlock(0, decide(raw3, core.TwoPCAbort))
+	if balance(0, sender).Cmp(diff(-5)) != 0 || balance(1, recipient).Cmp(diff(5)) != 0 {
+		t.Errorf("the sender should get back the value after the abort")
+	}
+	if as := chains[0].FetchAccounts([]string{sender})[0]; as.TwoPCLock != nil || as.Nonce != 2 {
+		t.Errorf("the sender should be unlocked with nonce 2, got nonce %d", as.Nonce)
+	}
+}
+
+// 检查 nonce 时，被拒绝的准备交易同样使用了发送者的 nonce，发送者之后的交易可以执行
+func TestTwoPCRefusedPrepareNonce(t *testing.T) {
+	chains := newTestChains(t, 2)
+	checkNonce := params.CheckNonce
+	params.CheckNonce = true
+	defer func() { params.CheckNonce = checkNonce }()
+
+	sender := "00000000000000000000000000000000000000c4"    //分片 0
+	recipient := "00000000000000000000000000000000000000d5" //分片 1
+	other := "00000000000000000000000000000000000000e6"     //分片 0
+	raw := core.NewTransaction(sender, recipient, new(big.Int).Add(params.Init_Balance, big.NewInt(1)), 0)
+	prepare := core.NewTwoPCTx(raw, core.TwoPCPrepare)
+	next := core.NewTransaction(sender, other, big.NewInt(3), 1)
+	for _, tx := range []*core.Transaction{prepare, next} {
+		chains[0].SendTx2Pool([]*core.Transaction{tx})
+		if err := chains[0].AddBlock(chains[0].GenerateBlock()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, r, err := chains[0].GetTransaction(prepare.TxHash); err != nil || r.Reason != chain.ErrInsufficientBalance.Error() {
+		t.Fatalf("the prepare should be refused by the balance, got %v %v", r, err)
+	}
+	if _, r, err := chains[0].GetTransaction(next.TxHash); err != nil || r.Reason != "" {
+		t.Fatalf("the next tx of the sender should be executed, got %v %v", r, err)
+	}
+	as := chains[0].FetchAccounts([]string{sender})[0]
+	if as.Nonce != 2 || as.Balance.Cmp(new(big.Int).Sub(params.Init_Balance, big.NewInt(3))) != 0 {
+		t.Errorf("the sender should have nonce 2 and pay 3, got nonce %d and balance %v", as.Nonce, as.Balance)
+	}
+}
+
+// 账户被 2PC 锁定期间，涉及该账户的普通交易放回交易池，提交之后再执行
+func TestTwoPCLockIsolation(t *testing.T) {
+	chains := newTestChains(t, 2)
+	key := shard.LoadOrGenerateNodeKey(params.DeciderShard, 0)
+	addBlock := func(sid int, txs ...*core.Transaction) {
+		chains[sid].SendTx2Pool(txs)
+		if err := chains[sid].AddBlock(chains[sid].GenerateBlock()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	executed := func(sid int, tx *core.Transaction) bool {
+		_, r, err := chains[sid].GetTransaction(tx.TxHash)
+		return err == nil && r.Reason == ""
+	}
+
+	sender := "00000000000000000000000000000000000000a2"    //分片 0
+	recipient := "00000000000000000000000000000000000000b3" //分片 1
+	raw := core.NewTransaction(sender, recipient, big.NewInt(5), 0)
+	debit := core.NewTransaction(sender, "00000000000000000000000000000000000000c4", big.NewInt(2), 1)
+	credit := core.NewTransaction("00000000000000000000000000000000000000d5", recipient, big.NewInt(3), 0)
+
+	addBlock(0, core.NewTwoPCTx(raw, core.TwoPCPrepare), debit) //同一批中位于准备交易之后
+	addBlock(1, core.NewTwoPCTx(raw, core.TwoPCPrepare))
+	addBlock(1, credit)
+	if executed(0, debit) || executed(1, credit) {
+		t.Fatalf("the txs of the locked accounts should not be executed")
+	}
+	if chains[0].Txpool.GetTxQueueLen() != 1 || chains[1].Txpool.GetTxQueueLen() != 1 {
+		t.Fatalf("the txs of the locked accounts should wait in the pools")
+	}
+
+	commit := core.NewTwoPCTx(raw, core.TwoPCCommit)
+	commit.SignTwoPCDecision(key)
+	addBlock(0, commit)
+	addBlock(1, commit)
+	addBlock(0)
+	addBlock(1)
+	if !executed(0, debit) || !executed(1, credit) {
+		t.Fatalf("the txs should be executed after the accounts are unlocked")
+	}
+	if b := chains[0].FetchAccounts([]string{sender})[0].Balance; b.Cmp(new(big.Int).Sub(params.Init_Balance, big.NewInt(7))) != 0 {
+		t.Errorf("the sender should pay 5 and 2, got balance %v", b)
+	}
+	if b := chains[1].FetchAccounts([]string{recipient})[0].Balance; b.Cmp(new(big.Int).Add(params.Init_Balance, big.NewInt(8))) != 0 {
+		t.Errorf("the recipient should get 5 and 3, got balance %v", b)
+	}
+}
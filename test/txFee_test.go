@@ -0,0 +1,80 @@
+package test
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"math/big"
+	"testing"
+)
+
+// 测试按手续费打包：gas 价格高的交易先打包，同一发送者的交易仍然按 nonce 顺序打包
+func TestTxPoolFeePriority(t *testing.T) {
+	params.TxPoolPolicy = "fee"
+	defer func() { params.TxPoolPolicy = "fifo" }()
+
+	a := "00000000000000000000000000000000000000a1"
+	b := "00000000000000000000000000000000000000b2"
+	c := "00000000000000000000000000000000000000c3"
+	newTx := func(sender string, nonce uint64, gasPrice int64) *core.Transaction {
+		return core.NewTransactionWithFee(sender, c, big.NewInt(1), nonce, big.NewInt(gasPrice), params.TxGas)
+	}
+	relayed := newTx(c, 7, 10)
+	relayed.Relayed = true
+	pool := core.NewTxPool()
+	pool.AddTxs2Pool([]*core.Transaction{newTx(a, 1, 100), newTx(a, 0, 1), newTx(b, 0, 50), relayed})
+
+	got := pool.PackTxs(2)
+	if len(got) != 2 || got[0].Sender != b || got[1] != relayed {
+		t.Fatalf("the first two txs should be the tx of b and the relayed tx")
+	}
+	// 打包之后加入的交易也按 gas 价格和 nonce 排序：b 的新交易排在队首 gas 价格为 1 的 a 之前
+	pool.AddTxs2Pool([]*core.Transaction{newTx(b, 1, 20), newTx(a, 2, 100)})
+	got = pool.PackTxs(10)
+	if len(got) != 4 || got[0].Sender != b || got[1].Nonce != 0 || got[2].Nonce != 1 || got[3].Nonce != 2 {
+		t.Fatalf("the new tx of b should be packed first, then the txs of a in nonce order")
+	}
+	pool.AddTxs2Pool([]*core.Transaction{newTx(a, 4, 1), newTx(b, 2, 2)})
+	pool.GetLocked()
+	pool.ResetTxQueue(pool.TxQueue[:1]) //直接修改交易队列之后队列和堆也随之更新
+	pool.GetUnlocked()
+	if got = pool.PackTxs(10); len(got) != 1 || got[0].Sender != a {
+		t.Fatalf("only the tx left in the queue should be packed")
+	}
+	if pool.GetTxQueueLen() != 0 {
+		t.Errorf("the pool should be empty")
+	}
+}
+
+// 测试手续费：发送者支付 TxGas * GasPrice，区块中的手续费奖励给出块节点
+func TestTxFee(t *testing.T) {
+	params.TxFee = true
+	defer func() { params.TxFee = false }()
+	bc := newTestChain(t)
+
+	sender := "00000000000000000000000000000000000000a1"
+	recipient := "00000000000000000000000000000000000000b2"
+	lowGas := core.NewTransactionWithFee(sender, recipient, big.NewInt(5), 1, big.NewInt(3), params.TxGas-1)
+	bc.SendTx2Pool([]*core.Transaction{
+		core.NewTransactionWithFee(sender, recipient, big.NewInt(5), 0, big.NewInt(3), params.TxGas),
+		lowGas,
+	})
+	if bc.Txpool.GetTxQueueLen() != 1 {
+		t.Fatalf("the tx whose gas limit is too low should be rejected")
+	}
+	b := bc.GenerateBlock()
+	if err := bc.AddBlock(b); err != nil {
+		t.Fatal(err)
+	}
+
+	fee := new(big.Int).SetUint64(3 * params.TxGas)
+	accounts := bc.FetchAccounts([]string{sender, core.MinerAddress(0, 0)})
+	want := new(big.Int).Sub(params.Init_Balance, big.NewInt(5))
+	want.Sub(want, fee)
+	if accounts[0].Balance.Cmp(want) != 0 {
+		t.Errorf("the balance of the sender is %v, want %v", accounts[0].Balance, want)
+	}
+	want = new(big.Int).Add(params.Init_Balance, fee)
+	if accounts[1].Balance.Cmp(want) != 0 {
+		t.Errorf("the balance of the miner is %v, want %v", accounts[1].Balance, want)
+	}
+}
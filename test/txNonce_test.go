@@ -0,0 +1,97 @@
+package test
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"blockEmulator/supervisor/committee"
+	"blockEmulator/supervisor/signal"
+	"blockEmulator/supervisor/supervisor_log"
+	"fmt"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+// 测试 nonce 检查：乱序到达的交易按 nonce 执行，重放的交易被丢弃，nonce 过大的交易留在交易池中
+func TestTxNonce(t *testing.T) {
+	bc := newTestChain(t)
+	params.CheckNonce = true
+	t.Cleanup(func() { params.CheckNonce = false })
+
+	sender := "00000000000000000000000000000000000000a1"
+	recipient := "00000000000000000000000000000000000000b2"
+	newTx := func(nonce uint64) *core.Transaction {
+		return core.NewTransaction(sender, recipient, big.NewInt(1), nonce)
+	}
+	bc.SendTx2Pool([]*core.Transaction{newTx(1), newTx(0), newTx(3)})
+	b := bc.GenerateBlock()
+	bc.AddBlock(b)
+	if len(b.Body) != 2 || b.Body[0].Nonce != 0 || b.Body[1].Nonce != 1 {
+		t.Fatalf("the block should contain the nonces 0 and 1, got %d txs", len(b.Body))
+	}
+	if bc.Txpool.GetTxQueueLen() != 1 {
+		t.Fatalf("the tx with nonce 3 should wait in the pool")
+	}
+
+	bc.SendTx2Pool([]*core.Transaction{newTx(1), newTx(2)}) //重放 nonce 1，补上 nonce 2
+	b = bc.GenerateBlock()
+	bc.AddBlock(b)
+	if len(b.Body) != 2 || b.Body[0].Nonce != 2 || b.Body[1].Nonce != 3 {
+		t.Fatalf("the block should contain the nonces 2 and 3, got %d txs", len(b.Body))
+	}
+	if n := bc.FetchAccounts([]string{sender})[0].Nonce; n != 4 {
+		t.Errorf("the next nonce of the sender is %d, want 4", n)
+	}
+	if bc.Txpool.GetTxQueueLen() != 0 {
+		t.Errorf("the replayed tx should be dropped")
+	}
+
+	// 余额不足的交易执行失败，但是使用了 nonce，同一发送者之后的交易可以执行
+	tooMuch := new(big.Int).Add(params.Init_Balance, big.NewInt(1))
+	bc.SendTx2Pool([]*core.Transaction{core.NewTransaction(sender, recipient, tooMuch, 4), newTx(5)})
+	b = bc.GenerateBlock()
+	bc.AddBlock(b)
+	if len(b.Body) != 2 {
+		t.Fatalf("the block should contain the nonces 4 and 5, got %d txs", len(b.Body))
+	}
+	if _, r, err := bc.GetTransaction(b.Body[0].TxHash); err != nil || r.Status != core.ReceiptFailed || r.Reason != chain.ErrInsufficientBalance.Error() {
+		t.Errorf("the tx with the insufficient balance should fail: %v %v", r, err)
+	}
+	if n := bc.FetchAccounts([]string{sender})[0].Nonce; n != 6 {
+		t.Errorf("the next nonce of the sender is %d, want 6", n)
+	}
+	if bc.Txpool.GetTxQueueLen() != 0 {
+		t.Errorf("no tx should wait in the pool")
+	}
+}
+
+// 同一进程中的每个委员会模块都从 0 开始为发送者分配 nonce（本地集群和启动器在一个进程中运行多次实验）
+func TestCommitteeNonces(t *testing.T) {
+	chdirTemp(t)
+	shardNum := params.ShardNum
+	t.Cleanup(func() { params.ShardNum = shardNum })
+	params.ShardNum = 2
+	params.ResetShardLeaders()
+	ipTable, injected := leaderListeners(t, 2)
+
+	line := fmt.Sprintf(",,,0x%040x,0x%040x,,0,0,1\n", 2, 4)
+	if err := os.WriteFile("txs.csv", []byte(line+line), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for run := 0; run < 2; run++ {
+		rthm := committee.NewRelayCommitteeModule(ipTable, signal.NewStopSignal(0), supervisor_log.NewSupervisorLog(), "txs.csv", 2, 100)
+		rthm.TxHandling()
+		for want := uint64(0); want < 2; want++ {
+			select {
+			case tx := <-injected:
+				if tx.Nonce != want {
+					t.Fatalf("run %d: the nonce should be %d, got %d", run, want, tx.Nonce)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("run %d: the tx with nonce %d is not injected", run, want)
+			}
+		}
+	}
+}
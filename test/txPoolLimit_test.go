@@ -0,0 +1,141 @@
+package test
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// 测试有界的交易池：重复的交易和超过发送者容量的交易被拒绝，超过容量时按驱逐策略驱逐交易，中继交易不会被驱逐
+func TestTxPoolLimit(t *testing.T) {
+	defer func() {
+		params.TxPoolCapacity, params.TxPoolSenderCapacity, params.TxPoolEviction = 0, 0, "oldest"
+	}()
+	recipient := "00000000000000000000000000000000000000c3"
+	newTx := func(sender string, nonce uint64, gasPrice int64) *core.Transaction {
+		return core.NewTransactionWithFee(sender, recipient, big.NewInt(1), nonce, big.NewInt(gasPrice), params.TxGas)
+	}
+	a := "00000000000000000000000000000000000000a1"
+	b := "00000000000000000000000000000000000000b2"
+
+	params.TxPoolCapacity, params.TxPoolSenderCapacity = 3, 2
+	pool := core.NewTxPool()
+	tx := newTx(a, 0, 1)
+	if err := pool.AddTx2Pool(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.AddTx2Pool(tx); !errors.Is(err, core.ErrTxDuplicate) {
+		t.Errorf("got %v, want %v", err, core.ErrTxDuplicate)
+	}
+	pool.AddTx2Pool(newTx(a, 1, 1))
+	if err := pool.AddTx2Pool(newTx(a, 2, 1)); !errors.Is(err, core.ErrTxSenderCapacity) {
+		t.Errorf("got %v, want %v", err, core.ErrTxSenderCapacity)
+	}
+	relayed := newTx(b, 9, 1)
+	relayed.Relayed = true
+	if rejected := pool.AddTxs2Pool([]*core.Transaction{relayed, newTx(b, 0, 1)}); rejected != 1 {
+		t.Errorf("%d txs are rejected or evicted, want 1", rejected)
+	}
+	if size, dropped := pool.Stats(); size != 3 || dropped != 3 {
+		t.Errorf("the pool has %d txs and dropped %d txs, want 3 and 3", size, dropped)
+	}
+	if pool.TxQueue[0] != relayed && pool.TxQueue[1] != relayed {
+		t.Errorf("the relayed tx should not be evicted")
+	}
+	if pool.TxQueue[0] == tx {
+		t.Errorf("the oldest tx should be evicted")
+	}
+
+	params.TxPoolEviction, params.TxPoolSenderCapacity = "lowest-fee", 0
+	pool = core.NewTxPool()
+	cheap := newTx(a, 0, 1)
+	pool.AddTxs2Pool([]*core.Transaction{newTx(b, 0, 5), cheap, newTx(b, 1, 3)})
+	if err := pool.AddTx2Pool(newTx(a, 1, 0)); !errors.Is(err, core.ErrTxPoolFull) {
+		t.Errorf("the new tx with the lowest fee should be evicted, got %v", err)
+	}
+	pool.AddTx2Pool(newTx(b, 2, 4))
+	for _, ptx := range pool.TxQueue {
+		if ptx == cheap {
+			t.Errorf("the tx with the lowest fee should be evicted")
+		}
+	}
+	if pool.GetTxQueueLen() != 3 {
+		t.Errorf("the pool has %d txs, want 3", pool.GetTxQueueLen())
+	}
+}
+
+// 测试交易池填满到 TxPoolCapacity 之后拒绝新的交易，打包之后腾出空间又能接收交易
+func TestTxPoolFullRecovery(t *testing.T) {
+	defer func() { params.TxPoolCapacity, params.TxPoolEviction = 0, "oldest" }()
+	params.TxPoolCapacity, params.TxPoolEviction = 4, "lowest-fee"
+	recipient := "00000000000000000000000000000000000000c3"
+	newTx := func(i int) *core.Transaction {
+		return core.NewTransaction(fmt.Sprintf("%040x", i+1), recipient, big.NewInt(1), 0)
+	}
+
+	pool := core.NewTxPool()
+	for i := 0; i < params.TxPoolCapacity; i++ {
+		if err := pool.AddTx2Pool(newTx(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pool.AddTx2Pool(newTx(4)); !errors.Is(err, core.ErrTxPoolFull) { //gas 价格相同时驱逐较晚到达的交易，即新交易
+		t.Fatalf("got %v, want %v", err, core.ErrTxPoolFull)
+	}
+	if rejected := pool.AddTxs2Pool([]*core.Transaction{newTx(5), newTx(6)}); rejected != 2 {
+		t.Errorf("%d txs are rejected, want 2", rejected)
+	}
+	if size, dropped := pool.Stats(); size != params.TxPoolCapacity || dropped != 3 {
+		t.Fatalf("the pool has %d txs and dropped %d txs, want %d and 3", size, dropped, params.TxPoolCapacity)
+	}
+
+	if packed := pool.PackTxs(2); len(packed) != 2 {
+		t.Fatalf("%d txs are packed, want 2", len(packed))
+	}
+	for i := 7; i < 9; i++ {
+		if err := pool.AddTx2Pool(newTx(i)); err != nil {
+			t.Fatalf("the pool should accept txs after packing: %v", err)
+		}
+	}
+	if err := pool.AddTx2Pool(newTx(9)); !errors.Is(err, core.ErrTxPoolFull) {
+		t.Errorf("got %v, want %v", err, core.ErrTxPoolFull)
+	}
+	if size, _ := pool.Stats(); size != params.TxPoolCapacity {
+		t.Errorf("the pool has %d txs, want %d", size, params.TxPoolCapacity)
+	}
+}
+
+// 测试加到池头的交易：按到达顺序打包时先被打包，按手续费打包时按 gas 价格打包，两种策略下都能检测重复的交易
+func TestTxPoolHead(t *testing.T) {
+	defer func() { params.TxPoolPolicy = "fifo" }()
+	recipient := "00000000000000000000000000000000000000c3"
+	newTx := func(i int, gasPrice int64) *core.Transaction {
+		return core.NewTransactionWithFee(fmt.Sprintf("%040x", i+1), recipient, big.NewInt(1), 0, big.NewInt(gasPrice), params.TxGas)
+	}
+	for _, policy := range []string{"fifo", "fee"} {
+		params.TxPoolPolicy = policy
+		pool := core.NewTxPool()
+		tail, head := newTx(0, 100), newTx(1, 1)
+		pool.AddTxs2Pool([]*core.Transaction{tail})
+		pool.AddTxs2Pool_Head([]*core.Transaction{head})
+		if err := pool.AddTx2Pool(head); !errors.Is(err, core.ErrTxDuplicate) {
+			t.Fatalf("%s: got %v, want %v", policy, err, core.ErrTxDuplicate)
+		}
+		want := head
+		if policy == "fee" {
+			want = tail
+		}
+		if got := pool.PackTxs(1); len(got) != 1 || got[0] != want {
+			t.Fatalf("%s: the wrong tx is packed first", policy)
+		}
+		if got := pool.PackTxs(1); len(got) != 1 || pool.GetTxQueueLen() != 0 {
+			t.Fatalf("%s: the pool should be empty after packing both txs", policy)
+		}
+		if err := pool.AddTx2Pool(head); err != nil {
+			t.Fatalf("%s: the packed tx should be accepted again: %v", policy, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"blockEmulator/chain"
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"blockEmulator/query"
+	"math/big"
+	"testing"
+)
+
+// 测试交易索引和收据：添加区块之后可以根据哈希找到交易，没有被执行的交易的收据记录原因
+func TestTxReceipt(t *testing.T) {
+	bc := newTestChain(t)
+
+	sender := "00000000000000000000000000000000000000a1"
+	recipient := "00000000000000000000000000000000000000b2"
+	ok := core.NewTransaction(sender, recipient, big.NewInt(1), 0)
+	tooLarge := core.NewTransaction(sender, recipient, new(big.Int).Add(params.Init_Balance, big.NewInt(1)), 1)
+	bc.SendTx2Pool([]*core.Transaction{ok, tooLarge})
+	b := bc.GenerateBlock()
+	if err := bc.AddBlock(b); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, r, err := bc.GetTransaction(tooLarge.TxHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tx.TxHash) != string(tooLarge.TxHash) || r.Index != 1 || r.BlockNumber != 1 {
+		t.Errorf("the tx is found at block %d index %d, want block 1 index 1", r.BlockNumber, r.Index)
+	}
+	if r.Status != core.ReceiptFailed || r.Reason != chain.ErrInsufficientBalance.Error() {
+		t.Errorf("the receipt should record the insufficient balance, got status %d %q", r.Status, r.Reason)
+	}
+	bc.CloseBlockChain()
+
+	tx, r = query.QueryTx(0, 0, ok.TxHash)
+	if tx == nil || r.Status != core.ReceiptSuccess || r.Stage != core.TxStageInner {
+		t.Fatalf("the executed tx should be found with a successful receipt")
+	}
+	if rs := query.QueryTxLifecycle(0, ok.TxHash); len(rs) != 1 || rs[0].ShardID != 0 {
+		t.Errorf("the lifecycle should contain one receipt, got %d", len(rs))
+	}
+}
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"blockEmulator/core"
+	"blockEmulator/params"
+	"math/big"
+	"testing"
+)
+
+// 测试交易签名：确定性私钥签名的交易能通过验证，被篡改或者冒充发送者的交易被交易池拒绝
+func TestTxSignature(t *testing.T) {
+	params.SignTxs = true
+	defer func() { params.SignTxs = false }()
+
+	sender := core.WorkloadAddress("32be343b94f860124dc4fee278fdcbd38c102d88")
+	recipient := core.WorkloadAddress("d551234ae421e3bcba99a0da6d736074f22192ff")
+	if sender != core.WorkloadAddress("32be343b94f860124dc4fee278fdcbd38c102d88") || len(sender) != 40 {
+		t.Fatalf("the workload address is not deterministic: %s", sender)
+	}
+
+	tx := core.NewTransaction(sender, recipient, big.NewInt(100), 1)
+	tx.SignWithWorkloadKey()
+	if err := tx.VerifySignature(); err != nil {
+		t.Fatal(err)
+	}
+	decoded := core.DecodeTx(tx.Encode())
+	decoded.Relayed = true //中继字段不参与签名
+	if err := decoded.VerifySignature(); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := core.DecodeTx(tx.Encode())
+	tampered.Value = big.NewInt(1000)
+	forged := core.NewTransaction(recipient, sender, big.NewInt(100), 1)
+	forged.Signature = tx.Signature
+	unsigned := core.NewTransaction(sender, recipient, big.NewInt(100), 2)
+
+	pool := core.NewTxPool()
+	if err := pool.AddTx2Pool(tx); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []*core.Transaction{tampered, forged, unsigned} {
+		if err := pool.AddTx2Pool(bad); err == nil {
+			t.Error("the transaction with an invalid signature is accepted")
+		}
+	}
+	another := core.NewTransaction(sender, recipient, big.NewInt(100), 3) //同一笔交易不能重复加入交易池
+	another.SignWithWorkloadKey()
+	if rejected := pool.AddTxs2Pool([]*core.Transaction{another, tampered, unsigned}); rejected != 2 {
+		t.Errorf("%d transactions are rejected, want 2", rejected)
+	}
+	if len(pool.TxQueue) != 2 {
+		t.Errorf("the pool has %d transactions, want 2", len(pool.TxQueue))
+	}
+}
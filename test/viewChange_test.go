@@ -0,0 +1,90 @@
+package test
+
+import (
+	"blockEmulator/build"
+	"blockEmulator/message"
+	"blockEmulator/params"
+	"blockEmulator/shard"
+	"encoding/json"
+	"testing"
+)
+
+// 新视图消息中的视图切换证明必须由声明的发送者签名，伪造、重复、其他视图和其他分片的证明都不被计入
+func TestViewChangeProofs(t *testing.T) {
+	keyStore := params.KeyStore_path
+	params.KeyStore_path = t.TempDir() + "/"
+	defer func() { params.KeyStore_path = keyStore }()
+
+	proofs := [][]byte{
+		signViewChange(t, 0, 0, 0, 1),
+		signViewChange(t, 0, 0, 0, 1), //重复
+		signViewChange(t, 0, 1, 1, 2), //其他视图
+		signViewChange(t, 1, 2, 1, 1), //其他分片
+		signViewChange(t, 0, 3, 0, 1), //节点 0 冒充节点 3
+	}
+	// 签名者声明为节点 3，但是使用节点 0 的私钥签名
+	node3 := &shard.Node{ShardID: 0, NodeID: 3}
+	b, _ := json.Marshal(message.ViewChange{NextView: 1, SenderNode: node3})
+	_, forged := message.SplitMessage(message.MergeSignedMessage(message.CViewChange, b, node3, shard.LoadOrGenerateNodeKey(0, 0)))
+	proofs = append(proofs, forged)
+	if vcs := message.VerifyViewChanges(proofs, 0, 1); len(vcs) != 1 {
+		t.Fatalf("only the view change of node 0 should be counted, got %d", len(vcs))
+	}
+
+	proofs = append(proofs, signViewChange(t, 0, 1, 1, 1), signViewChange(t, 0, 2, 2, 1))
+	if vcs := message.VerifyViewChanges(proofs, 0, 1); len(vcs) != 3 {
+		t.Fatalf("the view changes signed by 3 nodes should be counted, got %d", len(vcs))
+	}
+}
+
+// 节点 signer 签名的视图切换消息，消息中声明的发送者为 nid
+func signViewChange(t *testing.T, sid, nid, signer, view uint64) []byte {
+	b, err := json.Marshal(message.ViewChange{NextView: view, SenderNode: &shard.Node{ShardID: sid, NodeID: nid}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := &shard.Node{ShardID: sid, NodeID: signer}
+	_, signed := message.SplitMessage(message.MergeSignedMessage(message.CViewChange, b, node, shard.LoadOrGenerateNodeKey(sid, signer)))
+	return signed
+}
+
+// 分片主节点的记录只能被更新的视图覆盖
+func TestShardLeaderView(t *testing.T) {
+	params.ResetShardLeaders()
+	defer params.ResetShardLeaders()
+	if !params.SetShardLeader(0, 1, 1) || params.GetShardLeader(0) != 1 {
+		t.Fatalf("the leader of view 1 should be recorded")
+	}
+	if params.SetShardLeader(0, 0, 0) || params.GetShardLeader(0) != 1 {
+		t.Fatalf("a stale view should not overwrite the leader")
+	}
+	if !params.SetShardLeader(0, 2, 2) || params.GetShardLeader(0) != 2 {
+		t.Fatalf("the leader of view 2 should be recorded")
+	}
+}
+
+// 每个分片的主节点不发送任何消息，从节点超时后切换视图，新主节点继续出块
+func TestViewChange(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the local cluster takes several seconds")
+	}
+	txNum := 200
+	setupLocalCluster(t, txNum)
+	timeOut := params.ViewChangeTimeOut
+	t.Cleanup(func() { params.ViewChangeTimeOut = timeOut })
+	params.ViewChangeTimeOut = 2000
+	params.BatchSize = 20
+	params.InjectSpeed = 20 //交易分批注入，视图切换之后的交易发送给新主节点
+
+	build.RunLocalCluster(4, 2, 3, 1, "Silent")
+
+	if n := measureTotal(t, "View_Change_Count"); n < 2 {
+		t.Fatalf("both shards should change the view, got %v view changes", n)
+	}
+	if n := measureTotal(t, "Tx_number"); n == 0 {
+		t.Fatalf("the new leaders should commit txs")
+	}
+	if n := measureTotal(t, "Safety_Violation"); n != 0 {
+		t.Fatalf("the view change should not violate safety, got %v", n)
+	}
+}